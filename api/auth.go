@@ -0,0 +1,80 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/webutil"
+)
+
+const bearerPrefix = "Bearer "
+
+// RequireAPIToken is a middleware that authenticates requests with a per-user
+// API token sent as "Authorization: Bearer <token>". The token is hashed and
+// looked up in the api_tokens table; on success the owning user's ID is put
+// into the request context for handlers to authorize against.
+func RequireAPIToken(tokenRepo *datastore.APITokenRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get(webutil.HeaderAuthorization)
+			if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+				webutil.RespondWithError(w, http.StatusUnauthorized, "Missing or malformed API token")
+				return
+			}
+
+			tokenHash, err := webutil.GenerateHash(strings.TrimSpace(header[len(bearerPrefix):]))
+			if err != nil {
+				log.Printf("ERROR (Auth): Failed to hash API token: %v", err)
+				webutil.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+
+			token, err := tokenRepo.GetAPITokenByHash(r.Context(), tokenHash)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					webutil.RespondWithError(w, http.StatusUnauthorized, "Invalid API token")
+					return
+				}
+				log.Printf("ERROR (Auth): Failed to look up API token: %v", err)
+				webutil.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+
+			// Recording last use is best effort and must not fail the request.
+			if err := tokenRepo.TouchAPIToken(r.Context(), token.ID, time.Now().UTC()); err != nil {
+				log.Printf("WARN (Auth): %v", err)
+			}
+
+			ctx := webutil.WithAuthenticatedUserID(r.Context(), token.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireAdminSecret is a middleware that admits requests whose bearer token
+// equals the operator's admin secret, for maintenance endpoints that act
+// across users. With no secret configured, every request is rejected.
+func RequireAdminSecret(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get(webutil.HeaderAuthorization)
+			if secret == "" || len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+				webutil.RespondWithError(w, http.StatusUnauthorized, "Missing or malformed admin token")
+				return
+			}
+			token := strings.TrimSpace(header[len(bearerPrefix):])
+			if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+				log.Printf("WARN (Auth): Rejected admin request from %s: invalid token", r.RemoteAddr)
+				webutil.RespondWithError(w, http.StatusUnauthorized, "Invalid admin token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
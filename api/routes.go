@@ -7,6 +7,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/coreybb/logos/datastore"
 	rh "github.com/coreybb/logos/route-handlers"
 	"github.com/coreybb/logos/webutil"
 )
@@ -26,8 +27,23 @@ const (
 	readingsSubPath       = "/readings"
 	sourcesSubPath        = "/sources"
 	statusSubPath         = "/status"
+	retrySubPath          = "/retry"
 	subscriptionsSubPath  = "/subscriptions"   // For user subscriptions to sources
 	allowedSendersSubPath = "/allowed-senders" // For user's allowed sender whitelist
+	policySubPath         = "/policy"          // For user's ingestion policy
+	heldSubPath           = "/held"            // For emails held back by the ingestion policy
+	tokensSubPath         = "/tokens"          // For user's API tokens
+	urlSubPath            = "/url"             // For saving a web page as a reading
+	splitSubPath          = "/split"           // For a source's digest splitting settings
+	originalSubPath       = "/original"        // For the file a reading was converted from
+	fileSubPath           = "/file"            // For an edition's latest generated file
+	filesSubPath          = "/files"           // For the history of an edition's generated files
+	linkSubPath           = "/link"            // For an expiring download link
+	feedTokenSubPath      = "/feed-token"      // For the token behind a user's OPDS catalog
+	opdsV2SubPath         = "/v2"              // For the OPDS 2.0 variant of a catalog
+	coverSubPath          = "/cover"           // For an edition's cover image
+	thumbnailSubPath      = "/thumbnail"       // For an edition's cover thumbnail
+	destinationsSubPath   = "/destinations"    // For where a template's editions are delivered
 )
 
 const (
@@ -44,7 +60,10 @@ func SetupRoutes(
 	editionTemplateHandler *rh.EditionTemplateHandler,
 	userReadingSourceHandler *rh.UserReadingSourceHandler,
 	editionTemplateSourceHandler *rh.EditionTemplateSourceHandler,
+	editionTemplateDestinationHandler *rh.EditionTemplateDestinationHandler,
 	allowedSenderHandler *rh.AllowedSenderHandler,
+	apiTokenHandler *rh.APITokenHandler,
+	apiTokenRepo *datastore.APITokenRepository,
 ) http.Handler {
 	r := chi.NewRouter()
 
@@ -56,19 +75,29 @@ func SetupRoutes(
 	r.Use(middleware.Timeout(60 * time.Second))                              // Set a timeout context for requests
 	r.Use(SetHeader(webutil.HeaderContentType, webutil.ContentTypeJSONUTF8)) // Default Content-Type
 
+	// Every API route except sign-up requires a per-user API token
+	authenticate := RequireAPIToken(apiTokenRepo)
+
 	// API versioning or grouping
 	r.Route(apiBasePath, func(r chi.Router) {
-		configureUserRoutes(r, userHandler, readingHandler)
-		configureEditionRoutes(r, editionHandler)
-		configureReadingRoutes(r, readingHandler)
-		configureDeliveryRoutes(r, deliveryHandler)
-		configureSourceRoutes(r, sourceHandler)
-		configureDestinationRoutes(r, destinationHandler)
-		configureEditionTemplateRoutes(r, editionTemplateHandler)
-		configureEditionTemplateSourceRoutes(r, editionTemplateSourceHandler)
-		configureUserSubscriptionRoutes(r, userReadingSourceHandler)
-		configureUserSourceRoutes(r, sourceHandler)
-		configureAllowedSenderRoutes(r, allowedSenderHandler)
+		configureUserRoutes(r, userHandler, readingHandler, authenticate)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authenticate)
+			configureEditionRoutes(r, editionHandler)
+			configureReadingRoutes(r, readingHandler)
+			configureDeliveryRoutes(r, deliveryHandler)
+			configureSourceRoutes(r, sourceHandler)
+			configureDestinationRoutes(r, destinationHandler)
+			configureEditionTemplateRoutes(r, editionTemplateHandler)
+			configureEditionTemplateSourceRoutes(r, editionTemplateSourceHandler)
+			configureEditionTemplateDestinationRoutes(r, editionTemplateDestinationHandler)
+			configureUserSubscriptionRoutes(r, userReadingSourceHandler)
+			configureUserSourceRoutes(r, sourceHandler)
+			configureAllowedSenderRoutes(r, allowedSenderHandler)
+			configureAPITokenRoutes(r, apiTokenHandler)
+			configureSavedArticleRoutes(r, readingHandler)
+		})
 	})
 
 	// Health check endpoint
@@ -77,6 +106,33 @@ func SetupRoutes(
 	return r
 }
 
+// SetupOPDSRoutes serves each user's OPDS catalogs under /{token}, to be
+// mounted at rh.OPDSPath. The feed token in the path authenticates requests.
+func SetupOPDSRoutes(handler *rh.OPDSHandler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+
+	r.Route(pathWithParam("", "token"), func(r chi.Router) {
+		// OPDS 1.2 (Atom)
+		r.Get("/", webutil.MakeHandler(handler.HandleGetNavigationFeed))               // GET /opds/{token}/
+		r.Get(editionsBasePath, webutil.MakeHandler(handler.HandleGetAcquisitionFeed)) // GET /opds/{token}/editions?template=...&page=...
+		// OPDS 2.0 (JSON)
+		r.Get(opdsV2SubPath, webutil.MakeHandler(handler.HandleGetNavigationFeedJSON))                   // GET /opds/{token}/v2
+		r.Get(opdsV2SubPath+editionsBasePath, webutil.MakeHandler(handler.HandleGetAcquisitionFeedJSON)) // GET /opds/{token}/v2/editions
+		// Files and covers linked from both
+		r.Get(filesSubPath+pathWithParam("", paramID), webutil.MakeHandler(handler.HandleDownloadFile))                   // GET /opds/{token}/files/{id}
+		r.Get(pathWithParam(editionsBasePath, paramID)+coverSubPath, webutil.MakeHandler(handler.HandleGetCover))         // GET /opds/{token}/editions/{id}/cover
+		r.Get(pathWithParam(editionsBasePath, paramID)+thumbnailSubPath, webutil.MakeHandler(handler.HandleGetThumbnail)) // GET /opds/{token}/editions/{id}/thumbnail
+	})
+
+	return r
+}
+
 // Helper for constructing paths with a parameter
 func pathWithParam(basePath string, paramName string) string {
 	if basePath == "" {
@@ -86,16 +142,24 @@ func pathWithParam(basePath string, paramName string) string {
 }
 
 // --- User Routes ---
-func configureUserRoutes(r chi.Router, userHandler *rh.UserHandler, readingHandler *rh.ReadingHandler) {
+func configureUserRoutes(r chi.Router, userHandler *rh.UserHandler, readingHandler *rh.ReadingHandler, authenticate func(http.Handler) http.Handler) {
 	userSpecificPath := pathWithParam("", paramID) // e.g., "/{id}"
 
 	r.Route(usersBasePath, func(r chi.Router) {
-		r.Get("/", webutil.MakeHandler(userHandler.HandleGetUsers))
+		// Sign-up is open and returns the new user's first API token
 		r.Post("/", webutil.MakeHandler(userHandler.HandleCreateUser))
-		r.Route(userSpecificPath, func(r chi.Router) {
-			r.Get("/", webutil.MakeHandler(userHandler.HandleGetUser))
-			// Nested: Get readings for a specific user
-			r.Get(readingsSubPath, webutil.MakeHandler(readingHandler.HandleGetUserReadings)) // GET /users/{id}/readings
+
+		r.Group(func(r chi.Router) {
+			r.Use(authenticate)
+			r.Get("/", webutil.MakeHandler(userHandler.HandleGetUsers))
+			r.Route(userSpecificPath, func(r chi.Router) {
+				r.Get("/", webutil.MakeHandler(userHandler.HandleGetUser))
+				// Nested: Get readings for a specific user
+				r.Get(readingsSubPath, webutil.MakeHandler(readingHandler.HandleGetUserReadings)) // GET /users/{id}/readings
+				// Nested: Issue or revoke the token for the user's OPDS catalog
+				r.Post(feedTokenSubPath, webutil.MakeHandler(userHandler.HandleCreateFeedToken))   // POST /users/{id}/feed-token
+				r.Delete(feedTokenSubPath, webutil.MakeHandler(userHandler.HandleDeleteFeedToken)) // DELETE /users/{id}/feed-token
+			})
 		})
 	})
 }
@@ -107,7 +171,10 @@ func configureReadingRoutes(r chi.Router, handler *rh.ReadingHandler) {
 	r.Route(readingsBasePath, func(r chi.Router) {
 		r.Get("/", webutil.MakeHandler(handler.HandleGetReadings))
 		r.Post("/", webutil.MakeHandler(handler.HandleCreateReading))
-		r.Get(specificReadingPath, webutil.MakeHandler(handler.HandleGetReading))
+		r.Route(specificReadingPath, func(r chi.Router) {
+			r.Get("/", webutil.MakeHandler(handler.HandleGetReading))
+			r.Get(originalSubPath, webutil.MakeHandler(handler.HandleGetReadingOriginal)) // GET /readings/{id}/original
+		})
 	})
 }
 
@@ -125,6 +192,10 @@ func configureEditionRoutes(r chi.Router, handler *rh.EditionHandler) {
 			r.Post(readingsSubPath, webutil.MakeHandler(handler.HandleAddReadingToEdition)) // POST /editions/{id}/readings
 			// Generate document for an edition
 			r.Post("/generate", webutil.MakeHandler(handler.HandleGenerateEditionDocument)) // POST /editions/{id}/generate
+			// Generated files
+			r.Get(fileSubPath, webutil.MakeHandler(handler.HandleGetEditionFile))                         // GET /editions/{id}/file?format=epub
+			r.Post(fileSubPath+linkSubPath, webutil.MakeHandler(handler.HandleCreateEditionDownloadLink)) // POST /editions/{id}/file/link
+			r.Get(filesSubPath, webutil.MakeHandler(handler.HandleGetEditionFiles))                       // GET /editions/{id}/files
 		})
 	})
 }
@@ -139,6 +210,8 @@ func configureDeliveryRoutes(r chi.Router, handler *rh.DeliveryHandler) {
 			r.Get("/", webutil.MakeHandler(handler.HandleGetDelivery))
 			// Nested: Update status for a specific delivery
 			r.Patch(statusSubPath, webutil.MakeHandler(handler.HandleUpdateDeliveryStatus)) // PATCH /deliveries/{id}/status
+			// Nested: Manually retry a failed or pending delivery
+			r.Post(retrySubPath, webutil.MakeHandler(handler.HandleRetryDelivery)) // POST /deliveries/{id}/retry
 		})
 	})
 }
@@ -150,19 +223,22 @@ func configureSourceRoutes(r chi.Router, handler *rh.SourceHandler) {
 	r.Route(sourcesBasePath, func(r chi.Router) {
 		r.Get("/", webutil.MakeHandler(handler.HandleGetSources))
 		r.Post("/", webutil.MakeHandler(handler.HandleCreateSource))
-		r.Get(specificSourcePath, webutil.MakeHandler(handler.HandleGetSourceByID))
+		r.Route(specificSourcePath, func(r chi.Router) {
+			r.Get("/", webutil.MakeHandler(handler.HandleGetSourceByID))
+			// Nested: Configure how digest emails from this source are split into readings
+			r.Put(splitSubPath, webutil.MakeHandler(handler.HandleUpdateSourceSplit)) // PUT /sources/{id}/split
+		})
 	})
 }
 
 // --- Delivery Destination Routes ---
 func configureDestinationRoutes(r chi.Router, handler *rh.DestinationHandler) {
-	// specificDestinationPath := pathWithParam("", paramID) // e.g., "{id}"
+	specificDestinationPath := pathWithParam("", paramID) // e.g., "{id}"
 
 	r.Route(destinationsBasePath, func(r chi.Router) {
 		r.Get("/", webutil.MakeHandler(handler.HandleGetDestinations))    // Query param for user_id
 		r.Post("/", webutil.MakeHandler(handler.HandleCreateDestination)) // UserID in body
-		// Example for getting specific destination if needed later:
-		// r.Get(specificDestinationPath, webutil.MakeHandler(handler.HandleGetDestinationByID))
+		r.Get(specificDestinationPath, webutil.MakeHandler(handler.HandleGetDestinationByID))
 	})
 }
 
@@ -236,6 +312,21 @@ func configureEditionTemplateSourceRoutes(r chi.Router, handler *rh.EditionTempl
 	})
 }
 
+// --- Edition Template Destination Routes (template-to-destination assignment) ---
+func configureEditionTemplateDestinationRoutes(r chi.Router, handler *rh.EditionTemplateDestinationHandler) {
+	// Path: /edition-templates/{templateID}/destinations
+	templateDestinationsPath := editionTemplatesBasePath + pathWithParam("", "templateID") + destinationsSubPath
+
+	r.Route(templateDestinationsPath, func(r chi.Router) {
+		r.Get("/", webutil.MakeHandler(handler.HandleGetTemplateDestinations))
+
+		r.Route(pathWithParam("", "destinationID"), func(r chi.Router) {
+			r.Post("/", webutil.MakeHandler(handler.HandleAddDestinationToTemplate))
+			r.Delete("/", webutil.MakeHandler(handler.HandleRemoveDestinationFromTemplate))
+		})
+	})
+}
+
 // --- Allowed Sender Routes ---
 func configureAllowedSenderRoutes(r chi.Router, handler *rh.AllowedSenderHandler) {
 	// Path: /users/{userID}/allowed-senders
@@ -248,9 +339,39 @@ func configureAllowedSenderRoutes(r chi.Router, handler *rh.AllowedSenderHandler
 		r.Route(pathWithParam("", paramID), func(r chi.Router) {
 			r.Delete("/", webutil.MakeHandler(handler.HandleDeleteAllowedSender))
 		})
+
+		// Ingestion policy applied to senders not on the list
+		r.Get(policySubPath, webutil.MakeHandler(handler.HandleGetIngestionPolicy))
+		r.Put(policySubPath, webutil.MakeHandler(handler.HandleUpdateIngestionPolicy))
+
+		// Emails rejected or quarantined by the policy
+		r.Route(heldSubPath, func(r chi.Router) {
+			r.Get("/", webutil.MakeHandler(handler.HandleGetHeldEmails))
+			r.Post(pathWithParam("", "heldEmailID")+"/release", webutil.MakeHandler(handler.HandleReleaseHeldEmail))
+		})
 	})
 }
 
+// --- API Token Routes ---
+func configureAPITokenRoutes(r chi.Router, handler *rh.APITokenHandler) {
+	// Path: /users/{userID}/tokens
+	tokensPath := usersBasePath + pathWithParam("", "userID") + tokensSubPath
+
+	r.Route(tokensPath, func(r chi.Router) {
+		r.Get("/", webutil.MakeHandler(handler.HandleGetAPITokens))
+		r.Post("/", webutil.MakeHandler(handler.HandleCreateAPIToken))
+		r.Delete(pathWithParam("", paramID), webutil.MakeHandler(handler.HandleDeleteAPIToken))
+	})
+}
+
+// --- Saved Article Routes ---
+func configureSavedArticleRoutes(r chi.Router, handler *rh.ReadingHandler) {
+	// Path: /users/{userID}/readings/url
+	savedArticlesPath := usersBasePath + pathWithParam("", "userID") + readingsSubPath + urlSubPath
+
+	r.Post(savedArticlesPath, webutil.MakeHandler(handler.HandleSaveURL))
+}
+
 // --- Utility Functions ---
 
 // handleHealthCheck responds to a health check request.
@@ -0,0 +1,85 @@
+package articles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultFetchTimeout = 30 * time.Second
+	maxPageBytes        = 10 << 20 // 10 MiB
+	userAgent           = "Logos Article Saver (+https://lakonic.dev)"
+)
+
+var (
+	// ErrInvalidURL is returned for URLs that are not absolute http(s) URLs.
+	ErrInvalidURL = errors.New("invalid article URL")
+	// ErrUnfetchable is returned when the page cannot be fetched or is not HTML.
+	ErrUnfetchable = errors.New("article could not be fetched")
+)
+
+// Page is a fetched HTML document and the URL it was finally served from,
+// after redirects.
+type Page struct {
+	HTML []byte // UTF-8
+	URL  string
+}
+
+// fetchPage downloads an HTML page and decodes it to UTF-8 using the charset
+// from the Content-Type header or the document's meta tags.
+func fetchPage(ctx context.Context, client *http.Client, rawURL string) (*Page, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q must be an absolute http or https URL", ErrInvalidURL, rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, application/xhtml+xml;q=0.9, */*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: request failed: %v", ErrUnfetchable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned status %d", ErrUnfetchable, parsed, resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s is %s, not an HTML page", ErrUnfetchable, parsed, mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read body: %v", ErrUnfetchable, err)
+	}
+	if len(body) > maxPageBytes {
+		return nil, fmt.Errorf("%w: page exceeds %d bytes", ErrUnfetchable, maxPageBytes)
+	}
+
+	decoded, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unsupported charset: %v", ErrUnfetchable, err)
+	}
+	html, err := io.ReadAll(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode body: %v", ErrUnfetchable, err)
+	}
+
+	return &Page{HTML: html, URL: resp.Request.URL.String()}, nil
+}
@@ -0,0 +1,108 @@
+// Package articles saves web pages as readings for read-later use.
+package articles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ingestion"
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+	"github.com/google/uuid"
+)
+
+const (
+	savedArticlesSourceName = "Saved articles"
+	savedArticlesSourceType = "api"
+	// Each user gets their own source, identified by this prefix and their ID.
+	savedArticlesIdentifierPrefix = "saved-articles:"
+)
+
+// Saver fetches web pages and ingests them through the same pipeline and
+// content-hash deduplication as email and feeds. Saved pages are attributed
+// to a per-user "Saved articles" source that can be assigned to a magazine.
+type Saver struct {
+	sourceRepo            *datastore.SourceRepository
+	userReadingSourceRepo *datastore.UserReadingSourceRepository
+	orchestrator          *ingestion.IngestionOrchestrator
+	client                *http.Client
+}
+
+// NewSaver creates a new Saver. A nil client uses a default client that
+// refuses to connect to private network addresses.
+func NewSaver(
+	sourceRepo *datastore.SourceRepository,
+	userReadingSourceRepo *datastore.UserReadingSourceRepository,
+	orchestrator *ingestion.IngestionOrchestrator,
+	client *http.Client,
+) *Saver {
+	if client == nil {
+		client = webutil.NewPublicClient(defaultFetchTimeout)
+	}
+	return &Saver{
+		sourceRepo:            sourceRepo,
+		userReadingSourceRepo: userReadingSourceRepo,
+		orchestrator:          orchestrator,
+		client:                client,
+	}
+}
+
+// SaveURL fetches rawURL and stores it as a reading for the user. Errors
+// wrap ErrInvalidURL or ErrUnfetchable when the URL itself is the problem.
+func (s *Saver) SaveURL(ctx context.Context, userID, rawURL string) (*models.Reading, error) {
+	page, err := fetchPage(ctx, s.client, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := s.savedArticlesSource(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	reading, err := s.orchestrator.ProcessWebContent(ctx, userID, source.ID, ingestion.WebContent{
+		HTML: page.HTML,
+		URL:  page.URL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to ingest %s: %w", page.URL, err)
+	}
+	log.Printf("INFO (ArticleSaver): Saved %s as reading %s for user %s", page.URL, reading.ID, userID)
+	return reading, nil
+}
+
+// savedArticlesSource returns the user's "Saved articles" source, creating it
+// on first use. The user is (re)subscribed every time, which is a no-op when
+// the subscription already exists.
+func (s *Saver) savedArticlesSource(ctx context.Context, userID string) (*models.ReadingSource, error) {
+	identifier := savedArticlesIdentifierPrefix + userID
+	source, err := s.sourceRepo.GetSourceByIdentifierAndType(ctx, userID, identifier, savedArticlesSourceType)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to look up saved articles source for user %s: %w", userID, err)
+		}
+		source = &models.ReadingSource{
+			ID:         uuid.NewString(),
+			UserID:     userID,
+			CreatedAt:  time.Now().UTC(),
+			Name:       savedArticlesSourceName,
+			Type:       savedArticlesSourceType,
+			Identifier: identifier,
+		}
+		if err := s.sourceRepo.CreateReadingSource(ctx, source); err != nil {
+			return nil, fmt.Errorf("failed to create saved articles source for user %s: %w", userID, err)
+		}
+		log.Printf("INFO (ArticleSaver): Created saved articles source %s for user %s", source.ID, userID)
+	}
+
+	if err := s.userReadingSourceRepo.SubscribeUserToSource(ctx, userID, source.ID, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("failed to subscribe user %s to saved articles source: %w", userID, err)
+	}
+	return source, nil
+}
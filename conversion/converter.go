@@ -3,38 +3,64 @@ package conversion
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"log"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/coreybb/logos/models" // Import models package
 )
 
+// Backend selects how Markdown, DOCX and RTF are converted to HTML.
+type Backend string
+
+const (
+	// BackendBuiltin converts in process, with no external dependencies.
+	BackendBuiltin Backend = "builtin"
+	// BackendPandoc converts with pandoc, for higher fidelity, falling back
+	// to the built-in converters if pandoc fails.
+	BackendPandoc Backend = "pandoc"
+)
+
+// ParseBackend returns the backend with the given name, ignoring case.
+func ParseBackend(name string) (Backend, bool) {
+	for _, backend := range []Backend{BackendBuiltin, BackendPandoc} {
+		if strings.EqualFold(name, string(backend)) {
+			return backend, true
+		}
+	}
+	return "", false
+}
+
 // Converter provides methods to convert various document formats to HTML.
 type Converter struct {
-	pandocPath string // Path to pandoc executable, detected on creation
+	pandocPath string // Path to pandoc executable; empty unless the pandoc backend is in use
 	timeout    time.Duration
 }
 
-// NewConverter creates a new Converter, attempting to find pandoc.
-func NewConverter() (*Converter, error) {
-	path, err := exec.LookPath("pandoc")
-	if err != nil {
-		log.Printf("WARN (Converter): pandoc executable not found in PATH. Conversions requiring pandoc will fail.")
-		// Return a converter that can still handle non-pandoc formats
-		// but log the issue. Erroring out might be too harsh if only
-		// HTML/TXT/PDF etc are expected initially.
-		// Alternatively, return error:
-		// return nil, fmt.Errorf("pandoc executable not found in PATH: %w", err)
-	} else {
-		log.Printf("INFO (Converter): Found pandoc executable at: %s", path)
+// NewConverter creates a new Converter using the given backend. If the pandoc
+// backend is selected but pandoc isn't installed, the built-in converters
+// are used instead.
+func NewConverter(backend Backend) (*Converter, error) {
+	converter := &Converter{timeout: 30 * time.Second} // Default timeout for pandoc execution
+	switch backend {
+	case BackendBuiltin, "":
+		log.Printf("INFO (Converter): Using built-in document converters.")
+	case BackendPandoc:
+		path, err := exec.LookPath("pandoc")
+		if err != nil {
+			log.Printf("WARN (Converter): pandoc executable not found in PATH. Using built-in document converters.")
+		} else {
+			log.Printf("INFO (Converter): Found pandoc executable at: %s", path)
+			converter.pandocPath = path
+		}
+	default:
+		return nil, fmt.Errorf("unknown conversion backend: %q", backend)
 	}
-	return &Converter{
-		pandocPath: path,             // Store the path, even if empty
-		timeout:    30 * time.Second, // Default timeout for pandoc execution
-	}, nil
+	return converter, nil
 }
 
 // runPandoc executes the pandoc command to convert from one format to another.
@@ -95,63 +121,103 @@ func (c *Converter) runPandoc(ctx context.Context, fromFormat string, inputBytes
 // ToHTML attempts to convert the given content bytes in the specified originalFormat to HTML.
 // For formats that are already HTML or directly usable as HTML, it might pass them through.
 // It returns the (potentially converted) content bytes, the new format (usually models.ReadingFormatHTML if converted), and an error.
-func (c *Converter) ToHTML(contentBytes []byte, originalFormat models.ReadingFormat) ([]byte, models.ReadingFormat, error) {
-	ctx := context.Background() // Base context for pandoc execution
-
-	var pandocInputFormat string
-	performPandocConversion := false
+// Extracted images are embedded in the HTML as data: URIs.
+func (c *Converter) ToHTML(ctx context.Context, contentBytes []byte, originalFormat models.ReadingFormat) ([]byte, models.ReadingFormat, error) {
+	htmlBytes, images, format, err := c.ToHTMLWithImages(ctx, contentBytes, originalFormat)
+	if err != nil {
+		return htmlBytes, format, err
+	}
+	return embedImages(htmlBytes, images), format, nil
+}
 
+// ToHTMLWithImages is like ToHTML, but returns extracted images separately,
+// referenced from the HTML by ReadingImage.Ref().
+func (c *Converter) ToHTMLWithImages(ctx context.Context, contentBytes []byte, originalFormat models.ReadingFormat) ([]byte, []models.ReadingImage, models.ReadingFormat, error) {
 	switch originalFormat {
-	case models.ReadingFormatMD:
-		log.Printf("INFO (Converter): Attempting to convert Markdown to HTML.")
-		pandocInputFormat = "markdown"
-		performPandocConversion = true
-	case models.ReadingFormatDOCX:
-		log.Printf("INFO (Converter): Attempting to convert DOCX to HTML.")
-		pandocInputFormat = "docx"
-		performPandocConversion = true
-	case models.ReadingFormatRTF:
-		log.Printf("INFO (Converter): Attempting to convert RTF to HTML.")
-		pandocInputFormat = "rtf"
-		performPandocConversion = true
+	case models.ReadingFormatMD, models.ReadingFormatDOCX, models.ReadingFormatRTF:
+		if c.pandocPath != "" {
+			htmlBytes, err := c.convertWithPandoc(ctx, contentBytes, originalFormat)
+			if err == nil {
+				return htmlBytes, nil, models.ReadingFormatHTML, nil
+			}
+			log.Printf("WARN (Converter): pandoc failed to convert %s, using built-in converter: %v", originalFormat, err)
+		}
+		return c.convertBuiltin(contentBytes, originalFormat)
 
 	case models.ReadingFormatHTML: // If it's already identified as HTML
 		log.Printf("INFO (Converter): Content is already HTML, passing through.")
-		return contentBytes, models.ReadingFormatHTML, nil
+		return contentBytes, nil, models.ReadingFormatHTML, nil
 	case models.ReadingFormatTXT:
-		log.Printf("INFO (Converter): Converting TXT to basic HTML (wrapping in <pre>).")
-		// Basic conversion for TXT - wrap in <pre> tags.
-		// Alternatively, could use pandoc: pandocInputFormat = "plain", performPandocConversion = true
-		htmlBytes := []byte("<pre>" + string(contentBytes) + "</pre>")
-		return htmlBytes, models.ReadingFormatHTML, nil
-	case models.ReadingFormatPDF, models.ReadingFormatEPUB, models.ReadingFormatMOBI:
-		// These formats are not converted to HTML during this ingestion step.
+		log.Printf("INFO (Converter): Converting TXT to HTML.")
+		return textToHTML(contentBytes), nil, models.ReadingFormatHTML, nil
+	case models.ReadingFormatPDF:
+		log.Printf("INFO (Converter): Extracting text and images from PDF.")
+		content, err := ExtractPDF(contentBytes)
+		if err != nil {
+			return contentBytes, nil, originalFormat, fmt.Errorf("PDF extraction failed: %w", err)
+		}
+		return content.HTML, content.Images, models.ReadingFormatHTML, nil
+	case models.ReadingFormatEPUB:
+		log.Printf("INFO (Converter): Extracting chapters and images from EPUB.")
+		content, err := ExtractEPUB(contentBytes)
+		if err != nil {
+			return contentBytes, nil, originalFormat, fmt.Errorf("EPUB extraction failed: %w", err)
+		}
+		return content.HTML, content.Images, models.ReadingFormatHTML, nil
+	case models.ReadingFormatMOBI:
+		// MOBI is not converted to HTML during this ingestion step.
 		log.Printf("INFO (Converter): Format '%s' is a direct reading format, no HTML conversion performed by ToHTML.", originalFormat)
-		return contentBytes, originalFormat, nil // Return original bytes and format
+		return contentBytes, nil, originalFormat, nil // Return original bytes and format
 	default:
 		log.Printf("WARN (Converter): Unknown or unsupported format for ToHTML conversion: %s", originalFormat)
 		// Return original bytes and format, with an error indicating no conversion happened.
-		return contentBytes, originalFormat, fmt.Errorf("unsupported format for ToHTML conversion: %s", originalFormat)
+		return contentBytes, nil, originalFormat, fmt.Errorf("unsupported format for ToHTML conversion: %s", originalFormat)
 	}
+}
 
-	if performPandocConversion {
-		if c.pandocPath == "" {
-			err := fmt.Errorf("pandoc conversion required for %s, but pandoc executable was not found", originalFormat)
-			log.Printf("ERROR (Converter): %v", err)
-			// Return original content because conversion cannot proceed
-			return contentBytes, originalFormat, err
-		}
-		htmlBytes, err := c.runPandoc(ctx, pandocInputFormat, contentBytes)
-		if err != nil {
-			log.Printf("ERROR (Converter): Failed to convert %s to HTML using pandoc: %v", originalFormat, err)
-			// Return original content on conversion failure
-			return contentBytes, originalFormat, fmt.Errorf("pandoc conversion from %s failed: %w", originalFormat, err)
-		}
-		log.Printf("INFO (Converter): Successfully converted %s to HTML using pandoc.", originalFormat)
-		return htmlBytes, models.ReadingFormatHTML, nil
+// Converts Markdown, DOCX or RTF in process.
+func (c *Converter) convertBuiltin(contentBytes []byte, originalFormat models.ReadingFormat) ([]byte, []models.ReadingImage, models.ReadingFormat, error) {
+	log.Printf("INFO (Converter): Converting %s to HTML.", originalFormat)
+	var htmlBytes []byte
+	var images []models.ReadingImage
+	var err error
+	switch originalFormat {
+	case models.ReadingFormatMD:
+		htmlBytes = markdownToHTML(contentBytes)
+	case models.ReadingFormatDOCX:
+		htmlBytes, images, err = docxToHTML(contentBytes)
+	case models.ReadingFormatRTF:
+		htmlBytes, err = rtfToHTML(contentBytes)
+	}
+	if err != nil {
+		return contentBytes, nil, originalFormat, fmt.Errorf("%s conversion failed: %w", originalFormat, err)
 	}
+	return htmlBytes, images, models.ReadingFormatHTML, nil
+}
 
-	// Should not be reached due to the switch statement logic, but acts as a fallback.
-	log.Printf("WARN (Converter): Reached unexpected end of ToHTML function for format: %s", originalFormat)
-	return contentBytes, originalFormat, fmt.Errorf("unexpected state in ToHTML for format %s", originalFormat)
+// Converts Markdown, DOCX or RTF with pandoc.
+func (c *Converter) convertWithPandoc(ctx context.Context, contentBytes []byte, originalFormat models.ReadingFormat) ([]byte, error) {
+	pandocInputFormat := map[models.ReadingFormat]string{
+		models.ReadingFormatMD:   "markdown",
+		models.ReadingFormatDOCX: "docx",
+		models.ReadingFormatRTF:  "rtf",
+	}[originalFormat]
+	log.Printf("INFO (Converter): Attempting to convert %s to HTML using pandoc.", originalFormat)
+	htmlBytes, err := c.runPandoc(ctx, pandocInputFormat, contentBytes)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("INFO (Converter): Successfully converted %s to HTML using pandoc.", originalFormat)
+	return htmlBytes, nil
+}
+
+// Replaces references to extracted images with data: URIs. Callers of ToHTML
+// get self-contained HTML; the extractors return images separately.
+func embedImages(htmlBytes []byte, images []models.ReadingImage) []byte {
+	htmlString := string(htmlBytes)
+	for _, image := range images {
+		dataURI := "data:" + image.ContentType + ";base64," + base64.StdEncoding.EncodeToString(image.Data)
+		htmlString = strings.ReplaceAll(htmlString, image.Ref(), dataURI)
+	}
+	return []byte(htmlString)
 }
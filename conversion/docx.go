@@ -0,0 +1,586 @@
+package conversion
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+)
+
+// Matches the target of a HYPERLINK field instruction.
+var docxHyperlinkFieldRegex = regexp.MustCompile(`HYPERLINK\s+(?:\\[a-z]\s+)*"([^"]+)"`)
+
+// Matches built-in heading style names, such as "heading 2".
+var docxHeadingStyleRegex = regexp.MustCompile(`(?i)^heading\s*([1-6])$`)
+
+// A generic XML element, keyed by local names. WordprocessingML is only read,
+// never written, so namespaces can be ignored.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string
+}
+
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			parent.text += string(t)
+		}
+	}
+	return root, nil
+}
+
+func (n *xmlNode) child(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns the first descendant with the given name, depth first.
+func (n *xmlNode) find(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+		if found := c.find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+	return n.attrs[name]
+}
+
+// Reports whether a toggle property such as <w:b/> is on.
+func (n *xmlNode) toggle(name string) bool {
+	c := n.child(name)
+	if c == nil {
+		return false
+	}
+	switch c.attr("val") {
+	case "0", "false", "off", "none":
+		return false
+	}
+	return true
+}
+
+type docxRelationship struct {
+	target   string
+	external bool
+}
+
+type docxStyle struct {
+	heading int // 1 to 6; 0 if not a heading
+	quote   bool
+	code    bool
+}
+
+// A run of text with its formatting, or an image, within a paragraph.
+type textRun struct {
+	text                   string
+	bold, italic, under    bool
+	strike, sup, sub, code bool
+	href                   string
+	image                  string // Rendered <img> element
+	lineBreak              bool
+}
+
+type docxConverter struct {
+	files     map[string]*zip.File
+	rels      map[string]docxRelationship
+	styles    map[string]docxStyle
+	numFmts   map[string]map[string]string // numId, then ilvl, to the number format
+	images    []models.ReadingImage
+	imageRefs map[string]string // Image path to its reference, or "" if unusable
+	buf       bytes.Buffer
+	lists     []string // Open list tags, outermost first; each has an open <li>
+}
+
+// docxToHTML converts a Word document's body to an HTML fragment: headings
+// and quotes by paragraph style, bulleted and numbered lists with nesting,
+// bold, italic, underline, strikethrough, links, tables and images. Embedded
+// images are returned separately, referenced by ReadingImage.Ref().
+func docxToHTML(data []byte) ([]byte, []models.ReadingImage, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open DOCX archive: %w", err)
+	}
+	c := &docxConverter{
+		files:     make(map[string]*zip.File, len(archive.File)),
+		rels:      make(map[string]docxRelationship),
+		styles:    make(map[string]docxStyle),
+		numFmts:   make(map[string]map[string]string),
+		imageRefs: make(map[string]string),
+	}
+	for _, f := range archive.File {
+		c.files[f.Name] = f
+	}
+
+	document, err := c.readXML("word/document.xml")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read DOCX document: %w", err)
+	}
+	c.loadRelationships()
+	c.loadStyles()
+	c.loadNumbering()
+
+	body := document.find("body")
+	if body == nil {
+		return nil, nil, fmt.Errorf("DOCX document has no body")
+	}
+	c.blocks(body.children)
+	c.closeLists(0)
+	return c.buf.Bytes(), c.images, nil
+}
+
+func (c *docxConverter) readXML(name string) (*xmlNode, error) {
+	f, ok := c.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in archive", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxEPUBEntrySize))
+	if err != nil {
+		return nil, err
+	}
+	return parseXMLTree(data)
+}
+
+func (c *docxConverter) loadRelationships() {
+	rels, err := c.readXML("word/_rels/document.xml.rels")
+	if err != nil {
+		return
+	}
+	root := rels.find("Relationships")
+	if root == nil {
+		return
+	}
+	for _, rel := range root.children {
+		c.rels[rel.attr("Id")] = docxRelationship{
+			target:   rel.attr("Target"),
+			external: rel.attr("TargetMode") == "External",
+		}
+	}
+}
+
+func (c *docxConverter) loadStyles() {
+	styles, err := c.readXML("word/styles.xml")
+	if err != nil {
+		return
+	}
+	root := styles.find("styles")
+	if root == nil {
+		return
+	}
+	for _, s := range root.children {
+		if s.name != "style" {
+			continue
+		}
+		name := strings.ToLower(s.child("name").attr("val"))
+		var style docxStyle
+		if m := docxHeadingStyleRegex.FindStringSubmatch(name); m != nil {
+			style.heading, _ = strconv.Atoi(m[1])
+		} else if name == "title" {
+			style.heading = 1
+		} else if level := s.child("pPr").child("outlineLvl"); level != nil {
+			if n, err := strconv.Atoi(level.attr("val")); err == nil && n < 6 {
+				style.heading = n + 1
+			}
+		}
+		style.quote = strings.Contains(name, "quote")
+		style.code = strings.Contains(name, "code") || strings.Contains(name, "preformatted")
+		c.styles[s.attr("styleId")] = style
+	}
+}
+
+func (c *docxConverter) loadNumbering() {
+	numbering, err := c.readXML("word/numbering.xml")
+	if err != nil {
+		return
+	}
+	root := numbering.find("numbering")
+	if root == nil {
+		return
+	}
+	abstract := make(map[string]map[string]string)
+	for _, n := range root.children {
+		if n.name != "abstractNum" {
+			continue
+		}
+		levels := make(map[string]string)
+		for _, lvl := range n.children {
+			if lvl.name == "lvl" {
+				levels[lvl.attr("ilvl")] = lvl.child("numFmt").attr("val")
+			}
+		}
+		abstract[n.attr("abstractNumId")] = levels
+	}
+	for _, n := range root.children {
+		if n.name == "num" {
+			c.numFmts[n.attr("numId")] = abstract[n.child("abstractNumId").attr("val")]
+		}
+	}
+}
+
+func (c *docxConverter) blocks(nodes []*xmlNode) {
+	for _, n := range nodes {
+		switch n.name {
+		case "p":
+			c.paragraph(n)
+		case "tbl":
+			c.closeLists(0)
+			c.table(n)
+		case "sdt":
+			c.blocks(n.child("sdtContent").children)
+		case "customXml", "ins":
+			c.blocks(n.children)
+		}
+	}
+}
+
+func (c *docxConverter) paragraph(p *xmlNode) {
+	props := p.child("pPr")
+	style := c.styles[props.child("pStyle").attr("val")]
+	if level := props.child("outlineLvl"); level != nil && style.heading == 0 {
+		if n, err := strconv.Atoi(level.attr("val")); err == nil && n < 6 {
+			style.heading = n + 1
+		}
+	}
+
+	var runs []textRun
+	c.runs(p.children, textRun{}, &runs)
+	content := renderRuns(runs)
+	if strings.TrimSpace(htmlTagRegex.ReplaceAllString(content, "")) == "" && !strings.Contains(content, "<img") {
+		return
+	}
+
+	if numPr := props.child("numPr"); numPr != nil && style.heading == 0 {
+		numID, ilvl := numPr.child("numId").attr("val"), numPr.child("ilvl").attr("val")
+		if numID != "" && numID != "0" {
+			level, _ := strconv.Atoi(ilvl)
+			tag := "ol"
+			if fmtName := c.numFmts[numID][ilvl]; fmtName == "bullet" || fmtName == "none" || fmtName == "" {
+				tag = "ul"
+			}
+			c.listItem(min(level, 8), tag, content)
+			return
+		}
+	}
+	c.closeLists(0)
+
+	switch {
+	case style.heading > 0:
+		tag := "h" + strconv.Itoa(style.heading)
+		c.buf.WriteString("<" + tag + ">" + content + "</" + tag + ">\n")
+	case style.quote:
+		c.buf.WriteString("<blockquote><p>" + content + "</p></blockquote>\n")
+	case style.code:
+		c.buf.WriteString("<pre><code>" + content + "</code></pre>\n")
+	default:
+		c.buf.WriteString("<p>" + content + "</p>\n")
+	}
+}
+
+// Collects the runs of a paragraph, following hyperlinks, fields and
+// tracked insertions. Deleted text is left out.
+func (c *docxConverter) runs(nodes []*xmlNode, format textRun, out *[]textRun) {
+	var fieldInstr string
+	inFieldResult := false
+	for _, n := range nodes {
+		switch n.name {
+		case "r":
+			if fldChar := n.child("fldChar"); fldChar != nil {
+				switch fldChar.attr("fldCharType") {
+				case "begin":
+					fieldInstr, inFieldResult = "", false
+				case "separate":
+					inFieldResult = true
+				case "end":
+					fieldInstr, inFieldResult = "", false
+				}
+				continue
+			}
+			if instr := n.child("instrText"); instr != nil {
+				fieldInstr += instr.text
+				continue
+			}
+			runFormat := format
+			if inFieldResult {
+				if m := docxHyperlinkFieldRegex.FindStringSubmatch(fieldInstr); m != nil {
+					runFormat.href = m[1]
+				}
+			}
+			c.run(n, runFormat, out)
+		case "hyperlink":
+			linkFormat := format
+			if rel, ok := c.rels[n.attr("id")]; ok && rel.external {
+				linkFormat.href = rel.target
+			}
+			c.runs(n.children, linkFormat, out)
+		case "fldSimple":
+			fieldFormat := format
+			if m := docxHyperlinkFieldRegex.FindStringSubmatch(n.attr("instr")); m != nil {
+				fieldFormat.href = m[1]
+			}
+			c.runs(n.children, fieldFormat, out)
+		case "ins", "smartTag", "customXml", "sdt", "sdtContent":
+			c.runs(n.children, format, out)
+		}
+	}
+}
+
+func (c *docxConverter) run(r *xmlNode, format textRun, out *[]textRun) {
+	props := r.child("rPr")
+	format.bold = props.toggle("b")
+	format.italic = props.toggle("i")
+	format.under = props.child("u") != nil && props.child("u").attr("val") != "none"
+	format.strike = props.toggle("strike") || props.toggle("dstrike")
+	switch props.child("vertAlign").attr("val") {
+	case "superscript":
+		format.sup = true
+	case "subscript":
+		format.sub = true
+	}
+	if font := props.child("rFonts").attr("ascii"); strings.Contains(strings.ToLower(font), "courier") || strings.Contains(strings.ToLower(font), "mono") {
+		format.code = true
+	}
+
+	for _, n := range r.children {
+		item := format
+		switch n.name {
+		case "t":
+			item.text = n.text
+		case "tab":
+			item.text = " "
+		case "br", "cr":
+			if n.attr("type") == "page" || n.attr("type") == "column" {
+				continue
+			}
+			item = textRun{lineBreak: true}
+		case "noBreakHyphen":
+			item.text = "-"
+		case "drawing", "pict", "object":
+			img := c.image(n)
+			if img == "" {
+				continue
+			}
+			item = textRun{image: img}
+		default:
+			continue
+		}
+		*out = append(*out, item)
+	}
+}
+
+// Returns an <img> for a drawing's embedded picture, or "" if it has none
+// that can be stored.
+func (c *docxConverter) image(n *xmlNode) string {
+	relID := n.find("blip").attr("embed")
+	if relID == "" {
+		relID = n.find("imagedata").attr("id")
+	}
+	rel, ok := c.rels[relID]
+	if !ok || rel.external {
+		return ""
+	}
+	imagePath := path.Join("word", rel.target)
+	if strings.HasPrefix(rel.target, "/") {
+		imagePath = strings.TrimPrefix(rel.target, "/")
+	}
+
+	ref, loaded := c.imageRefs[imagePath]
+	if !loaded {
+		ref = c.loadImage(imagePath)
+		c.imageRefs[imagePath] = ref
+	}
+	if ref == "" {
+		return ""
+	}
+	alt := n.find("docPr").attr("descr")
+	return `<img src="` + ref + `" alt="` + html.EscapeString(alt) + `" />`
+}
+
+func (c *docxConverter) loadImage(imagePath string) string {
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(imagePath)))
+	if !readingImageTypes[contentType] {
+		return ""
+	}
+	f, ok := c.files[imagePath]
+	if !ok {
+		return ""
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxEPUBEntrySize))
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	hash, err := webutil.GenerateHash(string(data))
+	if err != nil {
+		return ""
+	}
+	image := models.ReadingImage{ContentHash: hash, ContentType: contentType, Data: data}
+	c.images = append(c.images, image)
+	return image.Ref()
+}
+
+// Starts a list item at the given nesting level, opening and closing lists
+// as needed. The item stays open so that deeper lists nest inside it.
+func (c *docxConverter) listItem(level int, tag, content string) {
+	c.closeLists(level + 1)
+	if len(c.lists) == level+1 {
+		if c.lists[level] != tag {
+			c.closeLists(level)
+		} else {
+			c.buf.WriteString("</li>\n")
+		}
+	}
+	for len(c.lists) < level+1 {
+		c.buf.WriteString("<" + tag + ">\n")
+		c.lists = append(c.lists, tag)
+		if len(c.lists) < level+1 {
+			c.buf.WriteString("<li>") // Skipped levels still need an item to nest in
+		}
+	}
+	c.buf.WriteString("<li>" + content)
+}
+
+// Closes open lists until depth remain.
+func (c *docxConverter) closeLists(depth int) {
+	for len(c.lists) > depth {
+		tag := c.lists[len(c.lists)-1]
+		c.buf.WriteString("</li>\n</" + tag + ">\n")
+		c.lists = c.lists[:len(c.lists)-1]
+	}
+}
+
+func (c *docxConverter) table(tbl *xmlNode) {
+	c.buf.WriteString("<table>\n")
+	for _, tr := range tbl.children {
+		if tr.name != "tr" {
+			continue
+		}
+		c.buf.WriteString("<tr>")
+		for _, tc := range tr.children {
+			if tc.name != "tc" {
+				continue
+			}
+			props := tc.child("tcPr")
+			if merge := props.child("vMerge"); merge != nil && merge.attr("val") != "restart" {
+				continue // Covered by the cell above
+			}
+			c.buf.WriteString("<td")
+			if span, err := strconv.Atoi(props.child("gridSpan").attr("val")); err == nil && span > 1 {
+				c.buf.WriteString(` colspan="` + strconv.Itoa(span) + `"`)
+			}
+			c.buf.WriteString(">")
+			c.blocks(tc.children)
+			c.closeLists(0)
+			c.buf.WriteString("</td>")
+		}
+		c.buf.WriteString("</tr>\n")
+	}
+	c.buf.WriteString("</table>\n")
+}
+
+// Renders runs as HTML, merging neighbours with the same formatting so
+// that a word split across runs doesn't get split across tags.
+func renderRuns(runs []textRun) string {
+	var b strings.Builder
+	for i := 0; i < len(runs); {
+		r := runs[i]
+		switch {
+		case r.lineBreak:
+			b.WriteString("<br />")
+			i++
+			continue
+		case r.image != "":
+			b.WriteString(r.image)
+			i++
+			continue
+		}
+
+		var text strings.Builder
+		j := i
+		for ; j < len(runs) && !runs[j].lineBreak && runs[j].image == "" && sameRunFormat(runs[j], r); j++ {
+			text.WriteString(runs[j].text)
+		}
+		i = j
+
+		s := html.EscapeString(text.String())
+		for _, wrap := range []struct {
+			on  bool
+			tag string
+		}{{r.code, "code"}, {r.sub, "sub"}, {r.sup, "sup"}, {r.strike, "s"}, {r.under, "u"}, {r.italic, "em"}, {r.bold, "strong"}} {
+			if wrap.on && strings.TrimSpace(s) != "" {
+				s = "<" + wrap.tag + ">" + s + "</" + wrap.tag + ">"
+			}
+		}
+		if r.href != "" {
+			s = `<a href="` + html.EscapeString(r.href) + `">` + s + `</a>`
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+func sameRunFormat(a, b textRun) bool {
+	return a.bold == b.bold && a.italic == b.italic && a.under == b.under && a.strike == b.strike &&
+		a.sup == b.sup && a.sub == b.sub && a.code == b.code && a.href == b.href
+}
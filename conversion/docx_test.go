@@ -0,0 +1,238 @@
+package conversion
+
+import (
+	"archive/zip"
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const docxNamespaces = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+	`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+	`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"`
+
+// buildZip returns a zip archive of the given files, in name order.
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range sortedKeysOf(files) {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("creating %s: %v", name, err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func sortedKeysOf(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// buildDOCX returns a Word document whose body is the given WordprocessingML,
+// with heading, quote and list definitions and one embedded PNG.
+func buildDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+	return buildZip(t, map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		"word/document.xml":   `<?xml version="1.0"?><w:document ` + docxNamespaces + `><w:body>` + body + `</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<?xml version="1.0"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId2" Type="hyperlink" Target="https://example.com/?a=1&amp;b=2" TargetMode="External"/>` +
+			`<Relationship Id="rId3" Type="image" Target="media/image1.png"/>` +
+			`<Relationship Id="rId4" Type="image" Target="media/missing.png"/>` +
+			`<Relationship Id="rId5" Type="image" Target="https://example.com/remote.png" TargetMode="External"/>` +
+			`</Relationships>`,
+		"word/styles.xml": `<?xml version="1.0"?><w:styles ` + docxNamespaces + `>` +
+			`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style>` +
+			`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/></w:style>` +
+			`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Intense Quote"/></w:style>` +
+			`<w:style w:type="paragraph" w:styleId="Custom"><w:name w:val="Custom"/><w:pPr><w:outlineLvl w:val="2"/></w:pPr></w:style>` +
+			`</w:styles>`,
+		"word/numbering.xml": `<?xml version="1.0"?><w:numbering ` + docxNamespaces + `>` +
+			`<w:abstractNum w:abstractNumId="10"><w:lvl w:ilvl="0"><w:numFmt w:val="bullet"/></w:lvl><w:lvl w:ilvl="1"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>` +
+			`<w:abstractNum w:abstractNumId="20"><w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl></w:abstractNum>` +
+			`<w:num w:numId="1"><w:abstractNumId w:val="10"/></w:num>` +
+			`<w:num w:numId="2"><w:abstractNumId w:val="20"/></w:num>` +
+			`</w:numbering>`,
+		"word/media/image1.png": "\x89PNG\r\n\x1a\nnot really a png",
+	})
+}
+
+func docxPara(props, runs string) string {
+	if props != "" {
+		props = "<w:pPr>" + props + "</w:pPr>"
+	}
+	return "<w:p>" + props + runs + "</w:p>"
+}
+
+func docxRun(props, text string) string {
+	if props != "" {
+		props = "<w:rPr>" + props + "</w:rPr>"
+	}
+	return `<w:r>` + props + `<w:t xml:space="preserve">` + text + `</w:t></w:r>`
+}
+
+func docxListItem(numID, level, text string) string {
+	return docxPara(`<w:numPr><w:ilvl w:val="`+level+`"/><w:numId w:val="`+numID+`"/></w:numPr>`, docxRun("", text))
+}
+
+func docxDrawing(relID, descr string) string {
+	return `<w:r><w:drawing><wp:inline><wp:docPr id="1" name="Picture" descr="` + descr + `"/>` +
+		`<a:graphic><a:graphicData><a:blip r:embed="` + relID + `"/></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`
+}
+
+func TestDOCXToHTML(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		wantImages int
+	}{
+		{
+			name: "paragraphs and run formatting",
+			body: docxPara("", docxRun("", "Plain ")+docxRun("<w:b/>", "bo")+docxRun("<w:b/>", "ld")+docxRun("", " and ")+
+				docxRun(`<w:i/><w:u w:val="single"/>`, "both")+docxRun(`<w:b w:val="0"/>`, " &lt;off&gt;")) +
+				docxPara("", "") +
+				docxPara("", docxRun(`<w:vertAlign w:val="superscript"/>`, "2")+docxRun(`<w:rFonts w:ascii="Courier New"/>`, "x")),
+			want: "<p>Plain <strong>bold</strong> and <em><u>both</u></em> &lt;off&gt;</p>\n<p><sup>2</sup><code>x</code></p>\n",
+		},
+		{
+			name: "headings by style and outline level",
+			body: docxPara(`<w:pStyle w:val="Heading1"/>`, docxRun("", "One")) +
+				docxPara(`<w:pStyle w:val="Title"/>`, docxRun("", "Title")) +
+				docxPara(`<w:pStyle w:val="Custom"/>`, docxRun("", "Three")) +
+				docxPara(`<w:outlineLvl w:val="1"/>`, docxRun("", "Two")) +
+				docxPara(`<w:pStyle w:val="Quote"/>`, docxRun("", "Quoted")),
+			want: "<h1>One</h1>\n<h1>Title</h1>\n<h3>Three</h3>\n<h2>Two</h2>\n<blockquote><p>Quoted</p></blockquote>\n",
+		},
+		{
+			name: "nested and mixed lists",
+			body: docxListItem("1", "0", "A") + docxListItem("1", "0", "B") + docxListItem("1", "1", "B1") +
+				docxListItem("2", "0", "C") + docxPara("", docxRun("", "after")),
+			want: "<ul>\n<li>A</li>\n<li>B<ul>\n<li>B1</li>\n</ul>\n</li>\n</ul>\n<ol>\n<li>C</li>\n</ol>\n<p>after</p>\n",
+		},
+		{
+			name: "hyperlinks",
+			body: docxPara("", `<w:hyperlink r:id="rId2">`+docxRun("", "rel link")+`</w:hyperlink>`) +
+				docxPara("", `<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText> HYPERLINK "https://example.org" </w:instrText></w:r>`+
+					`<w:r><w:fldChar w:fldCharType="separate"/></w:r>`+docxRun("", "field link")+`<w:r><w:fldChar w:fldCharType="end"/></w:r>`),
+			want: "<p><a href=\"https://example.com/?a=1&amp;b=2\">rel link</a></p>\n<p><a href=\"https://example.org\">field link</a></p>\n",
+		},
+		{
+			name: "embedded image, repeated",
+			body: docxPara("", docxDrawing("rId3", "A &quot;chart&quot;")) +
+				docxPara("", docxRun("", "again ")+docxDrawing("rId3", "")),
+			want:       "<p><img src=\"{ref}\" alt=\"A &#34;chart&#34;\" /></p>\n<p>again <img src=\"{ref}\" alt=\"\" /></p>\n",
+			wantImages: 1,
+		},
+		{
+			name: "missing and external images are dropped",
+			body: docxPara("", docxRun("", "text")+docxDrawing("rId4", "")+docxDrawing("rId5", "")+docxDrawing("rId9", "")),
+			want: "<p>text</p>\n",
+		},
+		{
+			name: "table",
+			body: `<w:tbl><w:tr><w:tc><w:tcPr><w:gridSpan w:val="2"/></w:tcPr>` + docxPara("", docxRun("", "wide")) + `</w:tc></w:tr>` +
+				`<w:tr><w:tc>` + docxPara("", docxRun("", "a")) + `</w:tc><w:tc>` + docxPara("", docxRun("", "b")) + `</w:tc></w:tr></w:tbl>`,
+			want: "<table>\n<tr><td colspan=\"2\"><p>wide</p>\n</td></tr>\n<tr><td><p>a</p>\n</td><td><p>b</p>\n</td></tr>\n</table>\n",
+		},
+		{
+			name: "deleted text is left out",
+			body: docxPara("", docxRun("", "kept")+`<w:del>`+docxRun("", " gone")+`</w:del>`+`<w:ins>`+docxRun("", " added")+`</w:ins>`),
+			want: "<p>kept added</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, images, err := docxToHTML(buildDOCX(t, tt.body))
+			if err != nil {
+				t.Fatalf("docxToHTML: %v", err)
+			}
+			if len(images) != tt.wantImages {
+				t.Fatalf("got %d images, want %d", len(images), tt.wantImages)
+			}
+			want := tt.want
+			if len(images) > 0 {
+				if images[0].ContentType != "image/png" {
+					t.Errorf("image content type = %q, want image/png", images[0].ContentType)
+				}
+				want = strings.ReplaceAll(want, "{ref}", images[0].Ref())
+			}
+			if string(got) != want {
+				t.Errorf("docxToHTML()\n got: %q\nwant: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDOCXToHTMLMalformed(t *testing.T) {
+	document := `<w:document ` + docxNamespaces + `><w:body>` + docxPara("", docxRun("", "text")) + `</w:body></w:document>`
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "not a zip", data: []byte("PK\x03\x04 truncated"), wantErr: true},
+		{name: "empty", data: nil, wantErr: true},
+		{name: "no document part", data: buildZip(t, map[string]string{"word/styles.xml": "<w:styles/>"}), wantErr: true},
+		{name: "document without a body", data: buildZip(t, map[string]string{"word/document.xml": "<w:document/>"}), wantErr: true},
+		{name: "document is not XML", data: buildZip(t, map[string]string{"word/document.xml": "<w:document><w:body><</w:document>"}), wantErr: true},
+		{
+			name: "unexpected roots in the other parts",
+			data: buildZip(t, map[string]string{
+				"word/document.xml":            document,
+				"word/_rels/document.xml.rels": "<Other/>",
+				"word/styles.xml":              "<Other/>",
+				"word/numbering.xml":           "<Other/>",
+			}),
+			want: "<p>text</p>\n",
+		},
+		{
+			name: "other parts are not XML",
+			data: buildZip(t, map[string]string{
+				"word/document.xml":            document,
+				"word/_rels/document.xml.rels": "<<<",
+				"word/styles.xml":              "",
+				"word/numbering.xml":           "\x00",
+			}),
+			want: "<p>text</p>\n",
+		},
+		{
+			name: "numbering that points nowhere",
+			data: buildZip(t, map[string]string{
+				"word/document.xml": `<w:document ` + docxNamespaces + `><w:body>` + docxListItem("7", "x", "item") + `</w:body></w:document>`,
+				"word/numbering.xml": `<w:numbering ` + docxNamespaces + `><w:num w:numId="7"/><w:abstractNum/>` +
+					`<w:num w:numId="8"><w:abstractNumId w:val="99"/></w:num></w:numbering>`,
+			}),
+			want: "<ul>\n<li>item</li>\n</ul>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := docxToHTML(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("docxToHTML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("docxToHTML()\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
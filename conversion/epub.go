@@ -0,0 +1,454 @@
+package conversion
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	// ErrEPUBEncrypted is returned for EPUBs whose chapters are encrypted, as
+	// DRM-protected ebooks are.
+	ErrEPUBEncrypted = errors.New("DRM-protected EPUBs are not supported")
+	// ErrEPUBNoContent is returned for EPUBs without any readable chapter.
+	ErrEPUBNoContent = errors.New("EPUB has no readable chapters")
+)
+
+// Files in the archive larger than this are not read.
+const maxEPUBEntrySize = 64 << 20
+
+// Font obfuscation algorithms. Fonts mangled this way are listed in
+// encryption.xml but the book itself is readable.
+var epubFontObfuscation = map[string]bool{
+	"http://www.idpf.org/2008/embedding": true,
+	"http://ns.adobe.com/pdf/enc#RC":     true,
+}
+
+// Image types that are stored with a reading; others are dropped.
+var readingImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// EPUBChapter is one document of an EPUB's spine.
+type EPUBChapter struct {
+	ID    string // Anchor of the chapter's section in EPUBContent.HTML
+	Title string // From the table of contents, or else the chapter's first heading; may be empty
+	HTML  []byte // The chapter as a <section>, with headings demoted one level
+}
+
+// EPUBContent is the content of an EPUB, in reading order.
+type EPUBContent struct {
+	Title    string                // From the package metadata; may be empty
+	Author   string                // Creators from the package metadata, joined with commas
+	Chapters []EPUBChapter         // Spine documents that have any content
+	HTML     []byte                // All chapters, one <section> each
+	Text     string                // Plain text, one paragraph per line
+	Images   []models.ReadingImage // Images, referenced from HTML by ReadingImage.Ref()
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubManifestItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+type epubPackageDocument struct {
+	Metadata struct {
+		Titles   []string `xml:"title"`
+		Creators []string `xml:"creator"`
+	} `xml:"metadata"`
+	Manifest []epubManifestItem `xml:"manifest>item"`
+	Spine    struct {
+		Toc      string `xml:"toc,attr"`
+		Itemrefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type epubEncryption struct {
+	Data []struct {
+		Method struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"EncryptionMethod"`
+		Reference struct {
+			URI string `xml:"URI,attr"`
+		} `xml:"CipherData>CipherReference"`
+	} `xml:"EncryptedData"`
+}
+
+type epubNCXPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []epubNCXPoint `xml:"navPoint"`
+}
+
+type epubNCX struct {
+	Points []epubNCXPoint `xml:"navMap>navPoint"`
+}
+
+// An opened EPUB archive.
+type epubBook struct {
+	files     map[string]*zip.File
+	manifest  map[string]epubManifestItem // By path within the archive
+	images    []models.ReadingImage
+	imageRefs map[string]string // Image path to its reference, or "" if unusable
+}
+
+// ExtractEPUB reads an EPUB's chapters in spine order and returns them as
+// one HTML fragment, a <section> per chapter. Chapter headings are demoted
+// one level so the reading's own title stays on top, links between chapters
+// point at the sections, and images are returned separately. Scripts,
+// styles and forms are dropped; the HTML still needs sanitizing.
+func ExtractEPUB(data []byte) (*EPUBContent, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB archive: %w", err)
+	}
+	book := &epubBook{
+		files:     make(map[string]*zip.File, len(archive.File)),
+		manifest:  make(map[string]epubManifestItem),
+		imageRefs: make(map[string]string),
+	}
+	for _, f := range archive.File {
+		book.files[f.Name] = f
+	}
+
+	opfPath, err := book.packagePath()
+	if err != nil {
+		return nil, err
+	}
+	var opf epubPackageDocument
+	if err := book.decodeXML(opfPath, &opf); err != nil {
+		return nil, fmt.Errorf("failed to read package document: %w", err)
+	}
+	itemsByID := make(map[string]epubManifestItem, len(opf.Manifest))
+	for i := range opf.Manifest {
+		item := &opf.Manifest[i]
+		item.Href = resolveEPUBPath(opfPath, item.Href)
+		itemsByID[item.ID] = *item
+		book.manifest[item.Href] = *item
+	}
+	if err := book.checkEncryption(); err != nil {
+		return nil, err
+	}
+
+	var spine []string
+	for _, ref := range opf.Spine.Itemrefs {
+		item, ok := itemsByID[ref.IDRef]
+		if !ok || ref.Linear == "no" || !isEPUBDocument(item.MediaType) {
+			continue
+		}
+		spine = append(spine, item.Href)
+	}
+	if len(spine) == 0 {
+		return nil, ErrEPUBNoContent
+	}
+	chapterIDs := make(map[string]string, len(spine))
+	for i, docPath := range spine {
+		if _, seen := chapterIDs[docPath]; !seen {
+			chapterIDs[docPath] = "chapter-" + strconv.Itoa(i+1)
+		}
+	}
+	toc := book.tableOfContents(opf.Manifest, itemsByID[opf.Spine.Toc])
+
+	content := &EPUBContent{
+		Title:  firstNonEmpty(opf.Metadata.Titles),
+		Author: strings.Join(trimAll(opf.Metadata.Creators), ", "),
+	}
+	var htmlBuf bytes.Buffer
+	var texts []string
+	htmlBuf.WriteString("<div>")
+	done := make(map[string]bool, len(spine))
+	for _, docPath := range spine {
+		if done[docPath] {
+			continue // Listed twice in the spine
+		}
+		done[docPath] = true
+		doc, err := book.read(docPath)
+		if err != nil {
+			log.Printf("WARN (ExtractEPUB): Skipping chapter %s: %v", docPath, err)
+			continue
+		}
+		chapter, text := book.chapter(docPath, doc, chapterIDs, toc[docPath])
+		if chapter == nil {
+			continue
+		}
+		content.Chapters = append(content.Chapters, *chapter)
+		htmlBuf.Write(chapter.HTML)
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+	htmlBuf.WriteString("</div>")
+	if len(texts) == 0 {
+		return nil, ErrEPUBNoContent
+	}
+
+	content.HTML = htmlBuf.Bytes()
+	content.Text = strings.Join(texts, "\n")
+	content.Images = book.images
+	return content, nil
+}
+
+// Returns the path of the package (OPF) document named by the container.
+func (b *epubBook) packagePath() (string, error) {
+	var container epubContainer
+	if err := b.decodeXML("META-INF/container.xml", &container); err == nil {
+		for _, rootfile := range container.Rootfiles {
+			if rootfile.FullPath != "" && (rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml") {
+				return rootfile.FullPath, nil
+			}
+		}
+	}
+	// Some hand-made books have no container; take the only package document.
+	for name := range b.files {
+		if strings.EqualFold(path.Ext(name), ".opf") {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("EPUB has no package document")
+}
+
+// Returns ErrEPUBEncrypted if any content document is encrypted. Obfuscated
+// fonts don't count.
+func (b *epubBook) checkEncryption() error {
+	if b.files["META-INF/encryption.xml"] == nil {
+		return nil
+	}
+	var encryption epubEncryption
+	if err := b.decodeXML("META-INF/encryption.xml", &encryption); err != nil {
+		return ErrEPUBEncrypted
+	}
+	for _, data := range encryption.Data {
+		if epubFontObfuscation[data.Method.Algorithm] {
+			continue
+		}
+		// Encrypted fonts or images still leave a readable book.
+		item := b.manifest[resolveEPUBPath("", data.Reference.URI)]
+		if item.MediaType == "" || isEPUBDocument(item.MediaType) {
+			return ErrEPUBEncrypted
+		}
+	}
+	return nil
+}
+
+// Maps chapter paths to their titles in the table of contents, from the
+// EPUB 3 navigation document or else the EPUB 2 NCX. Only entries that point
+// at the start of a chapter are used.
+func (b *epubBook) tableOfContents(manifest []epubManifestItem, ncxItem epubManifestItem) map[string]string {
+	toc := make(map[string]string)
+	add := func(base, href, label string) {
+		label = strings.Join(strings.Fields(label), " ")
+		if label == "" || strings.Contains(href, "#") {
+			return
+		}
+		docPath := resolveEPUBPath(base, href)
+		if _, ok := toc[docPath]; !ok {
+			toc[docPath] = label
+		}
+	}
+
+	for _, item := range manifest {
+		if !strings.Contains(" "+item.Properties+" ", " nav ") {
+			continue
+		}
+		data, err := b.read(item.Href)
+		if err != nil {
+			break
+		}
+		doc, err := html.Parse(bytes.NewReader(expandSelfClosingTags(data)))
+		if err != nil {
+			break
+		}
+		nav := findEPUBNav(doc)
+		if nav == nil {
+			break
+		}
+		walkHTML(nav, func(n *html.Node) bool {
+			if n.Type == html.ElementNode && n.Data == "a" {
+				add(item.Href, htmlAttr(n, "href"), htmlText(n))
+				return false
+			}
+			return true
+		})
+		if len(toc) > 0 {
+			return toc
+		}
+	}
+
+	if ncxItem.Href == "" {
+		return toc
+	}
+	var ncx epubNCX
+	if err := b.decodeXML(ncxItem.Href, &ncx); err != nil {
+		return toc
+	}
+	var walk func(points []epubNCXPoint)
+	walk = func(points []epubNCXPoint) {
+		for _, point := range points {
+			add(ncxItem.Href, point.Content.Src, point.Label)
+			walk(point.Children)
+		}
+	}
+	walk(ncx.Points)
+	return toc
+}
+
+// Returns the <nav epub:type="toc"> element, or else the first <nav>.
+func findEPUBNav(doc *html.Node) *html.Node {
+	var first, toc *html.Node
+	walkHTML(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || n.Data != "nav" {
+			return toc == nil
+		}
+		if first == nil {
+			first = n
+		}
+		if strings.Contains(" "+htmlAttr(n, "epub:type")+" ", " toc ") {
+			toc = n
+		}
+		return false
+	})
+	if toc != nil {
+		return toc
+	}
+	return first
+}
+
+// Returns a stored reference for the image at imagePath, loading it on first
+// use, or "" if it is missing or not a supported type.
+func (b *epubBook) imageRef(imagePath string) string {
+	if ref, ok := b.imageRefs[imagePath]; ok {
+		return ref
+	}
+	b.imageRefs[imagePath] = ""
+
+	contentType := b.manifest[imagePath].MediaType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(path.Ext(imagePath)))
+	}
+	if !readingImageTypes[contentType] {
+		return ""
+	}
+	data, err := b.read(imagePath)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	hash, err := webutil.GenerateHash(string(data))
+	if err != nil {
+		return ""
+	}
+	image := models.ReadingImage{ContentHash: hash, ContentType: contentType, Data: data}
+	for _, existing := range b.images {
+		if existing.ContentHash == hash {
+			b.imageRefs[imagePath] = existing.Ref()
+			return existing.Ref()
+		}
+	}
+	b.images = append(b.images, image)
+	b.imageRefs[imagePath] = image.Ref()
+	return image.Ref()
+}
+
+func (b *epubBook) read(name string) ([]byte, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in archive", name)
+	}
+	if f.UncompressedSize64 > maxEPUBEntrySize {
+		return nil, fmt.Errorf("%s is too large (%d bytes)", name, f.UncompressedSize64)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer rc.Close()
+	// The size in the directory can lie; don't trust it.
+	data, err := io.ReadAll(io.LimitReader(rc, maxEPUBEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxEPUBEntrySize {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return data, nil
+}
+
+func (b *epubBook) decodeXML(name string, v any) error {
+	data, err := b.read(name)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+// Resolves href, relative to the document at base, to a path in the archive.
+// Fragments and queries are dropped.
+func resolveEPUBPath(base, href string) string {
+	if i := strings.IndexAny(href, "#?"); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	if strings.HasPrefix(href, "/") {
+		return strings.TrimPrefix(path.Clean(href), "/")
+	}
+	return strings.TrimPrefix(path.Join(path.Dir(base), href), "./")
+}
+
+func isEPUBDocument(mediaType string) bool {
+	return mediaType == "application/xhtml+xml" || mediaType == "text/html"
+}
+
+func firstNonEmpty(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func trimAll(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			trimmed = append(trimmed, v)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,323 @@
+package conversion
+
+import (
+	"bytes"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Matches XHTML self-closing tags such as <a id="p12"/> and <div/>, which an
+// HTML parser reads as start tags.
+var selfClosingTagRegex = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9:-]*)(\s[^<>]*?)?\s*/>`)
+
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// Elements dropped from chapters along with their content.
+var epubDroppedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Link: true, atom.Meta: true,
+	atom.Noscript: true, atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Form: true,
+	atom.Input: true, atom.Button: true, atom.Select: true, atom.Textarea: true, atom.Audio: true,
+	atom.Video: true, atom.Canvas: true, atom.Math: true, atom.Title: true,
+}
+
+// Elements that end a line of a chapter's plain text.
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Blockquote: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Dt: true, atom.Dd: true, atom.Pre: true, atom.Tr: true, atom.Br: true,
+	atom.Figcaption: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Hr: true,
+}
+
+var demotedHeadings = map[atom.Atom]atom.Atom{
+	atom.H1: atom.H2, atom.H2: atom.H3, atom.H3: atom.H4, atom.H4: atom.H5, atom.H5: atom.H6,
+}
+
+// Rewrites a spine document as a chapter section. Returns nil if the
+// document has no text or images, such as a blank separator page.
+func (b *epubBook) chapter(docPath string, data []byte, chapterIDs map[string]string, tocTitle string) (*EPUBChapter, string) {
+	doc, err := html.Parse(bytes.NewReader(expandSelfClosingTags(data)))
+	if err != nil {
+		log.Printf("WARN (ExtractEPUB): Skipping chapter %s: %v", docPath, err)
+		return nil, ""
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return nil, ""
+	}
+	chapterID := chapterIDs[docPath]
+
+	title := tocTitle
+	if title == "" {
+		if heading := findHeading(body); heading != nil {
+			title = strings.Join(strings.Fields(htmlText(heading)), " ")
+		}
+	}
+	if title == "" {
+		if titleNode := findElement(doc, atom.Title); titleNode != nil {
+			title = strings.Join(strings.Fields(htmlText(titleNode)), " ")
+		}
+	}
+
+	b.rewriteChapter(body, docPath, chapterID, chapterIDs)
+	text := chapterText(body)
+	if text == "" && findElement(body, atom.Img) == nil {
+		return nil, ""
+	}
+
+	section := &html.Node{Type: html.ElementNode, Data: "section", DataAtom: atom.Section,
+		Attr: []html.Attribute{{Key: "id", Val: chapterID}}}
+	// Chapters without a heading of their own get their title from the table
+	// of contents, so they can be told apart in the edition.
+	if findHeading(body) == nil && tocTitle != "" {
+		heading := &html.Node{Type: html.ElementNode, Data: "h2", DataAtom: atom.H2}
+		heading.AppendChild(&html.Node{Type: html.TextNode, Data: tocTitle})
+		section.AppendChild(heading)
+	}
+	for child := body.FirstChild; child != nil; {
+		next := child.NextSibling
+		body.RemoveChild(child)
+		section.AppendChild(child)
+		child = next
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, section); err != nil {
+		log.Printf("WARN (ExtractEPUB): Skipping chapter %s: %v", docPath, err)
+		return nil, ""
+	}
+	return &EPUBChapter{ID: chapterID, Title: title, HTML: buf.Bytes()}, text
+}
+
+// Drops unsafe and unusable elements, demotes headings, points images at
+// stored copies, and makes ids and internal links unique across chapters.
+func (b *epubBook) rewriteChapter(n *html.Node, docPath, chapterID string, chapterIDs map[string]string) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.CommentNode {
+			n.RemoveChild(child)
+			child = next
+			continue
+		}
+		if child.Type != html.ElementNode {
+			child = next
+			continue
+		}
+
+		switch {
+		case epubDroppedElements[child.DataAtom]:
+			n.RemoveChild(child)
+			child = next
+			continue
+		case child.DataAtom == atom.Svg:
+			// Covers and full-page illustrations are often an SVG wrapping a
+			// single bitmap; keep the bitmap.
+			if img := b.svgImage(child, docPath); img != nil {
+				n.InsertBefore(img, child)
+			}
+			n.RemoveChild(child)
+			child = next
+			continue
+		case child.DataAtom == atom.Img:
+			ref := b.imageRef(resolveEPUBPath(docPath, htmlAttr(child, "src")))
+			if ref == "" {
+				n.RemoveChild(child)
+				child = next
+				continue
+			}
+			setHTMLAttr(child, "src", ref)
+			removeHTMLAttr(child, "srcset")
+		case child.DataAtom == atom.A:
+			rewriteEPUBLink(child, docPath, chapterIDs)
+		}
+
+		if demoted, ok := demotedHeadings[child.DataAtom]; ok {
+			child.DataAtom, child.Data = demoted, demoted.String()
+		}
+		if id := htmlAttr(child, "id"); id != "" {
+			setHTMLAttr(child, "id", chapterID+"-"+id)
+		}
+		removeHTMLAttr(child, "style")
+		b.rewriteChapter(child, docPath, chapterID, chapterIDs)
+		child = next
+	}
+}
+
+// Returns an <img> for the bitmap an SVG wraps, or nil if it has none.
+func (b *epubBook) svgImage(svg *html.Node, docPath string) *html.Node {
+	var src string
+	walkHTML(svg, func(n *html.Node) bool {
+		if src == "" && n.Type == html.ElementNode && n.Data == "image" {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					src = attr.Val
+				}
+			}
+		}
+		return src == ""
+	})
+	if src == "" {
+		return nil
+	}
+	ref := b.imageRef(resolveEPUBPath(docPath, src))
+	if ref == "" {
+		return nil
+	}
+	return &html.Node{Type: html.ElementNode, Data: "img", DataAtom: atom.Img,
+		Attr: []html.Attribute{{Key: "src", Val: ref}, {Key: "alt", Val: ""}}}
+}
+
+// Points links to other chapters at their sections. Links into files that
+// aren't chapters lose their href; external links are kept.
+func rewriteEPUBLink(a *html.Node, docPath string, chapterIDs map[string]string) {
+	href := htmlAttr(a, "href")
+	if href == "" {
+		return
+	}
+	if u, err := url.Parse(href); err == nil && u.Scheme != "" {
+		return
+	}
+	target := docPath
+	if !strings.HasPrefix(href, "#") {
+		target = resolveEPUBPath(docPath, href)
+	}
+	targetID, ok := chapterIDs[target]
+	if !ok {
+		removeHTMLAttr(a, "href")
+		return
+	}
+	if i := strings.Index(href, "#"); i >= 0 && i < len(href)-1 {
+		setHTMLAttr(a, "href", "#"+targetID+"-"+href[i+1:])
+		return
+	}
+	setHTMLAttr(a, "href", "#"+targetID)
+}
+
+// Returns a chapter's text, one paragraph per line.
+func chapterText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if n.Type == html.ElementNode && htmlBlockElements[n.DataAtom] {
+			b.WriteByte('\n')
+		}
+	}
+	walk(n)
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Rewrites XHTML self-closing tags of non-void elements as start and end
+// tags, so that an HTML parser doesn't nest what follows inside them.
+func expandSelfClosingTags(data []byte) []byte {
+	return selfClosingTagRegex.ReplaceAllFunc(data, func(tag []byte) []byte {
+		m := selfClosingTagRegex.FindSubmatch(tag)
+		name := string(m[1])
+		if htmlVoidElements[strings.ToLower(name)] {
+			return tag
+		}
+		expanded := make([]byte, 0, len(tag)+len(name)+3)
+		expanded = append(expanded, '<')
+		expanded = append(expanded, m[1]...)
+		expanded = append(expanded, m[2]...)
+		expanded = append(expanded, '>', '<', '/')
+		expanded = append(expanded, m[1]...)
+		return append(expanded, '>')
+	})
+}
+
+// Calls visit for n and its descendants in document order, skipping the
+// descendants of nodes for which visit returns false.
+func walkHTML(n *html.Node, visit func(*html.Node) bool) {
+	if !visit(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walkHTML(child, visit)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walkHTML(n, func(n *html.Node) bool {
+		if found == nil && n.Type == html.ElementNode && n.DataAtom == a {
+			found = n
+		}
+		return found == nil
+	})
+	return found
+}
+
+func findHeading(n *html.Node) *html.Node {
+	var found *html.Node
+	walkHTML(n, func(n *html.Node) bool {
+		if found == nil && n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				found = n
+			}
+		}
+		return found == nil
+	})
+	return found
+}
+
+func htmlText(n *html.Node) string {
+	var b strings.Builder
+	walkHTML(n, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func setHTMLAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func removeHTMLAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		if attr.Key != key {
+			attrs = append(attrs, attr)
+		}
+	}
+	n.Attr = attrs
+}
@@ -0,0 +1,252 @@
+package conversion
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testEPUBContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+const testEPUBPackage = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>  </dc:title>
+    <dc:title>A Tale of Two Files</dc:title>
+    <dc:creator>Ada Lovelace</dc:creator>
+    <dc:creator> Charles Babbage </dc:creator>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="one" href="Text/one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="two" href="Text/two%20parts.xhtml" media-type="application/xhtml+xml"/>
+    <item id="notes" href="Text/notes.xhtml" media-type="application/xhtml+xml"/>
+    <item id="blank" href="Text/blank.xhtml" media-type="application/xhtml+xml"/>
+    <item id="pic" href="Images/pic.png" media-type="image/png"/>
+    <item id="css" href="style.css" media-type="text/css"/>
+  </manifest>
+  <spine>
+    <itemref idref="two"/>
+    <itemref idref="blank"/>
+    <itemref idref="one"/>
+    <itemref idref="notes" linear="no"/>
+    <itemref idref="css"/>
+    <itemref idref="missing"/>
+  </spine>
+</package>`
+
+const testEPUBNav = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<nav epub:type="toc"><ol>
+  <li><a href="Text/two%20parts.xhtml">Part Two</a></li>
+  <li><a href="Text/one.xhtml">Part One</a></li>
+  <li><a href="Text/one.xhtml#later">Later in One</a></li>
+</ol></nav></body></html>`
+
+func testEPUBFiles() map[string]string {
+	return map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": testEPUBContainer,
+		"OEBPS/content.opf":      testEPUBPackage,
+		"OEBPS/nav.xhtml":        testEPUBNav,
+		"OEBPS/Text/two parts.xhtml": `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>ignored</title><style>p{}</style></head>
+<body><h1>The Second File</h1><p style="color:red">See <a href="one.xhtml#later">later</a>.<a id="top"/></p>
+<p><img src="../Images/pic.png" alt="A picture"/><img src="../Images/gone.png"/></p>
+<script>alert(1)</script></body></html>`,
+		"OEBPS/Text/blank.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><body><div> </div></body></html>`,
+		"OEBPS/Text/one.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<p>Opening &amp; text.</p><p id="later">More, <a href="https://example.com/">outside</a>.</p>
+<p><img src="/OEBPS/Images/pic.png"/></p></body></html>`,
+		"OEBPS/Text/notes.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Notes</p></body></html>`,
+		"OEBPS/Images/pic.png":   "\x89PNG fake image data",
+	}
+}
+
+func TestExtractEPUB(t *testing.T) {
+	content, err := ExtractEPUB(buildZip(t, testEPUBFiles()))
+	if err != nil {
+		t.Fatalf("ExtractEPUB: %v", err)
+	}
+
+	if content.Title != "A Tale of Two Files" {
+		t.Errorf("Title = %q, want %q", content.Title, "A Tale of Two Files")
+	}
+	if content.Author != "Ada Lovelace, Charles Babbage" {
+		t.Errorf("Author = %q, want %q", content.Author, "Ada Lovelace, Charles Babbage")
+	}
+
+	// The blank chapter is dropped; non-linear, non-document and unknown
+	// spine entries are skipped.
+	var got []string
+	for _, chapter := range content.Chapters {
+		got = append(got, chapter.ID+" "+chapter.Title)
+	}
+	want := []string{"chapter-1 Part Two", "chapter-3 Part One"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("chapters = %q, want %q", got, want)
+	}
+
+	// Both relative and absolute references to the image resolve to the
+	// same stored copy; the missing one is dropped.
+	if len(content.Images) != 1 {
+		t.Fatalf("got %d images, want 1", len(content.Images))
+	}
+	image := content.Images[0]
+	if image.ContentType != "image/png" || string(image.Data) != "\x89PNG fake image data" {
+		t.Errorf("image = %s %q, want the archive's pic.png", image.ContentType, image.Data)
+	}
+	ref := image.Ref()
+
+	wantHTML := `<div>` +
+		`<section id="chapter-1"><h2>The Second File</h2><p>See <a href="#chapter-3-later">later</a>.<a id="chapter-1-top"></a></p>` + "\n" +
+		fmt.Sprintf(`<p><img src="%s" alt="A picture"/></p>`, ref) + "\n</section>" +
+		`<section id="chapter-3"><h2>Part One</h2>` + "\n" +
+		`<p>Opening &amp; text.</p><p id="chapter-3-later">More, <a href="https://example.com/">outside</a>.</p>` + "\n" +
+		fmt.Sprintf(`<p><img src="%s"/></p></section>`, ref) +
+		`</div>`
+	if string(content.HTML) != wantHTML {
+		t.Errorf("HTML\n got: %s\nwant: %s", content.HTML, wantHTML)
+	}
+
+	wantText := "The Second File\nSee later.\nOpening & text.\nMore, outside."
+	if content.Text != wantText {
+		t.Errorf("Text = %q, want %q", content.Text, wantText)
+	}
+}
+
+// EPUB 2 books have an NCX table of contents instead of a navigation
+// document.
+func TestExtractEPUBNCX(t *testing.T) {
+	files := testEPUBFiles()
+	files["OEBPS/content.opf"] = strings.NewReplacer(
+		`properties="nav"`, "",
+		`<spine>`, `<spine toc="ncx">`,
+		`<manifest>`, `<manifest><item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>`,
+	).Replace(testEPUBPackage)
+	files["OEBPS/toc.ncx"] = `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"><navMap>
+  <navPoint id="p1"><navLabel><text>Book Two</text></navLabel><content src="Text/two%20parts.xhtml"/>
+    <navPoint id="p2"><navLabel><text>Book One</text></navLabel><content src="Text/one.xhtml"/></navPoint>
+  </navPoint>
+</navMap></ncx>`
+
+	content, err := ExtractEPUB(buildZip(t, files))
+	if err != nil {
+		t.Fatalf("ExtractEPUB: %v", err)
+	}
+	var got []string
+	for _, chapter := range content.Chapters {
+		got = append(got, chapter.Title)
+	}
+	if want := []string{"Book Two", "Book One"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("chapter titles = %q, want %q", got, want)
+	}
+}
+
+func TestExtractEPUBWithoutContainer(t *testing.T) {
+	files := testEPUBFiles()
+	delete(files, "META-INF/container.xml")
+
+	content, err := ExtractEPUB(buildZip(t, files))
+	if err != nil {
+		t.Fatalf("ExtractEPUB: %v", err)
+	}
+	if len(content.Chapters) != 2 {
+		t.Errorf("got %d chapters, want 2 from the only package document", len(content.Chapters))
+	}
+}
+
+func TestExtractEPUBErrors(t *testing.T) {
+	withFiles := func(change func(files map[string]string)) func(t *testing.T) []byte {
+		return func(t *testing.T) []byte {
+			files := testEPUBFiles()
+			change(files)
+			return buildZip(t, files)
+		}
+	}
+
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+		want error // Checked with errors.Is when set
+	}{
+		{
+			name: "not a zip",
+			data: func(*testing.T) []byte { return []byte("PK\x03\x04 not really") },
+		},
+		{
+			name: "no package document",
+			data: withFiles(func(files map[string]string) {
+				delete(files, "META-INF/container.xml")
+				delete(files, "OEBPS/content.opf")
+			}),
+		},
+		{
+			name: "container names a missing package document",
+			data: withFiles(func(files map[string]string) {
+				delete(files, "OEBPS/content.opf")
+			}),
+		},
+		{
+			name: "invalid package document",
+			data: withFiles(func(files map[string]string) {
+				files["OEBPS/content.opf"] = `<package><manifest><item id="one"`
+			}),
+		},
+		{
+			name: "empty spine",
+			data: withFiles(func(files map[string]string) {
+				files["OEBPS/content.opf"] = testEPUBPackage[:strings.Index(testEPUBPackage, "<spine>")] + "</package>"
+			}),
+			want: ErrEPUBNoContent,
+		},
+		{
+			name: "only blank chapters",
+			data: withFiles(func(files map[string]string) {
+				files["OEBPS/Text/one.xhtml"] = files["OEBPS/Text/blank.xhtml"]
+				files["OEBPS/Text/two parts.xhtml"] = "<html><body><p>\n</p></body></html>"
+			}),
+			want: ErrEPUBNoContent,
+		},
+		{
+			name: "encrypted chapters",
+			data: withFiles(func(files map[string]string) {
+				files["META-INF/encryption.xml"] = `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+  <enc:EncryptedData><enc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+  <enc:CipherData><enc:CipherReference URI="OEBPS/Text/one.xhtml"/></enc:CipherData></enc:EncryptedData>
+</encryption>`
+			}),
+			want: ErrEPUBEncrypted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := ExtractEPUB(tt.data(t))
+			if err == nil {
+				t.Fatalf("ExtractEPUB() = %+v, want an error", content)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("ExtractEPUB() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// Obfuscated fonts are listed in encryption.xml but leave the book readable.
+func TestExtractEPUBObfuscatedFonts(t *testing.T) {
+	files := testEPUBFiles()
+	files["META-INF/encryption.xml"] = `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+  <enc:EncryptedData><enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+  <enc:CipherData><enc:CipherReference URI="OEBPS/Fonts/serif.otf"/></enc:CipherData></enc:EncryptedData>
+</encryption>`
+
+	if _, err := ExtractEPUB(buildZip(t, files)); err != nil {
+		t.Errorf("ExtractEPUB: %v", err)
+	}
+}
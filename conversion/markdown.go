@@ -0,0 +1,952 @@
+package conversion
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Block-level patterns. Lines have had tabs expanded and the container
+// prefixes (blockquote markers, list indentation) stripped before matching.
+var (
+	mdATXHeadingRegex    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdSetextRegex        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdThematicBreakRegex = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdFenceRegex         = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*?)[ \t]*$")
+	mdBlockquoteRegex    = regexp.MustCompile(`^ {0,3}> ?`)
+	mdListItemRegex      = regexp.MustCompile(`^( {0,3})([-+*]|\d{1,9}[.)])( +|$)`)
+	mdHTMLBlockRegex     = regexp.MustCompile(`^ {0,3}(?:<!--|<\?|<![A-Z]|</?[a-zA-Z][a-zA-Z0-9-]*(?:\s|/?>|$))`)
+	mdTableDelimRegex    = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdLinkRefDefRegex    = regexp.MustCompile(`^ {0,3}\[((?:[^\]\\]|\\.){1,999})\]:[ \t]*\n?[ \t]*(<[^<>\n]*>|\S+)(?:[ \t]*\n?[ \t]*("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^()\\]|\\.)*\)))?[ \t]*(?:\n|$)`)
+)
+
+// Inline patterns.
+var (
+	mdEntityRegex     = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	mdAutolinkRegex   = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*)>`)
+	mdEmailRegex      = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	mdInlineHTMLRegex = regexp.MustCompile(`^(?:<[a-zA-Z][a-zA-Z0-9-]*(?:\s+[a-zA-Z_:][a-zA-Z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[a-zA-Z][a-zA-Z0-9-]*\s*>|<!--(?:[^-]|-[^-])*-->)`)
+)
+
+type mdBlockKind int
+
+const (
+	mdParagraph mdBlockKind = iota
+	mdHeading
+	mdThematicBreak
+	mdCode
+	mdHTML
+	mdBlockquote
+	mdList
+	mdListItem
+	mdTable
+)
+
+type mdBlock struct {
+	kind     mdBlockKind
+	text     string // Paragraph and heading source, code content, or raw HTML
+	level    int    // Heading level
+	info     string // Code fence info string
+	ordered  bool
+	start    int
+	tight    bool
+	align    []string   // Table column alignment
+	rows     [][]string // Table cells; the first row is the header
+	children []*mdBlock
+}
+
+type mdLinkRef struct {
+	dest, title string
+}
+
+type mdParser struct {
+	refs map[string]mdLinkRef
+}
+
+// markdownToHTML converts CommonMark, with GitHub-style tables and
+// strikethrough, to an HTML fragment. Raw HTML is passed through; the
+// result needs sanitizing like any other HTML content.
+func markdownToHTML(src []byte) []byte {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	p := &mdParser{refs: make(map[string]mdLinkRef)}
+	blocks := p.parseBlocks(lines)
+
+	var buf bytes.Buffer
+	p.render(&buf, blocks, false)
+	return buf.Bytes()
+}
+
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// Reports whether line starts a block that can interrupt a paragraph.
+func (p *mdParser) interruptsParagraph(line string) bool {
+	if mdATXHeadingRegex.MatchString(line) || mdThematicBreakRegex.MatchString(line) ||
+		mdFenceRegex.MatchString(line) || mdBlockquoteRegex.MatchString(line) || mdHTMLBlockRegex.MatchString(line) {
+		return true
+	}
+	// Only a list item with content, and for ordered lists one starting at
+	// 1, interrupts a paragraph.
+	if m := mdListItemRegex.FindStringSubmatch(line); m != nil && !isBlank(line[len(m[0]):]) {
+		marker := m[2]
+		return !isDigit(marker[0]) || strings.TrimLeft(marker[:len(marker)-1], "0") == "1"
+	}
+	return false
+}
+
+func (p *mdParser) parseBlocks(lines []string) []*mdBlock {
+	var blocks []*mdBlock
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+
+		if m := mdFenceRegex.FindStringSubmatch(line); m != nil && !(m[2][0] == '`' && strings.Contains(m[3], "`")) {
+			indent, fence := len(m[1]), m[2]
+			var code []string
+			i++
+			for ; i < len(lines); i++ {
+				trimmed := strings.TrimLeft(lines[i], " ")
+				if indentOf(lines[i]) < 4 && strings.HasPrefix(trimmed, fence[:1]) &&
+					len(trimmed)-len(strings.TrimLeft(trimmed, fence[:1])) >= len(fence) &&
+					isBlank(strings.TrimLeft(trimmed, fence[:1])) {
+					i++
+					break
+				}
+				code = append(code, trimIndent(lines[i], indent))
+			}
+			info := strings.Fields(unescapeMarkdown(m[3]))
+			block := &mdBlock{kind: mdCode, text: strings.Join(code, "\n")}
+			if len(info) > 0 {
+				block.info = info[0]
+			}
+			blocks = append(blocks, block)
+			continue
+		}
+
+		if m := mdATXHeadingRegex.FindStringSubmatch(line); m != nil {
+			blocks = append(blocks, &mdBlock{kind: mdHeading, level: len(m[1]), text: m[2]})
+			i++
+			continue
+		}
+
+		if mdThematicBreakRegex.MatchString(line) {
+			blocks = append(blocks, &mdBlock{kind: mdThematicBreak})
+			i++
+			continue
+		}
+
+		if mdBlockquoteRegex.MatchString(line) {
+			var quoted []string
+			for ; i < len(lines); i++ {
+				if loc := mdBlockquoteRegex.FindStringIndex(lines[i]); loc != nil {
+					quoted = append(quoted, lines[i][loc[1]:])
+					continue
+				}
+				// Lazy continuation of a quoted paragraph
+				if isBlank(lines[i]) || len(quoted) == 0 || isBlank(quoted[len(quoted)-1]) || p.interruptsParagraph(lines[i]) {
+					break
+				}
+				quoted = append(quoted, lines[i])
+			}
+			blocks = append(blocks, &mdBlock{kind: mdBlockquote, children: p.parseBlocks(quoted)})
+			continue
+		}
+
+		if indentOf(line) >= 4 {
+			var code []string
+			for ; i < len(lines) && (indentOf(lines[i]) >= 4 || isBlank(lines[i])); i++ {
+				code = append(code, trimIndent(lines[i], 4))
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			blocks = append(blocks, &mdBlock{kind: mdCode, text: strings.Join(code, "\n")})
+			continue
+		}
+
+		if mdHTMLBlockRegex.MatchString(line) {
+			var raw []string
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				raw = append(raw, lines[i])
+			}
+			blocks = append(blocks, &mdBlock{kind: mdHTML, text: strings.Join(raw, "\n")})
+			continue
+		}
+
+		if mdListItemRegex.MatchString(line) {
+			var list *mdBlock
+			list, i = p.parseList(lines, i)
+			blocks = append(blocks, list)
+			continue
+		}
+
+		if i+1 < len(lines) && strings.Contains(line, "|") && mdTableDelimRegex.MatchString(lines[i+1]) {
+			if table, next := parseTable(lines, i); table != nil {
+				blocks = append(blocks, table)
+				i = next
+				continue
+			}
+		}
+
+		// Paragraph, possibly turned into a heading by a setext underline.
+		para := []string{strings.TrimLeft(line, " ")}
+		i++
+		var heading *mdBlock
+		for ; i < len(lines) && !isBlank(lines[i]); i++ {
+			if m := mdSetextRegex.FindStringSubmatch(lines[i]); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				heading = &mdBlock{kind: mdHeading, level: level}
+				i++
+				break
+			}
+			if p.interruptsParagraph(lines[i]) {
+				break
+			}
+			para = append(para, strings.TrimLeft(lines[i], " "))
+		}
+		text := p.extractLinkRefs(strings.Join(para, "\n"))
+		if heading != nil {
+			if text == "" {
+				continue
+			}
+			heading.text = text
+			blocks = append(blocks, heading)
+			continue
+		}
+		if text != "" {
+			blocks = append(blocks, &mdBlock{kind: mdParagraph, text: text})
+		}
+	}
+	return blocks
+}
+
+// Parses the list starting at lines[i] and returns it with the index of the
+// first line after it.
+func (p *mdParser) parseList(lines []string, i int) (*mdBlock, int) {
+	first := mdListItemRegex.FindStringSubmatch(lines[i])
+	marker := first[2]
+	list := &mdBlock{kind: mdList, tight: true}
+	if isDigit(marker[0]) {
+		list.ordered = true
+		list.start, _ = strconv.Atoi(marker[:len(marker)-1])
+	}
+	sameList := func(m []string) bool {
+		other := m[2]
+		if list.ordered {
+			return isDigit(other[0]) && other[len(other)-1] == marker[len(marker)-1]
+		}
+		return other == marker
+	}
+
+	blankBetweenItems := false
+	for i < len(lines) {
+		m := mdListItemRegex.FindStringSubmatch(lines[i])
+		if m == nil || !sameList(m) || mdThematicBreakRegex.MatchString(lines[i]) {
+			break
+		}
+		contentIndent := len(m[1]) + len(m[2]) + len(m[3])
+		if len(m[3]) > 4 { // Indented code inside the item
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+		if isBlank(lines[i][len(m[0]):]) {
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+
+		item := []string{strings.Repeat(" ", max(0, len(m[0])-contentIndent)) + lines[i][len(m[0]):]}
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				item = append(item, "")
+				i++
+				continue
+			}
+			if indentOf(line) >= contentIndent {
+				item = append(item, line[contentIndent:])
+				i++
+				continue
+			}
+			// Lazy continuation of the item's last paragraph
+			if !isBlank(item[len(item)-1]) && !p.interruptsParagraph(line) && !mdListItemRegex.MatchString(line) {
+				item = append(item, line)
+				i++
+				continue
+			}
+			break
+		}
+
+		// Trailing blank lines separate items rather than belonging to one.
+		trailing := 0
+		for len(item) > 1 && isBlank(item[len(item)-1]) {
+			item = item[:len(item)-1]
+			trailing++
+		}
+		if trailing > 0 && i < len(lines) {
+			if next := mdListItemRegex.FindStringSubmatch(lines[i]); next != nil && sameList(next) {
+				blankBetweenItems = true
+			}
+		}
+		children := p.parseBlocks(item)
+		if hasBlankBetweenBlocks(item) && len(children) > 1 {
+			list.tight = false
+		}
+		list.children = append(list.children, &mdBlock{kind: mdListItem, children: children})
+		if trailing > 0 && (i >= len(lines) || !mdListItemRegex.MatchString(lines[i])) {
+			break
+		}
+	}
+	if blankBetweenItems {
+		list.tight = false
+	}
+	return list, i
+}
+
+// Reports whether a blank line separates content in an item's own lines,
+// ignoring blank lines inside fenced code.
+func hasBlankBetweenBlocks(lines []string) bool {
+	inFence := false
+	seenContent := false
+	blank := false
+	for _, line := range lines {
+		if mdFenceRegex.MatchString(line) && indentOf(line) < 4 {
+			inFence = !inFence
+		}
+		if inFence || indentOf(line) > 0 && !isBlank(line) && mdListItemRegex.MatchString(strings.TrimLeft(line, " ")) {
+			continue
+		}
+		if isBlank(line) {
+			blank = seenContent
+			continue
+		}
+		if blank && indentOf(line) == 0 {
+			return true
+		}
+		seenContent = true
+	}
+	return false
+}
+
+func parseTable(lines []string, i int) (*mdBlock, int) {
+	header := splitTableRow(lines[i])
+	delims := splitTableRow(lines[i+1])
+	if len(header) != len(delims) {
+		return nil, i
+	}
+	table := &mdBlock{kind: mdTable, align: make([]string, len(delims))}
+	for c, d := range delims {
+		d = strings.TrimSpace(d)
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			table.align[c] = "center"
+		case strings.HasSuffix(d, ":"):
+			table.align[c] = "right"
+		case strings.HasPrefix(d, ":"):
+			table.align[c] = "left"
+		}
+	}
+	table.rows = append(table.rows, header)
+	i += 2
+	for ; i < len(lines) && !isBlank(lines[i]); i++ {
+		if mdBlockquoteRegex.MatchString(lines[i]) || mdFenceRegex.MatchString(lines[i]) || mdATXHeadingRegex.MatchString(lines[i]) {
+			break
+		}
+		cells := splitTableRow(lines[i])
+		row := make([]string, len(header))
+		copy(row, cells)
+		table.rows = append(table.rows, row)
+	}
+	return table, i
+}
+
+// Splits a table row on unescaped pipes, outside code spans.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for j := 0; j < len(line); j++ {
+		c := line[j]
+		switch {
+		case c == '\\' && j+1 < len(line) && line[j+1] == '|':
+			cell.WriteByte('|')
+			j++
+			continue
+		case c == '`':
+			inCode = !inCode
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(c)
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// Removes link reference definitions from the start of a paragraph,
+// recording them, and returns what is left.
+func (p *mdParser) extractLinkRefs(text string) string {
+	for {
+		m := mdLinkRefDefRegex.FindStringSubmatch(text)
+		if m == nil {
+			return text
+		}
+		label := normalizeLinkLabel(m[1])
+		if label == "" {
+			return text
+		}
+		dest := m[2]
+		if strings.HasPrefix(dest, "<") {
+			dest = dest[1 : len(dest)-1]
+		}
+		title := m[3]
+		if len(title) >= 2 {
+			title = title[1 : len(title)-1]
+		}
+		if _, exists := p.refs[label]; !exists {
+			p.refs[label] = mdLinkRef{dest: unescapeMarkdown(dest), title: unescapeMarkdown(title)}
+		}
+		text = text[len(m[0]):]
+	}
+}
+
+func normalizeLinkLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func trimIndent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return line[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *mdParser) render(buf *bytes.Buffer, blocks []*mdBlock, tight bool) {
+	for _, block := range blocks {
+		switch block.kind {
+		case mdParagraph:
+			if tight {
+				buf.WriteString(p.inline(block.text))
+				buf.WriteByte('\n')
+				continue
+			}
+			buf.WriteString("<p>" + p.inline(block.text) + "</p>\n")
+		case mdHeading:
+			tag := "h" + strconv.Itoa(block.level)
+			buf.WriteString("<" + tag + ">" + p.inline(strings.TrimSpace(block.text)) + "</" + tag + ">\n")
+		case mdThematicBreak:
+			buf.WriteString("<hr />\n")
+		case mdCode:
+			buf.WriteString("<pre><code")
+			if block.info != "" {
+				buf.WriteString(` class="language-` + html.EscapeString(block.info) + `"`)
+			}
+			buf.WriteString(">")
+			if block.text != "" {
+				buf.WriteString(html.EscapeString(block.text) + "\n")
+			}
+			buf.WriteString("</code></pre>\n")
+		case mdHTML:
+			buf.WriteString(block.text + "\n")
+		case mdBlockquote:
+			buf.WriteString("<blockquote>\n")
+			p.render(buf, block.children, false)
+			buf.WriteString("</blockquote>\n")
+		case mdList:
+			tag := "ul"
+			if block.ordered {
+				tag = "ol"
+			}
+			buf.WriteString("<" + tag)
+			if block.ordered && block.start != 1 {
+				buf.WriteString(` start="` + strconv.Itoa(block.start) + `"`)
+			}
+			buf.WriteString(">\n")
+			for _, item := range block.children {
+				buf.WriteString("<li>")
+				p.render(buf, item.children, block.tight)
+				buf.WriteString("</li>\n")
+			}
+			buf.WriteString("</" + tag + ">\n")
+		case mdTable:
+			buf.WriteString("<table>\n<thead>\n")
+			for r, row := range block.rows {
+				cellTag := "td"
+				if r == 0 {
+					cellTag = "th"
+				}
+				if r == 1 {
+					buf.WriteString("<tbody>\n")
+				}
+				buf.WriteString("<tr>\n")
+				for c, cell := range row {
+					buf.WriteString("<" + cellTag)
+					if block.align[c] != "" {
+						buf.WriteString(` align="` + block.align[c] + `"`)
+					}
+					buf.WriteString(">" + p.inline(cell) + "</" + cellTag + ">\n")
+				}
+				buf.WriteString("</tr>\n")
+				if r == 0 {
+					buf.WriteString("</thead>\n")
+				}
+			}
+			if len(block.rows) > 1 {
+				buf.WriteString("</tbody>\n")
+			}
+			buf.WriteString("</table>\n")
+		}
+	}
+}
+
+// An inline element during parsing: literal text, a run of emphasis
+// delimiters, or finished HTML.
+type mdInline struct {
+	text     string // Literal text, or HTML if isHTML
+	isHTML   bool
+	delim    byte // '*', '_' or '~' for delimiter runs
+	count    int
+	canOpen  bool
+	canClose bool
+	bracket  bool // '[' or '![' awaiting its ']'
+	image    bool
+	active   bool // Brackets: whether this can still start a link
+}
+
+// Renders a paragraph's or heading's inline content.
+func (p *mdParser) inline(text string) string {
+	text = strings.TrimRight(text, " ")
+	var nodes []*mdInline
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			nodes = append(nodes, &mdInline{text: plain.String()})
+			plain.Reset()
+		}
+	}
+	addHTML := func(s string) {
+		flush()
+		nodes = append(nodes, &mdInline{text: s, isHTML: true})
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			addHTML("<br />\n")
+			i += 2
+		case c == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			plain.WriteByte(text[i+1])
+			i += 2
+		case c == '\n':
+			// Two or more trailing spaces make a hard break
+			trimmed := strings.TrimRight(plain.String(), " ")
+			if plain.Len()-len(trimmed) >= 2 {
+				plain.Reset()
+				plain.WriteString(trimmed)
+				addHTML("<br />\n")
+			} else {
+				plain.Reset()
+				plain.WriteString(trimmed)
+				plain.WriteByte('\n')
+			}
+			i++
+			for i < len(text) && text[i] == ' ' {
+				i++
+			}
+		case c == '`':
+			run := i
+			for run < len(text) && text[run] == '`' {
+				run++
+			}
+			ticks := text[i:run]
+			end := findClosingBackticks(text, run, len(ticks))
+			if end < 0 {
+				plain.WriteString(ticks)
+				i = run
+				continue
+			}
+			code := strings.ReplaceAll(text[run:end], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			addHTML("<code>" + html.EscapeString(code) + "</code>")
+			i = end + len(ticks)
+		case c == '&':
+			if m := mdEntityRegex.FindString(text[i:]); m != "" {
+				plain.WriteString(html.UnescapeString(m))
+				i += len(m)
+				continue
+			}
+			plain.WriteByte(c)
+			i++
+		case c == '<':
+			if m := mdAutolinkRegex.FindStringSubmatch(text[i:]); m != nil {
+				addHTML(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + `</a>`)
+				i += len(m[0])
+				continue
+			}
+			if m := mdEmailRegex.FindStringSubmatch(text[i:]); m != nil {
+				addHTML(`<a href="mailto:` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + `</a>`)
+				i += len(m[0])
+				continue
+			}
+			if m := mdInlineHTMLRegex.FindString(text[i:]); m != "" {
+				addHTML(m)
+				i += len(m)
+				continue
+			}
+			plain.WriteByte(c)
+			i++
+		case c == '*' || c == '_' || c == '~':
+			run := i
+			for run < len(text) && text[run] == c {
+				run++
+			}
+			before, _ := utf8.DecodeLastRuneInString(text[:i])
+			after, _ := utf8.DecodeRuneInString(text[run:])
+			if i == 0 {
+				before = ' '
+			}
+			if run == len(text) {
+				after = ' '
+			}
+			flush()
+			nodes = append(nodes, newDelimiterRun(c, run-i, before, after))
+			i = run
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			flush()
+			nodes = append(nodes, &mdInline{text: "![", bracket: true, image: true, active: true})
+			i += 2
+		case c == '[':
+			flush()
+			nodes = append(nodes, &mdInline{text: "[", bracket: true, active: true})
+			i++
+		case c == ']':
+			flush()
+			var consumed int
+			nodes, consumed = p.closeBracket(nodes, text[i+1:])
+			i += 1 + consumed
+		default:
+			plain.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	nodes = processEmphasis(nodes)
+	return renderInlines(nodes)
+}
+
+func newDelimiterRun(c byte, count int, before, after rune) *mdInline {
+	beforeSpace, afterSpace := unicode.IsSpace(before), unicode.IsSpace(after)
+	beforePunct, afterPunct := unicode.IsPunct(before) || unicode.IsSymbol(before), unicode.IsPunct(after) || unicode.IsSymbol(after)
+	leftFlanking := !afterSpace && (!afterPunct || beforeSpace || beforePunct)
+	rightFlanking := !beforeSpace && (!beforePunct || afterSpace || afterPunct)
+
+	run := &mdInline{text: strings.Repeat(string(c), count), delim: c, count: count}
+	switch c {
+	case '_':
+		run.canOpen = leftFlanking && (!rightFlanking || beforePunct)
+		run.canClose = rightFlanking && (!leftFlanking || afterPunct)
+	case '~':
+		// Only ~~ strikes through; single tildes are literal
+		run.canOpen, run.canClose = leftFlanking && count == 2, rightFlanking && count == 2
+	default:
+		run.canOpen, run.canClose = leftFlanking, rightFlanking
+	}
+	return run
+}
+
+func findClosingBackticks(text string, from, n int) int {
+	for i := from; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := i
+		for run < len(text) && text[run] == '`' {
+			run++
+		}
+		if run-i == n {
+			return i
+		}
+		i = run
+	}
+	return -1
+}
+
+// Handles a ']' by turning the span since the matching '[' into a link or
+// image if a destination or reference follows. Returns the new node list and
+// how many bytes of rest were used.
+func (p *mdParser) closeBracket(nodes []*mdInline, rest string) ([]*mdInline, int) {
+	opener := -1
+	for j := len(nodes) - 1; j >= 0; j-- {
+		if nodes[j].bracket {
+			opener = j
+			break
+		}
+	}
+	if opener < 0 {
+		return append(nodes, &mdInline{text: "]"}), 0
+	}
+	open := nodes[opener]
+	if !open.active {
+		open.bracket = false
+		return append(nodes, &mdInline{text: "]"}), 0
+	}
+
+	label := renderPlainText(nodes[opener+1:])
+	dest, title, consumed, ok := parseInlineLink(rest)
+	if !ok {
+		// Full [text][ref], collapsed [text][], or shortcut [text] reference
+		refLabel := label
+		consumed = 0
+		if strings.HasPrefix(rest, "[]") {
+			consumed = 2
+		} else if strings.HasPrefix(rest, "[") {
+			if end := strings.IndexByte(rest, ']'); end > 1 {
+				refLabel, consumed = rest[1:end], end+1
+			}
+		}
+		ref, found := p.refs[normalizeLinkLabel(refLabel)]
+		if !found {
+			open.bracket = false
+			return append(nodes, &mdInline{text: "]"}), 0
+		}
+		dest, title = ref.dest, ref.title
+	}
+
+	inner := processEmphasis(nodes[opener+1:])
+	var out string
+	titleAttr := ""
+	if title != "" {
+		titleAttr = ` title="` + html.EscapeString(title) + `"`
+	}
+	if open.image {
+		out = `<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(renderPlainText(inner)) + `"` + titleAttr + ` />`
+	} else {
+		out = `<a href="` + html.EscapeString(dest) + `"` + titleAttr + `>` + renderInlines(inner) + `</a>`
+		// Links can't contain links
+		for _, n := range nodes[:opener] {
+			if n.bracket && !n.image {
+				n.active = false
+			}
+		}
+	}
+	nodes = append(nodes[:opener], &mdInline{text: out, isHTML: true})
+	return nodes, consumed
+}
+
+// Parses "(dest "title")" at the start of s.
+func parseInlineLink(s string) (dest, title string, consumed int, ok bool) {
+	if !strings.HasPrefix(s, "(") {
+		return "", "", 0, false
+	}
+	i := 1
+	skipSpace := func() {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+			i++
+		}
+	}
+	skipSpace()
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], ">\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		start, depth := i, 0
+		for i < len(s) && s[i] > ' ' {
+			if s[i] == '\\' && i+1 < len(s) {
+				i += 2
+				continue
+			}
+			if s[i] == '(' {
+				depth++
+			} else if s[i] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+			i++
+		}
+		dest = s[start:i]
+	}
+	skipSpace()
+	if i < len(s) && (s[i] == '"' || s[i] == '\'' || s[i] == '(') {
+		closer := s[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := i + 1
+		for end < len(s) && s[end] != closer {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return "", "", 0, false
+		}
+		title = s[i+1 : end]
+		i = end + 1
+		skipSpace()
+	}
+	if i >= len(s) || s[i] != ')' {
+		return "", "", 0, false
+	}
+	return unescapeMarkdown(dest), unescapeMarkdown(title), i + 1, true
+}
+
+// Matches emphasis delimiters, innermost first, following CommonMark's
+// flanking rules, and collapses each matched span into HTML.
+func processEmphasis(nodes []*mdInline) []*mdInline {
+	for closer := 0; closer < len(nodes); closer++ {
+		c := nodes[closer]
+		for c.delim != 0 && c.canClose && c.count > 0 {
+			opener := -1
+			for j := closer - 1; j >= 0; j-- {
+				o := nodes[j]
+				if o.delim != c.delim || !o.canOpen || o.count == 0 {
+					continue
+				}
+				// The "rule of 3"
+				if (o.canClose || c.canOpen) && (o.count+c.count)%3 == 0 && !(o.count%3 == 0 && c.count%3 == 0) {
+					continue
+				}
+				opener = j
+				break
+			}
+			if opener < 0 {
+				break
+			}
+
+			o := nodes[opener]
+			use, tag := 1, "em"
+			switch {
+			case c.delim == '~':
+				use, tag = 2, "del"
+			case o.count >= 2 && c.count >= 2:
+				use, tag = 2, "strong"
+			}
+			span := &mdInline{text: "<" + tag + ">" + renderInlines(nodes[opener+1:closer]) + "</" + tag + ">", isHTML: true}
+			o.count -= use
+			c.count -= use
+			o.text, c.text = o.text[:o.count], c.text[:c.count]
+
+			rebuilt := append([]*mdInline{}, nodes[:opener+1]...)
+			rebuilt = append(rebuilt, span)
+			nodes = append(rebuilt, nodes[closer:]...)
+			closer = opener + 2
+		}
+	}
+	return nodes
+}
+
+func renderInlines(nodes []*mdInline) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		if n.isHTML {
+			b.WriteString(n.text)
+			continue
+		}
+		b.WriteString(html.EscapeString(n.text))
+	}
+	return b.String()
+}
+
+// Returns the text of nodes without markup, for image descriptions and
+// link labels.
+func renderPlainText(nodes []*mdInline) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		if n.isHTML {
+			b.WriteString(htmlTagRegex.ReplaceAllString(html.UnescapeString(n.text), ""))
+			continue
+		}
+		b.WriteString(n.text)
+	}
+	return b.String()
+}
+
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+func unescapeMarkdown(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			b.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if s[i] == '&' {
+			if m := mdEntityRegex.FindString(s[i:]); m != "" {
+				b.WriteString(html.UnescapeString(m))
+				i += len(m) - 1
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
@@ -0,0 +1,178 @@
+package conversion
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{
+			name: "ATX headings",
+			md:   "# One\n## Two ##\n###### Six\n####### Seven",
+			want: "<h1>One</h1>\n<h2>Two</h2>\n<h6>Six</h6>\n<p>####### Seven</p>\n",
+		},
+		{
+			name: "setext headings",
+			md:   "Title\n=====\n\nSubtitle\n---",
+			want: "<h1>Title</h1>\n<h2>Subtitle</h2>\n",
+		},
+		{
+			name: "paragraphs and emphasis",
+			md:   "Some *em*, **strong** and ~~gone~~ text.\n\nSecond_para_graph.",
+			want: "<p>Some <em>em</em>, <strong>strong</strong> and <del>gone</del> text.</p>\n<p>Second_para_graph.</p>\n",
+		},
+		{
+			name: "tight bullet list",
+			md:   "- one\n- two\n- three",
+			want: "<ul>\n<li>one\n</li>\n<li>two\n</li>\n<li>three\n</li>\n</ul>\n",
+		},
+		{
+			name: "loose ordered list with start",
+			md:   "3. three\n\n4. four",
+			want: "<ol start=\"3\">\n<li><p>three</p>\n</li>\n<li><p>four</p>\n</li>\n</ol>\n",
+		},
+		{
+			name: "nested list",
+			md:   "- outer\n  - inner",
+			want: "<ul>\n<li>outer\n<ul>\n<li>inner\n</li>\n</ul>\n</li>\n</ul>\n",
+		},
+		{
+			name: "fenced code with info string",
+			md:   "```go\nif a < b {\n\treturn\n}\n```",
+			want: "<pre><code class=\"language-go\">if a &lt; b {\n    return\n}\n</code></pre>\n",
+		},
+		{
+			name: "unterminated fence runs to the end",
+			md:   "~~~\ncode\n\nmore",
+			want: "<pre><code>code\n\nmore\n</code></pre>\n",
+		},
+		{
+			name: "indented code",
+			md:   "    x := 1\n    y := 2",
+			want: "<pre><code>x := 1\ny := 2\n</code></pre>\n",
+		},
+		{
+			name: "inline code",
+			md:   "Use `a<b` or `` ` ``.",
+			want: "<p>Use <code>a&lt;b</code> or <code>`</code>.</p>\n",
+		},
+		{
+			name: "GFM table with alignment",
+			md:   "| Name | Qty |\n|:-----|----:|\n| `a|b` | 1 |\n| c |",
+			want: "<table>\n<thead>\n<tr>\n<th align=\"left\">Name</th>\n<th align=\"right\">Qty</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td align=\"left\"><code>a|b</code></td>\n<td align=\"right\">1</td>\n</tr>\n" +
+				"<tr>\n<td align=\"left\">c</td>\n<td align=\"right\"></td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name: "table delimiter with wrong column count is a paragraph",
+			md:   "a | b\n--|--|--",
+			want: "<p>a | b\n--|--|--</p>\n",
+		},
+		{
+			name: "inline link with title",
+			md:   `[the *site*](https://example.com/a?b=1&c=2 "Title")`,
+			want: "<p><a href=\"https://example.com/a?b=1&amp;c=2\" title=\"Title\">the <em>site</em></a></p>\n",
+		},
+		{
+			name: "reference links",
+			md:   "[full][ref], [Ref][] and [ref].\n\n[ref]: <https://example.com/r> 'T'",
+			want: "<p><a href=\"https://example.com/r\" title=\"T\">full</a>, <a href=\"https://example.com/r\" title=\"T\">Ref</a> and <a href=\"https://example.com/r\" title=\"T\">ref</a>.</p>\n",
+		},
+		{
+			name: "image",
+			md:   `![a **cat**](cat.png)`,
+			want: "<p><img src=\"cat.png\" alt=\"a cat\" /></p>\n",
+		},
+		{
+			name: "autolinks",
+			md:   "<https://example.com> and <ada@example.com>",
+			want: "<p><a href=\"https://example.com\">https://example.com</a> and <a href=\"mailto:ada@example.com\">ada@example.com</a></p>\n",
+		},
+		{
+			name: "undefined reference stays literal",
+			md:   "[nothing] here",
+			want: "<p>[nothing] here</p>\n",
+		},
+		{
+			name: "blockquote with lazy continuation",
+			md:   "> quoted\nlazy\n\nafter",
+			want: "<blockquote>\n<p>quoted\nlazy</p>\n</blockquote>\n<p>after</p>\n",
+		},
+		{
+			name: "hard breaks and escapes",
+			md:   "one  \ntwo\\\nthree \\*not em\\*",
+			want: "<p>one<br />\ntwo<br />\nthree *not em*</p>\n",
+		},
+		{
+			name: "thematic break",
+			md:   "above\n\n* * *\n\nbelow",
+			want: "<p>above</p>\n<hr />\n<p>below</p>\n",
+		},
+		{
+			name: "raw HTML block passes through",
+			md:   "<div class=\"note\">\nhi\n</div>",
+			want: "<div class=\"note\">\nhi\n</div>\n",
+		},
+		{
+			name: "entities and CRLF line endings",
+			md:   "caf&eacute; &amp; bar\r\n\r\n2 < 3",
+			want: "<p>café &amp; bar</p>\n<p>2 &lt; 3</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(markdownToHTML([]byte(tt.md))); got != tt.want {
+				t.Errorf("markdownToHTML(%q)\n got: %q\nwant: %q", tt.md, got, tt.want)
+			}
+		})
+	}
+}
+
+// Markdown has no invalid input, but unbalanced or truncated syntax must not
+// panic.
+func TestMarkdownToHTMLMalformed(t *testing.T) {
+	inputs := []string{
+		"",
+		"[",
+		"]",
+		"![",
+		"[a](",
+		"[a](<b",
+		`[a](b "unterminated`,
+		"[a]: ",
+		"`",
+		"``` unterminated `info",
+		"***",
+		"**_~~",
+		"|",
+		"|\n|-",
+		"- ",
+		"1.",
+		"> ",
+		"\\",
+		"&#;",
+		"<",
+		strings.Repeat("[", 1000),
+		strings.Repeat("> ", 200) + "deep",
+		strings.Repeat("- ", 200) + "deep",
+		strings.Repeat("*a ", 500),
+		"\t\t\t- \t",
+		"\xff\xfe invalid utf-8 *\xff*",
+	}
+	for _, input := range inputs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("markdownToHTML(%q) panicked: %v", input, r)
+				}
+			}()
+			markdownToHTML([]byte(input))
+		}()
+	}
+}
@@ -0,0 +1,646 @@
+package conversion
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+)
+
+var (
+	// ErrPDFEncrypted is returned for encrypted PDFs, which aren't supported.
+	ErrPDFEncrypted = errors.New("encrypted PDFs are not supported")
+	// ErrPDFNoText is returned for PDFs without a text layer, such as scans.
+	ErrPDFNoText = errors.New("PDF has no extractable text")
+)
+
+// PDFContent is the reflowable content extracted from a PDF.
+type PDFContent struct {
+	Title  string                // From the document information, or else the first heading; may be empty
+	HTML   []byte                // Fragment of headings, paragraphs, lists and figures
+	Text   string                // Plain text, one paragraph per line
+	Images []models.ReadingImage // Figures, referenced from HTML by ReadingImage.Ref()
+}
+
+// Matches the bullet or number that starts a list item.
+var pdfListMarkerRegex = regexp.MustCompile(`^(?:([•◦▪▫●○■□‣⁃∙*–-])|(\d{1,3})[.)])\s+`)
+
+// Matches lines that are only a page number, such as "12", "- 12 -" or "Page 3 of 10".
+var pdfPageNumberRegex = regexp.MustCompile(`(?i)^[-–\s]*(?:page\s+)?(?:\d{1,4}|[ivxlc]{1,7})(?:\s*(?:of|/)\s*\d{1,4})?[-–\s]*$`)
+
+// Matches dot leaders, as in a table of contents.
+var pdfLeaderRegex = regexp.MustCompile(`(?:\s?[.:·…]){4,}\s?`)
+
+// Generic titles that PDF writers fill in for the author.
+var pdfPlaceholderTitleRegex = regexp.MustCompile(`(?i)^(untitled|microsoft word|document\d*|.*\.(docx?|pdf|indd|qxd|rtf|pages))$`)
+
+var pdfDigitsRegex = regexp.MustCompile(`\d+`)
+
+var pdfLigatureReplacer = strings.NewReplacer("ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", " ", " ")
+
+// ExtractPDF reads the text and figures of a PDF and lays them out again as
+// reflowable HTML. Lines are joined into paragraphs by their spacing and
+// indentation, larger type becomes headings, bulleted and numbered lines
+// become lists, and headers, footers and page numbers repeated across pages
+// are dropped. Text is taken in the order the PDF draws it, which for
+// almost all documents is reading order.
+func ExtractPDF(data []byte) (content *PDFContent, err error) {
+	defer func() {
+		// The parser trusts offsets and counts in the file; a corrupt one
+		// shouldn't take ingestion down with it.
+		if r := recover(); r != nil {
+			content, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.trailer["Encrypt"] != nil {
+		return nil, ErrPDFEncrypted
+	}
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF has no pages")
+	}
+
+	fonts := make(map[pdfRef]*pdfFont)
+	pageItems := make([][]pdfItem, 0, len(pages))
+	for _, page := range pages {
+		cr := newPDFContentReader(doc, fonts)
+		cr.run(doc.pageContent(page.dict), page.resources)
+		pageItems = append(pageItems, cr.items)
+	}
+
+	layout := newPDFLayout(doc, pageItems)
+	if layout.textLength == 0 {
+		return nil, ErrPDFNoText
+	}
+
+	content = layout.render()
+	content.Title = doc.title()
+	if content.Title == "" {
+		content.Title = layout.firstHeading
+	}
+	return content, nil
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// Returns the pages in order, with inherited resources resolved.
+func (d *pdfDocument) pages() []pdfPage {
+	catalog := d.dict(d.trailer["Root"])
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(node any, resources pdfDict, depth int)
+	walk = func(node any, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		dict := d.dict(node)
+		if dict == nil || depth > 64 {
+			return
+		}
+		if r := d.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+		if d.name(dict["Type"]) == "Page" || (dict["Kids"] == nil && dict["Contents"] != nil) {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+			return
+		}
+		for _, kid := range d.array(dict["Kids"]) {
+			walk(kid, resources, depth+1)
+		}
+	}
+	walk(catalog["Pages"], nil, 0)
+	return pages
+}
+
+// Returns a page's content streams, decoded and joined.
+func (d *pdfDocument) pageContent(page pdfDict) []byte {
+	var streams []*pdfStream
+	switch c := d.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		streams = append(streams, c)
+	case pdfArray:
+		for _, item := range c {
+			if s, ok := d.resolve(item).(*pdfStream); ok {
+				streams = append(streams, s)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, s := range streams {
+		data, err := d.decodeStream(s)
+		if err != nil {
+			log.Printf("WARN (PDFExtractor): Skipping undecodable content stream: %v", err)
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Returns the document's title from its information dictionary, unless it
+// is a placeholder such as a file name.
+func (d *pdfDocument) title() string {
+	raw, ok := d.resolve(d.dict(d.trailer["Info"])["Title"]).(pdfString)
+	if !ok {
+		return ""
+	}
+	title := strings.Join(strings.Fields(decodePDFTextString(raw)), " ")
+	title = strings.TrimPrefix(title, "Microsoft Word - ")
+	if pdfPlaceholderTitleRegex.MatchString(title) {
+		return ""
+	}
+	return title
+}
+
+// A line of text on a page, joined from the spans drawn along one baseline.
+type pdfLine struct {
+	text       string
+	x, y, endX float64
+	size       float64
+	bold       bool
+	page       int
+}
+
+type pdfBlockKind int
+
+const (
+	pdfParagraph pdfBlockKind = iota
+	pdfHeading
+	pdfListItem
+	pdfFigure
+)
+
+// A paragraph, heading, list item or figure of the output.
+type pdfBlock struct {
+	kind    pdfBlockKind
+	lines   []pdfLine
+	level   int  // Heading level
+	ordered bool // Numbered list item
+	image   *models.ReadingImage
+}
+
+type pdfLayout struct {
+	doc          *pdfDocument
+	blocks       []*pdfBlock
+	bodySize     float64
+	textLength   int
+	firstHeading string
+}
+
+// Either a line or an image, in page order.
+type pdfFlowItem struct {
+	line  *pdfLine
+	image *pdfImagePlacement
+}
+
+func newPDFLayout(doc *pdfDocument, pageItems [][]pdfItem) *pdfLayout {
+	layout := &pdfLayout{doc: doc}
+
+	pageFlows := make([][]pdfFlowItem, len(pageItems))
+	for page, items := range pageItems {
+		pageFlows[page] = buildPDFLines(page, items)
+	}
+	removeRunningHeaders(pageFlows)
+
+	var flow []pdfFlowItem
+	sizeChars := make(map[float64]int)
+	for _, pf := range pageFlows {
+		for _, item := range pf {
+			if item.line != nil {
+				n := len([]rune(item.line.text))
+				sizeChars[math.Round(item.line.size*2)/2] += n
+				layout.textLength += n
+			}
+			flow = append(flow, item)
+		}
+	}
+	for size, chars := range sizeChars {
+		if chars > sizeChars[layout.bodySize] || (chars == sizeChars[layout.bodySize] && size < layout.bodySize) {
+			layout.bodySize = size
+		}
+	}
+
+	layout.buildBlocks(flow, repeatedImages(pageItems))
+	layout.classifyHeadings()
+	return layout
+}
+
+// Joins spans that share a baseline and continue rightwards into lines.
+func buildPDFLines(page int, items []pdfItem) []pdfFlowItem {
+	var flow []pdfFlowItem
+	var cur *pdfLine
+	var last *pdfSpan
+	var sizeChars map[float64]int
+	var boldChars, chars int
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		cur.text = pdfLeaderRegex.ReplaceAllString(pdfLigatureReplacer.Replace(cur.text), " ")
+		cur.text = strings.Join(strings.Fields(cur.text), " ")
+		for size, n := range sizeChars {
+			if n > sizeChars[cur.size] || cur.size == 0 {
+				cur.size = size
+			}
+		}
+		cur.bold = boldChars*2 > chars
+		if cur.text != "" {
+			flow = append(flow, pdfFlowItem{line: cur})
+		}
+		cur = nil
+	}
+
+	for _, item := range items {
+		if item.image != nil {
+			flush()
+			flow = append(flow, pdfFlowItem{image: item.image})
+			continue
+		}
+		span := item.span
+		if last != nil && span.text == last.text && math.Abs(span.x-last.x) < 1 && math.Abs(span.y-last.y) < 1 {
+			continue // Drawn twice over itself, for a bold or shadow effect
+		}
+		last = span
+		if cur != nil && math.Abs(span.y-cur.y) <= 0.4*math.Max(span.size, cur.size) && span.x >= cur.endX-0.5*span.size {
+			gap := span.x - cur.endX
+			if gap > 0.15*span.size && !strings.HasSuffix(cur.text, " ") && !strings.HasPrefix(span.text, " ") {
+				cur.text += " "
+			}
+			cur.text += span.text
+			cur.endX = math.Max(cur.endX, span.endX)
+		} else {
+			flush()
+			cur = &pdfLine{text: span.text, x: span.x, y: span.y, endX: span.endX, page: page}
+			sizeChars = make(map[float64]int)
+			boldChars, chars = 0, 0
+		}
+		n := len([]rune(strings.TrimSpace(span.text)))
+		sizeChars[math.Round(span.size*2)/2] += n
+		chars += n
+		if span.bold {
+			boldChars += n
+		}
+	}
+	flush()
+	return flow
+}
+
+// Drops page numbers and running headers and footers: lines at the top or
+// bottom of a page that recur, digits aside, on at least half of the pages.
+func removeRunningHeaders(pageFlows [][]pdfFlowItem) {
+	const edgeLines = 2
+	// Running heads sit at the same height on every page, which also keeps a
+	// title page's title from counting as one.
+	key := func(l *pdfLine) string {
+		return fmt.Sprintf("%s@%d", strings.ToLower(pdfDigitsRegex.ReplaceAllString(l.text, "#")), int(math.Round(l.y/4)))
+	}
+
+	edges := func(flow []pdfFlowItem) []*pdfLine {
+		var lines []*pdfLine
+		for _, item := range flow {
+			if item.line != nil {
+				lines = append(lines, item.line)
+			}
+		}
+		if len(lines) <= 2*edgeLines {
+			return lines
+		}
+		return append(lines[:edgeLines:edgeLines], lines[len(lines)-edgeLines:]...)
+	}
+
+	counts := make(map[string]int)
+	for _, flow := range pageFlows {
+		seen := make(map[string]bool)
+		for _, l := range edges(flow) {
+			if k := key(l); !seen[k] {
+				seen[k] = true
+				counts[k]++
+			}
+		}
+	}
+
+	drop := make(map[*pdfLine]bool)
+	for _, flow := range pageFlows {
+		for _, l := range edges(flow) {
+			repeated := len(pageFlows) >= 3 && counts[key(l)]*2 >= len(pageFlows)
+			if repeated || pdfPageNumberRegex.MatchString(l.text) {
+				drop[l] = true
+			}
+		}
+	}
+	for i, flow := range pageFlows {
+		kept := flow[:0]
+		for _, item := range flow {
+			if item.line == nil || !drop[item.line] {
+				kept = append(kept, item)
+			}
+		}
+		pageFlows[i] = kept
+	}
+}
+
+// Returns the images drawn on at least half of the pages of a document of
+// three or more, such as logos in the page header.
+func repeatedImages(pageItems [][]pdfItem) map[int]bool {
+	repeated := make(map[int]bool)
+	if len(pageItems) < 3 {
+		return repeated
+	}
+	counts := make(map[int]int)
+	for _, items := range pageItems {
+		seen := make(map[int]bool)
+		for _, item := range items {
+			if item.image != nil && item.image.objNum != 0 && !seen[item.image.objNum] {
+				seen[item.image.objNum] = true
+				counts[item.image.objNum]++
+			}
+		}
+	}
+	for num, count := range counts {
+		if count*2 >= len(pageItems) {
+			repeated[num] = true
+		}
+	}
+	return repeated
+}
+
+// Groups lines into blocks. A new block starts at a change of type size or
+// weight, a list marker, a gap wider than the block's line spacing, a
+// first-line indent, or after a line that ends a sentence well short of the
+// block's right edge. Paragraphs continue across page and column breaks
+// when the text plainly runs on.
+func (layout *pdfLayout) buildBlocks(flow []pdfFlowItem, repeated map[int]bool) {
+	seenImages := make(map[string]bool)
+	var cur *pdfBlock
+	var left, right, lineGap float64
+
+	for _, item := range flow {
+		if item.image != nil {
+			if image := layout.figure(item.image, repeated, seenImages); image != nil {
+				cur = nil
+				layout.blocks = append(layout.blocks, &pdfBlock{kind: pdfFigure, image: image})
+			}
+			continue
+		}
+
+		line := *item.line
+		marker := pdfListMarkerRegex.FindStringSubmatch(line.text)
+		if cur != nil && marker == nil && layout.continuesBlock(cur, line, left, right, lineGap) {
+			prev := cur.lines[len(cur.lines)-1]
+			if len(cur.lines) == 1 && line.page == prev.page && prev.y > line.y {
+				lineGap = prev.y - line.y
+			}
+			cur.lines = append(cur.lines, line)
+			left = math.Min(left, line.x)
+			right = math.Max(right, line.endX)
+			continue
+		}
+
+		cur = &pdfBlock{kind: pdfParagraph}
+		if marker != nil {
+			cur.kind = pdfListItem
+			cur.ordered = marker[2] != ""
+			line.text = line.text[len(marker[0]):]
+		}
+		cur.lines = []pdfLine{line}
+		left, right, lineGap = line.x, line.endX, 0
+		layout.blocks = append(layout.blocks, cur)
+	}
+}
+
+func (layout *pdfLayout) continuesBlock(block *pdfBlock, line pdfLine, left, right, lineGap float64) bool {
+	prev := block.lines[len(block.lines)-1]
+	first := block.lines[0]
+	size := math.Max(line.size, prev.size)
+
+	if math.Abs(line.size-first.size) > 0.1*first.size || line.bold != first.bold {
+		return false
+	}
+
+	// Page or column break: carry on only mid-sentence
+	if line.page != prev.page || line.y >= prev.y {
+		return !endsSentence(prev.text) && startsLowercase(line.text)
+	}
+
+	gap := prev.y - line.y
+	expected := lineGap
+	if expected == 0 {
+		expected = prev.size * 1.3
+	}
+	if gap > expected*1.35+0.5 {
+		return false
+	}
+	if block.kind != pdfListItem {
+		if line.x > left+0.8*size && math.Abs(prev.x-left) < 0.5*size {
+			return false // First-line indent
+		}
+	}
+	if line.x < left-size {
+		// Outdented. Only the first line of a paragraph may be indented past
+		// the rest, and it then runs on to the right margin or mid-sentence.
+		runsOn := prev.endX >= line.endX-size || (!endsSentence(prev.text) && !strings.HasSuffix(prev.text, ";"))
+		if len(block.lines) >= 2 || !runsOn {
+			return false
+		}
+	}
+	if len(block.lines) >= 2 && endsSentence(prev.text) && prev.endX < right-4*size {
+		return false
+	}
+	return true
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, `"'”’)]`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") ||
+		strings.HasSuffix(text, "!") || strings.HasSuffix(text, ":")
+}
+
+func startsLowercase(text string) bool {
+	for _, r := range text {
+		return unicode.IsLower(r)
+	}
+	return false
+}
+
+// Decodes an image for a figure block. Returns nil for decorations, logos
+// repeated across pages, images already shown, and unsupported encodings.
+func (layout *pdfLayout) figure(placement *pdfImagePlacement, repeated map[int]bool, seen map[string]bool) *models.ReadingImage {
+	if repeated[placement.objNum] || placement.width < minPDFImageSize || placement.height < minPDFImageSize {
+		return nil
+	}
+	pxWidth, _ := layout.doc.number(placement.stream.dict["Width"])
+	pxHeight, _ := layout.doc.number(placement.stream.dict["Height"])
+	if pxWidth < minPDFImageSize || pxHeight < minPDFImageSize {
+		return nil
+	}
+
+	contentType, data, err := layout.doc.imageData(placement.stream)
+	if err != nil {
+		log.Printf("WARN (PDFExtractor): Skipping image object %d: %v", placement.objNum, err)
+		return nil
+	}
+	hash, err := webutil.GenerateHash(string(data))
+	if err != nil || seen[hash] {
+		return nil
+	}
+	seen[hash] = true
+	return &models.ReadingImage{ContentHash: hash, ContentType: contentType, Data: data}
+}
+
+// Marks blocks set in type noticeably larger than the body text as headings,
+// h2 for the largest size down to h4, as are short lines set entirely in
+// bold at body size.
+func (layout *pdfLayout) classifyHeadings() {
+	var sizes []float64
+	for _, b := range layout.blocks {
+		if layout.isLargeHeading(b) && !containsSize(sizes, b.lines[0].size) {
+			sizes = append(sizes, b.lines[0].size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+
+	for _, b := range layout.blocks {
+		switch {
+		case layout.isLargeHeading(b):
+			b.kind = pdfHeading
+			b.level = 2
+			for i, size := range sizes {
+				if math.Abs(size-b.lines[0].size) < 0.5 {
+					b.level = min(2+i, 4)
+				}
+			}
+		case b.kind == pdfParagraph && len(b.lines) == 1 && b.lines[0].bold &&
+			len(b.lines[0].text) <= 120 && !strings.ContainsAny(lastRune(b.lines[0].text), ".,;"):
+			b.kind = pdfHeading
+			b.level = 4
+		default:
+			continue
+		}
+		if layout.firstHeading == "" {
+			layout.firstHeading = joinPDFLines(b.lines)
+		}
+	}
+}
+
+func (layout *pdfLayout) isLargeHeading(b *pdfBlock) bool {
+	if b.kind == pdfFigure || len(b.lines) > 4 {
+		return false
+	}
+	return b.lines[0].size >= layout.bodySize*1.18 && len(joinPDFLines(b.lines)) <= 200
+}
+
+func containsSize(sizes []float64, size float64) bool {
+	for _, s := range sizes {
+		if math.Abs(s-size) < 0.5 {
+			return true
+		}
+	}
+	return false
+}
+
+func lastRune(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return ""
+	}
+	return string(r[len(r)-1])
+}
+
+// Joins the lines of a block, undoing hyphenation at line ends.
+func joinPDFLines(lines []pdfLine) string {
+	var sb strings.Builder
+	for i, l := range lines {
+		text := l.text
+		if i > 0 {
+			prev := []rune(lines[i-1].text)
+			hyphenated := len(prev) >= 2 && (prev[len(prev)-1] == '-' || prev[len(prev)-1] == '­') &&
+				unicode.IsLetter(prev[len(prev)-2]) && startsLowercase(text)
+			if hyphenated {
+				s := sb.String()
+				sb.Reset()
+				sb.WriteString(strings.TrimSuffix(strings.TrimSuffix(s, "-"), "­"))
+			} else {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+func (layout *pdfLayout) render() *PDFContent {
+	content := &PDFContent{}
+	var out, text strings.Builder
+	openList := ""
+
+	closeList := func() {
+		if openList != "" {
+			out.WriteString("</" + openList + ">\n")
+			openList = ""
+		}
+	}
+
+	out.WriteString("<div>\n")
+	for _, b := range layout.blocks {
+		if b.kind != pdfListItem {
+			closeList()
+		}
+		if b.kind == pdfFigure {
+			content.Images = append(content.Images, *b.image)
+			fmt.Fprintf(&out, "<figure><img src=\"%s\" alt=\"\"/></figure>\n", b.image.Ref())
+			continue
+		}
+
+		blockText := joinPDFLines(b.lines)
+		escaped := html.EscapeString(blockText)
+		text.WriteString(blockText)
+		text.WriteString("\n")
+
+		switch b.kind {
+		case pdfHeading:
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", b.level, escaped, b.level)
+		case pdfListItem:
+			tag := "ul"
+			if b.ordered {
+				tag = "ol"
+			}
+			if openList != tag {
+				closeList()
+				out.WriteString("<" + tag + ">\n")
+				openList = tag
+			}
+			fmt.Fprintf(&out, "<li>%s</li>\n", escaped)
+		default:
+			fmt.Fprintf(&out, "<p>%s</p>\n", escaped)
+		}
+	}
+	closeList()
+	out.WriteString("</div>\n")
+
+	content.HTML = []byte(out.String())
+	content.Text = text.String()
+	return content
+}
@@ -0,0 +1,339 @@
+package conversion
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// Affine transform [a b c d e f], as in the PDF "cm" operator.
+type pdfMatrix [6]float64
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// Returns m followed by n.
+func (m pdfMatrix) mul(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// A run of text drawn by one text-showing operation, in page coordinates
+// (points, origin at the bottom left).
+type pdfSpan struct {
+	text   string
+	x, y   float64 // Start of the baseline
+	endX   float64
+	size   float64 // Effective font size
+	bold   bool
+	italic bool
+}
+
+// An image drawn on a page.
+type pdfImagePlacement struct {
+	objNum        int
+	stream        *pdfStream
+	width, height float64 // Drawn size in points
+}
+
+// Text and images in the order the page draws them. Exactly one field is set.
+type pdfItem struct {
+	span  *pdfSpan
+	image *pdfImagePlacement
+}
+
+type pdfGraphicsState struct {
+	ctm       pdfMatrix
+	font      *pdfFont
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	hScale    float64
+	leading   float64
+	rise      float64
+}
+
+// Runs a page's content streams, collecting the text and images it draws.
+// Only the operators that affect text position and XObjects are interpreted.
+type pdfContentReader struct {
+	doc       *pdfDocument
+	fonts     map[pdfRef]*pdfFont
+	items     []pdfItem
+	gs        pdfGraphicsState
+	stack     []pdfGraphicsState
+	tm, tlm   pdfMatrix
+	formDepth int
+}
+
+// Guards against forms that draw themselves.
+const maxPDFFormDepth = 8
+
+func newPDFContentReader(doc *pdfDocument, fonts map[pdfRef]*pdfFont) *pdfContentReader {
+	return &pdfContentReader{
+		doc:   doc,
+		fonts: fonts,
+		gs:    pdfGraphicsState{ctm: pdfIdentity, font: pdfFallbackFont, hScale: 1},
+		tm:    pdfIdentity,
+		tlm:   pdfIdentity,
+	}
+}
+
+func (cr *pdfContentReader) run(content []byte, resources pdfDict) {
+	l := &pdfLexer{data: content}
+	var operands []any
+	for {
+		obj, err := l.readObject(false)
+		if err != nil {
+			return
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		if op == "BI" {
+			skipInlineImage(l)
+		} else {
+			cr.execute(string(op), operands, resources)
+		}
+		operands = nil
+	}
+}
+
+// Moves past inline image data (BI ... ID data EI), which isn't tokenizable.
+func skipInlineImage(l *pdfLexer) {
+	idx := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if idx < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += idx + 3
+	for l.pos < len(l.data) {
+		idx := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if idx < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		end := l.pos + idx
+		l.pos = end + 2
+		if isPDFSpace(l.data[end-1]) && (l.pos >= len(l.data) || isPDFSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+func operandNumber(operands []any, i int) float64 {
+	if i >= len(operands) {
+		return 0
+	}
+	return toFloat(operands[i])
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func (cr *pdfContentReader) execute(op string, operands []any, resources pdfDict) {
+	gs := &cr.gs
+	switch op {
+	case "q":
+		cr.stack = append(cr.stack, cr.gs)
+	case "Q":
+		if n := len(cr.stack); n > 0 {
+			cr.gs = cr.stack[n-1]
+			cr.stack = cr.stack[:n-1]
+		}
+	case "cm":
+		if len(operands) == 6 {
+			var m pdfMatrix
+			for i := range m {
+				m[i] = operandNumber(operands, i)
+			}
+			gs.ctm = m.mul(gs.ctm)
+		}
+
+	case "BT":
+		cr.tm, cr.tlm = pdfIdentity, pdfIdentity
+	case "Tf":
+		if len(operands) == 2 {
+			if name, ok := operands[0].(pdfName); ok {
+				gs.font = cr.font(resources, name)
+			}
+			gs.fontSize = operandNumber(operands, 1)
+		}
+	case "Tc":
+		gs.charSpace = operandNumber(operands, 0)
+	case "Tw":
+		gs.wordSpace = operandNumber(operands, 0)
+	case "Tz":
+		gs.hScale = operandNumber(operands, 0) / 100
+	case "TL":
+		gs.leading = operandNumber(operands, 0)
+	case "Ts":
+		gs.rise = operandNumber(operands, 0)
+	case "Td":
+		cr.moveText(operandNumber(operands, 0), operandNumber(operands, 1))
+	case "TD":
+		gs.leading = -operandNumber(operands, 1)
+		cr.moveText(operandNumber(operands, 0), operandNumber(operands, 1))
+	case "Tm":
+		if len(operands) == 6 {
+			for i := range cr.tm {
+				cr.tm[i] = operandNumber(operands, i)
+			}
+			cr.tlm = cr.tm
+		}
+	case "T*":
+		cr.moveText(0, -gs.leading)
+
+	case "Tj":
+		if len(operands) == 1 {
+			cr.showText(operands[0])
+		}
+	case "'":
+		cr.moveText(0, -gs.leading)
+		if len(operands) == 1 {
+			cr.showText(operands[0])
+		}
+	case "\"":
+		if len(operands) == 3 {
+			gs.wordSpace = operandNumber(operands, 0)
+			gs.charSpace = operandNumber(operands, 1)
+			cr.moveText(0, -gs.leading)
+			cr.showText(operands[2])
+		}
+	case "TJ":
+		if len(operands) == 1 {
+			arr, _ := operands[0].(pdfArray)
+			for _, item := range arr {
+				if _, ok := item.(pdfString); ok {
+					cr.showText(item)
+					continue
+				}
+				// Adjustments are in thousandths of text space, subtracted
+				cr.advance(-toFloat(item) / 1000 * gs.fontSize * gs.hScale)
+			}
+		}
+
+	case "Do":
+		if len(operands) == 1 {
+			if name, ok := operands[0].(pdfName); ok {
+				cr.drawXObject(resources, name)
+			}
+		}
+	}
+}
+
+func (cr *pdfContentReader) moveText(tx, ty float64) {
+	cr.tlm = pdfMatrix{1, 0, 0, 1, tx, ty}.mul(cr.tlm)
+	cr.tm = cr.tlm
+}
+
+func (cr *pdfContentReader) advance(tx float64) {
+	cr.tm = pdfMatrix{1, 0, 0, 1, tx, 0}.mul(cr.tm)
+}
+
+func (cr *pdfContentReader) showText(obj any) {
+	s, ok := obj.(pdfString)
+	if !ok || len(s) == 0 {
+		return
+	}
+	gs := &cr.gs
+	trm := pdfMatrix{gs.fontSize * gs.hScale, 0, 0, gs.fontSize, 0, gs.rise}.mul(cr.tm).mul(gs.ctm)
+	span := &pdfSpan{
+		x:      trm[4],
+		y:      trm[5],
+		size:   math.Hypot(trm[2], trm[3]),
+		bold:   gs.font.bold,
+		italic: gs.font.italic,
+	}
+
+	var text strings.Builder
+	for _, glyph := range gs.font.decode(s) {
+		text.WriteString(glyph.text)
+		tx := glyph.width*gs.fontSize + gs.charSpace
+		if glyph.space {
+			tx += gs.wordSpace
+		}
+		cr.advance(tx * gs.hScale)
+	}
+	end := pdfMatrix{1, 0, 0, 1, 0, gs.rise}.mul(cr.tm).mul(gs.ctm)
+	span.endX = end[4]
+	span.text = text.String()
+
+	if strings.TrimSpace(span.text) != "" && span.size > 0 {
+		cr.items = append(cr.items, pdfItem{span: span})
+	}
+}
+
+func (cr *pdfContentReader) font(resources pdfDict, name pdfName) *pdfFont {
+	ref := cr.doc.dict(resources["Font"])[name]
+	key, isRef := ref.(pdfRef)
+	if !isRef {
+		return cr.doc.loadFont(ref) // Direct font dictionaries are rare; not cached
+	}
+	if font, ok := cr.fonts[key]; ok {
+		return font
+	}
+	font := cr.doc.loadFont(ref)
+	cr.fonts[key] = font
+	return font
+}
+
+func (cr *pdfContentReader) drawXObject(resources pdfDict, name pdfName) {
+	ref := cr.doc.dict(resources["XObject"])[name]
+	stream, ok := cr.doc.resolve(ref).(*pdfStream)
+	if !ok {
+		return
+	}
+
+	switch cr.doc.name(stream.dict["Subtype"]) {
+	case "Image":
+		r, _ := ref.(pdfRef)
+		ctm := cr.gs.ctm
+		cr.items = append(cr.items, pdfItem{image: &pdfImagePlacement{
+			objNum: r.num,
+			stream: stream,
+			width:  math.Hypot(ctm[0], ctm[1]),
+			height: math.Hypot(ctm[2], ctm[3]),
+		}})
+
+	case "Form":
+		if cr.formDepth >= maxPDFFormDepth {
+			return
+		}
+		content, err := cr.doc.decodeStream(stream)
+		if err != nil {
+			return
+		}
+		formResources := cr.doc.dict(stream.dict["Resources"])
+		if formResources == nil {
+			formResources = resources
+		}
+
+		saved, savedTM, savedTLM, savedDepth := cr.gs, cr.tm, cr.tlm, len(cr.stack)
+		if m := cr.doc.array(stream.dict["Matrix"]); len(m) == 6 {
+			var matrix pdfMatrix
+			for i := range matrix {
+				matrix[i], _ = cr.doc.number(m[i])
+			}
+			cr.gs.ctm = matrix.mul(cr.gs.ctm)
+		}
+		cr.formDepth++
+		cr.run(content, formResources)
+		cr.formDepth--
+		cr.gs, cr.tm, cr.tlm = saved, savedTM, savedTLM
+		cr.stack = cr.stack[:min(savedDepth, len(cr.stack))]
+	}
+}
@@ -0,0 +1,417 @@
+package conversion
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// A font as far as text extraction needs it: how to split a string into
+// character codes, what text each code stands for, and how far it advances.
+type pdfFont struct {
+	codeBytes    int                // Bytes per character code: 1 for simple fonts, usually 2 for composite ones
+	toUnicode    map[uint32]string  // From the font's ToUnicode CMap, if any
+	encoding     *[256]rune         // Simple fonts: code to character
+	widths       map[uint32]float64 // Glyph widths in glyph space units
+	defaultWidth float64
+	scale        float64 // Glyph space to text space; 1/1000 except for Type3 fonts
+	bold         bool
+	italic       bool
+}
+
+// One decoded character code.
+type pdfGlyph struct {
+	text  string
+	width float64 // Advance in text space units, before scaling by font size
+	space bool    // Single-byte code 32, which word spacing applies to
+}
+
+func (f *pdfFont) decode(s []byte) []pdfGlyph {
+	glyphs := make([]pdfGlyph, 0, len(s)/f.codeBytes)
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		code := bytesToCode(s[i : i+f.codeBytes])
+		text, ok := f.toUnicode[code]
+		if !ok && f.encoding != nil && code < 256 {
+			if r := f.encoding[code]; r != 0 {
+				text = string(r)
+			}
+		}
+		width, ok := f.widths[code]
+		if !ok {
+			width = f.defaultWidth
+		}
+		glyphs = append(glyphs, pdfGlyph{
+			text:  text,
+			width: width * f.scale,
+			space: f.codeBytes == 1 && code == 32,
+		})
+	}
+	return glyphs
+}
+
+// Fallback used when a font can't be read, so its text still advances.
+var pdfFallbackFont = &pdfFont{codeBytes: 1, encoding: &winAnsiEncoding, defaultWidth: 500, scale: 0.001}
+
+func (d *pdfDocument) loadFont(obj any) *pdfFont {
+	dict := d.dict(obj)
+	if dict == nil {
+		return pdfFallbackFont
+	}
+
+	font := &pdfFont{codeBytes: 1, defaultWidth: 500, scale: 0.001}
+	subtype := d.name(dict["Subtype"])
+	baseFont := string(d.name(dict["BaseFont"]))
+	descriptor := d.dict(dict["FontDescriptor"])
+
+	if subtype == "Type0" {
+		font.codeBytes = 2
+		font.defaultWidth = 1000
+		if descendants := d.array(dict["DescendantFonts"]); len(descendants) > 0 {
+			cidFont := d.dict(descendants[0])
+			if dw, ok := d.number(cidFont["DW"]); ok {
+				font.defaultWidth = dw
+			}
+			font.widths = d.cidWidths(cidFont["W"])
+			if descriptor == nil {
+				descriptor = d.dict(cidFont["FontDescriptor"])
+			}
+		}
+	} else {
+		font.encoding = d.simpleEncoding(dict["Encoding"], baseFont)
+		font.widths = make(map[uint32]float64)
+		first, _ := d.number(dict["FirstChar"])
+		for i, w := range d.array(dict["Widths"]) {
+			if width, ok := d.number(w); ok {
+				font.widths[uint32(int(first)+i)] = width
+			}
+		}
+		if len(font.widths) == 0 {
+			// Standard 14 fonts come without widths; spaces are about half an average glyph
+			font.widths[32] = 250
+		}
+		if missing, ok := d.number(descriptor["MissingWidth"]); ok && missing > 0 {
+			font.defaultWidth = missing
+		}
+		if subtype == "Type3" {
+			if m := d.array(dict["FontMatrix"]); len(m) > 0 {
+				if sx, ok := d.number(m[0]); ok {
+					font.scale = sx
+				}
+			}
+		}
+	}
+
+	if stream, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := d.decodeStream(stream); err == nil {
+			font.toUnicode, font.codeBytes = parseToUnicodeCMap(data, font.codeBytes)
+		}
+	}
+
+	lowerName := strings.ToLower(baseFont)
+	font.bold = strings.Contains(lowerName, "bold") || strings.Contains(lowerName, "black") ||
+		strings.Contains(lowerName, "heavy") || strings.Contains(lowerName, "semibold")
+	font.italic = strings.Contains(lowerName, "italic") || strings.Contains(lowerName, "oblique")
+	if descriptor != nil {
+		flags, _ := d.number(descriptor["Flags"])
+		weight, _ := d.number(descriptor["FontWeight"])
+		font.bold = font.bold || int(flags)&(1<<18) != 0 || weight >= 600
+		font.italic = font.italic || int(flags)&(1<<6) != 0
+	}
+	return font
+}
+
+// Reads a CIDFont W array: "c [w1 w2 ...]" or "cFirst cLast w".
+func (d *pdfDocument) cidWidths(obj any) map[uint32]float64 {
+	widths := make(map[uint32]float64)
+	arr := d.array(obj)
+	for i := 0; i < len(arr); {
+		start, ok := d.number(arr[i])
+		if !ok || i+1 >= len(arr) {
+			break
+		}
+		if list := d.array(arr[i+1]); list != nil {
+			for j, w := range list {
+				if width, ok := d.number(w); ok {
+					widths[uint32(int(start)+j)] = width
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(arr) {
+			break
+		}
+		end, ok1 := d.number(arr[i+1])
+		width, ok2 := d.number(arr[i+2])
+		if ok1 && ok2 && end-start < 65536 {
+			for c := int(start); c <= int(end); c++ {
+				widths[uint32(c)] = width
+			}
+		}
+		i += 3
+	}
+	return widths
+}
+
+// Builds the code-to-character table of a simple font from its base
+// encoding and Differences array.
+func (d *pdfDocument) simpleEncoding(obj any, baseFont string) *[256]rune {
+	enc := new([256]rune)
+	base := d.name(obj)
+	var differences pdfArray
+	if dict := d.dict(obj); dict != nil {
+		base = d.name(dict["BaseEncoding"])
+		differences = d.array(dict["Differences"])
+	}
+
+	switch {
+	case base == "MacRomanEncoding":
+		*enc = macRomanEncoding
+	case base == "StandardEncoding":
+		*enc = standardEncoding
+	case base == "" && (strings.Contains(baseFont, "Symbol") || strings.Contains(baseFont, "Dingbats")):
+		// Symbolic fonts have their own built-in encodings; without a
+		// ToUnicode map their codes mean nothing useful.
+	default:
+		*enc = winAnsiEncoding
+	}
+
+	code := 0
+	for _, item := range differences {
+		switch v := d.resolve(item).(type) {
+		case int:
+			code = v
+		case pdfName:
+			if code >= 0 && code < 256 {
+				if r, ok := glyphNameToRune(string(v)); ok {
+					enc[code] = r
+				}
+			}
+			code++
+		}
+	}
+	return enc
+}
+
+// Parses a ToUnicode CMap. Returns the code mappings and the code length in
+// bytes implied by its codespace ranges, or fallbackBytes if it has none.
+func parseToUnicodeCMap(data []byte, fallbackBytes int) (map[uint32]string, int) {
+	mapping := make(map[uint32]string)
+	codeBytes := fallbackBytes
+	l := &pdfLexer{data: data}
+
+	var operands []any
+	for {
+		obj, err := l.readObject(false)
+		if err != nil {
+			break
+		}
+		kw, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			operands = nil
+			continue
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					codeBytes = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					mapping[bytesToCode(src)] = utf16BEToString(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := bytesToCode(lo), bytesToCode(hi)
+				if end < start || end-start > 65535 {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					// Each code maps to the destination with its last unit incremented
+					units := utf16BEUnits(dst)
+					for c := start; c <= end && len(units) > 0; c++ {
+						mapping[c] = string(utf16.Decode(units))
+						units[len(units)-1]++
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && start+uint32(j) <= end {
+							mapping[start+uint32(j)] = utf16BEToString(s)
+						}
+					}
+				}
+			}
+		}
+		operands = nil
+	}
+	return mapping, codeBytes
+}
+
+func bytesToCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16BEUnits(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+func utf16BEToString(b []byte) string {
+	return string(utf16.Decode(utf16BEUnits(b)))
+}
+
+// Decodes a PDF text string, such as a document title: UTF-16BE with a byte
+// order mark, UTF-8 with one (PDF 2.0), or else PDFDocEncoding.
+func decodePDFTextString(b []byte) string {
+	switch {
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		return utf16BEToString(b[2:])
+	case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		return string(b[3:])
+	}
+	// PDFDocEncoding agrees with WinAnsi for the characters titles use
+	runes := make([]rune, 0, len(b))
+	for _, c := range b {
+		if r := winAnsiEncoding[c]; r != 0 {
+			runes = append(runes, r)
+		}
+	}
+	return string(runes)
+}
+
+// Maps a glyph name from an encoding's Differences array to its character,
+// following the Adobe Glyph List conventions for uniXXXX, uXXXX and
+// ligature names.
+func glyphNameToRune(name string) (rune, bool) {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i] // Variants such as "a.sc"
+	}
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 {
+		if v, err := strconv.ParseUint(name[3:7], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	return 0, false
+}
+
+var winAnsiEncoding = func() [256]rune {
+	var enc [256]rune
+	for c := 32; c < 256; c++ {
+		enc[c] = rune(c)
+	}
+	enc[127] = 0
+	for i, r := range []rune("€\x00‚ƒ„…†‡ˆ‰Š‹Œ\x00Ž\x00\x00‘’“”•–—˜™š›œ\x00žŸ") {
+		enc[0x80+i] = r
+	}
+	enc['\t'], enc['\n'], enc['\r'] = '\t', '\n', '\r'
+	return enc
+}()
+
+var standardEncoding = func() [256]rune {
+	var enc [256]rune
+	for c := 32; c < 127; c++ {
+		enc[c] = rune(c)
+	}
+	enc[0x27], enc[0x60] = '’', '‘'
+	upper := map[int]rune{
+		0xA1: '¡', 0xA2: '¢', 0xA3: '£', 0xA4: '⁄', 0xA5: '¥', 0xA6: 'ƒ', 0xA7: '§', 0xA8: '¤',
+		0xA9: '\'', 0xAA: '“', 0xAB: '«', 0xAC: '‹', 0xAD: '›', 0xAE: 'ﬁ', 0xAF: 'ﬂ',
+		0xB1: '–', 0xB2: '†', 0xB3: '‡', 0xB4: '·', 0xB6: '¶', 0xB7: '•', 0xB8: '‚', 0xB9: '„',
+		0xBA: '”', 0xBB: '»', 0xBC: '…', 0xBD: '‰', 0xBF: '¿', 0xC1: '`', 0xC2: '´', 0xC3: 'ˆ',
+		0xC4: '˜', 0xC5: '¯', 0xC6: '˘', 0xC7: '˙', 0xC8: '¨', 0xCA: '˚', 0xCB: '¸', 0xCD: '˝',
+		0xCE: '˛', 0xCF: 'ˇ', 0xD0: '—', 0xE1: 'Æ', 0xE3: 'ª', 0xE8: 'Ł', 0xE9: 'Ø', 0xEA: 'Œ',
+		0xEB: 'º', 0xF1: 'æ', 0xF5: 'ı', 0xF8: 'ł', 0xF9: 'ø', 0xFA: 'œ', 0xFB: 'ß',
+	}
+	for c, r := range upper {
+		enc[c] = r
+	}
+	return enc
+}()
+
+var macRomanEncoding = func() [256]rune {
+	var enc [256]rune
+	for c := 32; c < 127; c++ {
+		enc[c] = rune(c)
+	}
+	for i, r := range []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ") {
+		enc[0x80+i] = r
+	}
+	return enc
+}()
+
+// Glyph names used in Differences arrays, beyond single letters.
+var glyphNames = func() map[string]rune {
+	names := map[string]rune{
+		"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$',
+		"percent": '%', "ampersand": '&', "quotesingle": '\'', "quoteright": '’', "parenleft": '(',
+		"parenright": ')', "asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-',
+		"period": '.', "slash": '/', "colon": ':', "semicolon": ';', "less": '<',
+		"equal": '=', "greater": '>', "question": '?', "at": '@', "bracketleft": '[',
+		"backslash": '\\', "bracketright": ']', "asciicircum": '^', "underscore": '_', "grave": '`',
+		"quoteleft": '‘', "braceleft": '{', "bar": '|', "braceright": '}', "asciitilde": '~',
+		"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+		"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+		"bullet": '•', "endash": '–', "emdash": '—', "quotedblleft": '“', "quotedblright": '”',
+		"quotesinglbase": '‚', "quotedblbase": '„', "ellipsis": '…', "dagger": '†', "daggerdbl": '‡',
+		"fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ',
+		"trademark": '™', "copyright": '©', "registered": '®', "degree": '°', "section": '§',
+		"paragraph": '¶', "periodcentered": '·', "guillemotleft": '«', "guillemotright": '»',
+		"guilsinglleft": '‹', "guilsinglright": '›', "minus": '−', "Euro": '€', "sterling": '£',
+		"yen": '¥', "cent": '¢', "currency": '¤', "exclamdown": '¡', "questiondown": '¿',
+		"nbspace": ' ', "nonbreakingspace": ' ', "sfthyphen": '­', "softhyphen": '­',
+		"florin": 'ƒ', "perthousand": '‰', "fraction": '⁄', "dotlessi": 'ı', "lslash": 'ł',
+		"Lslash": 'Ł', "oe": 'œ', "OE": 'Œ', "Scaron": 'Š', "scaron": 'š', "Zcaron": 'Ž',
+		"zcaron": 'ž', "Ydieresis": 'Ÿ', "circumflex": 'ˆ', "tilde": '˜', "acute": '´',
+		"dieresis": '¨', "macron": '¯', "cedilla": '¸', "ordfeminine": 'ª', "ordmasculine": 'º',
+		"onehalf": '½', "onequarter": '¼', "threequarters": '¾', "plusminus": '±', "mu": 'µ',
+		"brokenbar": '¦', "logicalnot": '¬', "onesuperior": '¹', "twosuperior": '²', "threesuperior": '³',
+	}
+	// Latin-1 letters from U+00C0, named by letter and accent
+	latin1 := []string{
+		"Agrave", "Aacute", "Acircumflex", "Atilde", "Adieresis", "Aring", "AE", "Ccedilla",
+		"Egrave", "Eacute", "Ecircumflex", "Edieresis", "Igrave", "Iacute", "Icircumflex", "Idieresis",
+		"Eth", "Ntilde", "Ograve", "Oacute", "Ocircumflex", "Otilde", "Odieresis", "multiply",
+		"Oslash", "Ugrave", "Uacute", "Ucircumflex", "Udieresis", "Yacute", "Thorn", "germandbls",
+		"agrave", "aacute", "acircumflex", "atilde", "adieresis", "aring", "ae", "ccedilla",
+		"egrave", "eacute", "ecircumflex", "edieresis", "igrave", "iacute", "icircumflex", "idieresis",
+		"eth", "ntilde", "ograve", "oacute", "ocircumflex", "otilde", "odieresis", "divide",
+		"oslash", "ugrave", "uacute", "ucircumflex", "udieresis", "yacute", "thorn", "ydieresis",
+	}
+	for i, name := range latin1 {
+		names[name] = rune(0xC0 + i)
+	}
+	return names
+}()
@@ -0,0 +1,166 @@
+package conversion
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Images smaller than this in either dimension, in pixels or in points as
+// drawn, are rules, bullets and spacers rather than figures.
+const minPDFImageSize = 32
+
+// Returns an image XObject as JPEG or PNG data. JPEGs are passed through;
+// raw samples in gray, RGB, CMYK or indexed color are encoded as PNG. Other
+// encodings (JPEG 2000, masks, unusual color spaces) are not supported.
+func (d *pdfDocument) imageData(stream *pdfStream) (contentType string, data []byte, err error) {
+	dict := stream.dict
+	if imageMask, _ := d.resolve(dict["ImageMask"]).(bool); imageMask {
+		return "", nil, fmt.Errorf("image masks are not supported")
+	}
+
+	samples, codec, err := d.decodeStreamUntilImageCodec(stream)
+	if err != nil {
+		return "", nil, err
+	}
+	switch codec {
+	case "DCTDecode", "DCT":
+		return "image/jpeg", samples, nil
+	case "":
+	default:
+		return "", nil, fmt.Errorf("unsupported image encoding %s", codec)
+	}
+
+	width, _ := d.number(dict["Width"])
+	height, _ := d.number(dict["Height"])
+	bpc, ok := d.number(dict["BitsPerComponent"])
+	if !ok {
+		bpc = 8
+	}
+	w, h := int(width), int(height)
+	if w <= 0 || h <= 0 || width*height > 50_000_000 {
+		return "", nil, fmt.Errorf("invalid image dimensions %dx%d", w, h)
+	}
+	if bpc != 8 {
+		return "", nil, fmt.Errorf("unsupported bits per component %v", bpc)
+	}
+
+	colorSpace, components, palette := d.colorSpace(dict["ColorSpace"])
+	if len(samples) < w*h*components {
+		return "", nil, fmt.Errorf("image data too short for %dx%d %s", w, h, colorSpace)
+	}
+
+	var img image.Image
+	switch colorSpace {
+	case "DeviceGray":
+		gray := image.NewGray(image.Rect(0, 0, w, h))
+		copy(gray.Pix, samples)
+		img = gray
+	case "DeviceRGB", "DeviceCMYK":
+		rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+		for i := 0; i < w*h; i++ {
+			px := samples[i*components : (i+1)*components]
+			var c color.RGBA
+			if components == 4 {
+				c = cmykToRGBA(px)
+			} else {
+				c = color.RGBA{px[0], px[1], px[2], 255}
+			}
+			rgba.Pix[i*4], rgba.Pix[i*4+1], rgba.Pix[i*4+2], rgba.Pix[i*4+3] = c.R, c.G, c.B, 255
+		}
+		img = rgba
+	case "Indexed":
+		paletted := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+		copy(paletted.Pix, samples)
+		img = paletted
+	default:
+		return "", nil, fmt.Errorf("unsupported color space %s", colorSpace)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return "image/png", buf.Bytes(), nil
+}
+
+func cmykToRGBA(px []byte) color.RGBA {
+	r, g, b := color.CMYKToRGB(px[0], px[1], px[2], px[3])
+	return color.RGBA{r, g, b, 255}
+}
+
+// Resolves a color space to one of DeviceGray, DeviceRGB, DeviceCMYK or
+// Indexed, with its number of components per sample and, for Indexed, the
+// palette.
+func (d *pdfDocument) colorSpace(obj any) (string, int, color.Palette) {
+	switch cs := d.resolve(obj).(type) {
+	case pdfName:
+		switch cs {
+		case "DeviceGray", "G", "CalGray":
+			return "DeviceGray", 1, nil
+		case "DeviceRGB", "RGB", "CalRGB":
+			return "DeviceRGB", 3, nil
+		case "DeviceCMYK", "CMYK":
+			return "DeviceCMYK", 4, nil
+		}
+		return string(cs), 0, nil
+
+	case pdfArray:
+		if len(cs) == 0 {
+			return "", 0, nil
+		}
+		switch d.name(cs[0]) {
+		case "ICCBased":
+			if len(cs) > 1 {
+				if stream, ok := d.resolve(cs[1]).(*pdfStream); ok {
+					n, _ := d.number(stream.dict["N"])
+					switch n {
+					case 1:
+						return "DeviceGray", 1, nil
+					case 3:
+						return "DeviceRGB", 3, nil
+					case 4:
+						return "DeviceCMYK", 4, nil
+					}
+				}
+			}
+		case "CalGray", "CalRGB":
+			return d.colorSpace(cs[0])
+		case "Indexed", "I":
+			if len(cs) < 4 {
+				break
+			}
+			base, baseComponents, _ := d.colorSpace(cs[1])
+			var lookup []byte
+			switch l := d.resolve(cs[3]).(type) {
+			case pdfString:
+				lookup = l
+			case *pdfStream:
+				lookup, _ = d.decodeStream(l)
+			}
+			if baseComponents == 0 || base == "Indexed" {
+				break
+			}
+			var palette color.Palette
+			for i := 0; (i+1)*baseComponents <= len(lookup) && i < 256; i++ {
+				px := lookup[i*baseComponents : (i+1)*baseComponents]
+				switch baseComponents {
+				case 1:
+					palette = append(palette, color.RGBA{px[0], px[0], px[0], 255})
+				case 3:
+					palette = append(palette, color.RGBA{px[0], px[1], px[2], 255})
+				case 4:
+					palette = append(palette, cmykToRGBA(px))
+				}
+			}
+			// Samples may index past a short lookup table
+			for len(palette) < 256 {
+				palette = append(palette, color.RGBA{0, 0, 0, 255})
+			}
+			return "Indexed", 1, palette
+		}
+	}
+	return "", 0, nil
+}
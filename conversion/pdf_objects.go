@@ -0,0 +1,697 @@
+package conversion
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// PDF object model. Numbers are int or float64, strings are pdfString and
+// content stream operators are pdfKeyword.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte // Raw, still encoded
+	}
+)
+
+var errPDFEndOfData = errors.New("unexpected end of PDF data")
+
+// Upper bound on decoded stream size, so a small compressed stream can't
+// exhaust memory.
+const maxPDFStreamSize = 64 << 20
+
+// Tokenizes and parses PDF objects from a byte slice.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isPDFDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// Skips whitespace and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// Reads the next object. Keywords, including the closing delimiters "]" and
+// ">>", are returned as pdfKeyword. References ("1 0 R") are resolved into
+// pdfRef only when refs is true; content streams have none.
+func (l *pdfLexer) readObject(refs bool) (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEndOfData
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.readName(), nil
+	case c == '(':
+		return l.readLiteralString(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.readDict(refs)
+	case c == '<':
+		return l.readHexString(), nil
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '[':
+		l.pos++
+		return l.readArray(refs)
+	case c == ']':
+		l.pos++
+		return pdfKeyword("]"), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		num := l.readNumber()
+		if n, ok := num.(int); ok && refs && n >= 0 {
+			if ref, ok := l.tryReadRef(n); ok {
+				return ref, nil
+			}
+		}
+		return num, nil
+	case isPDFDelim(c):
+		// Stray delimiter; skip it rather than loop forever.
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	switch word := string(l.data[start:l.pos]); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return pdfKeyword(word), nil
+	}
+}
+
+// Looks ahead for "gen R" after an object number.
+func (l *pdfLexer) tryReadRef(num int) (pdfRef, bool) {
+	save := l.pos
+	l.skipSpace()
+	if l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		if gen, ok := l.readNumber().(int); ok {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+				(l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelim(l.data[l.pos+1])) {
+				l.pos++
+				return pdfRef{num, gen}, true
+			}
+		}
+	}
+	l.pos = save
+	return pdfRef{}, false
+}
+
+func (l *pdfLexer) readNumber() any {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if (c < '0' || c > '9') && c != '.' && c != '-' {
+			break
+		}
+		l.pos++
+	}
+	s := string(l.data[start:l.pos])
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func (l *pdfLexer) readName() pdfName {
+	l.pos++ // Leading slash
+	var buf []byte
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if b, err := hex.DecodeString(string(l.data[l.pos+1 : l.pos+3])); err == nil {
+				buf = append(buf, b[0])
+				l.pos += 3
+				continue
+			}
+		}
+		buf = append(buf, c)
+		l.pos++
+	}
+	return pdfName(buf)
+}
+
+func (l *pdfLexer) readLiteralString() pdfString {
+	l.pos++ // Opening parenthesis
+	var buf []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return buf
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return buf
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (l *pdfLexer) readHexString() pdfString {
+	l.pos++ // Opening angle bracket
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // Closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded := make([]byte, len(digits)/2)
+	n, _ := hex.Decode(decoded, digits)
+	return decoded[:n]
+}
+
+func (l *pdfLexer) readArray(refs bool) (pdfArray, error) {
+	var arr pdfArray
+	for {
+		obj, err := l.readObject(refs)
+		if err != nil {
+			return arr, err
+		}
+		if kw, ok := obj.(pdfKeyword); ok && kw == "]" {
+			return arr, nil
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (l *pdfLexer) readDict(refs bool) (pdfDict, error) {
+	dict := make(pdfDict)
+	for {
+		key, err := l.readObject(refs)
+		if err != nil {
+			return dict, err
+		}
+		if kw, ok := key.(pdfKeyword); ok && kw == ">>" {
+			return dict, nil
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue // Malformed entry; keep going
+		}
+		value, err := l.readObject(refs)
+		if err != nil {
+			return dict, err
+		}
+		if kw, ok := value.(pdfKeyword); ok && kw == ">>" {
+			return dict, nil
+		}
+		dict[name] = value
+	}
+}
+
+// A parsed PDF file. Objects are found by scanning the file for "N G obj"
+// rather than trusting the cross-reference table, which is frequently wrong
+// in mailed PDFs. Later definitions of an object replace earlier ones, as
+// incremental updates append them.
+type pdfDocument struct {
+	objects map[int]any
+	trailer pdfDict
+}
+
+var (
+	pdfObjHeaderRegex = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+	pdfTrailerRegex   = regexp.MustCompile(`trailer[ \t\r\n\f\x00]*<<`)
+)
+
+func parsePDF(data []byte) (*pdfDocument, error) {
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	doc := &pdfDocument{objects: make(map[int]any), trailer: make(pdfDict)}
+	var objectStreams []*pdfStream
+	skipUntil := 0
+	for _, m := range pdfObjHeaderRegex.FindAllSubmatchIndex(data, -1) {
+		if m[0] < skipUntil {
+			continue // Inside a stream we have already read
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		obj, err := l.readObject(true)
+		if err != nil {
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok {
+			if stream, end, ok := readStreamBody(data, l.pos, dict); ok {
+				obj = stream
+				skipUntil = end
+				switch dict["Type"] {
+				case pdfName("XRef"):
+					// Cross-reference streams carry the trailer entries
+					for _, key := range []pdfName{"Root", "Info", "Encrypt"} {
+						if dict[key] != nil {
+							doc.trailer[key] = dict[key]
+						}
+					}
+				case pdfName("ObjStm"):
+					objectStreams = append(objectStreams, stream)
+				}
+			}
+		}
+		doc.objects[num] = obj
+	}
+	if len(doc.objects) == 0 {
+		return nil, fmt.Errorf("no objects found in PDF")
+	}
+
+	// Classic trailers, in file order so later updates win
+	for _, idx := range pdfTrailerRegex.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: idx[1] - 2}
+		if obj, err := l.readObject(true); err == nil {
+			if dict, ok := obj.(pdfDict); ok {
+				for k, v := range dict {
+					doc.trailer[k] = v
+				}
+			}
+		}
+	}
+
+	for _, stream := range objectStreams {
+		if err := doc.loadObjectStream(stream); err != nil {
+			return nil, fmt.Errorf("failed to read object stream: %w", err)
+		}
+	}
+
+	if doc.trailer["Root"] == nil {
+		for num, obj := range doc.objects {
+			if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				doc.trailer["Root"] = pdfRef{num: num}
+			}
+		}
+	}
+	return doc, nil
+}
+
+// Finds the data of a stream whose dictionary ends at pos. Returns the raw
+// data and the offset just past "endstream".
+func readStreamBody(data []byte, pos int, dict pdfDict) (*pdfStream, int, bool) {
+	l := &pdfLexer{data: data, pos: pos}
+	l.skipSpace()
+	if !bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+		return nil, 0, false
+	}
+	start := l.pos + len("stream")
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+
+	// Trust a direct /Length when "endstream" follows it; otherwise search.
+	if length, ok := dict["Length"].(int); ok && length >= 0 && start+length <= len(data) {
+		rest := bytes.TrimLeft(data[start+length:min(len(data), start+length+32)], " \t\r\n\x00")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			end := start + length
+			return &pdfStream{dict: dict, data: data[start:end]}, end + len("endstream"), true
+		}
+	}
+	idx := bytes.Index(data[start:], []byte("endstream"))
+	if idx < 0 {
+		return &pdfStream{dict: dict, data: data[start:]}, len(data), true
+	}
+	end := start + idx
+	body := bytes.TrimSuffix(bytes.TrimSuffix(data[start:end], []byte("\n")), []byte("\r"))
+	return &pdfStream{dict: dict, data: body}, end + len("endstream"), true
+}
+
+// Adds the objects packed into an object stream. Objects also defined
+// directly in the file keep that definition.
+func (d *pdfDocument) loadObjectStream(stream *pdfStream) error {
+	data, err := d.decodeStream(stream)
+	if err != nil {
+		return err
+	}
+	count, _ := d.resolve(stream.dict["N"]).(int)
+	first, _ := d.resolve(stream.dict["First"]).(int)
+	if first > len(data) {
+		return fmt.Errorf("object stream offset %d out of range", first)
+	}
+
+	header := &pdfLexer{data: data[:first]}
+	for i := 0; i < count; i++ {
+		numObj, err1 := header.readObject(false)
+		offObj, err2 := header.readObject(false)
+		if err1 != nil || err2 != nil {
+			break
+		}
+		num, ok1 := numObj.(int)
+		off, ok2 := offObj.(int)
+		if !ok1 || !ok2 || first+off >= len(data) {
+			continue
+		}
+		if _, exists := d.objects[num]; exists {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: first + off}
+		if obj, err := l.readObject(true); err == nil {
+			d.objects[num] = obj
+		}
+	}
+	return nil
+}
+
+// Follows references until a direct object is reached.
+func (d *pdfDocument) resolve(obj any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(obj any) pdfDict {
+	switch v := d.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (d *pdfDocument) array(obj any) pdfArray {
+	if arr, ok := d.resolve(obj).(pdfArray); ok {
+		return arr
+	}
+	return nil
+}
+
+func (d *pdfDocument) number(obj any) (float64, bool) {
+	switch v := d.resolve(obj).(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func (d *pdfDocument) name(obj any) pdfName {
+	n, _ := d.resolve(obj).(pdfName)
+	return n
+}
+
+// Returns a stream's filters in the order they apply.
+func (d *pdfDocument) streamFilters(stream *pdfStream) ([]pdfName, []pdfDict) {
+	var names []pdfName
+	var params []pdfDict
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		names = []pdfName{f}
+		params = []pdfDict{d.dict(stream.dict["DecodeParms"])}
+	case pdfArray:
+		paramArr := d.array(stream.dict["DecodeParms"])
+		for i, item := range f {
+			names = append(names, d.name(item))
+			var p pdfDict
+			if i < len(paramArr) {
+				p = d.dict(paramArr[i])
+			}
+			params = append(params, p)
+		}
+	}
+	return names, params
+}
+
+// Decodes a stream. Image codecs (DCTDecode, JPXDecode) are left applied;
+// use decodeImageStream for image data.
+func (d *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	data, _, err := d.decodeStreamUntilImageCodec(stream)
+	return data, err
+}
+
+func (d *pdfDocument) decodeStreamUntilImageCodec(stream *pdfStream) ([]byte, pdfName, error) {
+	data := stream.data
+	names, params := d.streamFilters(stream)
+	for i, name := range names {
+		var err error
+		switch name {
+		case "FlateDecode", "Fl":
+			data, err = inflatePDF(data)
+			if err == nil {
+				data, err = d.applyPredictor(data, params[i])
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = decodeASCIIHex(data)
+		case "ASCII85Decode", "A85":
+			data, err = decodeASCII85(data)
+		case "RunLengthDecode", "RL":
+			data = decodeRunLength(data)
+		case "DCTDecode", "DCT", "JPXDecode":
+			return data, name, nil
+		default:
+			return nil, "", fmt.Errorf("unsupported PDF stream filter %q", name)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to apply %s: %w", name, err)
+		}
+	}
+	return data, "", nil
+}
+
+// Inflates zlib data, keeping whatever could be read from a truncated or
+// corrupt stream; PDF writers get checksums wrong more often than data.
+func inflatePDF(data []byte) ([]byte, error) {
+	var r io.Reader
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		r = flate.NewReader(bytes.NewReader(data))
+	} else {
+		r = zr
+	}
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStreamSize))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Reverses PNG predictors (Predictor >= 10), used mostly by object streams
+// and images.
+func (d *pdfDocument) applyPredictor(data []byte, params pdfDict) ([]byte, error) {
+	if params == nil {
+		return data, nil
+	}
+	predictor, _ := d.number(params["Predictor"])
+	if predictor < 10 {
+		return data, nil
+	}
+	colors, bpc, columns := 1.0, 8.0, 1.0
+	if v, ok := d.number(params["Colors"]); ok {
+		colors = v
+	}
+	if v, ok := d.number(params["BitsPerComponent"]); ok {
+		bpc = v
+	}
+	if v, ok := d.number(params["Columns"]); ok {
+		columns = v
+	}
+	rowBits := colors * bpc * columns
+	if !(rowBits > 0 && rowBits <= 8*maxPDFStreamSize) {
+		return nil, fmt.Errorf("invalid predictor row length")
+	}
+	bpp := max(1, int(colors*bpc+7)/8)
+	rowLen := int(rowBits+7) / 8
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1 <= len(data); pos += rowLen + 1 {
+		filter := data[pos]
+		row := make([]byte, rowLen)
+		copy(row, data[pos+1:min(len(data), pos+1+rowLen)])
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch filter {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paethPredictor(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paethPredictor(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func decodeASCIIHex(data []byte) ([]byte, error) {
+	if i := bytes.IndexByte(data, '>'); i >= 0 {
+		data = data[:i]
+	}
+	l := &pdfLexer{data: append(append([]byte{'<'}, data...), '>')}
+	return l.readHexString(), nil
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data)/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+func decodeRunLength(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		length := int(data[i])
+		i++
+		switch {
+		case length == 128:
+			return out
+		case length < 128:
+			end := min(len(data), i+length+1)
+			out = append(out, data[i:end]...)
+			i = end
+		default:
+			if i < len(data) {
+				out = append(out, bytes.Repeat(data[i:i+1], 257-length)...)
+			}
+			i++
+		}
+	}
+	return out
+}
@@ -0,0 +1,311 @@
+package conversion
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testPDF assembles a PDF from numbered objects, with a correct
+// cross-reference table.
+type testPDF struct {
+	objects []string
+}
+
+// add appends an object and returns its number.
+func (p *testPDF) add(obj string) int {
+	p.objects = append(p.objects, obj)
+	return len(p.objects)
+}
+
+func (p *testPDF) addStream(dict string, data []byte) int {
+	return p.add(fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data))
+}
+
+// addPage adds a single-page tree drawing content with the given resources
+// and returns the catalog's object number.
+func (p *testPDF) addPage(content int, resources string) int {
+	pages := len(p.objects) + 1
+	p.add(fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", pages+1))
+	p.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << %s >> >>", pages, content, resources))
+	return p.add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+}
+
+func (p *testPDF) bytes(root int, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(p.objects))
+	for i, obj := range p.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(p.objects)+1, root, trailer, xref)
+	return buf.Bytes()
+}
+
+const testPDFHelvetica = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+
+// textPDF returns a one-page PDF drawing content with Helvetica as /F1.
+func textPDF(content string, trailer string) []byte {
+	p := &testPDF{}
+	font := p.add(testPDFHelvetica)
+	stream := p.addStream("", []byte(content))
+	root := p.addPage(stream, fmt.Sprintf("/Font << /F1 %d 0 R >>", font))
+	return p.bytes(root, trailer)
+}
+
+func deflate(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatalf("deflating: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("deflating: %v", err)
+	}
+	return buf.Bytes()
+}
+
+const testPDFArticle = `BT /F1 24 Tf 72 720 Td (Big Title) Tj ET
+BT /F1 12 Tf 72 680 Td (The first line of a paragraph) Tj 0 -14 Td (continues here.) Tj ET
+BT /F1 12 Tf 72 620 Td (\(A < B & C\)) Tj ET
+BT /F1 12 Tf 72 580 Td [(Kern)-20(ed )(text)] TJ ET`
+
+const testPDFArticleHTML = "<div>\n<h2>Big Title</h2>\n<p>The first line of a paragraph continues here.</p>\n" +
+	"<p>(A &lt; B &amp; C)</p>\n<p>Kerned text</p>\n</div>\n"
+
+func TestExtractPDFText(t *testing.T) {
+	content, err := ExtractPDF(textPDF(testPDFArticle, "/Info << /Title (Quarterly Report) >>"))
+	if err != nil {
+		t.Fatalf("ExtractPDF: %v", err)
+	}
+	if string(content.HTML) != testPDFArticleHTML {
+		t.Errorf("HTML\n got: %q\nwant: %q", content.HTML, testPDFArticleHTML)
+	}
+	if content.Title != "Quarterly Report" {
+		t.Errorf("Title = %q, want %q", content.Title, "Quarterly Report")
+	}
+	wantText := "Big Title\nThe first line of a paragraph continues here.\n(A < B & C)\nKerned text\n"
+	if content.Text != wantText {
+		t.Errorf("Text = %q, want %q", content.Text, wantText)
+	}
+}
+
+func TestExtractPDFTitleFallsBackToHeading(t *testing.T) {
+	content, err := ExtractPDF(textPDF(testPDFArticle, "/Info << /Title (Microsoft Word - draft.docx) >>"))
+	if err != nil {
+		t.Fatalf("ExtractPDF: %v", err)
+	}
+	if content.Title != "Big Title" {
+		t.Errorf("Title = %q, want the first heading", content.Title)
+	}
+}
+
+func TestExtractPDFFlateDecode(t *testing.T) {
+	for _, filter := range []string{"/Filter /FlateDecode", "/Filter [/FlateDecode]"} {
+		t.Run(filter, func(t *testing.T) {
+			p := &testPDF{}
+			font := p.add(testPDFHelvetica)
+			stream := p.addStream(filter, deflate(t, testPDFArticle))
+			root := p.addPage(stream, fmt.Sprintf("/Font << /F1 %d 0 R >>", font))
+
+			content, err := ExtractPDF(p.bytes(root, ""))
+			if err != nil {
+				t.Fatalf("ExtractPDF: %v", err)
+			}
+			if string(content.HTML) != testPDFArticleHTML {
+				t.Errorf("HTML\n got: %q\nwant: %q", content.HTML, testPDFArticleHTML)
+			}
+		})
+	}
+}
+
+func TestExtractPDFToUnicode(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0004> <4F60597D>
+endbfchar
+1 beginbfrange
+<0002> <0003> <0069>
+endbfrange
+1 beginbfrange
+<0010> <0011> [<0041> <D83DDE00>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+	p := &testPDF{}
+	toUnicode := p.addStream("/Filter /FlateDecode", deflate(t, cmap))
+	descendant := p.add("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /NotoSans /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /DW 1000 >>")
+	font := p.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /NotoSans /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", descendant, toUnicode))
+	stream := p.addStream("", []byte("BT /F1 12 Tf 72 720 Td <000100020003> Tj <0004> Tj <00100011> Tj ET"))
+	root := p.addPage(stream, fmt.Sprintf("/Font << /F1 %d 0 R >>", font))
+
+	content, err := ExtractPDF(p.bytes(root, ""))
+	if err != nil {
+		t.Fatalf("ExtractPDF: %v", err)
+	}
+	if want := "Hij你好A😀\n"; content.Text != want {
+		t.Errorf("Text = %q, want %q", content.Text, want)
+	}
+}
+
+func TestExtractPDFImageXObject(t *testing.T) {
+	const size = 40
+	pixels := make([]byte, size*size)
+	for i := range pixels {
+		pixels[i] = byte(i)
+	}
+
+	p := &testPDF{}
+	font := p.add(testPDFHelvetica)
+	image := p.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", size, size),
+		deflate(t, string(pixels)))
+	tiny := p.addStream("/Type /XObject /Subtype /Image /Width 4 /Height 4 /ColorSpace /DeviceGray /BitsPerComponent 8", make([]byte, 16))
+	stream := p.addStream("", []byte("BT /F1 12 Tf 72 720 Td (Caption above.) Tj ET\nq 200 0 0 200 72 480 cm /Im1 Do Q\nq 4 0 0 4 72 400 cm /Im2 Do Q"))
+	root := p.addPage(stream, fmt.Sprintf("/Font << /F1 %d 0 R >> /XObject << /Im1 %d 0 R /Im2 %d 0 R >>", font, image, tiny))
+
+	content, err := ExtractPDF(p.bytes(root, ""))
+	if err != nil {
+		t.Fatalf("ExtractPDF: %v", err)
+	}
+	if len(content.Images) != 1 {
+		t.Fatalf("got %d images, want 1 (the spacer is dropped)", len(content.Images))
+	}
+	img := content.Images[0]
+	if img.ContentType != "image/png" {
+		t.Errorf("ContentType = %q, want image/png", img.ContentType)
+	}
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("decoding extracted PNG: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != size || b.Dy() != size {
+		t.Errorf("image is %dx%d, want %dx%d", b.Dx(), b.Dy(), size, size)
+	}
+	wantHTML := "<div>\n<p>Caption above.</p>\n<figure><img src=\"" + img.Ref() + "\" alt=\"\"/></figure>\n</div>\n"
+	if string(content.HTML) != wantHTML {
+		t.Errorf("HTML\n got: %q\nwant: %q", content.HTML, wantHTML)
+	}
+}
+
+// Sizes in stream and image dictionaries come from the file and mustn't drive
+// allocations.
+func TestExtractPDFOversizedDimensions(t *testing.T) {
+	p := &testPDF{}
+	font := p.add(testPDFHelvetica)
+	wide := p.addStream("/Subtype /Image /Width 4294967296 /Height 4294967296 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte("x"))
+	columns := p.addStream("/Subtype /Image /Width 40 /Height 40 /ColorSpace /DeviceGray /BitsPerComponent 8 "+
+		"/Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 1e12 >>", deflate(t, "\x02abc"))
+	stream := p.addStream("", []byte("BT /F1 12 Tf 72 720 Td (Text.) Tj ET q 100 0 0 100 0 0 cm /Im1 Do /Im2 Do Q"))
+	root := p.addPage(stream, fmt.Sprintf("/Font << /F1 %d 0 R >> /XObject << /Im1 %d 0 R /Im2 %d 0 R >>", font, wide, columns))
+
+	checkExtractPDF(t, p.bytes(root, ""))
+}
+
+func TestExtractPDFErrors(t *testing.T) {
+	p := &testPDF{}
+	image := p.addStream("/Subtype /Image /Width 40 /Height 40 /ColorSpace /DeviceGray /BitsPerComponent 8", make([]byte, 1600))
+	stream := p.addStream("", []byte("q 100 0 0 100 0 0 cm /Im1 Do Q"))
+	imageOnly := p.bytes(p.addPage(stream, fmt.Sprintf("/XObject << /Im1 %d 0 R >>", image)), "")
+
+	tests := []struct {
+		name string
+		data []byte
+		want error // Checked with errors.Is when set
+	}{
+		{name: "empty", data: nil},
+		{name: "garbage", data: []byte("this is not a PDF at all")},
+		{name: "header only", data: []byte("%PDF-1.4\n%%EOF")},
+		{name: "no pages", data: (&testPDF{objects: []string{"<< /Type /Catalog >>"}}).bytes(1, "")},
+		{name: "encrypted", data: textPDF(testPDFArticle, "/Encrypt << /Filter /Standard /V 2 >>"), want: ErrPDFEncrypted},
+		{name: "no text layer", data: imageOnly, want: ErrPDFNoText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := ExtractPDF(tt.data)
+			if err == nil {
+				t.Fatalf("ExtractPDF() = %+v, want an error", content)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("ExtractPDF() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// checkExtractPDF fails if extraction recovered from a panic; ExtractPDF
+// turns panics into "malformed PDF" errors as a last resort, and the parser
+// shouldn't rely on it.
+func checkExtractPDF(t *testing.T, data []byte) {
+	t.Helper()
+	content, err := ExtractPDF(data)
+	if err != nil && strings.HasPrefix(err.Error(), "malformed PDF:") {
+		t.Fatalf("ExtractPDF panicked on %q: %v", data, err)
+	}
+	if err == nil && content == nil {
+		t.Fatalf("ExtractPDF returned neither content nor an error")
+	}
+}
+
+func TestExtractPDFTruncated(t *testing.T) {
+	p := &testPDF{}
+	font := p.add(testPDFHelvetica)
+	stream := p.addStream("/Filter /FlateDecode", deflate(t, testPDFArticle))
+	valid := p.bytes(p.addPage(stream, fmt.Sprintf("/Font << /F1 %d 0 R >>", font)), "")
+
+	for n := 0; n < len(valid); n++ {
+		checkExtractPDF(t, valid[:n])
+	}
+}
+
+func FuzzExtractPDF(f *testing.F) {
+	f.Add(textPDF(testPDFArticle, "/Info << /Title (Title) >>"))
+	f.Add(textPDF("BT /F1 12 Tf [(a) 1e9 (b)] TJ ET BI /W 1 /H 1 ID x EI", ""))
+	f.Add([]byte("%PDF-1.4\n1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n2 0 obj << /Kids [2 0 R] >> endobj"))
+	f.Add([]byte("%PDF-1.5\n1 0 obj << /Type /ObjStm /N 3 /First 999 /Length 4 >>\nstream\nabcd\nendstream"))
+	f.Add([]byte("%PDF-1.5\n1 0 obj << /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >> /Length 3 >>\nstream\nabc\nendstream"))
+	f.Add([]byte("%PDF-1.5\n1 0 obj << /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 1e12 >> /Length 3 >>\nstream\nabc\nendstream"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkExtractPDF(t, data)
+	})
+}
+
+func TestParseToUnicodeCMapMalformed(t *testing.T) {
+	inputs := []string{
+		"",
+		"beginbfchar <01> endbfchar",
+		"beginbfrange <0000> <FFFF> <> endbfrange",
+		"beginbfrange <FFFFFFFF> <00000000> <0041> endbfrange",
+		"beginbfrange <0000> <0001> [ endbfrange",
+		"begincodespacerange <> <FF> endcodespacerange",
+		"beginbfchar (unterminated",
+	}
+	for _, input := range inputs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("parseToUnicodeCMap(%q) panicked: %v", input, r)
+				}
+			}()
+			parseToUnicodeCMap([]byte(input), 1)
+		}()
+	}
+}
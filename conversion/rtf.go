@@ -0,0 +1,308 @@
+package conversion
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Destinations whose content is not part of the document text.
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true, "footer": true,
+	"footerl": true, "footerr": true, "footerf": true, "footnote": true, "object": true,
+	"listtable": true, "listoverridetable": true, "rsidtbl": true, "generator": true,
+	"xmlnstbl": true, "themedata": true, "colorschememapping": true, "latentstyles": true,
+	"datastore": true, "filetbl": true, "revtbl": true, "pgdsctbl": true, "nonshppict": true,
+}
+
+// Control words that stand for a single character.
+var rtfSymbols = map[string]string{
+	"tab": "\t", "emdash": "—", "endash": "–", "bullet": "•", "lquote": "‘", "rquote": "’",
+	"ldblquote": "“", "rdblquote": "”", "emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// The character formatting and destination of an RTF group. Groups inherit
+// their parent's state and restore it when they close.
+type rtfState struct {
+	format      textRun
+	skip        bool
+	listText    bool // Inside \listtext or \pntext, the rendered list marker
+	fieldInstr  bool // Inside \fldinst
+	field       *rtfField
+	unicodeSkip int // Characters to skip after \uN, set by \ucN
+}
+
+type rtfField struct {
+	instr string
+}
+
+// The properties of the current paragraph, reset by \pard.
+type rtfParagraph struct {
+	heading  int // 1 to 6, from \outlinelevelN; 0 if not a heading
+	list     bool
+	listMark string
+	runs     []textRun
+}
+
+type rtfConverter struct {
+	data     []byte
+	pos      int
+	state    rtfState
+	stack    []rtfState
+	para     rtfParagraph
+	encoding *[256]rune
+	buf      bytes.Buffer
+	openList string // "ul" or "ol" while a list is open
+}
+
+// rtfToHTML converts the text of an RTF document to an HTML fragment,
+// keeping paragraphs, outline-level headings, lists, bold, italic, underline,
+// strikethrough and hyperlinks. Tables become paragraphs and pictures are
+// dropped.
+func rtfToHTML(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(`{\rtf`)) {
+		return nil, fmt.Errorf("not an RTF document")
+	}
+	c := &rtfConverter{data: data, encoding: &winAnsiEncoding, state: rtfState{unicodeSkip: 1}}
+	c.parse()
+	c.endParagraph()
+	c.closeList()
+	return c.buf.Bytes(), nil
+}
+
+func (c *rtfConverter) parse() {
+	for c.pos < len(c.data) {
+		ch := c.data[c.pos]
+		switch ch {
+		case '{':
+			c.pos++
+			c.stack = append(c.stack, c.state)
+		case '}':
+			c.pos++
+			if len(c.stack) > 0 {
+				c.state = c.stack[len(c.stack)-1]
+				c.stack = c.stack[:len(c.stack)-1]
+			}
+		case '\\':
+			c.controlWord()
+		case '\r', '\n':
+			c.pos++
+		default:
+			c.pos++
+			c.text(string(c.encoding[ch]))
+		}
+	}
+}
+
+func (c *rtfConverter) controlWord() {
+	c.pos++ // Backslash
+	if c.pos >= len(c.data) {
+		return
+	}
+
+	ch := c.data[c.pos]
+	if !isASCIILetter(ch) {
+		c.pos++
+		switch ch {
+		case '\'':
+			if c.pos+2 <= len(c.data) {
+				if b, err := strconv.ParseUint(string(c.data[c.pos:c.pos+2]), 16, 8); err == nil {
+					c.text(string(c.encoding[b]))
+				}
+				c.pos += 2
+			}
+		case '*':
+			// An optional destination: skip it unless it's one we read.
+			if !c.peekWord("fldinst") {
+				c.state.skip = true
+			}
+		case '~':
+			c.text(" ")
+		case '_':
+			c.text("‑")
+		case '\r', '\n':
+			c.endParagraph()
+		case '\\', '{', '}':
+			c.text(string(ch))
+		}
+		return
+	}
+
+	start := c.pos
+	for c.pos < len(c.data) && isASCIILetter(c.data[c.pos]) {
+		c.pos++
+	}
+	word := string(c.data[start:c.pos])
+	paramStart := c.pos
+	if c.pos < len(c.data) && c.data[c.pos] == '-' {
+		c.pos++
+	}
+	for c.pos < len(c.data) && isDigit(c.data[c.pos]) {
+		c.pos++
+	}
+	param, hasParam := 0, c.pos > paramStart
+	if hasParam {
+		param, _ = strconv.Atoi(string(c.data[paramStart:c.pos]))
+	}
+	if c.pos < len(c.data) && c.data[c.pos] == ' ' {
+		c.pos++ // The delimiting space is part of the control word
+	}
+
+	c.apply(word, param, hasParam)
+}
+
+func (c *rtfConverter) apply(word string, param int, hasParam bool) {
+	on := !hasParam || param != 0
+	switch {
+	case rtfSkippedDestinations[word]:
+		c.state.skip = true
+	case word == "mac":
+		c.encoding = &macRomanEncoding
+	case word == "listtext" || word == "pntext":
+		c.state.listText = true
+		c.para.list = true
+	case word == "field":
+		c.state.field = &rtfField{}
+	case word == "fldinst":
+		c.state.fieldInstr = true
+	case word == "fldrslt":
+		if c.state.field != nil {
+			if m := docxHyperlinkFieldRegex.FindStringSubmatch(c.state.field.instr); m != nil {
+				c.state.format.href = m[1]
+			}
+		}
+	case c.state.skip:
+	case word == "par" || word == "sect" || word == "page" || word == "row":
+		c.endParagraph()
+	case word == "cell":
+		c.text(" ")
+	case word == "pard":
+		c.para.heading, c.para.list = 0, false
+	case word == "plain":
+		href := c.state.format.href
+		c.state.format = textRun{href: href}
+	case word == "line":
+		c.para.runs = append(c.para.runs, textRun{lineBreak: true})
+	case word == "b":
+		c.state.format.bold = on
+	case word == "i":
+		c.state.format.italic = on
+	case word == "ul":
+		c.state.format.under = on
+	case word == "ulnone":
+		c.state.format.under = false
+	case word == "strike" || word == "striked":
+		c.state.format.strike = on
+	case word == "super":
+		c.state.format.sup = on
+	case word == "sub":
+		c.state.format.sub = on
+	case word == "nosupersub":
+		c.state.format.sup, c.state.format.sub = false, false
+	case word == "outlinelevel":
+		if param >= 0 && param < 6 {
+			c.para.heading = param + 1
+		}
+	case word == "ls" || word == "ilvl" || word == "pnlvlblt" || word == "pnlvlbody":
+		c.para.list = true
+	case word == "uc":
+		c.state.unicodeSkip = param
+	case word == "u":
+		if param < 0 {
+			param += 65536
+		}
+		c.text(string(rune(param)))
+		c.skipFallback(c.state.unicodeSkip)
+	default:
+		if symbol, ok := rtfSymbols[word]; ok {
+			c.text(symbol)
+		}
+	}
+}
+
+// Skips the ANSI fallback for a \uN character: n characters, where an
+// escaped byte counts as one.
+func (c *rtfConverter) skipFallback(n int) {
+	for ; n > 0 && c.pos < len(c.data); n-- {
+		switch c.data[c.pos] {
+		case '{', '}':
+			return
+		case '\\':
+			if c.pos+1 < len(c.data) && c.data[c.pos+1] == '\'' {
+				c.pos += 4
+				continue
+			}
+			return
+		}
+		c.pos++
+	}
+}
+
+func (c *rtfConverter) peekWord(word string) bool {
+	rest := c.data[c.pos:]
+	rest = bytes.TrimLeft(rest, " \r\n")
+	return bytes.HasPrefix(rest, []byte(`\`+word))
+}
+
+func (c *rtfConverter) text(s string) {
+	switch {
+	case c.state.skip:
+	case c.state.fieldInstr:
+		if c.state.field != nil {
+			c.state.field.instr += s
+		}
+	case c.state.listText:
+		c.para.listMark += s
+	default:
+		run := c.state.format
+		run.text = s
+		c.para.runs = append(c.para.runs, run)
+	}
+}
+
+func (c *rtfConverter) endParagraph() {
+	if c.state.skip {
+		return
+	}
+	para := c.para
+	c.para = rtfParagraph{heading: para.heading, list: para.list}
+	content := strings.TrimSpace(renderRuns(para.runs))
+	if content == "" {
+		return
+	}
+
+	if para.list && para.heading == 0 {
+		tag := "ul"
+		if mark := strings.TrimSpace(para.listMark); mark != "" && (isDigit(mark[0]) || strings.ContainsAny(mark[len(mark)-1:], ".)")) {
+			tag = "ol"
+		}
+		if c.openList != tag {
+			c.closeList()
+			c.buf.WriteString("<" + tag + ">\n")
+			c.openList = tag
+		}
+		c.buf.WriteString("<li>" + content + "</li>\n")
+		return
+	}
+	c.closeList()
+
+	if para.heading > 0 {
+		tag := "h" + strconv.Itoa(para.heading)
+		c.buf.WriteString("<" + tag + ">" + content + "</" + tag + ">\n")
+		return
+	}
+	c.buf.WriteString("<p>" + content + "</p>\n")
+}
+
+func (c *rtfConverter) closeList() {
+	if c.openList != "" {
+		c.buf.WriteString("</" + c.openList + ">\n")
+		c.openList = ""
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,124 @@
+package conversion
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRTFToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		rtf  string
+		want string
+	}{
+		{
+			name: "paragraphs and toggles",
+			rtf:  `{\rtf1\ansi{\fonttbl{\f0 Times;}}\pard Hello \b bold\b0  world\par Second \i it\i0  and \ul under\ulnone\par}`,
+			want: "<p>Hello <strong>bold</strong> world</p>\n<p>Second <em>it</em> and <u>under</u></p>\n",
+		},
+		{
+			name: "nested groups restore formatting",
+			rtf:  `{\rtf1 a{\b b{\i c}d}e\par}`,
+			want: "<p>a<strong>b</strong><strong><em>c</em></strong><strong>d</strong>e</p>\n",
+		},
+		{
+			name: "hex escapes",
+			rtf:  `{\rtf1\ansi caf\'e9 \'93quoted\'94 \'80\par}`,
+			want: "<p>café “quoted” €</p>\n",
+		},
+		{
+			name: "Mac Roman hex escapes",
+			rtf:  `{\rtf1\mac caf\'8e\par}`,
+			want: "<p>café</p>\n",
+		},
+		{
+			name: "unicode escapes skip their fallback",
+			rtf:  `{\rtf1 a\u8212\'97b \u8220?q\u8221? {\uc0\u9731}{\uc2\u20320xxy}\par}`,
+			want: "<p>a—b “q” ☃你y</p>\n",
+		},
+		{
+			name: "negative unicode parameter",
+			rtf:  `{\rtf1 \u-3913?\par}`,
+			want: "<p>\uf0b7</p>\n", // Symbol font bullet
+		},
+		{
+			name: "control symbols",
+			rtf:  `{\rtf1 a\~b\_c\\d\{e\}\tab f\emdash g\par}`,
+			want: "<p>a b‑c\\d{e}\tf—g</p>\n",
+		},
+		{
+			name: "skipped destinations",
+			rtf:  `{\rtf1{\info{\title Hidden}{\author Ada}}{\*\generator Word;}{\*\unknown ignored}{\colortbl;\red0\green0\blue0;}Shown\par}`,
+			want: "<p>Shown</p>\n",
+		},
+		{
+			name: "outline level headings",
+			rtf:  `{\rtf1\pard\outlinelevel0 Title\par\pard\outlinelevel2 Sub\par\pard Body\par}`,
+			want: "<h1>Title</h1>\n<h3>Sub</h3>\n<p>Body</p>\n",
+		},
+		{
+			name: "bulleted then numbered lists",
+			rtf: `{\rtf1{\pntext\bullet\tab}\ls1 one\par{\pntext\bullet\tab}two\par\pard ` +
+				`{\listtext 1.\tab}\ls2 first\par\pard after\par}`,
+			want: "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>\n<p>after</p>\n",
+		},
+		{
+			name: "hyperlink field",
+			rtf:  `{\rtf1 See {\field{\*\fldinst{HYPERLINK "https://example.com/?a=1&b=2"}}{\fldrslt{\ul site}}} now.\par}`,
+			want: "<p>See <a href=\"https://example.com/?a=1&amp;b=2\"><u>site</u></a> now.</p>\n",
+		},
+		{
+			name: "line breaks and escaped newlines",
+			rtf:  "{\\rtf1 one\\line two\\\nthree\r\n\\par}",
+			want: "<p>one<br />two</p>\n<p>three</p>\n",
+		},
+		{
+			name: "HTML in the text is escaped",
+			rtf:  `{\rtf1 <script>&\par}`,
+			want: "<p>&lt;script&gt;&amp;</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rtfToHTML([]byte(tt.rtf))
+			if err != nil {
+				t.Fatalf("rtfToHTML: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("rtfToHTML(%q)\n got: %q\nwant: %q", tt.rtf, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRTFToHTMLMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		rtf     string
+		wantErr bool
+	}{
+		{name: "empty", rtf: "", wantErr: true},
+		{name: "not RTF", rtf: "Hello {world}", wantErr: true},
+		{name: "unclosed groups", rtf: `{\rtf1 {\b unclosed`},
+		{name: "extra closing braces", rtf: `{\rtf1 a}}}}b\par}`},
+		{name: "trailing backslash", rtf: `{\rtf1 a\`},
+		{name: "truncated hex escape", rtf: `{\rtf1 a\'e`},
+		{name: "invalid hex escape", rtf: `{\rtf1 a\'zz\par}`},
+		{name: "truncated unicode fallback", rtf: `{\rtf1 \uc4\u233\'`},
+		{name: "unicode parameter overflow", rtf: `{\rtf1 \u99999999999999999999 x\par}`},
+		{name: "huge fallback count", rtf: `{\rtf1 \uc2147483647\u65 text\par}`},
+		{name: "deep nesting", rtf: `{\rtf1 ` + strings.Repeat("{", 10000) + "deep" + strings.Repeat("}", 10000)},
+		{name: "field without result", rtf: `{\rtf1 {\field{\*\fldinst HYPERLINK "x"`},
+		{name: "result without field", rtf: `{\rtf1 {\fldrslt text}\par}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rtfToHTML([]byte(tt.rtf))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rtfToHTML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
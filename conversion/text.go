@@ -0,0 +1,286 @@
+package conversion
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Matches URLs to link in plain text, with or without a scheme.
+var textURLRegex = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// Matches a list item marker: a bullet, or a number or letter followed by a
+// period or parenthesis.
+var textListMarkerRegex = regexp.MustCompile(`^\s{0,3}(?:([-*+•·–])|(\d{1,3}|[a-zA-Z])[.)])\s+`)
+
+// Matches a line of repeated punctuation used as a separator, such as
+// "-----" or "=====".
+var textRuleRegex = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:=\s*){3,}|(?:_\s*){3,}|(?:\*\s*){3,}|(?:~\s*){3,}|(?:#\s*){3,})$`)
+
+// Lines at least this long are taken to be hard-wrapped, so they are joined
+// to the next line of the paragraph with a space rather than a line break.
+const textWrappedLineLength = 60
+
+// textToHTML renders plain text, such as a text-only newsletter, as HTML.
+// Blank lines separate paragraphs; lines starting with ">" become
+// blockquotes, lines starting with a bullet or number become lists, and
+// indented blocks stay preformatted. Everything is escaped and URLs are
+// linked.
+func textToHTML(text []byte) []byte {
+	normalized := strings.ToValidUTF8(string(text), string(utf8.RuneError))
+	normalized = strings.ReplaceAll(normalized, "\r\n", "\n")
+	normalized = strings.ReplaceAll(normalized, "\r", "\n")
+
+	lines := strings.Split(normalized, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	var b strings.Builder
+	renderTextLines(&b, lines)
+	return []byte(b.String())
+}
+
+func renderTextLines(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		switch {
+		case strings.TrimSpace(lines[i]) == "":
+			i++
+		case isQuotedLine(lines[i]):
+			var quoted []string
+			for ; i < len(lines) && isQuotedLine(lines[i]); i++ {
+				quoted = append(quoted, unquoteLine(lines[i]))
+			}
+			b.WriteString("<blockquote>\n")
+			renderTextLines(b, quoted)
+			b.WriteString("</blockquote>\n")
+		default:
+			start := i
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !isQuotedLine(lines[i]); i++ {
+			}
+			renderTextBlock(b, lines[start:i])
+		}
+	}
+}
+
+// Renders a run of non-blank lines as a preformatted block, a rule, or
+// paragraphs and lists.
+func renderTextBlock(b *strings.Builder, lines []string) {
+	if isPreformattedBlock(lines) {
+		b.WriteString("<pre>")
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(linkText(trimIndent(line, 4)))
+		}
+		b.WriteString("</pre>\n")
+		return
+	}
+
+	var paragraph []string
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + joinTextLines(paragraph) + "</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if textRuleRegex.MatchString(line) {
+			flushParagraph()
+			b.WriteString("<hr />\n")
+			i++
+			continue
+		}
+		if !textListMarkerRegex.MatchString(line) || !startsTextList(lines[i:], len(paragraph) > 0) {
+			paragraph = append(paragraph, line)
+			i++
+			continue
+		}
+
+		flushParagraph()
+		i += renderTextList(b, lines[i:])
+	}
+	flushParagraph()
+}
+
+// Reports whether a line with a list marker starts a list. A single
+// numbered line in the middle of a paragraph, such as a wrapped sentence
+// ending in "in 2024. The", is not a list.
+func startsTextList(lines []string, inParagraph bool) bool {
+	m := textListMarkerRegex.FindStringSubmatch(lines[0])
+	if m[1] != "" && !inParagraph {
+		return true
+	}
+	markers := 0
+	for _, line := range lines {
+		if textListMarkerRegex.MatchString(line) {
+			markers++
+		}
+	}
+	return markers >= 2
+}
+
+// Renders the list starting at lines[0] and returns the number of lines it
+// used. Indented lines continue the previous item.
+func renderTextList(b *strings.Builder, lines []string) int {
+	tag := "ul"
+	if textListMarkerRegex.FindStringSubmatch(lines[0])[1] == "" {
+		tag = "ol"
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	var item []string
+	flushItem := func() {
+		if len(item) > 0 {
+			b.WriteString("<li>" + joinTextLines(item) + "</li>\n")
+			item = nil
+		}
+	}
+
+	i := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := textListMarkerRegex.FindString(line); m != "" {
+			flushItem()
+			item = append(item, line[len(m):])
+			continue
+		}
+		if indentOf(line) == 0 || textRuleRegex.MatchString(line) {
+			break
+		}
+		item = append(item, strings.TrimSpace(line))
+	}
+	flushItem()
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// Joins the lines of a paragraph, keeping short lines (such as those of an
+// address or a poem) on their own.
+func joinTextLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if i > 0 {
+			if utf8.RuneCountInString(strings.TrimSpace(lines[i-1])) >= textWrappedLineLength {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString("<br />\n")
+			}
+		}
+		b.WriteString(linkText(line))
+	}
+	return b.String()
+}
+
+// Escapes text for HTML, linking the URLs in it.
+func linkText(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range textURLRegex.FindAllStringIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		end = start + len(trimURLPunctuation(s[start:end]))
+		if end <= start {
+			continue
+		}
+		b.WriteString(html.EscapeString(s[last:start]))
+		link := s[start:end]
+		href := link
+		if strings.HasPrefix(strings.ToLower(href), "www.") {
+			href = "http://" + href
+		}
+		b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(link) + `</a>`)
+		last = end
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	return b.String()
+}
+
+// Drops punctuation that ends the sentence around a URL rather than the URL
+// itself, keeping closing parentheses that match one in the URL.
+func trimURLPunctuation(url string) string {
+	for len(url) > 0 {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'*>]", last) >= 0:
+			url = url[:len(url)-1]
+		case last == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+			url = url[:len(url)-1]
+		default:
+			return url
+		}
+	}
+	return url
+}
+
+func isQuotedLine(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// Removes one level of quoting from a line.
+func unquoteLine(line string) string {
+	line = strings.TrimPrefix(strings.TrimLeft(line, " "), ">")
+	return strings.TrimPrefix(line, " ")
+}
+
+// Reports whether every line of a block is indented by four spaces, as code
+// and ASCII tables usually are.
+func isPreformattedBlock(lines []string) bool {
+	for _, line := range lines {
+		if indentOf(line) < 4 {
+			return false
+		}
+	}
+	return true
+}
+
+// UnwrapFlowed undoes the soft line breaks of format=flowed text (RFC 3676):
+// a line ending in a space continues on the next line at the same quote
+// depth. With delSp, that trailing space was added by the sender and is
+// removed. Quoted lines keep a "> " marker per level and space-stuffing is
+// undone, so the result reads like ordinary plain text.
+func UnwrapFlowed(text string, delSp bool) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var out []string
+	var current strings.Builder
+	currentDepth := -1 // Quote depth of the open paragraph, or -1 if none
+
+	flush := func() {
+		if currentDepth >= 0 {
+			out = append(out, strings.Repeat("> ", currentDepth)+current.String())
+			current.Reset()
+			currentDepth = -1
+		}
+	}
+
+	for _, line := range lines {
+		depth := 0
+		for depth < len(line) && line[depth] == '>' {
+			depth++
+		}
+		content := strings.TrimPrefix(line[depth:], " ") // Space-stuffing
+
+		if currentDepth >= 0 && depth != currentDepth {
+			flush() // A change of quote depth ends a paragraph
+		}
+		current.WriteString(content)
+		currentDepth = depth
+
+		isFlowed := strings.HasSuffix(content, " ") && content != "-- "
+		if !isFlowed {
+			flush()
+			continue
+		}
+		if delSp {
+			trimmed := current.String()
+			current.Reset()
+			current.WriteString(trimmed[:len(trimmed)-1])
+		}
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
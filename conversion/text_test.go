@@ -0,0 +1,157 @@
+package conversion
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextToHTML(t *testing.T) {
+	wrapped := strings.Repeat("word ", 12) + "end"
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "escaping",
+			text: `a < b & "c" <script>`,
+			want: "<p>a &lt; b &amp; &#34;c&#34; &lt;script&gt;</p>\n",
+		},
+		{
+			name: "paragraphs keep short lines apart",
+			text: "Ada Lovelace\n12 Main St\n\n\nNext paragraph",
+			want: "<p>Ada Lovelace<br />\n12 Main St</p>\n<p>Next paragraph</p>\n",
+		},
+		{
+			name: "hard-wrapped lines are joined",
+			text: wrapped + "\nand more\nshort",
+			want: "<p>" + wrapped + " and more<br />\nshort</p>\n",
+		},
+		{
+			name: "bullet list after a paragraph",
+			text: "Intro:\n- one\n* two\n  continued\nAfter",
+			want: "<p>Intro:</p>\n<ul>\n<li>one</li>\n<li>two<br />\ncontinued</li>\n</ul>\n<p>After</p>\n",
+		},
+		{
+			name: "numbered list",
+			text: "1. first\n2) second\na. third",
+			want: "<ol>\n<li>first</li>\n<li>second</li>\n<li>third</li>\n</ol>\n",
+		},
+		{
+			name: "lone number in a paragraph is not a list",
+			text: "We counted to\n12. Then we stopped",
+			want: "<p>We counted to<br />\n12. Then we stopped</p>\n",
+		},
+		{
+			name: "nested quotes",
+			text: "> quoted\n> > nested\n>> deeper\nreply",
+			want: "<blockquote>\n<p>quoted</p>\n<blockquote>\n<p>nested<br />\ndeeper</p>\n</blockquote>\n</blockquote>\n<p>reply</p>\n",
+		},
+		{
+			name: "indented block is preformatted",
+			text: "    if a < b {\n\t  return\n    }",
+			want: "<pre>if a &lt; b {\n  return\n}</pre>\n",
+		},
+		{
+			name: "separator lines",
+			text: "above\n- - -\nbelow\n=====",
+			want: "<p>above</p>\n<hr />\n<p>below</p>\n<hr />\n",
+		},
+		{
+			name: "links",
+			text: "See https://example.com/a?b=1&c=2. Or www.example.org, (http://x.com/(y)).",
+			want: `<p>See <a href="https://example.com/a?b=1&amp;c=2">https://example.com/a?b=1&amp;c=2</a>. ` +
+				`Or <a href="http://www.example.org">www.example.org</a>, ` +
+				`(<a href="http://x.com/(y)">http://x.com/(y)</a>).</p>` + "\n",
+		},
+		{
+			name: "link text is escaped",
+			text: `https://example.com/"><script>`,
+			want: `<p><a href="https://example.com/">https://example.com/</a>&#34;&gt;&lt;script&gt;</p>` + "\n",
+		},
+		{
+			name: "line endings and invalid UTF-8",
+			text: "a\r\nb\xff\rc",
+			want: "<p>a<br />\nb�<br />\nc</p>\n",
+		},
+		{
+			name: "blank input",
+			text: " \n\t\n",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(textToHTML([]byte(tt.text))); got != tt.want {
+				t.Errorf("textToHTML(%q)\n got: %q\nwant: %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnwrapFlowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		delSp bool
+		want  string
+	}{
+		{
+			name: "soft breaks are joined",
+			text: "This is a \nflowed paragraph.\nFixed line.\n",
+			want: "This is a flowed paragraph.\nFixed line.\n",
+		},
+		{
+			name:  "DelSp removes the added space",
+			text:  "Supercalifragi \nlistic and  \nmore\n",
+			delSp: true,
+			want:  "Supercalifragilistic and more\n",
+		},
+		{
+			name: "without DelSp the space is kept",
+			text: "Supercalifragi \nlistic\n",
+			want: "Supercalifragi listic\n",
+		},
+		{
+			name: "quoted flowed lines",
+			text: "> quoted \n> text\n>> deeper \n>>still\nreply",
+			want: "> quoted text\n> > deeper still\nreply",
+		},
+		{
+			name:  "quoted flowed lines with DelSp",
+			text:  ">> flo \n>> wed\n",
+			delSp: true,
+			want:  "> > flowed\n",
+		},
+		{
+			name: "change of quote depth ends a paragraph",
+			text: "> flowed \nnot quoted",
+			want: "> flowed \nnot quoted",
+		},
+		{
+			name: "space-stuffing is undone",
+			text: " From me\n >not a quote",
+			want: "From me\n>not a quote",
+		},
+		{
+			name: "signature separator is not flowed",
+			text: "Bye\n-- \nAda",
+			want: "Bye\n-- \nAda",
+		},
+		{
+			name: "CRLF line endings",
+			text: "one \r\ntwo\r\n",
+			want: "one two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnwrapFlowed(tt.text, tt.delSp); got != tt.want {
+				t.Errorf("UnwrapFlowed(%q, %v)\n got: %q\nwant: %q", tt.text, tt.delSp, got, tt.want)
+			}
+		})
+	}
+}
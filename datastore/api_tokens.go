@@ -0,0 +1,158 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// apiTokenTouchInterval limits how often last_used_at is rewritten for a
+// token that is used on every request.
+const apiTokenTouchInterval = time.Minute
+
+// APITokenRepository handles database operations for the api_tokens table.
+type APITokenRepository struct {
+	db *sql.DB
+}
+
+// NewAPITokenRepository creates a new APITokenRepository.
+func NewAPITokenRepository(db *sql.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+// CreateAPIToken stores a new token for a user. token.TokenHash must already
+// hold the hash of the plaintext token.
+func (r *APITokenRepository) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	if err := validateAPIToken(token); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, insertAPITokenQuery,
+		token.ID, token.UserID, token.CreatedAt, token.Name, token.TokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert API token: %w", err)
+	}
+	return nil
+}
+
+// GetAPITokenByHash looks up the token whose hash matches tokenHash.
+func (r *APITokenRepository) GetAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	query := `
+		SELECT id, user_id, created_at, name, token_hash, last_used_at
+		FROM api_tokens
+		WHERE token_hash = $1
+	`
+	var token models.APIToken
+	var lastUsedAt sql.NullTime
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+	err := row.Scan(&token.ID, &token.UserID, &token.CreatedAt, &token.Name, &token.TokenHash, &lastUsedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API token not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get API token by hash: %w", err)
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return &token, nil
+}
+
+// GetAPITokensByUserID retrieves a user's tokens, newest first.
+func (r *APITokenRepository) GetAPITokensByUserID(ctx context.Context, userID string) ([]models.APIToken, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, user_id, created_at, name, token_hash, last_used_at
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var token models.APIToken
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.UserID, &token.CreatedAt, &token.Name, &token.TokenHash, &lastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API token row for user %s: %w", userID, err)
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API token rows for user %s: %w", userID, err)
+	}
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes a token, scoped to its owner.
+func (r *APITokenRepository) DeleteAPIToken(ctx context.Context, tokenID string, userID string) error {
+	if _, err := uuid.Parse(tokenID); err != nil {
+		return fmt.Errorf("invalid API token ID format: %w", err)
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token %s: %w", tokenID, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected for API token %s: %w", tokenID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("API token not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// TouchAPIToken records that a token was used at usedAt. Writes are skipped
+// when the stored time is less than apiTokenTouchInterval old.
+func (r *APITokenRepository) TouchAPIToken(ctx context.Context, tokenID string, usedAt time.Time) error {
+	query := `
+		UPDATE api_tokens SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+	if _, err := r.db.ExecContext(ctx, query, tokenID, usedAt, usedAt.Add(-apiTokenTouchInterval)); err != nil {
+		return fmt.Errorf("failed to update last use of API token %s: %w", tokenID, err)
+	}
+	return nil
+}
+
+const insertAPITokenQuery = `
+	INSERT INTO api_tokens (id, user_id, created_at, name, token_hash)
+	VALUES ($1, $2, $3, $4, $5)
+`
+
+func validateAPIToken(token *models.APIToken) error {
+	if _, err := uuid.Parse(token.ID); err != nil {
+		return fmt.Errorf("invalid API token ID format: %w", err)
+	}
+	if _, err := uuid.Parse(token.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if token.Name == "" {
+		return fmt.Errorf("API token name cannot be empty")
+	}
+	if token.TokenHash == "" {
+		return fmt.Errorf("API token hash cannot be empty")
+	}
+	return nil
+}
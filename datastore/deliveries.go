@@ -113,3 +113,84 @@ func (r *DeliveryRepository) UpdateDeliveryStatus(ctx context.Context, deliveryI
 
 	return nil
 }
+
+// RetryableDelivery is a failed, pending or stalled delivery together with its
+// attempt history, from which the retry backoff is computed.
+type RetryableDelivery struct {
+	models.Delivery
+	Attempts      int
+	LastAttemptAt *time.Time
+}
+
+// GetRetryableDeliveries returns failed and pending deliveries, and deliveries
+// left processing since before staleBefore (e.g., because the instance sending
+// them stopped), that have a generated file and fewer than maxAttempts
+// recorded attempts, oldest first.
+func (r *DeliveryRepository) GetRetryableDeliveries(ctx context.Context, maxAttempts int, staleBefore time.Time) ([]RetryableDelivery, error) {
+	query := `
+		SELECT d.id, d.edition_id, d.delivery_destination_id, d.created_at, d.completed_at,
+		       d.edition_format, d.file_path, d.file_size, d.started_at, d.status,
+		       COUNT(a.id), MAX(a.created_at)
+		FROM deliveries d
+		LEFT JOIN delivery_attempts a ON a.delivery_id = d.id
+		WHERE d.file_path <> ''
+		  AND (d.status IN ('failed', 'pending')
+		       OR (d.status = 'processing' AND COALESCE(d.started_at, d.created_at) < $2))
+		GROUP BY d.id
+		HAVING COUNT(a.id) < $1
+		ORDER BY d.created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, maxAttempts, staleBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retryable deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []RetryableDelivery{}
+	for rows.Next() {
+		var d RetryableDelivery
+		var formatStr string
+		var statusStr string
+		if err := rows.Scan(
+			&d.ID, &d.EditionID, &d.DeliveryDestinationID, &d.CreatedAt,
+			&d.CompletedAt, &formatStr, &d.FilePath, &d.FileSize,
+			&d.StartedAt, &statusStr, &d.Attempts, &d.LastAttemptAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan retryable delivery row: %w", err)
+		}
+		d.Format = models.EditionFormat(formatStr)
+		d.Status = models.DeliveryStatus(statusStr)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating retryable delivery rows: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ClaimDeliveryForRetry moves a failed or pending delivery, or one left
+// processing since before staleBefore, to processing. It reports false if the
+// delivery is in any other state, e.g. because another instance claimed it
+// first.
+func (r *DeliveryRepository) ClaimDeliveryForRetry(ctx context.Context, deliveryID string, startedAt, staleBefore time.Time) (bool, error) {
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return false, fmt.Errorf("invalid delivery ID format: %w", err)
+	}
+
+	query := `
+		UPDATE deliveries
+		SET status = $2, started_at = $3, completed_at = NULL
+		WHERE id = $1
+		  AND (status IN ('failed', 'pending')
+		       OR (status = 'processing' AND COALESCE(started_at, created_at) < $4))
+	`
+	result, err := r.db.ExecContext(ctx, query, deliveryID, string(models.DeliveryStatusProcessing), startedAt, staleBefore)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery %s for retry: %w", deliveryID, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check claim of delivery %s: %w", deliveryID, err)
+	}
+	return rowsAffected == 1, nil
+}
@@ -39,25 +39,11 @@ func (r *DestinationRepository) CreateEmailDestination(ctx context.Context, dest
 	// Defer rollback in case of errors
 	defer tx.Rollback() // Rollback is safe even if Commit succeeds
 
-	// 1. Unset other default destinations for this user if this one is default
-	if dest.IsDefault {
-		unsetQuery := `UPDATE delivery_destinations_base SET is_default = false WHERE user_id = $1 AND id != $2`
-		if _, err := tx.ExecContext(ctx, unsetQuery, dest.UserID, dest.ID); err != nil {
-			return fmt.Errorf("failed to unset other default destinations: %w", err)
-		}
+	if err := insertBaseDestination(ctx, tx, dest); err != nil {
+		return err
 	}
 
-	// 2. Insert into base table
-	baseQuery := `
-		INSERT INTO delivery_destinations_base (id, user_id, created_at, is_default, name, type)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
-	_, err = tx.ExecContext(ctx, baseQuery, dest.ID, dest.UserID, dest.CreatedAt, dest.IsDefault, dest.Name, dest.Type)
-	if err != nil {
-		return fmt.Errorf("failed to insert base destination: %w", err)
-	}
-
-	// 3. Insert into email_destinations table
+	// Insert into email_destinations table
 	emailQuery := `INSERT INTO email_destinations (id, email_address) VALUES ($1, $2)`
 	_, err = tx.ExecContext(ctx, emailQuery, dest.ID, emailAddress)
 	if err != nil {
@@ -73,6 +59,68 @@ func (r *DestinationRepository) CreateEmailDestination(ctx context.Context, dest
 	return nil
 }
 
+func (r *DestinationRepository) CreateWebhookDestination(ctx context.Context, dest *models.DeliveryDestination, webhook *models.WebhookDestination) error {
+	if _, err := uuid.Parse(dest.ID); err != nil {
+		return fmt.Errorf("invalid destination ID format: %w", err)
+	}
+	if _, err := uuid.Parse(dest.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	if webhook.URL == "" {
+		return fmt.Errorf("webhook URL cannot be empty")
+	}
+	if webhook.Secret == "" {
+		return fmt.Errorf("webhook secret cannot be empty")
+	}
+	if dest.Type != "webhook" {
+		return fmt.Errorf("destination type must be 'webhook' for this function")
+	}
+	webhook.ID = dest.ID
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertBaseDestination(ctx, tx, dest); err != nil {
+		return err
+	}
+
+	webhookQuery := `INSERT INTO webhook_destinations (id, url, secret) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, webhookQuery, webhook.ID, webhook.URL, webhook.Secret); err != nil {
+		return fmt.Errorf("failed to insert webhook destination details: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertBaseDestination writes the shared delivery_destinations_base row
+// inside tx, first clearing any other default if dest is the new default.
+func insertBaseDestination(ctx context.Context, tx *sql.Tx, dest *models.DeliveryDestination) error {
+	if dest.IsDefault {
+		unsetQuery := `UPDATE delivery_destinations_base SET is_default = false WHERE user_id = $1 AND id != $2`
+		if _, err := tx.ExecContext(ctx, unsetQuery, dest.UserID, dest.ID); err != nil {
+			return fmt.Errorf("failed to unset other default destinations: %w", err)
+		}
+	}
+
+	baseQuery := `
+		INSERT INTO delivery_destinations_base (id, user_id, created_at, is_default, name, type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := tx.ExecContext(ctx, baseQuery, dest.ID, dest.UserID, dest.CreatedAt, dest.IsDefault, dest.Name, dest.Type)
+	if err != nil {
+		return fmt.Errorf("failed to insert base destination: %w", err)
+	}
+	return nil
+}
+
 func (r *DestinationRepository) GetDestinationsByUserID(ctx context.Context, userID string) ([]models.DeliveryDestination, error) {
 	if _, err := uuid.Parse(userID); err != nil {
 		return nil, fmt.Errorf("invalid user ID format: %w", err)
@@ -158,3 +206,55 @@ func (r *DestinationRepository) GetEmailDestinationDetails(ctx context.Context,
 	}
 	return &dest, emailAddress, nil
 }
+
+// GetDestinationByID returns the base record of a destination of any type,
+// so callers can dispatch on Type before loading type-specific details.
+func (r *DestinationRepository) GetDestinationByID(ctx context.Context, destinationID string) (*models.DeliveryDestination, error) {
+	if _, err := uuid.Parse(destinationID); err != nil {
+		return nil, fmt.Errorf("invalid destination ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, user_id, created_at, is_default, name, type
+		FROM delivery_destinations_base
+		WHERE id = $1
+	`
+	var dest models.DeliveryDestination
+	row := r.db.QueryRowContext(ctx, query, destinationID)
+	err := row.Scan(&dest.ID, &dest.UserID, &dest.CreatedAt, &dest.IsDefault, &dest.Name, &dest.Type)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("destination not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get destination %s: %w", destinationID, err)
+	}
+	return &dest, nil
+}
+
+func (r *DestinationRepository) GetWebhookDestinationDetails(ctx context.Context, destinationID string) (*models.DeliveryDestination, *models.WebhookDestination, error) {
+	if _, err := uuid.Parse(destinationID); err != nil {
+		return nil, nil, fmt.Errorf("invalid destination ID format: %w", err)
+	}
+
+	query := `
+		SELECT b.id, b.user_id, b.created_at, b.is_default, b.name, b.type, w.url, w.secret
+		FROM delivery_destinations_base b
+		JOIN webhook_destinations w ON b.id = w.id
+		WHERE b.id = $1 AND b.type = 'webhook'
+	`
+	var dest models.DeliveryDestination
+	var webhook models.WebhookDestination
+	row := r.db.QueryRowContext(ctx, query, destinationID)
+	err := row.Scan(
+		&dest.ID, &dest.UserID, &dest.CreatedAt, &dest.IsDefault, &dest.Name, &dest.Type,
+		&webhook.URL, &webhook.Secret,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("webhook destination not found: %w", err)
+		}
+		return nil, nil, fmt.Errorf("failed to get webhook destination details: %w", err)
+	}
+	webhook.ID = dest.ID
+	return &dest, &webhook, nil
+}
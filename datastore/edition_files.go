@@ -0,0 +1,176 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// EditionFileRepository handles database operations for the edition_files
+// table, the history of generated edition files.
+type EditionFileRepository struct {
+	db *sql.DB
+}
+
+// NewEditionFileRepository creates a new EditionFileRepository.
+func NewEditionFileRepository(db *sql.DB) *EditionFileRepository {
+	return &EditionFileRepository{db: db}
+}
+
+// CreateEditionFile records a generated edition file.
+func (r *EditionFileRepository) CreateEditionFile(ctx context.Context, file *models.EditionFile) error {
+	if _, err := uuid.Parse(file.ID); err != nil {
+		return fmt.Errorf("invalid edition file ID format: %w", err)
+	}
+	if _, err := uuid.Parse(file.EditionID); err != nil {
+		return fmt.Errorf("invalid edition ID format: %w", err)
+	}
+	if file.Format == "" || file.StorageKey == "" || file.ContentHash == "" {
+		return fmt.Errorf("missing required fields for creating edition file")
+	}
+
+	query := `
+		INSERT INTO edition_files (id, edition_id, created_at, edition_format, storage_key, file_size, content_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		file.ID, file.EditionID, file.CreatedAt, string(file.Format), file.StorageKey, file.FileSize, file.ContentHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert edition file: %w", err)
+	}
+	return nil
+}
+
+// GetEditionFileByID retrieves a generated edition file by its ID.
+func (r *EditionFileRepository) GetEditionFileByID(ctx context.Context, fileID string) (*models.EditionFile, error) {
+	if _, err := uuid.Parse(fileID); err != nil {
+		return nil, fmt.Errorf("invalid edition file ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, edition_id, created_at, edition_format, storage_key, file_size, content_hash
+		FROM edition_files
+		WHERE id = $1
+	`
+	file, err := scanEditionFile(r.db.QueryRowContext(ctx, query, fileID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("edition file not found: %w", err)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetEditionFilesByEditionID lists the files generated for an edition, newest
+// first.
+func (r *EditionFileRepository) GetEditionFilesByEditionID(ctx context.Context, editionID string) ([]models.EditionFile, error) {
+	if _, err := uuid.Parse(editionID); err != nil {
+		return nil, fmt.Errorf("invalid edition ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, edition_id, created_at, edition_format, storage_key, file_size, content_hash
+		FROM edition_files
+		WHERE edition_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, editionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files for edition %s: %w", editionID, err)
+	}
+	defer rows.Close()
+
+	files := []models.EditionFile{}
+	for rows.Next() {
+		file, err := scanEditionFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating edition file rows: %w", err)
+	}
+	return files, nil
+}
+
+// GetLatestEditionFile retrieves the most recently generated file for an
+// edition in the given format, or in any format if format is "".
+func (r *EditionFileRepository) GetLatestEditionFile(ctx context.Context, editionID string, format models.EditionFormat) (*models.EditionFile, error) {
+	if _, err := uuid.Parse(editionID); err != nil {
+		return nil, fmt.Errorf("invalid edition ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, edition_id, created_at, edition_format, storage_key, file_size, content_hash
+		FROM edition_files
+		WHERE edition_id = $1 AND ($2 = '' OR edition_format::text = $2)
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	file, err := scanEditionFile(r.db.QueryRowContext(ctx, query, editionID, string(format)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("edition file not found: %w", err)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetLatestEditionFilesByUserID retrieves, for each of a user's editions, the
+// most recently generated file in each format, keyed by edition ID.
+func (r *EditionFileRepository) GetLatestEditionFilesByUserID(ctx context.Context, userID string) (map[string][]models.EditionFile, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT DISTINCT ON (f.edition_id, f.edition_format)
+		       f.id, f.edition_id, f.created_at, f.edition_format, f.storage_key, f.file_size, f.content_hash
+		FROM edition_files f
+		JOIN editions e ON e.id = f.edition_id
+		WHERE e.user_id = $1
+		ORDER BY f.edition_id, f.edition_format, f.created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query edition files for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	files := make(map[string][]models.EditionFile)
+	for rows.Next() {
+		file, err := scanEditionFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files[file.EditionID] = append(files[file.EditionID], *file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating edition file rows: %w", err)
+	}
+	return files, nil
+}
+
+// Scans an edition file from a row, returning sql.ErrNoRows unwrapped.
+func scanEditionFile(row interface{ Scan(...any) error }) (*models.EditionFile, error) {
+	var file models.EditionFile
+	var formatStr string
+	err := row.Scan(
+		&file.ID, &file.EditionID, &file.CreatedAt, &formatStr, &file.StorageKey, &file.FileSize, &file.ContentHash,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan edition file row: %w", err)
+	}
+	file.Format = models.EditionFormat(formatStr)
+	return &file, nil
+}
@@ -0,0 +1,166 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// EditionTemplateDestinationRepository handles database operations for the
+// edition_template_destinations join table.
+type EditionTemplateDestinationRepository struct {
+	db *sql.DB
+}
+
+// NewEditionTemplateDestinationRepository creates a new EditionTemplateDestinationRepository.
+func NewEditionTemplateDestinationRepository(db *sql.DB) *EditionTemplateDestinationRepository {
+	return &EditionTemplateDestinationRepository{db: db}
+}
+
+// AddDestinationToTemplate associates a delivery destination with an edition template.
+func (r *EditionTemplateDestinationRepository) AddDestinationToTemplate(ctx context.Context, templateID string, destinationID string, createdAt time.Time) error {
+	if _, err := uuid.Parse(templateID); err != nil {
+		return fmt.Errorf("invalid edition template ID format: %w", err)
+	}
+	if _, err := uuid.Parse(destinationID); err != nil {
+		return fmt.Errorf("invalid delivery destination ID format: %w", err)
+	}
+	if createdAt.IsZero() {
+		return fmt.Errorf("created_at timestamp must be provided")
+	}
+
+	query := `
+		INSERT INTO edition_template_destinations (edition_template_id, delivery_destination_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (edition_template_id, delivery_destination_id) DO NOTHING
+	`
+	_, err := r.db.ExecContext(ctx, query, templateID, destinationID, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to add destination %s to template %s: %w", destinationID, templateID, err)
+	}
+
+	return nil
+}
+
+// RemoveDestinationFromTemplate removes the association between a delivery destination and an edition template.
+func (r *EditionTemplateDestinationRepository) RemoveDestinationFromTemplate(ctx context.Context, templateID string, destinationID string) error {
+	if _, err := uuid.Parse(templateID); err != nil {
+		return fmt.Errorf("invalid edition template ID format: %w", err)
+	}
+	if _, err := uuid.Parse(destinationID); err != nil {
+		return fmt.Errorf("invalid delivery destination ID format: %w", err)
+	}
+
+	query := `DELETE FROM edition_template_destinations WHERE edition_template_id = $1 AND delivery_destination_id = $2`
+	result, err := r.db.ExecContext(ctx, query, templateID, destinationID)
+	if err != nil {
+		return fmt.Errorf("failed to remove destination %s from template %s: %w", destinationID, templateID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for remove destination operation (template %s, destination %s): %w", templateID, destinationID, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no destination assignment found for template %s and destination %s: %w", templateID, destinationID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// GetDestinationsForTemplate retrieves all delivery destinations assigned to
+// a specific edition template, in the order they were assigned.
+func (r *EditionTemplateDestinationRepository) GetDestinationsForTemplate(ctx context.Context, templateID string) ([]models.DeliveryDestination, error) {
+	if _, err := uuid.Parse(templateID); err != nil {
+		return nil, fmt.Errorf("invalid edition template ID format: %w", err)
+	}
+
+	query := `
+		SELECT d.id, d.user_id, d.created_at, d.is_default, d.name, d.type
+		FROM delivery_destinations_base d
+		JOIN edition_template_destinations etd ON d.id = etd.delivery_destination_id
+		WHERE etd.edition_template_id = $1
+		ORDER BY etd.created_at ASC, d.name ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destinations for template %s: %w", templateID, err)
+	}
+	defer rows.Close()
+
+	var destinations []models.DeliveryDestination
+	for rows.Next() {
+		var dest models.DeliveryDestination
+		if err := rows.Scan(&dest.ID, &dest.UserID, &dest.CreatedAt, &dest.IsDefault, &dest.Name, &dest.Type); err != nil {
+			return nil, fmt.Errorf("failed to scan destination row for template %s: %w", templateID, err)
+		}
+		destinations = append(destinations, dest)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating destination rows for template %s: %w", templateID, err)
+	}
+
+	if destinations == nil {
+		destinations = []models.DeliveryDestination{}
+	}
+
+	return destinations, nil
+}
+
+// GetDeliveryDestinationIDs returns where an edition generated from a template
+// goes: the template's assigned destinations, in the order they were
+// assigned, or if it has none the user's default destination. Returns an
+// empty slice if there is nowhere to deliver to.
+func (r *EditionTemplateDestinationRepository) GetDeliveryDestinationIDs(ctx context.Context, templateID string, userID string) ([]string, error) {
+	if _, err := uuid.Parse(templateID); err != nil {
+		return nil, fmt.Errorf("invalid edition template ID format: %w", err)
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT d.id
+		FROM delivery_destinations_base d
+		JOIN edition_template_destinations etd ON d.id = etd.delivery_destination_id
+		WHERE etd.edition_template_id = $1
+		ORDER BY etd.created_at ASC, d.name ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destinations for template %s: %w", templateID, err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan destination ID for template %s: %w", templateID, err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating destination IDs for template %s: %w", templateID, err)
+	}
+	if len(ids) > 0 {
+		return ids, nil
+	}
+
+	var defaultID string
+	query = `SELECT id FROM delivery_destinations_base WHERE user_id = $1 AND is_default = true LIMIT 1`
+	err = r.db.QueryRowContext(ctx, query, userID).Scan(&defaultID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ids, nil
+		}
+		return nil, fmt.Errorf("failed to get default destination for user %s: %w", userID, err)
+	}
+	return append(ids, defaultID), nil
+}
@@ -143,6 +143,25 @@ func (r *EditionTemplateRepository) GetEditionTemplateByID(ctx context.Context,
 	return &t, nil
 }
 
+// GetEditionTemplateOwnerID returns the ID of the user who owns a template,
+// for authorization checks on routes that address templates by ID alone.
+func (r *EditionTemplateRepository) GetEditionTemplateOwnerID(ctx context.Context, templateID string) (string, error) {
+	if _, err := uuid.Parse(templateID); err != nil {
+		return "", fmt.Errorf("invalid template ID format: %w", err)
+	}
+
+	query := `SELECT user_id FROM edition_templates WHERE id = $1`
+	var userID string
+	err := r.db.QueryRowContext(ctx, query, templateID).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("edition template not found for id %s: %w", templateID, err)
+		}
+		return "", fmt.Errorf("failed to get owner of edition template %s: %w", templateID, err)
+	}
+	return userID, nil
+}
+
 func (r *EditionTemplateRepository) GetEditionTemplatesByUserID(ctx context.Context, userID string) ([]models.EditionTemplate, error) {
 	if _, err := uuid.Parse(userID); err != nil {
 		return nil, fmt.Errorf("invalid user ID format: %w", err)
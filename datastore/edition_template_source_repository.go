@@ -80,7 +80,7 @@ func (r *EditionTemplateSourceRepository) GetSourcesForTemplate(ctx context.Cont
 	}
 
 	query := `
-		SELECT rs.id, rs.created_at, rs.name, rs.type, rs.identifier
+		SELECT rs.id, rs.user_id, rs.created_at, rs.name, rs.type, rs.identifier, rs.split_mode, rs.split_selector
 		FROM reading_sources rs
 		JOIN edition_template_sources ets ON rs.id = ets.reading_source_id
 		WHERE ets.edition_template_id = $1
@@ -95,9 +95,11 @@ func (r *EditionTemplateSourceRepository) GetSourcesForTemplate(ctx context.Cont
 	var sources []models.ReadingSource
 	for rows.Next() {
 		var source models.ReadingSource
-		if err := rows.Scan(&source.ID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier); err != nil {
+		var splitModeStr string
+		if err := rows.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier, &splitModeStr, &source.SplitSelector); err != nil {
 			return nil, fmt.Errorf("failed to scan source row for template %s: %w", templateID, err)
 		}
+		source.SplitMode = models.SplitMode(splitModeStr)
 		sources = append(sources, source)
 	}
 
@@ -124,9 +124,10 @@ func (r *EditionRepository) AddReadingToEdition(ctx context.Context, editionID,
 // For now, let's put it here, but acknowledge the potential mismatch.
 func (r *EditionRepository) GetReadingsForEdition(ctx context.Context, editionID string) ([]models.Reading, error) {
 	query := `
-		SELECT r.id, r.reading_source_id, r.author, r.created_at, r.content_hash,
-		       r.content_body, r.excerpt, r.format, r.published_at, r.storage_path, r.title
+		SELECT r.id, r.user_id, r.reading_source_id, r.author, r.created_at, r.content_hash,
+		       COALESCE(rb.content_body, ''), r.excerpt, r.format, r.published_at, r.storage_path, r.title
 		FROM readings r
+		LEFT JOIN reading_bodies rb ON rb.body_hash = r.body_hash
 		JOIN edition_readings er ON r.id = er.reading_id
 		WHERE er.edition_id = $1
 		ORDER BY r.created_at DESC
@@ -142,7 +143,7 @@ func (r *EditionRepository) GetReadingsForEdition(ctx context.Context, editionID
 		var reading models.Reading
 		var formatStr string
 		if err := rows.Scan(
-			&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+			&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 			&reading.ContentHash, &reading.ContentBody, &reading.Excerpt, &formatStr, &reading.PublishedAt,
 			&reading.StoragePath, &reading.Title,
 		); err != nil {
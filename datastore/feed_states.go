@@ -0,0 +1,68 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// FeedStateRepository handles database operations for the feed_states table.
+type FeedStateRepository struct {
+	db *sql.DB
+}
+
+// NewFeedStateRepository creates a new FeedStateRepository.
+func NewFeedStateRepository(db *sql.DB) *FeedStateRepository {
+	return &FeedStateRepository{db: db}
+}
+
+// GetFeedState retrieves the polling state for a reading source.
+// Returns nil, nil if the feed has never been polled.
+func (r *FeedStateRepository) GetFeedState(ctx context.Context, sourceID string) (*models.FeedState, error) {
+	if _, err := uuid.Parse(sourceID); err != nil {
+		return nil, fmt.Errorf("invalid reading source ID format: %w", err)
+	}
+
+	query := `
+		SELECT reading_source_id, etag, last_modified, last_polled_at, last_error
+		FROM feed_states
+		WHERE reading_source_id = $1
+	`
+	var state models.FeedState
+	row := r.db.QueryRowContext(ctx, query, sourceID)
+	err := row.Scan(&state.ReadingSourceID, &state.ETag, &state.LastModified, &state.LastPolledAt, &state.LastError)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get feed state for source %s: %w", sourceID, err)
+	}
+	return &state, nil
+}
+
+// UpsertFeedState creates or replaces the polling state for a reading source.
+func (r *FeedStateRepository) UpsertFeedState(ctx context.Context, state *models.FeedState) error {
+	if _, err := uuid.Parse(state.ReadingSourceID); err != nil {
+		return fmt.Errorf("invalid reading source ID format: %w", err)
+	}
+
+	query := `
+		INSERT INTO feed_states (reading_source_id, etag, last_modified, last_polled_at, last_error)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (reading_source_id) DO UPDATE
+		SET etag = EXCLUDED.etag,
+		    last_modified = EXCLUDED.last_modified,
+		    last_polled_at = EXCLUDED.last_polled_at,
+		    last_error = EXCLUDED.last_error
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		state.ReadingSourceID, state.ETag, state.LastModified, state.LastPolledAt, state.LastError,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert feed state for source %s: %w", state.ReadingSourceID, err)
+	}
+	return nil
+}
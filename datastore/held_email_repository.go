@@ -0,0 +1,158 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// HeldEmailRepository handles database operations for the held_emails table.
+type HeldEmailRepository struct {
+	db *sql.DB
+}
+
+// NewHeldEmailRepository creates a new HeldEmailRepository.
+func NewHeldEmailRepository(db *sql.DB) *HeldEmailRepository {
+	return &HeldEmailRepository{db: db}
+}
+
+// CreateHeldEmail records an inbound email that was rejected or quarantined.
+func (r *HeldEmailRepository) CreateHeldEmail(ctx context.Context, held *models.HeldEmail) error {
+	if _, err := uuid.Parse(held.ID); err != nil {
+		return fmt.Errorf("invalid held email ID format: %w", err)
+	}
+	if _, err := uuid.Parse(held.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if held.SenderEmail == "" {
+		return fmt.Errorf("sender email cannot be empty")
+	}
+	if held.Reason == "" {
+		return fmt.Errorf("held email reason cannot be empty")
+	}
+
+	query := `
+		INSERT INTO held_emails (
+			id, user_id, created_at, sender_email, subject,
+			message_id, reason, status, raw_mime
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		held.ID, held.UserID, held.CreatedAt, held.SenderEmail, held.Subject,
+		held.MessageID, held.Reason, string(held.Status), held.RawMIME,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert held email: %w", err)
+	}
+	return nil
+}
+
+// GetHeldEmailsByUserID retrieves held emails for a user, newest first.
+// If status is empty, emails in every status are returned.
+func (r *HeldEmailRepository) GetHeldEmailsByUserID(ctx context.Context, userID string, status models.HeldEmailStatus) ([]models.HeldEmail, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, user_id, created_at, sender_email, subject,
+		       message_id, reason, status, released_at
+		FROM held_emails
+		WHERE user_id = $1 AND ($2 = '' OR status::text = $2)
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query held emails for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var heldEmails []models.HeldEmail
+	for rows.Next() {
+		var held models.HeldEmail
+		var statusStr string
+		if err := rows.Scan(
+			&held.ID, &held.UserID, &held.CreatedAt, &held.SenderEmail, &held.Subject,
+			&held.MessageID, &held.Reason, &statusStr, &held.ReleasedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan held email row for user %s: %w", userID, err)
+		}
+		held.Status = models.HeldEmailStatus(statusStr)
+		heldEmails = append(heldEmails, held)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating held email rows for user %s: %w", userID, err)
+	}
+
+	if heldEmails == nil {
+		heldEmails = []models.HeldEmail{}
+	}
+
+	return heldEmails, nil
+}
+
+// GetHeldEmailByID retrieves a single held email for a user, including its raw MIME.
+func (r *HeldEmailRepository) GetHeldEmailByID(ctx context.Context, heldEmailID string, userID string) (*models.HeldEmail, error) {
+	if _, err := uuid.Parse(heldEmailID); err != nil {
+		return nil, fmt.Errorf("invalid held email ID format: %w", err)
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, user_id, created_at, sender_email, subject,
+		       message_id, reason, status, raw_mime, released_at
+		FROM held_emails
+		WHERE id = $1 AND user_id = $2
+	`
+	var held models.HeldEmail
+	var statusStr string
+	row := r.db.QueryRowContext(ctx, query, heldEmailID, userID)
+	err := row.Scan(
+		&held.ID, &held.UserID, &held.CreatedAt, &held.SenderEmail, &held.Subject,
+		&held.MessageID, &held.Reason, &statusStr, &held.RawMIME, &held.ReleasedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("held email not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get held email by ID: %w", err)
+	}
+	held.Status = models.HeldEmailStatus(statusStr)
+	return &held, nil
+}
+
+// MarkHeldEmailReleased flags a held email as released into the ingestion pipeline.
+func (r *HeldEmailRepository) MarkHeldEmailReleased(ctx context.Context, heldEmailID string, userID string, releasedAt time.Time) error {
+	if _, err := uuid.Parse(heldEmailID); err != nil {
+		return fmt.Errorf("invalid held email ID format: %w", err)
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		UPDATE held_emails
+		SET status = $3, released_at = $4
+		WHERE id = $1 AND user_id = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, heldEmailID, userID, string(models.HeldEmailStatusReleased), releasedAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark held email %s as released: %w", heldEmailID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for release of held email %s: %w", heldEmailID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("held email not found (ID: %s, UserID: %s): %w", heldEmailID, userID, sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// IngestionJobRepository handles database operations for the ingestion_jobs
+// table, which serves as a durable queue for inbound email.
+type IngestionJobRepository struct {
+	db *sql.DB
+}
+
+// NewIngestionJobRepository creates a new IngestionJobRepository.
+func NewIngestionJobRepository(db *sql.DB) *IngestionJobRepository {
+	return &IngestionJobRepository{db: db}
+}
+
+const ingestionJobColumns = `
+	id, user_id, created_at, updated_at, sender, subject, raw_mime,
+	status, attempts, run_after, locked_until, last_error, completed_at
+`
+
+// CreateIngestionJob enqueues an inbound email for processing.
+func (r *IngestionJobRepository) CreateIngestionJob(ctx context.Context, job *models.IngestionJob) error {
+	if _, err := uuid.Parse(job.ID); err != nil {
+		return fmt.Errorf("invalid ingestion job ID format: %w", err)
+	}
+	if _, err := uuid.Parse(job.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if job.RawMIME == "" {
+		return fmt.Errorf("ingestion job raw MIME cannot be empty")
+	}
+
+	query := `
+		INSERT INTO ingestion_jobs (
+			id, user_id, created_at, updated_at, sender, subject, raw_mime,
+			status, attempts, run_after
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		job.ID, job.UserID, job.CreatedAt, job.UpdatedAt, job.Sender, job.Subject, job.RawMIME,
+		string(job.Status), job.Attempts, job.RunAfter,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert ingestion job: %w", err)
+	}
+	return nil
+}
+
+// ClaimIngestionJob takes the next due job, counts an attempt and leases it
+// to the caller until now+lease. Pending jobs, failed jobs whose retry is due
+// and running jobs whose lease has expired (e.g., because the instance
+// processing them stopped) are due. Concurrent callers skip each other's
+// rows rather than waiting on them. Returns nil if no job is due.
+func (r *IngestionJobRepository) ClaimIngestionJob(ctx context.Context, now time.Time, lease time.Duration) (*models.IngestionJob, error) {
+	query := `
+		UPDATE ingestion_jobs
+		SET status = 'running', attempts = attempts + 1, locked_until = $2, updated_at = $1
+		WHERE id = (
+			SELECT id FROM ingestion_jobs
+			WHERE (status IN ('pending', 'failed') AND run_after <= $1)
+			   OR (status = 'running' AND locked_until < $1)
+			ORDER BY run_after ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + ingestionJobColumns
+	job, err := scanIngestionJob(r.db.QueryRowContext(ctx, query, now, now.Add(lease)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim ingestion job: %w", err)
+	}
+	return job, nil
+}
+
+// GetIngestionJobByID retrieves a single ingestion job, including its raw MIME.
+func (r *IngestionJobRepository) GetIngestionJobByID(ctx context.Context, jobID string) (*models.IngestionJob, error) {
+	if _, err := uuid.Parse(jobID); err != nil {
+		return nil, fmt.Errorf("invalid ingestion job ID format: %w", err)
+	}
+
+	query := `SELECT ` + ingestionJobColumns + ` FROM ingestion_jobs WHERE id = $1`
+	job, err := scanIngestionJob(r.db.QueryRowContext(ctx, query, jobID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("ingestion job not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get ingestion job by ID: %w", err)
+	}
+	return job, nil
+}
+
+// MarkIngestionJobSucceeded records that a job was processed.
+func (r *IngestionJobRepository) MarkIngestionJobSucceeded(ctx context.Context, jobID string, completedAt time.Time) error {
+	query := `
+		UPDATE ingestion_jobs
+		SET status = $2, locked_until = NULL, last_error = NULL, completed_at = $3, updated_at = $3
+		WHERE id = $1
+	`
+	return r.updateJob(ctx, jobID, query, string(models.IngestionJobStatusSucceeded), completedAt)
+}
+
+// ScheduleIngestionJobRetry records a failed attempt and when to try again.
+func (r *IngestionJobRepository) ScheduleIngestionJobRetry(ctx context.Context, jobID string, lastError string, runAfter time.Time) error {
+	query := `
+		UPDATE ingestion_jobs
+		SET status = $2, locked_until = NULL, last_error = $3, run_after = $4, updated_at = $5
+		WHERE id = $1
+	`
+	return r.updateJob(ctx, jobID, query, string(models.IngestionJobStatusFailed), lastError, runAfter, time.Now().UTC())
+}
+
+// MarkIngestionJobDead moves a job to the dead-letter state, where it stays
+// until it is reprocessed by hand.
+func (r *IngestionJobRepository) MarkIngestionJobDead(ctx context.Context, jobID string, lastError string, completedAt time.Time) error {
+	query := `
+		UPDATE ingestion_jobs
+		SET status = $2, locked_until = NULL, last_error = $3, completed_at = $4, updated_at = $4
+		WHERE id = $1
+	`
+	return r.updateJob(ctx, jobID, query, string(models.IngestionJobStatusDead), lastError, completedAt)
+}
+
+func (r *IngestionJobRepository) updateJob(ctx context.Context, jobID, query string, args ...any) error {
+	if _, err := uuid.Parse(jobID); err != nil {
+		return fmt.Errorf("invalid ingestion job ID format: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, append([]any{jobID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update ingestion job %s: %w", jobID, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for ingestion job %s: %w", jobID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("ingestion job not found (ID: %s): %w", jobID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func scanIngestionJob(row *sql.Row) (*models.IngestionJob, error) {
+	var job models.IngestionJob
+	var statusStr string
+	var lastError sql.NullString
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.CreatedAt, &job.UpdatedAt, &job.Sender, &job.Subject, &job.RawMIME,
+		&statusStr, &job.Attempts, &job.RunAfter, &job.LockedUntil, &lastError, &job.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Status = models.IngestionJobStatus(statusStr)
+	job.LastError = lastError.String
+	return &job, nil
+}
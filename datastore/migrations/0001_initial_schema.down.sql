@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS delivery_attempts;
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS allowed_senders;
+DROP TABLE IF EXISTS email_destinations;
+DROP TABLE IF EXISTS delivery_destinations_base;
+DROP TABLE IF EXISTS edition_readings;
+DROP TABLE IF EXISTS user_reading_sources;
+DROP TABLE IF EXISTS user_readings;
+DROP TABLE IF EXISTS readings;
+DROP TABLE IF EXISTS editions;
+DROP TABLE IF EXISTS edition_templates;
+DROP TABLE IF EXISTS reading_sources;
+DROP TABLE IF EXISTS users;
+
+DROP TYPE IF EXISTS delivery_status;
+DROP TYPE IF EXISTS reading_source_type;
+DROP TYPE IF EXISTS delivery_destination_type;
+DROP TYPE IF EXISTS edition_format;
+DROP TYPE IF EXISTS edition_delivery_interval;
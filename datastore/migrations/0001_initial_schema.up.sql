@@ -0,0 +1,191 @@
+-- Baseline schema. Types and tables are created only if missing so that
+-- databases built by hand from db.md can adopt migrations in place.
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'edition_delivery_interval') THEN
+    CREATE TYPE edition_delivery_interval AS ENUM
+      ('hourly', 'daily', 'weekly', 'monthly');
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'edition_format') THEN
+    CREATE TYPE edition_format AS ENUM('epub', 'mobi', 'pdf');
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'delivery_destination_type') THEN
+    CREATE TYPE delivery_destination_type AS ENUM('api', 'email', 'webhook');
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'reading_source_type') THEN
+    CREATE TYPE reading_source_type AS ENUM('api', 'email', 'rss');
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'delivery_status') THEN
+    CREATE TYPE delivery_status AS ENUM
+      ('delivered', 'failed', 'pending', 'processing');
+  END IF;
+END
+$$;
+
+
+CREATE TABLE IF NOT EXISTS users(
+  id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  email varchar(255) NOT NULL,
+  email_token varchar(32) NOT NULL,
+  CONSTRAINT users_pkey PRIMARY KEY(id)
+);
+
+
+CREATE TABLE IF NOT EXISTS reading_sources(
+  id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  "name" text NOT NULL,
+  "type" reading_source_type NOT NULL,
+  identifier varchar NOT NULL,
+  CONSTRAINT reading_sources_pkey PRIMARY KEY(id)
+);
+
+
+CREATE TABLE IF NOT EXISTS edition_templates(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  delivery_interval edition_delivery_interval NOT NULL,
+  delivery_time time NOT NULL,
+  description text,
+  format edition_format NOT NULL,
+  is_recurring boolean NOT NULL,
+  "name" text NOT NULL,
+  CONSTRAINT edition_templates_pkey PRIMARY KEY(id),
+  CONSTRAINT edition_templates_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS editions(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  edition_template_id uuid NOT NULL,
+  "name" varchar(100) NOT NULL,
+  CONSTRAINT editions_pkey PRIMARY KEY(id),
+  CONSTRAINT editions_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT editions_edition_template_id_fkey
+    FOREIGN KEY (edition_template_id) REFERENCES edition_templates (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS readings(
+  id uuid NOT NULL,
+  reading_source_id uuid NOT NULL,
+  author text,
+  created_at timestamp NOT NULL,
+  content_hash varchar(64) NOT NULL,
+  excerpt text NOT NULL,
+  format varchar(10) NOT NULL DEFAULT 'html',
+  published_at timestamp with time zone,
+  storage_path varchar(255) NOT NULL,
+  title text NOT NULL,
+  CONSTRAINT readings_pkey PRIMARY KEY(id),
+  CONSTRAINT readings_reading_source_id_fkey
+    FOREIGN KEY (reading_source_id) REFERENCES reading_sources (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS user_readings(
+  reading_id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  received_at timestamp with time zone NOT NULL,
+  CONSTRAINT user_readings_pkey PRIMARY KEY(user_id, reading_id),
+  CONSTRAINT user_readings_reading_id_fkey
+    FOREIGN KEY (reading_id) REFERENCES readings (id) ON DELETE Cascade,
+  CONSTRAINT user_readings_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade
+);
+
+
+CREATE TABLE IF NOT EXISTS user_reading_sources(
+  reading_source_id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT user_reading_sources_pkey PRIMARY KEY(reading_source_id, user_id),
+  CONSTRAINT user_reading_sources_reading_source_id_fkey
+    FOREIGN KEY (reading_source_id) REFERENCES reading_sources (id),
+  CONSTRAINT user_reading_sources_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS edition_readings(
+  edition_id uuid NOT NULL,
+  reading_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT edition_readings_pkey PRIMARY KEY(edition_id, reading_id),
+  CONSTRAINT edition_readings_edition_id_fkey
+    FOREIGN KEY (edition_id) REFERENCES editions (id),
+  CONSTRAINT edition_readings_reading_id_fkey
+    FOREIGN KEY (reading_id) REFERENCES readings (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS delivery_destinations_base(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  is_default bool NOT NULL,
+  "name" text NOT NULL,
+  "type" delivery_destination_type NOT NULL,
+  CONSTRAINT delivery_destinations_base_pkey PRIMARY KEY(id),
+  CONSTRAINT delivery_destinations_base_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS email_destinations(
+  id uuid NOT NULL,
+  email_address varchar(255) NOT NULL,
+  CONSTRAINT email_destinations_pkey PRIMARY KEY(id),
+  CONSTRAINT email_destinations_id_fkey
+    FOREIGN KEY (id) REFERENCES delivery_destinations_base (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS allowed_senders(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  email_pattern varchar(255) NOT NULL,
+  "name" text NOT NULL,
+  CONSTRAINT allowed_senders_pkey PRIMARY KEY(id),
+  CONSTRAINT allowed_senders_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS deliveries(
+  id uuid NOT NULL,
+  edition_id uuid NOT NULL,
+  delivery_destination_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  completed_at timestamp,
+  edition_format edition_format NOT NULL,
+  file_path text NOT NULL,
+  file_size integer NOT NULL,
+  started_at timestamp,
+  status delivery_status NOT NULL,
+  CONSTRAINT deliveries_pkey PRIMARY KEY(id),
+  CONSTRAINT deliveries_delivery_destination_id_fkey
+    FOREIGN KEY (delivery_destination_id) REFERENCES delivery_destinations_base (id),
+  CONSTRAINT deliveries_edition_id_fkey
+    FOREIGN KEY (edition_id) REFERENCES editions (id)
+);
+
+
+CREATE TABLE IF NOT EXISTS delivery_attempts(
+  id uuid NOT NULL,
+  delivery_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  error_message text,
+  status delivery_status NOT NULL,
+  CONSTRAINT delivery_attempts_pkey PRIMARY KEY(id),
+  CONSTRAINT delivery_attempts_delivery_id_fkey
+    FOREIGN KEY (delivery_id) REFERENCES deliveries (id)
+);
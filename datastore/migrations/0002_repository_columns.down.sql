@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS edition_template_sources;
+
+ALTER TABLE readings DROP COLUMN IF EXISTS content_body;
+ALTER TABLE editions DROP COLUMN IF EXISTS created_at;
+ALTER TABLE edition_templates DROP COLUMN IF EXISTS color_images;
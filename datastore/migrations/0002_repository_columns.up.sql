@@ -0,0 +1,22 @@
+-- Columns and tables the repositories use that db.md never described.
+
+ALTER TABLE edition_templates
+  ADD COLUMN IF NOT EXISTS color_images boolean NOT NULL DEFAULT false;
+
+ALTER TABLE editions
+  ADD COLUMN IF NOT EXISTS created_at timestamp NOT NULL DEFAULT now();
+
+ALTER TABLE readings
+  ADD COLUMN IF NOT EXISTS content_body text NOT NULL DEFAULT '';
+
+
+CREATE TABLE IF NOT EXISTS edition_template_sources(
+  edition_template_id uuid NOT NULL,
+  reading_source_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT edition_template_sources_pkey PRIMARY KEY(edition_template_id, reading_source_id),
+  CONSTRAINT edition_template_sources_edition_template_id_fkey
+    FOREIGN KEY (edition_template_id) REFERENCES edition_templates (id) ON DELETE Cascade,
+  CONSTRAINT edition_template_sources_reading_source_id_fkey
+    FOREIGN KEY (reading_source_id) REFERENCES reading_sources (id) ON DELETE Cascade
+);
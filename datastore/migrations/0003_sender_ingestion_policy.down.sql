@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS held_emails;
+
+ALTER TABLE users DROP COLUMN IF EXISTS ingestion_policy;
+
+DROP TYPE IF EXISTS held_email_status;
+DROP TYPE IF EXISTS ingestion_policy;
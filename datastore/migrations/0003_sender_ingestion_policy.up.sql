@@ -0,0 +1,31 @@
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'ingestion_policy') THEN
+    CREATE TYPE ingestion_policy AS ENUM('open', 'allowlist', 'quarantine');
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'held_email_status') THEN
+    CREATE TYPE held_email_status AS ENUM('rejected', 'quarantined', 'released');
+  END IF;
+END
+$$;
+
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS ingestion_policy ingestion_policy NOT NULL DEFAULT 'open';
+
+
+CREATE TABLE IF NOT EXISTS held_emails(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  sender_email varchar(255) NOT NULL,
+  subject text NOT NULL,
+  message_id text NOT NULL,
+  reason text NOT NULL,
+  status held_email_status NOT NULL,
+  raw_mime text NOT NULL,
+  released_at timestamp,
+  CONSTRAINT held_emails_pkey PRIMARY KEY(id),
+  CONSTRAINT held_emails_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade
+);
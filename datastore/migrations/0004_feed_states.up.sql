@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS feed_states(
+  reading_source_id uuid NOT NULL,
+  etag text NOT NULL DEFAULT '',
+  last_modified text NOT NULL DEFAULT '',
+  last_polled_at timestamp,
+  last_error text NOT NULL DEFAULT '',
+  CONSTRAINT feed_states_pkey PRIMARY KEY(reading_source_id),
+  CONSTRAINT feed_states_reading_source_id_fkey
+    FOREIGN KEY (reading_source_id) REFERENCES reading_sources (id) ON DELETE Cascade
+);
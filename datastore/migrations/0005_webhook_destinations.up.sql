@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS webhook_destinations(
+  id uuid NOT NULL,
+  url text NOT NULL,
+  secret varchar(64) NOT NULL,
+  CONSTRAINT webhook_destinations_pkey PRIMARY KEY(id),
+  CONSTRAINT webhook_destinations_id_fkey
+    FOREIGN KEY (id) REFERENCES delivery_destinations_base (id)
+);
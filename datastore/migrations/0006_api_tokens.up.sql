@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_tokens(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  name varchar(255) NOT NULL,
+  token_hash varchar(64) NOT NULL,
+  last_used_at timestamp,
+  CONSTRAINT api_tokens_pkey PRIMARY KEY(id),
+  CONSTRAINT api_tokens_token_hash_key UNIQUE(token_hash),
+  CONSTRAINT api_tokens_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reading_images(
+  reading_id uuid NOT NULL,
+  content_hash varchar(64) NOT NULL,
+  content_type varchar(100) NOT NULL,
+  "data" bytea NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT reading_images_pkey PRIMARY KEY(reading_id, content_hash),
+  CONSTRAINT reading_images_reading_id_fkey
+    FOREIGN KEY (reading_id) REFERENCES readings (id) ON DELETE Cascade
+);
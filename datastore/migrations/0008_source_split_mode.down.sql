@@ -0,0 +1,5 @@
+ALTER TABLE reading_sources
+  DROP COLUMN IF EXISTS split_selector,
+  DROP COLUMN IF EXISTS split_mode;
+
+DROP TYPE IF EXISTS source_split_mode;
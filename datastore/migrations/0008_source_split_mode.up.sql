@@ -0,0 +1,12 @@
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'source_split_mode') THEN
+    CREATE TYPE source_split_mode AS ENUM('none', 'headings', 'selector');
+  END IF;
+END
+$$;
+
+
+ALTER TABLE reading_sources
+  ADD COLUMN IF NOT EXISTS split_mode source_split_mode NOT NULL DEFAULT 'none',
+  ADD COLUMN IF NOT EXISTS split_selector text NOT NULL DEFAULT '';
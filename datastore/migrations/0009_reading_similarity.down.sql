@@ -0,0 +1,3 @@
+ALTER TABLE readings
+  DROP COLUMN IF EXISTS duplicate_of,
+  DROP COLUMN IF EXISTS simhash;
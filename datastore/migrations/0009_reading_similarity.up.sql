@@ -0,0 +1,4 @@
+ALTER TABLE readings
+  ADD COLUMN IF NOT EXISTS simhash bigint,
+  ADD COLUMN IF NOT EXISTS duplicate_of uuid
+    CONSTRAINT readings_duplicate_of_fkey REFERENCES readings (id) ON DELETE Set null;
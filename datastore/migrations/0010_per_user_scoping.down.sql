@@ -0,0 +1,19 @@
+-- Per-user copies of sources and readings made by the up migration remain
+-- separate rows.
+
+ALTER TABLE readings
+  ADD COLUMN IF NOT EXISTS content_body text NOT NULL DEFAULT '';
+
+UPDATE readings r
+  SET content_body = b.content_body
+  FROM reading_bodies b
+  WHERE b.body_hash = r.body_hash;
+
+ALTER TABLE readings
+  DROP COLUMN IF EXISTS body_hash,
+  DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS reading_bodies;
+
+ALTER TABLE reading_sources
+  DROP COLUMN IF EXISTS user_id;
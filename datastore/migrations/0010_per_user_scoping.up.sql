@@ -0,0 +1,166 @@
+-- Scopes reading sources and readings to a single user. Rows that several
+-- users shared are copied so that each user gets their own. Content bodies
+-- move to reading_bodies, keyed by the SHA-256 of the exact stored body, so
+-- identical bodies are still stored once without one user's copy standing in
+-- for another's.
+
+CREATE TABLE IF NOT EXISTS reading_bodies(
+  body_hash varchar(64) NOT NULL,
+  content_body text NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT reading_bodies_pkey PRIMARY KEY(body_hash)
+);
+
+
+ALTER TABLE reading_sources
+  ADD COLUMN IF NOT EXISTS user_id uuid;
+
+ALTER TABLE readings
+  ADD COLUMN IF NOT EXISTS user_id uuid,
+  ADD COLUMN IF NOT EXISTS body_hash varchar(64);
+
+
+UPDATE readings
+  SET body_hash = encode(sha256(convert_to(content_body, 'UTF8')), 'hex');
+
+INSERT INTO reading_bodies (body_hash, content_body, created_at)
+  SELECT DISTINCT ON (body_hash) body_hash, content_body, created_at
+  FROM readings
+  ORDER BY body_hash, created_at
+ON CONFLICT (body_hash) DO NOTHING;
+
+
+-- Every user a source has served: its subscribers, the recipients of its
+-- readings and the owners of templates it is assigned to. One user keeps the
+-- existing row; the others get a copy with an ID derived from source and user.
+CREATE TEMP TABLE source_owners ON COMMIT DROP AS
+  SELECT source_id, user_id,
+    CASE WHEN row_number() OVER (PARTITION BY source_id ORDER BY user_id) = 1
+      THEN source_id
+      ELSE md5(source_id::text || user_id::text)::uuid
+    END AS new_id
+  FROM (
+    SELECT reading_source_id AS source_id, user_id FROM user_reading_sources
+    UNION
+    SELECT r.reading_source_id, ur.user_id
+    FROM readings r
+    JOIN user_readings ur ON ur.reading_id = r.id
+    UNION
+    SELECT ets.reading_source_id, et.user_id
+    FROM edition_template_sources ets
+    JOIN edition_templates et ON et.id = ets.edition_template_id
+  ) owners;
+
+INSERT INTO reading_sources (id, user_id, created_at, name, type, identifier, split_mode, split_selector)
+  SELECT so.new_id, so.user_id, rs.created_at, rs.name, rs.type, rs.identifier, rs.split_mode, rs.split_selector
+  FROM source_owners so
+  JOIN reading_sources rs ON rs.id = so.source_id
+  WHERE so.new_id <> so.source_id;
+
+UPDATE reading_sources rs
+  SET user_id = so.user_id
+  FROM source_owners so
+  WHERE so.source_id = rs.id AND so.new_id = rs.id;
+
+INSERT INTO feed_states (reading_source_id, etag, last_modified, last_polled_at, last_error)
+  SELECT so.new_id, fs.etag, fs.last_modified, fs.last_polled_at, fs.last_error
+  FROM source_owners so
+  JOIN feed_states fs ON fs.reading_source_id = so.source_id
+  WHERE so.new_id <> so.source_id;
+
+UPDATE user_reading_sources urs
+  SET reading_source_id = so.new_id
+  FROM source_owners so
+  WHERE so.source_id = urs.reading_source_id AND so.user_id = urs.user_id
+    AND so.new_id <> so.source_id;
+
+UPDATE edition_template_sources ets
+  SET reading_source_id = so.new_id
+  FROM edition_templates et, source_owners so
+  WHERE et.id = ets.edition_template_id
+    AND so.source_id = ets.reading_source_id AND so.user_id = et.user_id
+    AND so.new_id <> so.source_id;
+
+
+-- Readings are split the same way, by the users they were linked to. Each copy
+-- is attributed to that user's copy of the source.
+CREATE TEMP TABLE reading_owners ON COMMIT DROP AS
+  SELECT reading_id, user_id,
+    CASE WHEN row_number() OVER (PARTITION BY reading_id ORDER BY user_id) = 1
+      THEN reading_id
+      ELSE md5(reading_id::text || user_id::text)::uuid
+    END AS new_id
+  FROM user_readings;
+
+INSERT INTO readings (
+    id, user_id, reading_source_id, author, created_at, content_hash, body_hash,
+    excerpt, format, published_at, storage_path, title, simhash, duplicate_of
+  )
+  SELECT ro.new_id, ro.user_id, so.new_id, r.author, r.created_at, r.content_hash, r.body_hash,
+    r.excerpt, r.format, r.published_at, r.storage_path, r.title, r.simhash, r.duplicate_of
+  FROM reading_owners ro
+  JOIN readings r ON r.id = ro.reading_id
+  JOIN source_owners so ON so.source_id = r.reading_source_id AND so.user_id = ro.user_id
+  WHERE ro.new_id <> ro.reading_id;
+
+UPDATE readings r
+  SET user_id = ro.user_id, reading_source_id = so.new_id
+  FROM reading_owners ro, source_owners so
+  WHERE ro.reading_id = r.id AND ro.new_id = r.id
+    AND so.source_id = r.reading_source_id AND so.user_id = ro.user_id;
+
+INSERT INTO reading_images (reading_id, content_hash, content_type, "data", created_at)
+  SELECT ro.new_id, ri.content_hash, ri.content_type, ri."data", ri.created_at
+  FROM reading_owners ro
+  JOIN reading_images ri ON ri.reading_id = ro.reading_id
+  WHERE ro.new_id <> ro.reading_id;
+
+UPDATE user_readings ur
+  SET reading_id = ro.new_id
+  FROM reading_owners ro
+  WHERE ro.reading_id = ur.reading_id AND ro.user_id = ur.user_id
+    AND ro.new_id <> ro.reading_id;
+
+UPDATE edition_readings er
+  SET reading_id = ro.new_id
+  FROM editions e, reading_owners ro
+  WHERE e.id = er.edition_id
+    AND ro.reading_id = er.reading_id AND ro.user_id = e.user_id
+    AND ro.new_id <> ro.reading_id;
+
+-- Near-duplicate links point at the same user's copy of the original, or
+-- nowhere if the user never had it.
+UPDATE readings r
+  SET duplicate_of = ro.new_id
+  FROM reading_owners ro
+  WHERE ro.reading_id = r.duplicate_of AND ro.user_id = r.user_id;
+
+UPDATE readings r
+  SET duplicate_of = NULL
+  FROM readings original
+  WHERE original.id = r.duplicate_of AND original.user_id IS DISTINCT FROM r.user_id;
+
+
+-- Readings and sources no user ever received or subscribed to are unreachable.
+DELETE FROM edition_readings er
+  USING readings r
+  WHERE r.id = er.reading_id AND r.user_id IS NULL;
+
+DELETE FROM readings WHERE user_id IS NULL;
+
+DELETE FROM reading_sources WHERE user_id IS NULL;
+
+
+ALTER TABLE reading_sources
+  ALTER COLUMN user_id SET NOT NULL,
+  ADD CONSTRAINT reading_sources_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade;
+
+ALTER TABLE readings
+  ALTER COLUMN user_id SET NOT NULL,
+  ALTER COLUMN body_hash SET NOT NULL,
+  DROP COLUMN content_body,
+  ADD CONSTRAINT readings_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade,
+  ADD CONSTRAINT readings_body_hash_fkey
+    FOREIGN KEY (body_hash) REFERENCES reading_bodies (body_hash);
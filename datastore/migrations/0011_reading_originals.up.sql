@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reading_originals(
+  reading_id uuid NOT NULL,
+  file_name text NOT NULL,
+  format varchar(10) NOT NULL,
+  content_type varchar(100) NOT NULL,
+  "data" bytea NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT reading_originals_pkey PRIMARY KEY(reading_id),
+  CONSTRAINT reading_originals_reading_id_fkey
+    FOREIGN KEY (reading_id) REFERENCES readings (id) ON DELETE Cascade
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ingestion_jobs;
+
+DROP TYPE IF EXISTS ingestion_job_status;
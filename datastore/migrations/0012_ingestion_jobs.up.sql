@@ -0,0 +1,33 @@
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'ingestion_job_status') THEN
+    CREATE TYPE ingestion_job_status AS ENUM('pending', 'running', 'succeeded', 'failed', 'dead');
+  END IF;
+END
+$$;
+
+
+CREATE TABLE IF NOT EXISTS ingestion_jobs(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  sender text NOT NULL,
+  subject text NOT NULL,
+  raw_mime text NOT NULL,
+  status ingestion_job_status NOT NULL DEFAULT 'pending',
+  attempts integer NOT NULL DEFAULT 0,
+  run_after timestamp NOT NULL,
+  locked_until timestamp,
+  last_error text,
+  completed_at timestamp,
+  CONSTRAINT ingestion_jobs_pkey PRIMARY KEY(id),
+  CONSTRAINT ingestion_jobs_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade
+);
+
+
+-- Workers only look at jobs that are waiting or whose lease may have expired.
+CREATE INDEX IF NOT EXISTS ingestion_jobs_claimable_idx
+  ON ingestion_jobs (run_after)
+  WHERE status IN ('pending', 'running', 'failed');
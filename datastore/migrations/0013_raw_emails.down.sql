@@ -0,0 +1,4 @@
+ALTER TABLE readings
+  DROP COLUMN IF EXISTS raw_email_id;
+
+DROP TABLE IF EXISTS raw_emails;
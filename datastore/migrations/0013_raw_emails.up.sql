@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS raw_emails(
+  id uuid NOT NULL,
+  user_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  message_id text NOT NULL DEFAULT '',
+  sender_email text NOT NULL,
+  subject text NOT NULL,
+  content_hash varchar(64) NOT NULL,
+  compressed_mime bytea NOT NULL,
+  mime_size integer NOT NULL,
+  CONSTRAINT raw_emails_pkey PRIMARY KEY(id),
+  CONSTRAINT raw_emails_user_id_content_hash_key UNIQUE(user_id, content_hash),
+  CONSTRAINT raw_emails_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade
+);
+
+
+ALTER TABLE readings
+  ADD COLUMN IF NOT EXISTS raw_email_id uuid
+    CONSTRAINT readings_raw_email_id_fkey REFERENCES raw_emails (id) ON DELETE Set null;
+
+
+CREATE INDEX IF NOT EXISTS readings_raw_email_id_idx
+  ON readings (raw_email_id)
+  WHERE raw_email_id IS NOT NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS edition_files(
+  id uuid NOT NULL,
+  edition_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  edition_format edition_format NOT NULL,
+  storage_key text NOT NULL,
+  file_size integer NOT NULL,
+  content_hash varchar(64) NOT NULL,
+  CONSTRAINT edition_files_pkey PRIMARY KEY(id),
+  CONSTRAINT edition_files_edition_id_fkey
+    FOREIGN KEY (edition_id) REFERENCES editions (id) ON DELETE Cascade
+);
+
+
+CREATE INDEX IF NOT EXISTS edition_files_edition_id_created_at_idx
+  ON edition_files (edition_id, created_at DESC);
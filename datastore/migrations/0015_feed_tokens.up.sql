@@ -0,0 +1,6 @@
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS feed_token_hash varchar(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_feed_token_hash_key
+  ON users (feed_token_hash)
+  WHERE feed_token_hash IS NOT NULL;
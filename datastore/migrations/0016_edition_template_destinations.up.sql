@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS edition_template_destinations(
+  edition_template_id uuid NOT NULL,
+  delivery_destination_id uuid NOT NULL,
+  created_at timestamp NOT NULL,
+  CONSTRAINT edition_template_destinations_pkey PRIMARY KEY(edition_template_id, delivery_destination_id),
+  CONSTRAINT edition_template_destinations_edition_template_id_fkey
+    FOREIGN KEY (edition_template_id) REFERENCES edition_templates (id) ON DELETE Cascade,
+  CONSTRAINT edition_template_destinations_delivery_destination_id_fkey
+    FOREIGN KEY (delivery_destination_id) REFERENCES delivery_destinations_base (id) ON DELETE Cascade
+);
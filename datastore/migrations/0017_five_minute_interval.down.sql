@@ -0,0 +1,10 @@
+-- Enum values can't be dropped, so the type is rebuilt without it. Templates
+-- using it fall back to hourly.
+UPDATE edition_templates SET delivery_interval = 'hourly' WHERE delivery_interval = 'every_five_minutes';
+ALTER TYPE edition_delivery_interval RENAME TO edition_delivery_interval_old;
+CREATE TYPE edition_delivery_interval AS ENUM
+  ('hourly', 'daily', 'weekly', 'monthly');
+ALTER TABLE edition_templates
+  ALTER COLUMN delivery_interval TYPE edition_delivery_interval
+  USING delivery_interval::text::edition_delivery_interval;
+DROP TYPE edition_delivery_interval_old;
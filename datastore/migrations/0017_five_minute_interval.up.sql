@@ -0,0 +1,3 @@
+-- The scheduler also runs templates every five minutes, which the baseline
+-- enum was missing.
+ALTER TYPE edition_delivery_interval ADD VALUE IF NOT EXISTS 'every_five_minutes' BEFORE 'hourly';
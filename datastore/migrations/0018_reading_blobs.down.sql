@@ -0,0 +1,26 @@
+-- Payloads written since the up migration exist only in the blob store. Their
+-- image and original rows are dropped, and readings whose body isn't in the
+-- database get an empty one.
+
+DELETE FROM reading_images WHERE "data" IS NULL;
+DELETE FROM reading_originals WHERE "data" IS NULL;
+
+ALTER TABLE reading_images
+  DROP CONSTRAINT IF EXISTS reading_images_payload_check,
+  DROP COLUMN IF EXISTS storage_key,
+  ALTER COLUMN "data" SET NOT NULL;
+
+ALTER TABLE reading_originals
+  DROP CONSTRAINT IF EXISTS reading_originals_payload_check,
+  DROP COLUMN IF EXISTS storage_key,
+  ALTER COLUMN "data" SET NOT NULL;
+
+INSERT INTO reading_bodies (body_hash, content_body, created_at)
+  SELECT DISTINCT ON (body_hash) body_hash, '', created_at
+  FROM readings
+  ORDER BY body_hash, created_at
+ON CONFLICT (body_hash) DO NOTHING;
+
+ALTER TABLE readings
+  ADD CONSTRAINT readings_body_hash_fkey
+    FOREIGN KEY (body_hash) REFERENCES reading_bodies (body_hash);
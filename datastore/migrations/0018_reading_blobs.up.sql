@@ -0,0 +1,18 @@
+-- Reading content, images and originals are kept in the blob store. Rows
+-- written before keep their payload in the database and are still read from
+-- there; new rows only record the object key.
+
+ALTER TABLE readings
+  DROP CONSTRAINT IF EXISTS readings_body_hash_fkey;
+
+ALTER TABLE reading_images
+  ADD COLUMN IF NOT EXISTS storage_key text,
+  ALTER COLUMN "data" DROP NOT NULL,
+  ADD CONSTRAINT reading_images_payload_check
+    CHECK ("data" IS NOT NULL OR storage_key IS NOT NULL);
+
+ALTER TABLE reading_originals
+  ADD COLUMN IF NOT EXISTS storage_key text,
+  ALTER COLUMN "data" DROP NOT NULL,
+  ADD CONSTRAINT reading_originals_payload_check
+    CHECK ("data" IS NOT NULL OR storage_key IS NOT NULL);
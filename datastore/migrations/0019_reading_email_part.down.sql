@@ -0,0 +1,2 @@
+ALTER TABLE readings
+  DROP COLUMN IF EXISTS raw_email_part;
@@ -0,0 +1,6 @@
+-- Which part of its archived email a reading was built from: 0 for the whole
+-- email, or the article's position from 1 in a split digest. Reprocessing
+-- pairs stored readings with rebuilt ones by it. Readings archived before
+-- this are NULL.
+ALTER TABLE readings
+  ADD COLUMN IF NOT EXISTS raw_email_part integer;
@@ -0,0 +1,2 @@
+ALTER TABLE reading_sources
+  DROP CONSTRAINT IF EXISTS reading_sources_user_id_identifier_type_key;
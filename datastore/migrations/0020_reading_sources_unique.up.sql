@@ -0,0 +1,51 @@
+-- A user has one source per identifier and type. Sources auto-created twice
+-- by concurrent ingestion or subscription are merged into the oldest one,
+-- which takes over their readings, subscriptions, template assignments and,
+-- if it has none of its own, their feed state.
+CREATE TEMP TABLE duplicate_sources ON COMMIT DROP AS
+  SELECT id, keep_id
+  FROM (
+    SELECT id,
+      first_value(id) OVER (PARTITION BY user_id, identifier, "type" ORDER BY created_at, id) AS keep_id
+    FROM reading_sources
+  ) sources
+  WHERE id <> keep_id;
+
+UPDATE readings r
+  SET reading_source_id = ds.keep_id
+  FROM duplicate_sources ds
+  WHERE ds.id = r.reading_source_id;
+
+INSERT INTO user_reading_sources (reading_source_id, user_id, created_at)
+  SELECT ds.keep_id, urs.user_id, MIN(urs.created_at)
+  FROM user_reading_sources urs
+  JOIN duplicate_sources ds ON ds.id = urs.reading_source_id
+  GROUP BY ds.keep_id, urs.user_id
+ON CONFLICT (reading_source_id, user_id) DO NOTHING;
+
+DELETE FROM user_reading_sources urs
+  USING duplicate_sources ds
+  WHERE ds.id = urs.reading_source_id;
+
+INSERT INTO edition_template_sources (edition_template_id, reading_source_id, created_at)
+  SELECT ets.edition_template_id, ds.keep_id, MIN(ets.created_at)
+  FROM edition_template_sources ets
+  JOIN duplicate_sources ds ON ds.id = ets.reading_source_id
+  GROUP BY ets.edition_template_id, ds.keep_id
+ON CONFLICT (edition_template_id, reading_source_id) DO NOTHING;
+
+INSERT INTO feed_states (reading_source_id, etag, last_modified, last_polled_at, last_error)
+  SELECT DISTINCT ON (ds.keep_id) ds.keep_id, fs.etag, fs.last_modified, fs.last_polled_at, fs.last_error
+  FROM feed_states fs
+  JOIN duplicate_sources ds ON ds.id = fs.reading_source_id
+  ORDER BY ds.keep_id, fs.last_polled_at DESC NULLS LAST
+ON CONFLICT (reading_source_id) DO NOTHING;
+
+-- Their template assignments and feed states cascade.
+DELETE FROM reading_sources rs
+  USING duplicate_sources ds
+  WHERE ds.id = rs.id;
+
+
+ALTER TABLE reading_sources
+  ADD CONSTRAINT reading_sources_user_id_identifier_type_key UNIQUE(user_id, identifier, "type");
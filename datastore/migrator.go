@@ -0,0 +1,290 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the Postgres advisory lock key held while migrating, so
+// instances booting at the same time don't apply the same step twice.
+const migrationLockID = 7_031_905_117
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema step with its rollback.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator applies the SQL migrations embedded from datastore/migrations and
+// records them in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations pairs NNNN_name.up.sql and NNNN_name.down.sql files and
+// returns them ordered by version.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, path := range paths {
+		name := path[len("migrations/"):]
+		match := migrationFilePattern.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s does not match NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status lists every known migration with the time it was applied, if it has been.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureMigrationsTable(ctx, m.db); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedMigrations(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns how many
+// were applied. Each migration runs in its own transaction.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.runInTx(ctx, conn, migration.up,
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, time.Now().UTC(),
+		)
+		if err != nil {
+			return count, fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("INFO (Migrator): Applied migration %04d_%s", migration.Version, migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the most recently applied migrations, newest first, and
+// returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	count := 0
+	for _, version := range versions {
+		if count == steps {
+			break
+		}
+		migration, ok := known[version]
+		if !ok {
+			return count, fmt.Errorf("migration %d is applied but not included in this build", version)
+		}
+		err := m.runInTx(ctx, conn, migration.down,
+			`DELETE FROM schema_migrations WHERE version = $1`, migration.Version,
+		)
+		if err != nil {
+			return count, fmt.Errorf("failed to roll back migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("INFO (Migrator): Rolled back migration %04d_%s", migration.Version, migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Conn.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (m *Migrator) ensureMigrationsTable(ctx context.Context, q queryer) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations(
+		  version integer NOT NULL,
+		  "name" text NOT NULL,
+		  applied_at timestamp NOT NULL,
+		  CONSTRAINT schema_migrations_pkey PRIMARY KEY(version)
+		)
+	`
+	if _, err := q.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) appliedMigrations(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schema_migrations rows: %w", err)
+	}
+	return applied, nil
+}
+
+// lock takes the migration advisory lock on a dedicated connection and
+// makes sure schema_migrations exists. The returned func releases both.
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get connection for migrations: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			log.Printf("WARN (Migrator): Failed to release migration lock: %v", err)
+		}
+		conn.Close()
+	}
+
+	if err := m.ensureMigrationsTable(ctx, conn); err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return conn, unlock, nil
+}
+
+// runInTx executes a migration script and its schema_migrations bookkeeping
+// statement atomically.
+func (m *Migrator) runInTx(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package datastore
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var (
+	createEnumPattern = regexp.MustCompile(`(?is)CREATE TYPE\s+(\w+)\s+AS ENUM\s*\(([^)]*)\)`)
+	addValuePattern   = regexp.MustCompile(`(?is)ALTER TYPE\s+(\w+)\s+ADD VALUE\s+(?:IF NOT EXISTS\s+)?'([^']+)'`)
+	enumLabelPattern  = regexp.MustCompile(`'([^']+)'`)
+)
+
+// enumValues replays the up migrations in order and returns the labels each
+// enum type ends up with.
+func enumValues(t *testing.T, migrations []Migration) map[string]map[string]bool {
+	t.Helper()
+	enums := make(map[string]map[string]bool)
+	for _, migration := range migrations {
+		for _, match := range createEnumPattern.FindAllStringSubmatch(migration.up, -1) {
+			labels := make(map[string]bool)
+			for _, label := range enumLabelPattern.FindAllStringSubmatch(match[2], -1) {
+				labels[label[1]] = true
+			}
+			enums[strings.ToLower(match[1])] = labels
+		}
+		for _, match := range addValuePattern.FindAllStringSubmatch(migration.up, -1) {
+			labels, ok := enums[strings.ToLower(match[1])]
+			if !ok {
+				t.Fatalf("migration %04d_%s adds a value to unknown enum %s", migration.Version, migration.Name, match[1])
+			}
+			labels[match[2]] = true
+		}
+	}
+	return enums
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMigrationEnumsMatchValidation(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	enums := enumValues(t, migrations)
+
+	tests := []struct {
+		enum  string
+		valid map[string]bool
+	}{
+		{enum: "edition_delivery_interval", valid: validEditionDeliveryInterval},
+		{enum: "edition_format", valid: validEditionFormatStrings},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.enum, func(t *testing.T) {
+			labels, ok := enums[tt.enum]
+			if !ok {
+				t.Fatalf("no migration creates enum %s", tt.enum)
+			}
+			if got, want := sortedKeys(labels), sortedKeys(tt.valid); !reflect.DeepEqual(got, want) {
+				t.Errorf("enum %s has values %v after migrating, validation accepts %v", tt.enum, got, want)
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"migrations/0002_b.up.sql":   {Data: []byte("SELECT 2;")},
+				"migrations/0002_b.down.sql": {Data: []byte("SELECT -2;")},
+				"migrations/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"migrations/0001_a.down.sql": {Data: []byte("SELECT -1;")},
+			},
+			want: []int{1, 2},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"migrations/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "version used twice",
+			files: fstest.MapFS{
+				"migrations/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"migrations/0001_b.down.sql": {Data: []byte("SELECT -1;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "badly named file",
+			files: fstest.MapFS{
+				"migrations/first.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []int
+			for _, m := range migrations {
+				got = append(got, m.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package datastore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
+)
+
+// RawEmailRepository handles database operations for the raw_emails table,
+// the archive of inbound email as received.
+type RawEmailRepository struct {
+	db *sql.DB
+}
+
+// NewRawEmailRepository creates a new RawEmailRepository.
+func NewRawEmailRepository(db *sql.DB) *RawEmailRepository {
+	return &RawEmailRepository{db: db}
+}
+
+// CreateRawEmail archives an email, compressing its MIME. If the user already
+// has an archived email with the same content hash (e.g., because ingestion
+// of it was retried), that row is kept and rawEmail.ID is set to its ID.
+func (r *RawEmailRepository) CreateRawEmail(ctx context.Context, rawEmail *models.RawEmail) error {
+	if _, err := uuid.Parse(rawEmail.ID); err != nil {
+		return fmt.Errorf("invalid raw email ID format: %w", err)
+	}
+	if _, err := uuid.Parse(rawEmail.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if rawEmail.RawMIME == "" || len(rawEmail.ContentHash) != 64 {
+		return fmt.Errorf("missing required fields for archiving raw email")
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := io.WriteString(zw, rawEmail.RawMIME); err != nil {
+		return fmt.Errorf("failed to compress raw email: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress raw email: %w", err)
+	}
+	rawEmail.MIMESize = len(rawEmail.RawMIME)
+
+	// The no-op update makes RETURNING yield the existing row's ID on conflict.
+	query := `
+		INSERT INTO raw_emails (
+			id, user_id, created_at, message_id, sender_email, subject,
+			content_hash, compressed_mime, mime_size
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, content_hash) DO UPDATE SET content_hash = EXCLUDED.content_hash
+		RETURNING id
+	`
+	err := r.db.QueryRowContext(ctx, query,
+		rawEmail.ID, rawEmail.UserID, rawEmail.CreatedAt, rawEmail.MessageID, rawEmail.SenderEmail, rawEmail.Subject,
+		rawEmail.ContentHash, compressed.Bytes(), rawEmail.MIMESize,
+	).Scan(&rawEmail.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert raw email: %w", err)
+	}
+	return nil
+}
+
+// GetRawEmailIDByContentHash returns the ID of the user's archived email with
+// the given content hash, or "" if the email hasn't been archived.
+func (r *RawEmailRepository) GetRawEmailIDByContentHash(ctx context.Context, userID, contentHash string) (string, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if len(contentHash) != 64 {
+		return "", fmt.Errorf("invalid content hash format (expected 64 hex characters)")
+	}
+
+	var rawEmailID string
+	query := `SELECT id FROM raw_emails WHERE user_id = $1 AND content_hash = $2`
+	err := r.db.QueryRowContext(ctx, query, userID, contentHash).Scan(&rawEmailID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to look up raw email by content hash: %w", err)
+	}
+	return rawEmailID, nil
+}
+
+// GetRawEmailByID retrieves an archived email with its MIME decompressed.
+func (r *RawEmailRepository) GetRawEmailByID(ctx context.Context, rawEmailID string) (*models.RawEmail, error) {
+	if _, err := uuid.Parse(rawEmailID); err != nil {
+		return nil, fmt.Errorf("invalid raw email ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, user_id, created_at, message_id, sender_email, subject,
+		       content_hash, compressed_mime, mime_size
+		FROM raw_emails
+		WHERE id = $1
+	`
+	var rawEmail models.RawEmail
+	var compressed []byte
+	err := r.db.QueryRowContext(ctx, query, rawEmailID).Scan(
+		&rawEmail.ID, &rawEmail.UserID, &rawEmail.CreatedAt, &rawEmail.MessageID, &rawEmail.SenderEmail, &rawEmail.Subject,
+		&rawEmail.ContentHash, &compressed, &rawEmail.MIMESize,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("raw email not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get raw email by ID: %w", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress raw email %s: %w", rawEmailID, err)
+	}
+	mime, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress raw email %s: %w", rawEmailID, err)
+	}
+	rawEmail.RawMIME = string(mime)
+	return &rawEmail, nil
+}
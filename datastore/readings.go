@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/coreybb/logos/models"
@@ -24,36 +25,251 @@ func NewReadingRepository(db *sql.DB) *ReadingRepository {
 // It assumes the caller provides all necessary fields including the generated ID.
 func (r *ReadingRepository) CreateReading(ctx context.Context, reading *models.Reading) error {
 	// Optional: Validate required fields or formats here if not done by caller
-	if reading.ID == "" || reading.SourceID == "" || reading.ContentHash == "" || reading.Excerpt == "" || reading.StoragePath == "" || reading.Title == "" {
+	if reading.ID == "" || reading.SourceID == "" || reading.ContentHash == "" || reading.BodyHash == "" || reading.Excerpt == "" || reading.StoragePath == "" || reading.Title == "" {
 		return fmt.Errorf("missing required fields for creating reading")
 	}
 	if _, err := uuid.Parse(reading.ID); err != nil {
 		return fmt.Errorf("invalid reading ID format: %w", err)
 	}
+	if _, err := uuid.Parse(reading.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
 	if _, err := uuid.Parse(reading.SourceID); err != nil {
 		return fmt.Errorf("invalid reading source ID format: %w", err)
 	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The body itself is in the blob store at StoragePath.
 	query := `
 		INSERT INTO readings (
-			id, reading_source_id, author, created_at, content_hash,
-			content_body, excerpt, format, published_at, storage_path, title
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			id, user_id, reading_source_id, author, created_at, content_hash,
+			body_hash, excerpt, format, published_at, storage_path, title,
+			simhash, duplicate_of, raw_email_id, raw_email_part
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
-	_, err := r.db.ExecContext(ctx, query,
-		reading.ID, reading.SourceID, reading.Author, reading.CreatedAt, reading.ContentHash,
-		reading.ContentBody, reading.Excerpt, string(reading.Format), reading.PublishedAt, reading.StoragePath, reading.Title,
+	_, err = tx.ExecContext(ctx, query,
+		reading.ID, reading.UserID, reading.SourceID, reading.Author, reading.CreatedAt, reading.ContentHash,
+		reading.BodyHash, reading.Excerpt, string(reading.Format), reading.PublishedAt, reading.StoragePath, reading.Title,
+		reading.SimHash, reading.DuplicateOf, reading.RawEmailID, reading.RawEmailPart,
 	)
 	if err != nil {
 		// Add specific error checks, e.g., unique constraint on content_hash?
 		return fmt.Errorf("failed to insert reading: %w", err)
 	}
+
+	if err := insertReadingFiles(ctx, tx, reading); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Records a reading's images and, if it has one, its original file. Their
+// data is in the blob store under their storage keys.
+func insertReadingFiles(ctx context.Context, tx *sql.Tx, reading *models.Reading) error {
+	imageQuery := `
+		INSERT INTO reading_images (reading_id, content_hash, content_type, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (reading_id, content_hash) DO NOTHING
+	`
+	for i := range reading.Images {
+		image := &reading.Images[i]
+		if image.StorageKey == "" {
+			return fmt.Errorf("missing storage key for image %s of reading", image.ContentHash)
+		}
+		image.ReadingID = reading.ID
+		if image.CreatedAt.IsZero() {
+			image.CreatedAt = reading.CreatedAt
+		}
+		if _, err := tx.ExecContext(ctx, imageQuery, image.ReadingID, image.ContentHash, image.ContentType, image.StorageKey, image.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert image %s for reading: %w", image.ContentHash, err)
+		}
+	}
+
+	if original := reading.Original; original != nil {
+		if original.StorageKey == "" {
+			return fmt.Errorf("missing storage key for original file of reading")
+		}
+		original.ReadingID = reading.ID
+		if original.CreatedAt.IsZero() {
+			original.CreatedAt = reading.CreatedAt
+		}
+		originalQuery := `
+			INSERT INTO reading_originals (reading_id, file_name, format, content_type, storage_key, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`
+		if _, err := tx.ExecContext(ctx, originalQuery, original.ReadingID, original.FileName, string(original.Format), original.ContentType, original.StorageKey, original.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert original file for reading: %w", err)
+		}
+	}
 	return nil
 }
 
-// GetReadingByContentHash retrieves a reading by its content hash.
-// Returns nil, nil if no reading is found with that hash.
-func (r *ReadingRepository) GetReadingByContentHash(ctx context.Context, hash string) (*models.Reading, error) {
+// GetReadingOriginal retrieves the file a reading was converted from. Data is
+// only set for files stored in the database; others are in the blob store at
+// StorageKey.
+func (r *ReadingRepository) GetReadingOriginal(ctx context.Context, readingID string) (*models.ReadingOriginal, error) {
+	if _, err := uuid.Parse(readingID); err != nil {
+		return nil, fmt.Errorf("invalid reading ID format: %w", err)
+	}
+
+	query := `
+		SELECT reading_id, file_name, format, content_type, data, COALESCE(storage_key, ''), created_at
+		FROM reading_originals
+		WHERE reading_id = $1
+	`
+	var original models.ReadingOriginal
+	var formatStr string
+	err := r.db.QueryRowContext(ctx, query, readingID).Scan(
+		&original.ReadingID, &original.FileName, &formatStr, &original.ContentType, &original.Data, &original.StorageKey, &original.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("reading original not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get reading original: %w", err)
+	}
+	original.Format = models.ReadingFormat(formatStr)
+	return &original, nil
+}
+
+// ReadingFingerprint is the similarity fingerprint of a stored reading.
+type ReadingFingerprint struct {
+	ID          string
+	SimHash     int64
+	DuplicateOf *string
+}
+
+// GetFingerprintsSince retrieves the fingerprints of a user's readings created
+// after since, newest first, for near-duplicate detection.
+func (r *ReadingRepository) GetFingerprintsSince(ctx context.Context, userID string, since time.Time) ([]ReadingFingerprint, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT id, simhash, duplicate_of
+		FROM readings
+		WHERE user_id = $1 AND simhash IS NOT NULL AND created_at > $2
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading fingerprints for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var fingerprints []ReadingFingerprint
+	for rows.Next() {
+		var fp ReadingFingerprint
+		if err := rows.Scan(&fp.ID, &fp.SimHash, &fp.DuplicateOf); err != nil {
+			return nil, fmt.Errorf("failed to scan reading fingerprint row: %w", err)
+		}
+		fingerprints = append(fingerprints, fp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading fingerprint rows: %w", err)
+	}
+
+	if fingerprints == nil {
+		fingerprints = []ReadingFingerprint{}
+	}
+
+	return fingerprints, nil
+}
+
+// GetDeliveredFingerprintsSince retrieves the fingerprints of readings in the
+// user's editions created after since, so a new edition can leave out
+// near-duplicates of what the user has already received.
+func (r *ReadingRepository) GetDeliveredFingerprintsSince(ctx context.Context, userID string, since time.Time) ([]ReadingFingerprint, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `
+		SELECT DISTINCT r.id, r.simhash, r.duplicate_of
+		FROM readings r
+		JOIN edition_readings er ON r.id = er.reading_id
+		JOIN editions e ON e.id = er.edition_id
+		WHERE e.user_id = $1 AND e.created_at > $2 AND r.simhash IS NOT NULL
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivered reading fingerprints for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var fingerprints []ReadingFingerprint
+	for rows.Next() {
+		var fp ReadingFingerprint
+		if err := rows.Scan(&fp.ID, &fp.SimHash, &fp.DuplicateOf); err != nil {
+			return nil, fmt.Errorf("failed to scan delivered reading fingerprint row: %w", err)
+		}
+		fingerprints = append(fingerprints, fp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delivered reading fingerprint rows: %w", err)
+	}
+
+	if fingerprints == nil {
+		fingerprints = []ReadingFingerprint{}
+	}
+
+	return fingerprints, nil
+}
+
+// GetReadingImagesForEdition retrieves the stored inline images of every
+// reading in an edition, keyed by reading ID. As with GetReadingOriginal,
+// Data is only set for images stored in the database.
+func (r *ReadingRepository) GetReadingImagesForEdition(ctx context.Context, editionID string) (map[string][]models.ReadingImage, error) {
+	if _, err := uuid.Parse(editionID); err != nil {
+		return nil, fmt.Errorf("invalid edition ID format: %w", err)
+	}
+
+	query := `
+		SELECT ri.reading_id, ri.content_hash, ri.content_type, ri.data, COALESCE(ri.storage_key, ''), ri.created_at
+		FROM reading_images ri
+		JOIN edition_readings er ON ri.reading_id = er.reading_id
+		WHERE er.edition_id = $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, editionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading images for edition %s: %w", editionID, err)
+	}
+	defer rows.Close()
+
+	images := make(map[string][]models.ReadingImage)
+	for rows.Next() {
+		var image models.ReadingImage
+		if err := rows.Scan(&image.ReadingID, &image.ContentHash, &image.ContentType, &image.Data, &image.StorageKey, &image.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reading image row: %w", err)
+		}
+		images[image.ReadingID] = append(images[image.ReadingID], image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading image rows: %w", err)
+	}
+
+	return images, nil
+}
+
+// GetReadingByContentHash retrieves a user's reading by its content hash.
+// Returns nil, nil if the user has no reading with that hash.
+func (r *ReadingRepository) GetReadingByContentHash(ctx context.Context, userID, hash string) (*models.Reading, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
 	if hash == "" {
 		return nil, fmt.Errorf("content hash cannot be empty")
 	}
@@ -63,17 +279,17 @@ func (r *ReadingRepository) GetReadingByContentHash(ctx context.Context, hash st
 	}
 
 	query := `
-		SELECT id, reading_source_id, author, created_at, content_hash,
+		SELECT id, user_id, reading_source_id, author, created_at, content_hash,
 		       excerpt, format, published_at, storage_path, title
 		FROM readings
-		WHERE content_hash = $1
+		WHERE user_id = $1 AND content_hash = $2
 		LIMIT 1
-	` // LIMIT 1 just in case (though hash should be unique)
+	` // LIMIT 1 just in case (though hash should be unique per user)
 	var reading models.Reading
 	var formatStr string
-	row := r.db.QueryRowContext(ctx, query, hash)
+	row := r.db.QueryRowContext(ctx, query, userID, hash)
 	err := row.Scan(
-		&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+		&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 		&reading.ContentHash, &reading.Excerpt, &formatStr, &reading.PublishedAt,
 		&reading.StoragePath, &reading.Title,
 	)
@@ -97,7 +313,7 @@ func (r *ReadingRepository) GetReadingByID(ctx context.Context, readingID string
 	}
 
 	query := `
-		SELECT id, reading_source_id, author, created_at, content_hash,
+		SELECT id, user_id, reading_source_id, author, created_at, content_hash,
 		       excerpt, format, published_at, storage_path, title
 		FROM readings
 		WHERE id = $1
@@ -106,7 +322,7 @@ func (r *ReadingRepository) GetReadingByID(ctx context.Context, readingID string
 	var formatStr string
 	row := r.db.QueryRowContext(ctx, query, readingID)
 	err := row.Scan(
-		&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+		&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 		&reading.ContentHash, &reading.Excerpt, &formatStr, &reading.PublishedAt,
 		&reading.StoragePath, &reading.Title,
 	)
@@ -124,7 +340,7 @@ func (r *ReadingRepository) GetReadingByID(ctx context.Context, readingID string
 // Currently retrieves all readings.
 func (r *ReadingRepository) GetReadings(ctx context.Context) ([]models.Reading, error) {
 	query := `
-		SELECT id, reading_source_id, author, created_at, content_hash,
+		SELECT id, user_id, reading_source_id, author, created_at, content_hash,
 		       excerpt, format, published_at, storage_path, title
 		FROM readings
 		ORDER BY created_at DESC
@@ -140,7 +356,7 @@ func (r *ReadingRepository) GetReadings(ctx context.Context) ([]models.Reading,
 		var reading models.Reading
 		var formatStr string
 		if err := rows.Scan(
-			&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+			&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 			&reading.ContentHash, &reading.Excerpt, &formatStr, &reading.PublishedAt,
 			&reading.StoragePath, &reading.Title,
 		); err != nil {
@@ -168,11 +384,11 @@ func (r *ReadingRepository) GetReadingsByUserID(ctx context.Context, userID stri
 	}
 
 	query := `
-		SELECT r.id, r.reading_source_id, r.author, r.created_at, r.content_hash,
+		SELECT r.id, r.user_id, r.reading_source_id, r.author, r.created_at, r.content_hash,
 		       r.excerpt, r.format, r.published_at, r.storage_path, r.title
 		FROM readings r
 		JOIN user_readings ur ON r.id = ur.reading_id
-		WHERE ur.user_id = $1
+		WHERE ur.user_id = $1 AND r.user_id = $1
 		ORDER BY ur.received_at DESC
 	`
 	rows, err := r.db.QueryContext(ctx, query, userID)
@@ -186,7 +402,7 @@ func (r *ReadingRepository) GetReadingsByUserID(ctx context.Context, userID stri
 		var reading models.Reading
 		var formatStr string
 		if err := rows.Scan(
-			&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+			&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 			&reading.ContentHash, &reading.Excerpt, &formatStr, &reading.PublishedAt,
 			&reading.StoragePath, &reading.Title,
 		); err != nil {
@@ -214,11 +430,11 @@ func (r *ReadingRepository) GetUserReadingsSince(ctx context.Context, userID str
 	}
 
 	query := `
-		SELECT r.id, r.reading_source_id, r.author, r.created_at, r.content_hash,
+		SELECT r.id, r.user_id, r.reading_source_id, r.author, r.created_at, r.content_hash,
 		       r.excerpt, r.format, r.published_at, r.storage_path, r.title
 		FROM readings r
 		JOIN user_readings ur ON r.id = ur.reading_id
-		WHERE ur.user_id = $1 AND ur.received_at > $2
+		WHERE ur.user_id = $1 AND r.user_id = $1 AND ur.received_at > $2
 		ORDER BY ur.received_at ASC
 	`
 	rows, err := r.db.QueryContext(ctx, query, userID, since)
@@ -232,7 +448,7 @@ func (r *ReadingRepository) GetUserReadingsSince(ctx context.Context, userID str
 		var reading models.Reading
 		var formatStr string
 		if err := rows.Scan(
-			&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+			&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 			&reading.ContentHash, &reading.Excerpt, &formatStr, &reading.PublishedAt,
 			&reading.StoragePath, &reading.Title,
 		); err != nil {
@@ -264,11 +480,11 @@ func (r *ReadingRepository) GetUserReadingsSinceBySourceIDs(ctx context.Context,
 	}
 
 	query := `
-		SELECT r.id, r.reading_source_id, r.author, r.created_at, r.content_hash,
-		       r.excerpt, r.format, r.published_at, r.storage_path, r.title
+		SELECT r.id, r.user_id, r.reading_source_id, r.author, r.created_at, r.content_hash,
+		       r.excerpt, r.format, r.published_at, r.storage_path, r.title, r.simhash, r.duplicate_of
 		FROM readings r
 		JOIN user_readings ur ON r.id = ur.reading_id
-		WHERE ur.user_id = $1 AND ur.received_at > $2 AND r.reading_source_id = ANY($3)
+		WHERE ur.user_id = $1 AND r.user_id = $1 AND ur.received_at > $2 AND r.reading_source_id = ANY($3)
 		ORDER BY ur.received_at ASC
 	`
 	rows, err := r.db.QueryContext(ctx, query, userID, since, sourceIDs)
@@ -282,9 +498,9 @@ func (r *ReadingRepository) GetUserReadingsSinceBySourceIDs(ctx context.Context,
 		var reading models.Reading
 		var formatStr string
 		if err := rows.Scan(
-			&reading.ID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
+			&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt,
 			&reading.ContentHash, &reading.Excerpt, &formatStr, &reading.PublishedAt,
-			&reading.StoragePath, &reading.Title,
+			&reading.StoragePath, &reading.Title, &reading.SimHash, &reading.DuplicateOf,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan reading row: %w", err)
 		}
@@ -323,3 +539,192 @@ func (r *ReadingRepository) AddUserReading(ctx context.Context, userID, readingI
 	}
 	return nil
 }
+
+// UserHasReading reports whether a reading is linked to a user.
+func (r *ReadingRepository) UserHasReading(ctx context.Context, userID, readingID string) (bool, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return false, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if _, err := uuid.Parse(readingID); err != nil {
+		return false, fmt.Errorf("invalid reading ID format: %w", err)
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM user_readings WHERE user_id = $1 AND reading_id = $2)`
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, userID, readingID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check reading %s for user %s: %w", readingID, userID, err)
+	}
+	return exists, nil
+}
+
+// ReplaceReadingContent overwrites a reading's derived content (its title,
+// author, excerpt, body, format, fingerprint, images, original file and the
+// part of its email it was built from) with the values in reading, such as
+// after it was rebuilt from its archived email.
+// The reading's identity, owner, source and dates are left unchanged.
+func (r *ReadingRepository) ReplaceReadingContent(ctx context.Context, reading *models.Reading) error {
+	if reading.ContentHash == "" || reading.BodyHash == "" || reading.Excerpt == "" || reading.StoragePath == "" || reading.Title == "" {
+		return fmt.Errorf("missing required fields for replacing reading content")
+	}
+	if _, err := uuid.Parse(reading.ID); err != nil {
+		return fmt.Errorf("invalid reading ID format: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE readings
+		SET author = $2, content_hash = $3, body_hash = $4, excerpt = $5, format = $6,
+		    storage_path = $7, title = $8, simhash = $9, raw_email_part = $10
+		WHERE id = $1
+	`
+	result, err := tx.ExecContext(ctx, query,
+		reading.ID, reading.Author, reading.ContentHash, reading.BodyHash, reading.Excerpt, string(reading.Format),
+		reading.StoragePath, reading.Title, reading.SimHash, reading.RawEmailPart,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update reading %s: %w", reading.ID, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for reading %s: %w", reading.ID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("reading not found (ID: %s): %w", reading.ID, sql.ErrNoRows)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reading_images WHERE reading_id = $1`, reading.ID); err != nil {
+		return fmt.Errorf("failed to delete images of reading %s: %w", reading.ID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reading_originals WHERE reading_id = $1`, reading.ID); err != nil {
+		return fmt.Errorf("failed to delete original file of reading %s: %w", reading.ID, err)
+	}
+	if err := insertReadingFiles(ctx, tx, reading); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ReadingSelection picks readings by ID, by source, or by when they were
+// created. Fields left empty don't narrow the selection.
+type ReadingSelection struct {
+	ReadingID string
+	SourceID  string
+	Since     *time.Time // Inclusive
+	Until     *time.Time // Exclusive
+}
+
+// IsEmpty reports whether the selection has no criteria, and so would
+// match every reading.
+func (s ReadingSelection) IsEmpty() bool {
+	return s.ReadingID == "" && s.SourceID == "" && s.Since == nil && s.Until == nil
+}
+
+// Columns of a reading joined with reading_bodies. Only readings stored
+// before content moved to the blob store have a body there; the others come
+// back with an empty ContentBody.
+const readingWithBodyColumns = `
+	r.id, r.user_id, r.reading_source_id, r.author, r.created_at, r.content_hash,
+	r.body_hash, COALESCE(rb.content_body, ''), r.excerpt, r.format, r.published_at, r.storage_path,
+	r.title, r.simhash, r.duplicate_of, r.raw_email_id, r.raw_email_part
+`
+
+// GetSelectedReadings retrieves the readings matching a non-empty selection,
+// oldest first.
+func (r *ReadingRepository) GetSelectedReadings(ctx context.Context, selection ReadingSelection) ([]models.Reading, error) {
+	if selection.IsEmpty() {
+		return nil, fmt.Errorf("reading selection must have at least one criterion")
+	}
+
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if selection.ReadingID != "" {
+		if _, err := uuid.Parse(selection.ReadingID); err != nil {
+			return nil, fmt.Errorf("invalid reading ID format: %w", err)
+		}
+		addCondition("r.id = $%d", selection.ReadingID)
+	}
+	if selection.SourceID != "" {
+		if _, err := uuid.Parse(selection.SourceID); err != nil {
+			return nil, fmt.Errorf("invalid reading source ID format: %w", err)
+		}
+		addCondition("r.reading_source_id = $%d", selection.SourceID)
+	}
+	if selection.Since != nil {
+		addCondition("r.created_at >= $%d", *selection.Since)
+	}
+	if selection.Until != nil {
+		addCondition("r.created_at < $%d", *selection.Until)
+	}
+
+	query := `
+		SELECT ` + readingWithBodyColumns + `
+		FROM readings r
+		LEFT JOIN reading_bodies rb ON r.body_hash = rb.body_hash
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY r.created_at ASC, r.id ASC
+	`
+	return r.queryReadingsWithBodies(ctx, query, args...)
+}
+
+// GetReadingsByRawEmailID retrieves the readings built from an archived
+// email, in the order they were created.
+func (r *ReadingRepository) GetReadingsByRawEmailID(ctx context.Context, rawEmailID string) ([]models.Reading, error) {
+	if _, err := uuid.Parse(rawEmailID); err != nil {
+		return nil, fmt.Errorf("invalid raw email ID format: %w", err)
+	}
+
+	query := `
+		SELECT ` + readingWithBodyColumns + `
+		FROM readings r
+		LEFT JOIN reading_bodies rb ON r.body_hash = rb.body_hash
+		WHERE r.raw_email_id = $1
+		ORDER BY r.created_at ASC, r.id ASC
+	`
+	return r.queryReadingsWithBodies(ctx, query, rawEmailID)
+}
+
+func (r *ReadingRepository) queryReadingsWithBodies(ctx context.Context, query string, args ...any) ([]models.Reading, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []models.Reading
+	for rows.Next() {
+		var reading models.Reading
+		var formatStr string
+		if err := rows.Scan(
+			&reading.ID, &reading.UserID, &reading.SourceID, &reading.Author, &reading.CreatedAt, &reading.ContentHash,
+			&reading.BodyHash, &reading.ContentBody, &reading.Excerpt, &formatStr, &reading.PublishedAt, &reading.StoragePath,
+			&reading.Title, &reading.SimHash, &reading.DuplicateOf, &reading.RawEmailID, &reading.RawEmailPart,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan reading row: %w", err)
+		}
+		reading.Format = models.ReadingFormat(formatStr)
+		readings = append(readings, reading)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading rows: %w", err)
+	}
+
+	if readings == nil {
+		readings = []models.Reading{}
+	}
+
+	return readings, nil
+}
@@ -18,10 +18,16 @@ func NewSourceRepository(db *sql.DB) *SourceRepository {
 	return &SourceRepository{db: db}
 }
 
+// CreateReadingSource inserts a reading source. If the user already has a
+// source with the same identifier and type (e.g., because it was auto-created
+// concurrently), that row is kept and source is set to it.
 func (r *SourceRepository) CreateReadingSource(ctx context.Context, source *models.ReadingSource) error {
 	if _, err := uuid.Parse(source.ID); err != nil {
 		return fmt.Errorf("invalid reading source ID format: %w", err)
 	}
+	if _, err := uuid.Parse(source.UserID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
 	// Add validation for Name, Type enum, and Identifier
 	if source.Name == "" {
 		return fmt.Errorf("reading source name cannot be empty")
@@ -34,14 +40,53 @@ func (r *SourceRepository) CreateReadingSource(ctx context.Context, source *mode
 	}
 	// Consider adding validation for source.Type against allowed values if not done elsewhere
 
+	if source.SplitMode == "" {
+		source.SplitMode = models.SplitModeNone
+	}
+	if _, ok := models.IsValidSplitMode(string(source.SplitMode)); !ok {
+		return fmt.Errorf("invalid split mode: %s", source.SplitMode)
+	}
+
+	// The no-op update makes RETURNING yield the existing row on conflict.
 	query := `
-		INSERT INTO reading_sources (id, created_at, name, type, identifier)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO reading_sources (id, user_id, created_at, name, type, identifier, split_mode, split_selector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, identifier, type) DO UPDATE SET identifier = EXCLUDED.identifier
+		RETURNING id, created_at, name, split_mode, split_selector
 	`
-	_, err := r.db.ExecContext(ctx, query, source.ID, source.CreatedAt, source.Name, source.Type, source.Identifier)
+	var splitModeStr string
+	err := r.db.QueryRowContext(ctx, query, source.ID, source.UserID, source.CreatedAt, source.Name, source.Type, source.Identifier, string(source.SplitMode), source.SplitSelector).
+		Scan(&source.ID, &source.CreatedAt, &source.Name, &splitModeStr, &source.SplitSelector)
 	if err != nil {
 		return fmt.Errorf("failed to insert reading source: %w", err)
 	}
+	source.SplitMode = models.SplitMode(splitModeStr)
+	return nil
+}
+
+// UpdateSplitMode sets how HTML content from a source is split into readings.
+// The selector is only meaningful with models.SplitModeSelector.
+func (r *SourceRepository) UpdateSplitMode(ctx context.Context, sourceID string, mode models.SplitMode, selector string) error {
+	if _, err := uuid.Parse(sourceID); err != nil {
+		return fmt.Errorf("invalid reading source ID format: %w", err)
+	}
+	if _, ok := models.IsValidSplitMode(string(mode)); !ok {
+		return fmt.Errorf("invalid split mode: %s", mode)
+	}
+
+	query := `UPDATE reading_sources SET split_mode = $2, split_selector = $3 WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, sourceID, string(mode), selector)
+	if err != nil {
+		return fmt.Errorf("failed to update split mode for reading source %s: %w", sourceID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for split mode update %s: %w", sourceID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("reading source not found: %w", sql.ErrNoRows)
+	}
 	return nil
 }
 
@@ -49,22 +94,45 @@ func (r *SourceRepository) GetReadingSourceByID(ctx context.Context, sourceID st
 	if _, err := uuid.Parse(sourceID); err != nil {
 		return nil, fmt.Errorf("invalid reading source ID format: %w", err)
 	}
-	query := `SELECT id, created_at, name, type, identifier FROM reading_sources WHERE id = $1`
+	query := `SELECT id, user_id, created_at, name, type, identifier, split_mode, split_selector FROM reading_sources WHERE id = $1`
 	var source models.ReadingSource
+	var splitModeStr string
 	row := r.db.QueryRowContext(ctx, query, sourceID)
-	err := row.Scan(&source.ID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier)
+	err := row.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier, &splitModeStr, &source.SplitSelector)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("reading source not found: %w", err)
 		}
 		return nil, fmt.Errorf("failed to get reading source by ID: %w", err)
 	}
+	source.SplitMode = models.SplitMode(splitModeStr)
 	return &source, nil
 }
 
-// GetSourceByIdentifierAndType retrieves a reading source by its identifier and type.
+// GetReadingSourceOwnerID returns the ID of the user a reading source belongs to.
+func (r *SourceRepository) GetReadingSourceOwnerID(ctx context.Context, sourceID string) (string, error) {
+	if _, err := uuid.Parse(sourceID); err != nil {
+		return "", fmt.Errorf("invalid reading source ID format: %w", err)
+	}
+
+	query := `SELECT user_id FROM reading_sources WHERE id = $1`
+	var userID string
+	err := r.db.QueryRowContext(ctx, query, sourceID).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("reading source not found: %w", err)
+		}
+		return "", fmt.Errorf("failed to get owner of reading source %s: %w", sourceID, err)
+	}
+	return userID, nil
+}
+
+// GetSourceByIdentifierAndType retrieves a user's reading source by its identifier and type.
 // This is useful for finding a specific RSS feed by URL or an email source by sender address.
-func (r *SourceRepository) GetSourceByIdentifierAndType(ctx context.Context, identifier string, sourceType string) (*models.ReadingSource, error) {
+func (r *SourceRepository) GetSourceByIdentifierAndType(ctx context.Context, userID, identifier, sourceType string) (*models.ReadingSource, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
 	if identifier == "" {
 		return nil, fmt.Errorf("identifier cannot be empty")
 	}
@@ -73,33 +141,35 @@ func (r *SourceRepository) GetSourceByIdentifierAndType(ctx context.Context, ide
 	}
 	// Optional: validate sourceType against allowed enum values
 
-	query := `SELECT id, created_at, name, type, identifier FROM reading_sources WHERE identifier = $1 AND type = $2`
+	query := `SELECT id, user_id, created_at, name, type, identifier, split_mode, split_selector FROM reading_sources WHERE user_id = $1 AND identifier = $2 AND type = $3`
 	var source models.ReadingSource
-	row := r.db.QueryRowContext(ctx, query, identifier, sourceType)
-	err := row.Scan(&source.ID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier)
+	var splitModeStr string
+	row := r.db.QueryRowContext(ctx, query, userID, identifier, sourceType)
+	err := row.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier, &splitModeStr, &source.SplitSelector)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("reading source not found for identifier '%s' and type '%s': %w", identifier, sourceType, err)
 		}
 		return nil, fmt.Errorf("failed to get reading source by identifier and type: %w", err)
 	}
+	source.SplitMode = models.SplitMode(splitModeStr)
 	return &source, nil
 }
 
-// GetUnassignedSourcesByUserID retrieves reading sources that have provided content
-// to this user but are not yet assigned to any of the user's edition templates.
-// These are sources awaiting triage.
+// GetUnassignedSourcesByUserID retrieves the user's reading sources that have
+// provided content but are not yet assigned to any of the user's edition
+// templates. These are sources awaiting triage.
 func (r *SourceRepository) GetUnassignedSourcesByUserID(ctx context.Context, userID string) ([]models.ReadingSource, error) {
 	if _, err := uuid.Parse(userID); err != nil {
 		return nil, fmt.Errorf("invalid user ID format: %w", err)
 	}
 
 	query := `
-		SELECT DISTINCT rs.id, rs.created_at, rs.name, rs.type, rs.identifier
+		SELECT rs.id, rs.user_id, rs.created_at, rs.name, rs.type, rs.identifier, rs.split_mode, rs.split_selector
 		FROM reading_sources rs
-		JOIN readings rd ON rd.reading_source_id = rs.id
-		JOIN user_readings ur ON ur.reading_id = rd.id AND ur.user_id = $1
-		WHERE rs.id NOT IN (
+		WHERE rs.user_id = $1
+		AND EXISTS (SELECT 1 FROM readings rd WHERE rd.reading_source_id = rs.id AND rd.user_id = $1)
+		AND rs.id NOT IN (
 			SELECT ets.reading_source_id
 			FROM edition_template_sources ets
 			JOIN edition_templates et ON et.id = ets.edition_template_id
@@ -116,9 +186,11 @@ func (r *SourceRepository) GetUnassignedSourcesByUserID(ctx context.Context, use
 	var sources []models.ReadingSource
 	for rows.Next() {
 		var source models.ReadingSource
-		if err := rows.Scan(&source.ID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier); err != nil {
+		var splitModeStr string
+		if err := rows.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier, &splitModeStr, &source.SplitSelector); err != nil {
 			return nil, fmt.Errorf("failed to scan unassigned source row for user %s: %w", userID, err)
 		}
+		source.SplitMode = models.SplitMode(splitModeStr)
 		sources = append(sources, source)
 	}
 	if err = rows.Err(); err != nil {
@@ -130,20 +202,27 @@ func (r *SourceRepository) GetUnassignedSourcesByUserID(ctx context.Context, use
 	return sources, nil
 }
 
-func (r *SourceRepository) GetReadingSources(ctx context.Context) ([]models.ReadingSource, error) {
-	query := `SELECT id, created_at, name, type, identifier FROM reading_sources ORDER BY name ASC`
-	rows, err := r.db.QueryContext(ctx, query)
+// GetReadingSourcesByUserID retrieves all of a user's reading sources.
+func (r *SourceRepository) GetReadingSourcesByUserID(ctx context.Context, userID string) ([]models.ReadingSource, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `SELECT id, user_id, created_at, name, type, identifier, split_mode, split_selector FROM reading_sources WHERE user_id = $1 ORDER BY name ASC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query reading sources: %w", err)
+		return nil, fmt.Errorf("failed to query reading sources for user %s: %w", userID, err)
 	}
 	defer rows.Close()
 
 	var sources []models.ReadingSource
 	for rows.Next() {
 		var source models.ReadingSource
-		if err := rows.Scan(&source.ID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier); err != nil {
+		var splitModeStr string
+		if err := rows.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier, &splitModeStr, &source.SplitSelector); err != nil {
 			return nil, fmt.Errorf("failed to scan reading source row: %w", err)
 		}
+		source.SplitMode = models.SplitMode(splitModeStr)
 		sources = append(sources, source)
 	}
 	if err = rows.Err(); err != nil {
@@ -154,3 +233,35 @@ func (r *SourceRepository) GetReadingSources(ctx context.Context) ([]models.Read
 	}
 	return sources, nil
 }
+
+// GetReadingSourcesByType retrieves all reading sources of the given type (e.g. "rss").
+func (r *SourceRepository) GetReadingSourcesByType(ctx context.Context, sourceType string) ([]models.ReadingSource, error) {
+	if sourceType == "" {
+		return nil, fmt.Errorf("source type cannot be empty")
+	}
+
+	query := `SELECT id, user_id, created_at, name, type, identifier, split_mode, split_selector FROM reading_sources WHERE type = $1 ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query, sourceType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading sources of type '%s': %w", sourceType, err)
+	}
+	defer rows.Close()
+
+	var sources []models.ReadingSource
+	for rows.Next() {
+		var source models.ReadingSource
+		var splitModeStr string
+		if err := rows.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type, &source.Identifier, &splitModeStr, &source.SplitSelector); err != nil {
+			return nil, fmt.Errorf("failed to scan reading source row of type '%s': %w", sourceType, err)
+		}
+		source.SplitMode = models.SplitMode(splitModeStr)
+		sources = append(sources, source)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading source rows of type '%s': %w", sourceType, err)
+	}
+	if sources == nil {
+		sources = []models.ReadingSource{}
+	}
+	return sources, nil
+}
@@ -94,7 +94,7 @@ func (r *UserReadingSourceRepository) GetUserSubscribedSources(ctx context.Conte
 	}
 
 	query := `
-		SELECT rs.id, rs.created_at, rs.name, rs.type
+		SELECT rs.id, rs.user_id, rs.created_at, rs.name, rs.type
 		FROM reading_sources rs
 		JOIN user_reading_sources urs ON rs.id = urs.reading_source_id
 		WHERE urs.user_id = $1
@@ -109,7 +109,7 @@ func (r *UserReadingSourceRepository) GetUserSubscribedSources(ctx context.Conte
 	var sources []models.ReadingSource
 	for rows.Next() {
 		var source models.ReadingSource
-		if err := rows.Scan(&source.ID, &source.CreatedAt, &source.Name, &source.Type); err != nil {
+		if err := rows.Scan(&source.ID, &source.UserID, &source.CreatedAt, &source.Name, &source.Type); err != nil {
 			return nil, fmt.Errorf("failed to scan subscribed source row for user %s: %w", userID, err)
 		}
 		sources = append(sources, source)
@@ -144,3 +144,36 @@ func (r *UserReadingSourceRepository) IsUserSubscribed(ctx context.Context, user
 	}
 	return exists, nil
 }
+
+// GetSubscribedUserIDs retrieves the IDs of all users subscribed to a reading source.
+func (r *UserReadingSourceRepository) GetSubscribedUserIDs(ctx context.Context, sourceID string) ([]string, error) {
+	if _, err := uuid.Parse(sourceID); err != nil {
+		return nil, fmt.Errorf("invalid reading source ID format: %w", err)
+	}
+
+	query := `SELECT user_id FROM user_reading_sources WHERE reading_source_id = $1 ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscribed users for source %s: %w", sourceID, err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan subscribed user row for source %s: %w", sourceID, err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subscribed user rows for source %s: %w", sourceID, err)
+	}
+
+	if userIDs == nil {
+		userIDs = []string{}
+	}
+
+	return userIDs, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/coreybb/logos/models"
+	"github.com/google/uuid"
 )
 
 type UserRepository struct {
@@ -16,44 +17,72 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, user *models.User, emailToken string) error {
+// CreateUser inserts a user together with their first API token, so a new
+// account is never left without a way to authenticate.
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User, emailToken string, apiToken *models.APIToken) error {
 	// The user model currently doesn't have EmailToken, but the schema does.
 	// We need to decide if the token should be part of the model or passed separately.
 	// Passing separately seems cleaner as it's often generated just before insertion.
+	if user.IngestionPolicy == "" {
+		user.IngestionPolicy = models.IngestionPolicyOpen
+	}
+	if err := validateAPIToken(apiToken); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO users (id, created_at, email, email_token)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users (id, created_at, email, email_token, ingestion_policy)
+		VALUES ($1, $2, $3, $4, $5)
 	`
-	_, err := r.db.ExecContext(ctx, query, user.ID, user.CreatedAt, user.Email, emailToken)
+	_, err = tx.ExecContext(ctx, query, user.ID, user.CreatedAt, user.Email, emailToken, string(user.IngestionPolicy))
 	if err != nil {
 		// Consider checking for specific DB errors like unique constraint violation if needed.
 		return fmt.Errorf("failed to insert user: %w", err)
 	}
+
+	_, err = tx.ExecContext(ctx, insertAPITokenQuery,
+		apiToken.ID, apiToken.UserID, apiToken.CreatedAt, apiToken.Name, apiToken.TokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert initial API token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
 // GetUserByID retrieves a user by their ID.
 func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
 	query := `
-		SELECT id, created_at, email
+		SELECT id, created_at, email, ingestion_policy
 		FROM users
 		WHERE id = $1
 	`
 	var user models.User
+	var policyStr string
 	row := r.db.QueryRowContext(ctx, query, userID)
-	err := row.Scan(&user.ID, &user.CreatedAt, &user.Email)
+	err := row.Scan(&user.ID, &user.CreatedAt, &user.Email, &policyStr)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user not found: %w", err)
 		}
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
+	user.IngestionPolicy = models.IngestionPolicy(policyStr)
 	return &user, nil
 }
 
 func (r *UserRepository) GetUsers(ctx context.Context) ([]models.User, error) {
 	query := `
-		SELECT id, created_at, email
+		SELECT id, created_at, email, ingestion_policy
 		FROM users
 		ORDER BY created_at DESC
 	` // Example ordering
@@ -66,11 +95,13 @@ func (r *UserRepository) GetUsers(ctx context.Context) ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.CreatedAt, &user.Email); err != nil {
+		var policyStr string
+		if err := rows.Scan(&user.ID, &user.CreatedAt, &user.Email, &policyStr); err != nil {
 			// Log scan error? Return partial list? Fail fast?
 			// Failing fast seems reasonable here.
 			return nil, fmt.Errorf("failed to scan user row: %w", err)
 		}
+		user.IngestionPolicy = models.IngestionPolicy(policyStr)
 		users = append(users, user)
 	}
 
@@ -80,3 +111,90 @@ func (r *UserRepository) GetUsers(ctx context.Context) ([]models.User, error) {
 
 	return users, nil
 }
+
+// GetIngestionPolicy returns the ingestion policy configured for a user.
+func (r *UserRepository) GetIngestionPolicy(ctx context.Context, userID string) (models.IngestionPolicy, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `SELECT ingestion_policy FROM users WHERE id = $1`
+	var policyStr string
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&policyStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("user not found: %w", err)
+		}
+		return "", fmt.Errorf("failed to get ingestion policy for user %s: %w", userID, err)
+	}
+	return models.IngestionPolicy(policyStr), nil
+}
+
+// UpdateIngestionPolicy sets the ingestion policy for a user.
+func (r *UserRepository) UpdateIngestionPolicy(ctx context.Context, userID string, policy models.IngestionPolicy) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	if _, ok := models.IsValidIngestionPolicy(string(policy)); !ok {
+		return fmt.Errorf("invalid ingestion policy: %s", policy)
+	}
+
+	query := `UPDATE users SET ingestion_policy = $2 WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, userID, string(policy))
+	if err != nil {
+		return fmt.Errorf("failed to update ingestion policy for user %s: %w", userID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for ingestion policy update %s: %w", userID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SetFeedTokenHash replaces the hash of a user's catalog feed token. A nil
+// hash revokes the token.
+func (r *UserRepository) SetFeedTokenHash(ctx context.Context, userID string, tokenHash *string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	query := `UPDATE users SET feed_token_hash = $2 WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, userID, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to update feed token for user %s: %w", userID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for feed token update %s: %w", userID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// GetUserByFeedTokenHash retrieves the user whose catalog feed token hashes
+// to tokenHash.
+func (r *UserRepository) GetUserByFeedTokenHash(ctx context.Context, tokenHash string) (*models.User, error) {
+	query := `
+		SELECT id, created_at, email, ingestion_policy
+		FROM users
+		WHERE feed_token_hash = $1
+	`
+	var user models.User
+	var policyStr string
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&user.ID, &user.CreatedAt, &user.Email, &policyStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get user by feed token: %w", err)
+	}
+	user.IngestionPolicy = models.IngestionPolicy(policyStr)
+	return &user, nil
+}
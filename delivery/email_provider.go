@@ -7,11 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 )
 
@@ -38,29 +34,20 @@ func NewEmailDeliveryProvider(apiKey, fromEmail, fromName string) *EmailDelivery
 
 func (p *EmailDeliveryProvider) Type() string { return "email" }
 
-func (p *EmailDeliveryProvider) Deliver(ctx context.Context, filePath string, fileName string, recipientAddress string) error {
-	fileBytes, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read ebook file %s: %w", filePath, err)
-	}
-
-	encoded := base64.StdEncoding.EncodeToString(fileBytes)
-	contentType := mime.TypeByExtension(filepath.Ext(filePath))
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
+func (p *EmailDeliveryProvider) Deliver(ctx context.Context, target DeliveryTarget) error {
+	encoded := base64.StdEncoding.EncodeToString(target.File)
 
 	payload := sgMailPayload{
 		Personalizations: []sgPersonalization{{
-			To: []sgAddress{{Email: recipientAddress}},
+			To: []sgAddress{{Email: target.Address}},
 		}},
 		From:    sgAddress{Email: p.fromEmail, Name: p.fromName},
-		Subject: fileName,
+		Subject: target.FileName,
 		Content: []sgContent{{Type: "text/plain", Value: "Your ebook is attached."}},
 		Attachments: []sgAttachment{{
 			Content:  encoded,
-			Type:     contentType,
-			Filename: fileName,
+			Type:     target.ContentType,
+			Filename: target.FileName,
 		}},
 	}
 
@@ -69,22 +56,8 @@ func (p *EmailDeliveryProvider) Deliver(ctx context.Context, filePath string, fi
 		return fmt.Errorf("failed to marshal SendGrid payload: %w", err)
 	}
 
-	// Retry up to 3 times with backoff for transient network errors
-	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		if attempt > 0 {
-			log.Printf("INFO (EmailDeliveryProvider): Retry attempt %d for SendGrid delivery", attempt)
-			time.Sleep(time.Duration(attempt*5) * time.Second)
-		}
-
-		lastErr = p.sendRequest(ctx, body)
-		if lastErr == nil {
-			return nil
-		}
-		log.Printf("WARN (EmailDeliveryProvider): Attempt %d failed: %v", attempt+1, lastErr)
-	}
-
-	return lastErr
+	// Sent once; the delivery retrier re-sends failures, recording each attempt.
+	return p.sendRequest(ctx, body)
 }
 
 func (p *EmailDeliveryProvider) sendRequest(ctx context.Context, body []byte) error {
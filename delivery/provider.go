@@ -2,22 +2,42 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
 	"github.com/google/uuid"
 )
 
+// ErrDeliveryNotRetryable is returned by RetryDelivery for deliveries that are
+// not failed or pending, or that have no generated file to send.
+var ErrDeliveryNotRetryable = errors.New("delivery cannot be retried")
+
 // DeliveryProvider is the adapter interface for delivery mechanisms.
 // Implement this to add new delivery types (email, API, webhook, etc.).
 type DeliveryProvider interface {
 	// Type returns the destination type this provider handles (e.g. "email").
 	Type() string
-	// Deliver sends the file at filePath to recipientAddress.
-	Deliver(ctx context.Context, filePath string, fileName string, recipientAddress string) error
+	// Deliver sends the delivery's file to the target address.
+	Deliver(ctx context.Context, target DeliveryTarget) error
+}
+
+// DeliveryTarget describes a single send: the delivery being executed, the
+// file loaded from the blob store and the name presented to the recipient,
+// and the resolved destination details.
+type DeliveryTarget struct {
+	Delivery    *models.Delivery
+	FileName    string
+	File        []byte
+	ContentType string
+	// Address is the recipient email address or the webhook URL.
+	Address string
+	// Secret signs webhook payloads. Empty for other destination types.
+	Secret string
 }
 
 // DeliveryService orchestrates delivery execution by selecting the
@@ -27,12 +47,14 @@ type DeliveryService struct {
 	deliveryRepo    *datastore.DeliveryRepository
 	destinationRepo *datastore.DestinationRepository
 	attemptRepo     *datastore.DeliveryAttemptRepository
+	blobs           storage.BlobStore
 }
 
 func NewDeliveryService(
 	deliveryRepo *datastore.DeliveryRepository,
 	destinationRepo *datastore.DestinationRepository,
 	attemptRepo *datastore.DeliveryAttemptRepository,
+	blobs storage.BlobStore,
 	providers ...DeliveryProvider,
 ) *DeliveryService {
 	providerMap := make(map[string]DeliveryProvider, len(providers))
@@ -44,45 +66,108 @@ func NewDeliveryService(
 		deliveryRepo:    deliveryRepo,
 		destinationRepo: destinationRepo,
 		attemptRepo:     attemptRepo,
+		blobs:           blobs,
 	}
 }
 
 // ExecuteDelivery looks up the destination, selects the right provider,
 // sends the file, and updates delivery status and attempt records.
+// Every call that fails is recorded as a failed attempt so the retrier can
+// count it, including failures to resolve the destination.
 func (s *DeliveryService) ExecuteDelivery(ctx context.Context, d *models.Delivery) error {
-	// Look up destination to get type and address.
-	dest, emailAddress, err := s.destinationRepo.GetEmailDestinationDetails(ctx, d.DeliveryDestinationID)
+	provider, target, err := s.resolveTarget(ctx, d)
 	if err != nil {
-		return fmt.Errorf("failed to look up destination %s: %w", d.DeliveryDestinationID, err)
+		s.recordResult(ctx, d, err)
+		return err
+	}
+
+	// Mark as processing.
+	now := time.Now().UTC()
+	if err := s.deliveryRepo.UpdateDeliveryStatus(ctx, d.ID, models.DeliveryStatusProcessing, &now, nil); err != nil {
+		log.Printf("WARN (DeliveryService): Failed to set processing status for delivery %s: %v", d.ID, err)
+	}
+
+	// Execute delivery.
+	deliverErr := provider.Deliver(ctx, target)
+	s.recordResult(ctx, d, deliverErr)
+	if deliverErr == nil {
+		log.Printf("INFO (DeliveryService): Delivery %s completed successfully to %s", d.ID, target.Address)
+	}
+	return deliverErr
+}
+
+// RetryDelivery claims a failed, pending or stalled processing delivery and
+// executes it again. It returns ErrDeliveryNotRetryable if the delivery is in
+// any other state.
+func (s *DeliveryService) RetryDelivery(ctx context.Context, d *models.Delivery) error {
+	if d.FilePath == "" {
+		return fmt.Errorf("%w: no ebook has been generated for delivery %s", ErrDeliveryNotRetryable, d.ID)
+	}
+	now := time.Now().UTC()
+	claimed, err := s.deliveryRepo.ClaimDeliveryForRetry(ctx, d.ID, now, now.Add(-processingTimeout))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("%w: delivery %s is no longer failed, pending or stalled", ErrDeliveryNotRetryable, d.ID)
+	}
+	return s.ExecuteDelivery(ctx, d)
+}
+
+// resolveTarget finds the provider and recipient details for a delivery and
+// loads its file.
+func (s *DeliveryService) resolveTarget(ctx context.Context, d *models.Delivery) (DeliveryProvider, DeliveryTarget, error) {
+	// Look up destination to get its type, then the type-specific details.
+	dest, err := s.destinationRepo.GetDestinationByID(ctx, d.DeliveryDestinationID)
+	if err != nil {
+		return nil, DeliveryTarget{}, fmt.Errorf("failed to look up destination %s: %w", d.DeliveryDestinationID, err)
 	}
 
 	provider, ok := s.providers[dest.Type]
 	if !ok {
-		return fmt.Errorf("no delivery provider registered for type %q", dest.Type)
+		return nil, DeliveryTarget{}, fmt.Errorf("no delivery provider registered for type %q", dest.Type)
+	}
+
+	// Build a human-readable file name from the edition format.
+	target := DeliveryTarget{
+		Delivery: d,
+		FileName: fmt.Sprintf("edition.%s", d.Format),
 	}
 
 	// Resolve recipient address based on destination type.
-	var recipientAddress string
 	switch dest.Type {
 	case "email":
-		recipientAddress = emailAddress
+		_, emailAddress, err := s.destinationRepo.GetEmailDestinationDetails(ctx, dest.ID)
+		if err != nil {
+			return nil, DeliveryTarget{}, fmt.Errorf("failed to look up email destination %s: %w", dest.ID, err)
+		}
+		target.Address = emailAddress
+	case "webhook":
+		_, webhook, err := s.destinationRepo.GetWebhookDestinationDetails(ctx, dest.ID)
+		if err != nil {
+			return nil, DeliveryTarget{}, fmt.Errorf("failed to look up webhook destination %s: %w", dest.ID, err)
+		}
+		target.Address = webhook.URL
+		target.Secret = webhook.Secret
 	default:
-		return fmt.Errorf("unsupported destination type %q", dest.Type)
+		return nil, DeliveryTarget{}, fmt.Errorf("unsupported destination type %q", dest.Type)
 	}
 
-	// Mark as processing.
-	now := time.Now().UTC()
-	if err := s.deliveryRepo.UpdateDeliveryStatus(ctx, d.ID, models.DeliveryStatusProcessing, &now, nil); err != nil {
-		log.Printf("WARN (DeliveryService): Failed to set processing status for delivery %s: %v", d.ID, err)
+	file, err := s.blobs.Get(ctx, d.FilePath)
+	if err != nil {
+		return nil, DeliveryTarget{}, fmt.Errorf("failed to load ebook file %s: %w", d.FilePath, err)
+	}
+	target.File = file.Data
+	target.ContentType = file.ContentType
+	if target.ContentType == "" {
+		target.ContentType = d.Format.ContentType()
 	}
 
-	// Build a human-readable file name from the edition format.
-	fileName := fmt.Sprintf("edition.%s", d.Format)
-
-	// Execute delivery.
-	deliverErr := provider.Deliver(ctx, d.FilePath, fileName, recipientAddress)
+	return provider, target, nil
+}
 
-	// Record the attempt and update final status.
+// recordResult sets the delivery's final status and records the attempt.
+func (s *DeliveryService) recordResult(ctx context.Context, d *models.Delivery, deliverErr error) {
 	completedAt := time.Now().UTC()
 	attempt := models.DeliveryAttempt{
 		ID:         uuid.NewString(),
@@ -98,12 +183,9 @@ func (s *DeliveryService) ExecuteDelivery(ctx context.Context, d *models.Deliver
 	} else {
 		attempt.Status = string(models.DeliveryStatusDelivered)
 		_ = s.deliveryRepo.UpdateDeliveryStatus(ctx, d.ID, models.DeliveryStatusDelivered, nil, &completedAt)
-		log.Printf("INFO (DeliveryService): Delivery %s completed successfully to %s", d.ID, recipientAddress)
 	}
 
 	if err := s.attemptRepo.CreateAttempt(ctx, &attempt); err != nil {
 		log.Printf("WARN (DeliveryService): Failed to record attempt for delivery %s: %v", d.ID, err)
 	}
-
-	return deliverErr
 }
@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+)
+
+const (
+	DefaultMaxAttempts    = 5
+	DefaultRetryBaseDelay = 10 * time.Minute
+	DefaultRetryMaxDelay  = 12 * time.Hour
+
+	// pendingGracePeriod leaves freshly generated deliveries to the request
+	// that created them before the retrier treats them as abandoned.
+	pendingGracePeriod = 10 * time.Minute
+
+	// processingTimeout is how long a delivery may stay processing before it
+	// is treated as stalled, e.g. because the instance sending it stopped.
+	// Sends time out well before this.
+	processingTimeout = 15 * time.Minute
+)
+
+// RetryPolicy controls how often and how long failed deliveries are retried.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts, including the first, before a delivery is given up on
+	BaseDelay   time.Duration // Wait after the first failed attempt; doubles after each further failure
+	MaxDelay    time.Duration // Upper bound on the wait between attempts
+}
+
+// Backoff returns how long to wait after the given number of failed attempts.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Retrier re-attempts failed, abandoned pending and stalled processing
+// deliveries with exponential backoff. It is driven by the scheduler tick.
+type Retrier struct {
+	service      *DeliveryService
+	deliveryRepo *datastore.DeliveryRepository
+	policy       RetryPolicy
+}
+
+func NewRetrier(service *DeliveryService, deliveryRepo *datastore.DeliveryRepository, policy RetryPolicy) *Retrier {
+	return &Retrier{service: service, deliveryRepo: deliveryRepo, policy: policy}
+}
+
+// RetryDue retries every delivery whose backoff has elapsed and which has not
+// used up its attempts. Returns the number of deliveries that succeeded.
+func (r *Retrier) RetryDue(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	candidates, err := r.deliveryRepo.GetRetryableDeliveries(ctx, r.policy.MaxAttempts, now.Add(-processingTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch retryable deliveries: %w", err)
+	}
+
+	delivered := 0
+	for i := range candidates {
+		candidate := &candidates[i]
+
+		var dueAt time.Time
+		if candidate.LastAttemptAt == nil {
+			dueAt = candidate.CreatedAt.Add(pendingGracePeriod)
+		} else {
+			dueAt = candidate.LastAttemptAt.Add(r.policy.Backoff(candidate.Attempts))
+		}
+		if now.Before(dueAt) {
+			continue
+		}
+
+		log.Printf("INFO (DeliveryRetrier): Retrying delivery %s (attempt %d of %d)", candidate.ID, candidate.Attempts+1, r.policy.MaxAttempts)
+		err := r.service.RetryDelivery(ctx, &candidate.Delivery)
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, ErrDeliveryNotRetryable):
+			// Claimed elsewhere since the query ran.
+		default:
+			if candidate.Attempts+1 >= r.policy.MaxAttempts {
+				log.Printf("WARN (DeliveryRetrier): Giving up on delivery %s after %d attempts", candidate.ID, r.policy.MaxAttempts)
+			}
+		}
+	}
+	return delivered, nil
+}
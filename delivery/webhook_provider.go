@@ -0,0 +1,181 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/webutil"
+)
+
+// Headers sent with every webhook delivery. The signature is the hex-encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the destination secret.
+const (
+	WebhookTimestampHeader = "X-Logos-Timestamp"
+	WebhookSignatureHeader = "X-Logos-Signature"
+	WebhookDeliveryHeader  = "X-Logos-Delivery-ID"
+
+	webhookSignaturePrefix = "sha256="
+	webhookEvent           = "edition.delivered"
+	webhookTimeout         = 30 * time.Second
+)
+
+// WebhookDeliveryProvider POSTs ebook files with edition metadata to a
+// user-supplied URL, signing each request with the destination's secret.
+// Since the URL is user-supplied, it may only resolve to public addresses.
+type WebhookDeliveryProvider struct {
+	client *http.Client
+}
+
+func NewWebhookDeliveryProvider() *WebhookDeliveryProvider {
+	return &WebhookDeliveryProvider{
+		client: webutil.NewPublicClient(webhookTimeout),
+	}
+}
+
+func (p *WebhookDeliveryProvider) Type() string { return "webhook" }
+
+func (p *WebhookDeliveryProvider) Deliver(ctx context.Context, target DeliveryTarget) error {
+	if target.Secret == "" {
+		return fmt.Errorf("webhook destination has no signing secret")
+	}
+
+	d := target.Delivery
+
+	payload := webhookPayload{
+		Event:       webhookEvent,
+		DeliveryID:  d.ID,
+		EditionID:   d.EditionID,
+		Format:      string(d.Format),
+		FileName:    target.FileName,
+		ContentType: target.ContentType,
+		FileSize:    len(target.File),
+		CreatedAt:   d.CreatedAt,
+		File:        base64.StdEncoding.EncodeToString(target.File),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	// Retry up to 3 times with backoff for transient failures. Each attempt is
+	// signed with a fresh timestamp so receivers can keep a tight replay window.
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			log.Printf("INFO (WebhookDeliveryProvider): Retry attempt %d for delivery %s", attempt, d.ID)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("webhook delivery cancelled after %d attempts: %w", attempt, ctx.Err())
+			case <-time.After(time.Duration(attempt*5) * time.Second):
+			}
+		}
+
+		lastErr = p.sendRequest(ctx, target, body)
+		if lastErr == nil {
+			return nil
+		}
+		var permanent *permanentWebhookError
+		if errors.As(lastErr, &permanent) {
+			return lastErr
+		}
+		log.Printf("WARN (WebhookDeliveryProvider): Attempt %d failed: %v", attempt+1, lastErr)
+	}
+
+	return lastErr
+}
+
+func (p *WebhookDeliveryProvider) sendRequest(ctx context.Context, target DeliveryTarget, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Address, bytes.NewReader(body))
+	if err != nil {
+		return &permanentWebhookError{fmt.Errorf("failed to create webhook request: %w", err)}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, webhookSignaturePrefix+SignWebhookPayload(target.Secret, timestamp, body))
+	req.Header.Set(WebhookDeliveryHeader, target.Delivery.ID)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		// The error is stored on the delivery attempt, which the user can
+		// read back, so it carries the status line and never the body.
+		err := fmt.Errorf("webhook returned status %s", resp.Status)
+		// Client errors other than rate limiting won't succeed on retry.
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return &permanentWebhookError{err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SignWebhookPayload returns the hex-encoded HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with secret.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the timestamp and signature headers of a
+// received webhook against its raw body. Requests whose timestamp is more
+// than tolerance away from now are rejected to prevent replays.
+func VerifyWebhookSignature(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("webhook timestamp outside tolerance of %s", tolerance)
+	}
+
+	expected := webhookSignaturePrefix + SignWebhookPayload(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+		return fmt.Errorf("webhook signature mismatch")
+	}
+	return nil
+}
+
+// permanentWebhookError marks failures that retrying will not fix.
+type permanentWebhookError struct {
+	err error
+}
+
+func (e *permanentWebhookError) Error() string { return e.err.Error() }
+
+func (e *permanentWebhookError) Unwrap() error { return e.err }
+
+// webhookPayload is the JSON body POSTed to webhook destinations.
+type webhookPayload struct {
+	Event       string    `json:"event"`
+	DeliveryID  string    `json:"delivery_id"`
+	EditionID   string    `json:"edition_id"`
+	Format      string    `json:"format"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	FileSize    int       `json:"file_size"`
+	CreatedAt   time.Time `json:"created_at"`
+	File        string    `json:"file"` // base64-encoded ebook
+}
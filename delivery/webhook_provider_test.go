@@ -0,0 +1,103 @@
+package delivery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreybb/logos/models"
+)
+
+func testWebhookTarget(url string) DeliveryTarget {
+	return DeliveryTarget{
+		Delivery: &models.Delivery{ID: "delivery-1", EditionID: "edition-1", Format: models.EditionFormatEPUB, CreatedAt: time.Now()},
+		Address:  url,
+		Secret:   "whsec",
+		File:     []byte("ebook"),
+		FileName: "edition.epub",
+	}
+}
+
+func TestWebhookDeliveryRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	err := NewWebhookDeliveryProvider().sendRequest(context.Background(), testWebhookTarget(server.URL), []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("sendRequest to %s: error = %v, want a non-public address error", server.URL, err)
+	}
+	if called {
+		t.Error("webhook on a loopback address was called")
+	}
+
+	// Ranges outside RFC 1918 that still reach internal networks. The dial
+	// is refused before connecting, so nothing needs to listen there.
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "private", url: "http://10.0.0.1/hook"},
+		{name: "link-local metadata", url: "http://169.254.169.254/latest"},
+		{name: "carrier-grade NAT", url: "http://100.64.0.1/hook"},
+		{name: "carrier-grade NAT upper bound", url: "http://100.127.255.254/hook"},
+		{name: "IETF protocol assignments", url: "http://192.0.0.170/hook"},
+		{name: "benchmarking", url: "http://198.18.0.1/hook"},
+		{name: "IPv4-mapped private", url: "http://[::ffff:10.0.0.1]/hook"},
+		{name: "NAT64", url: "http://[64:ff9b::a00:1]/hook"},
+		{name: "local-use NAT64", url: "http://[64:ff9b:1::a00:1]/hook"},
+		{name: "unique local", url: "http://[fd00::1]/hook"},
+		{name: "IPv6 loopback", url: "http://[::1]/hook"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewWebhookDeliveryProvider().sendRequest(context.Background(), testWebhookTarget(tt.url), []byte("{}"))
+			if err == nil || !strings.Contains(err.Error(), "non-public address") {
+				t.Errorf("sendRequest to %s: error = %v, want a non-public address error", tt.url, err)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveryErrorOmitsResponseBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "internal secret")
+	}))
+	defer server.Close()
+
+	p := &WebhookDeliveryProvider{client: server.Client()}
+	err := p.Deliver(context.Background(), testWebhookTarget(server.URL))
+	if err == nil {
+		t.Fatal("Deliver succeeded against a 403 response")
+	}
+	if want := "webhook returned status 403 Forbidden"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestWebhookDeliveryStopsRetryingWhenCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	p := &WebhookDeliveryProvider{client: server.Client()}
+	start := time.Now()
+	err := p.Deliver(ctx, testWebhookTarget(server.URL))
+	if err == nil {
+		t.Fatal("Deliver succeeded against a 503 response")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Deliver took %s after its context was cancelled", elapsed)
+	}
+}
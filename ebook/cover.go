@@ -0,0 +1,120 @@
+package ebook
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	"github.com/coreybb/logos/models"
+)
+
+const (
+	minCoverImageSide = 120 // Smaller images are icons and logos rather than artwork
+	coverJPEGQuality  = 85
+)
+
+// RenderCover draws a width×height JPEG cover for an edition. The largest of
+// the edition's inline images is scaled to fill it, cropping whichever sides
+// overflow. Editions without a usable image get a plain tile whose color is
+// derived from seed, so that issues of the same magazine look alike.
+func RenderCover(images []models.ReadingImage, seed string, width, height int) ([]byte, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid cover size %dx%d", width, height)
+	}
+
+	cover := image.NewRGBA(image.Rect(0, 0, width, height))
+	if src := largestCoverImage(images); src != nil {
+		scaleToFill(cover, src)
+	} else {
+		draw.Draw(cover, cover.Bounds(), image.NewUniform(coverColor(seed)), image.Point{}, draw.Src)
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, cover, &jpeg.Options{Quality: coverJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode cover: %w", err)
+	}
+	return encoded.Bytes(), nil
+}
+
+// Decodes the image with the largest area that is at least minCoverImageSide
+// on both sides. Only headers are read to compare sizes.
+func largestCoverImage(images []models.ReadingImage) image.Image {
+	best, bestArea := -1, 0
+	for i, img := range images {
+		config, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+		if err != nil || config.Width < minCoverImageSide || config.Height < minCoverImageSide {
+			continue
+		}
+		if area := config.Width * config.Height; area > bestArea {
+			best, bestArea = i, area
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(images[best].Data))
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// Scales src over dst, cropping it to dst's aspect ratio around its center.
+// Each destination pixel averages the source pixels it covers, so that
+// downscaled photos don't alias.
+func scaleToFill(dst *image.RGBA, src image.Image) {
+	db, sb := dst.Bounds(), src.Bounds()
+	// The crop rectangle within src, in src coordinates.
+	cropW, cropH := sb.Dx(), sb.Dy()
+	if cropW*db.Dy() > cropH*db.Dx() {
+		cropW = cropH * db.Dx() / db.Dy()
+	} else {
+		cropH = cropW * db.Dy() / db.Dx()
+	}
+	x0 := sb.Min.X + (sb.Dx()-cropW)/2
+	y0 := sb.Min.Y + (sb.Dy()-cropH)/2
+
+	for dy := 0; dy < db.Dy(); dy++ {
+		sy0 := y0 + dy*cropH/db.Dy()
+		sy1 := max(y0+(dy+1)*cropH/db.Dy(), sy0+1)
+		for dx := 0; dx < db.Dx(); dx++ {
+			sx0 := x0 + dx*cropW/db.Dx()
+			sx1 := max(x0+(dx+1)*cropW/db.Dx(), sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			// JPEG has no alpha channel, so the premultiplied average is
+			// composited onto white.
+			white := 0xffff - a/n
+			dst.SetRGBA(db.Min.X+dx, db.Min.Y+dy, color.RGBA{
+				R: uint8((r/n + white) >> 8),
+				G: uint8((g/n + white) >> 8),
+				B: uint8((b/n + white) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+}
+
+// Picks a muted color for a plain cover from a hash of seed.
+func coverColor(seed string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	sum := h.Sum32()
+	// Each channel stays in 64-191 so the tile is neither glaring nor black.
+	return color.RGBA{
+		R: uint8(64 + sum&0x7f),
+		G: uint8(64 + (sum>>8)&0x7f),
+		B: uint8(64 + (sum>>16)&0x7f),
+		A: 0xff,
+	}
+}
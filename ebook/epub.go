@@ -1,12 +1,15 @@
 package ebook
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"image"
 	"image/color"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -21,34 +24,29 @@ import (
 
 var imgSrcRegex = regexp.MustCompile(`<img([^>]*)\ssrc=["']([^"']+)["']([^>]*)>`)
 
-// EditionGenerator handles the generation of EPUB ebooks.
-type EditionGenerator struct{}
+// EPUBRenderer generates EPUB ebooks using go-epub.
+type EPUBRenderer struct{}
 
-func NewEditionGenerator() *EditionGenerator {
-	log.Println("INFO (EditionGenerator): Using go-epub for EPUB generation")
-	return &EditionGenerator{}
+func NewEPUBRenderer() *EPUBRenderer {
+	log.Println("INFO (EPUBRenderer): Using go-epub for EPUB generation")
+	return &EPUBRenderer{}
 }
 
-// GenerateEdition creates an EPUB from a set of readings with a title page,
+func (er *EPUBRenderer) Format() models.EditionFormat { return models.EditionFormatEPUB }
+
+// Render creates an EPUB from a set of readings with a title page,
 // table of contents, and individual chapters per article.
-func (eg *EditionGenerator) GenerateEdition(
+func (er *EPUBRenderer) Render(
 	ctx context.Context,
 	readings []models.Reading,
 	metadata models.EditionMetadata,
-	outputFormat models.EditionFormat,
 	outputDir string,
 	editionID string,
 	colorImages bool,
 ) (generatedFilePath string, fileSize int64, err error) {
 
-	if len(readings) == 0 {
-		return "", 0, fmt.Errorf("no readings provided")
-	}
-	if outputDir == "" {
-		return "", 0, fmt.Errorf("output directory cannot be empty")
-	}
-	if editionID == "" {
-		return "", 0, fmt.Errorf("edition ID cannot be empty")
+	if err := validateRenderInput(readings, outputDir, editionID); err != nil {
+		return "", 0, err
 	}
 
 	startTime := time.Now()
@@ -81,18 +79,19 @@ func (eg *EditionGenerator) GenerateEdition(
 	}
 
 	// Each reading as its own chapter
+	stored := newStoredImages(readings)
 	for i, reading := range readings {
 		if reading.Format != models.ReadingFormatHTML || reading.ContentBody == "" {
 			continue
 		}
 
 		articleHTML := buildArticleSection(reading)
-		articleHTML = embedImages(e, articleHTML, colorImages)
+		articleHTML = embedImages(e, articleHTML, stored, colorImages)
 
 		sectionID := fmt.Sprintf("article-%d", i+1)
 		_, err = e.AddSection(articleHTML, reading.Title, sectionID, "")
 		if err != nil {
-			log.Printf("WARN (EditionGenerator): Failed to add section for reading %s: %v", reading.ID, err)
+			log.Printf("WARN (EPUBRenderer): Failed to add section for reading %s: %v", reading.ID, err)
 			continue
 		}
 	}
@@ -115,7 +114,7 @@ func (eg *EditionGenerator) GenerateEdition(
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("INFO (EditionGenerator): Successfully generated EPUB for edition %s: %s (%d articles, %d bytes, %s)",
+	log.Printf("INFO (EPUBRenderer): Successfully generated EPUB for edition %s: %s (%d articles, %d bytes, %s)",
 		editionID, fullOutputFilePath, len(readings), stat.Size(), duration)
 
 	return fullOutputFilePath, stat.Size(), nil
@@ -143,9 +142,30 @@ func buildArticleSection(reading models.Reading) string {
 	return sb.String()
 }
 
-// embedImages finds all <img> tags with external URLs, downloads and embeds
-// them in the EPUB, optionally converting to grayscale.
-func embedImages(e *epub.Epub, html string, colorImages bool) string {
+// storedImages holds the images stored with an edition's readings, keyed by
+// the reference their HTML uses, and remembers where each was embedded so
+// an image shared by several readings is only added to the EPUB once.
+type storedImages struct {
+	byRef    map[string]models.ReadingImage
+	embedded map[string]string
+}
+
+func newStoredImages(readings []models.Reading) *storedImages {
+	s := &storedImages{
+		byRef:    make(map[string]models.ReadingImage),
+		embedded: make(map[string]string),
+	}
+	for _, reading := range readings {
+		for _, img := range reading.Images {
+			s.byRef[img.Ref()] = img
+		}
+	}
+	return s
+}
+
+// embedImages finds all <img> tags with external URLs or references to
+// stored images and embeds them in the EPUB, optionally converting to grayscale.
+func embedImages(e *epub.Epub, html string, stored *storedImages, colorImages bool) string {
 	imageCount := 0
 
 	result := imgSrcRegex.ReplaceAllStringFunc(html, func(match string) string {
@@ -159,24 +179,45 @@ func embedImages(e *epub.Epub, html string, colorImages bool) string {
 		if strings.HasPrefix(srcURL, "data:") {
 			return match
 		}
-		if !strings.HasPrefix(srcURL, "http://") && !strings.HasPrefix(srcURL, "https://") {
-			return match
-		}
-
-		imageCount++
-		internalName := fmt.Sprintf("image-%03d", imageCount)
 
 		var embeddedPath string
 		var err error
 
-		if colorImages {
-			embeddedPath, err = e.AddImage(srcURL, internalName)
-		} else {
-			embeddedPath, err = addGrayscaleImage(e, srcURL, internalName)
+		switch {
+		case strings.HasPrefix(srcURL, models.ReadingImageScheme):
+			if path, ok := stored.embedded[srcURL]; ok {
+				return fmt.Sprintf(`<img%s src="%s"%s>`, submatches[1], path, submatches[3])
+			}
+			img, ok := stored.byRef[srcURL]
+			if !ok {
+				log.Printf("WARN (EPUBRenderer): No stored image for %s", srcURL)
+				return match
+			}
+			imageCount++
+			// Named by content so the name stays unique across chapters.
+			internalName := "stored-" + img.ContentHash
+			if colorImages {
+				embeddedPath, err = e.AddImage(imageDataURI(img.ContentType, img.Data), internalName)
+			} else {
+				embeddedPath, err = addGrayscaleImageData(e, bytes.NewReader(img.Data), internalName)
+			}
+			if err == nil {
+				stored.embedded[srcURL] = embeddedPath
+			}
+		case strings.HasPrefix(srcURL, "http://"), strings.HasPrefix(srcURL, "https://"):
+			imageCount++
+			internalName := fmt.Sprintf("image-%03d", imageCount)
+			if colorImages {
+				embeddedPath, err = e.AddImage(srcURL, internalName)
+			} else {
+				embeddedPath, err = addGrayscaleImage(e, srcURL, internalName)
+			}
+		default:
+			return match
 		}
 
 		if err != nil {
-			log.Printf("WARN (EditionGenerator): Failed to embed image %s: %v", srcURL, err)
+			log.Printf("WARN (EPUBRenderer): Failed to embed image %s: %v", srcURL, err)
 			return match
 		}
 
@@ -184,7 +225,7 @@ func embedImages(e *epub.Epub, html string, colorImages bool) string {
 	})
 
 	if imageCount > 0 {
-		log.Printf("INFO (EditionGenerator): Embedded %d images in EPUB (color: %t)", imageCount, colorImages)
+		log.Printf("INFO (EPUBRenderer): Embedded %d images in EPUB (color: %t)", imageCount, colorImages)
 	}
 
 	return result
@@ -202,7 +243,12 @@ func addGrayscaleImage(e *epub.Epub, srcURL string, internalName string) (string
 		return "", fmt.Errorf("image download returned status %d", resp.StatusCode)
 	}
 
-	src, format, err := image.Decode(resp.Body)
+	return addGrayscaleImageData(e, resp.Body, internalName)
+}
+
+// addGrayscaleImageData decodes an image, converts it to grayscale, and adds it to the EPUB.
+func addGrayscaleImageData(e *epub.Epub, r io.Reader, internalName string) (string, error) {
+	src, format, err := image.Decode(r)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -215,22 +261,24 @@ func addGrayscaleImage(e *epub.Epub, srcURL string, internalName string) (string
 		}
 	}
 
-	tmpFile, err := os.CreateTemp("", "logos-img-*."+format)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file for image: %w", err)
-	}
-	defer os.Remove(tmpFile.Name())
-
+	// go-epub reads image sources again when the book is written, so hand it
+	// the converted image as a data URI rather than a temporary file.
+	var encoded bytes.Buffer
+	mediaType := "image/png"
 	switch format {
 	case "jpeg":
-		err = jpeg.Encode(tmpFile, gray, &jpeg.Options{Quality: 85})
+		mediaType = "image/jpeg"
+		err = jpeg.Encode(&encoded, gray, &jpeg.Options{Quality: 85})
 	default:
-		err = png.Encode(tmpFile, gray)
+		err = png.Encode(&encoded, gray)
 	}
-	tmpFile.Close()
 	if err != nil {
 		return "", fmt.Errorf("failed to encode grayscale image: %w", err)
 	}
 
-	return e.AddImage(tmpFile.Name(), internalName)
+	return e.AddImage(imageDataURI(mediaType, encoded.Bytes()), internalName)
+}
+
+func imageDataURI(mediaType string, data []byte) string {
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
 }
@@ -0,0 +1,363 @@
+package ebook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register GIF decoding for embedded images
+	"image/jpeg"
+	_ "image/png" // Register PNG decoding for embedded images
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/models"
+)
+
+const (
+	maxPDFImageBytes = 20 << 20 // 20 MiB
+	minPDFImageSide  = 3        // Skip tracking pixels and spacers
+	pdfJPEGQuality   = 85
+)
+
+// PageSize is a PDF page size in points (1/72 inch).
+type PageSize struct {
+	Name   string
+	Width  float64
+	Height float64
+}
+
+var (
+	PageSizeA4     = PageSize{Name: "A4", Width: 595.28, Height: 841.89}
+	PageSizeA5     = PageSize{Name: "A5", Width: 419.53, Height: 595.28} // Comfortable on e-readers
+	PageSizeLetter = PageSize{Name: "Letter", Width: 612, Height: 792}
+)
+
+// ParsePageSize looks up a page size by name, case-insensitively.
+func ParsePageSize(name string) (PageSize, bool) {
+	for _, size := range []PageSize{PageSizeA4, PageSizeA5, PageSizeLetter} {
+		if strings.EqualFold(name, size.Name) {
+			return size, true
+		}
+	}
+	return PageSize{}, false
+}
+
+// PDFRenderer generates a PDF edition with a title page, a clickable table of
+// contents, one section per reading and embedded images. It is pure Go and
+// uses the standard PDF fonts, so no fonts are embedded.
+type PDFRenderer struct {
+	pageSize PageSize
+	client   *http.Client
+}
+
+// NewPDFRenderer creates a PDFRenderer producing pages of the given size.
+func NewPDFRenderer(pageSize PageSize) *PDFRenderer {
+	log.Printf("INFO (PDFRenderer): Using %s pages for PDF generation", pageSize.Name)
+	return &PDFRenderer{
+		pageSize: pageSize,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (r *PDFRenderer) Format() models.EditionFormat { return models.EditionFormatPDF }
+
+type pdfTOCEntry struct {
+	title string
+	page  *pdfPage
+	top   float64
+}
+
+// Render writes the edition to outputDir as editionID.pdf.
+func (r *PDFRenderer) Render(
+	ctx context.Context,
+	readings []models.Reading,
+	metadata models.EditionMetadata,
+	outputDir string,
+	editionID string,
+	colorImages bool,
+) (generatedFilePath string, fileSize int64, err error) {
+	if err := validateRenderInput(readings, outputDir, editionID); err != nil {
+		return "", 0, err
+	}
+	startTime := time.Now()
+
+	title := metadata.Title
+	if title == "" {
+		title = "Logos Edition"
+	}
+	author := metadata.Author
+	if author == "" {
+		author = "Logos"
+	}
+	date := metadata.Date
+	if date == "" {
+		date = time.Now().Format("January 2, 2006")
+	}
+
+	doc := newPDFDocument(r.pageSize, title, author)
+	images := &pdfImageLoader{ctx: ctx, doc: doc, client: r.client, stored: newStoredImages(readings), colorImages: colorImages, cache: make(map[string]*pdfImage)}
+	layout := newPDFLayout(doc)
+
+	// Title page
+	layout.newPage()
+	layout.top = r.pageSize.Height * 0.3
+	layout.paragraph(textWords(title, pdfStyle{font: fontBold, size: 24}), pdfParagraphOptions{center: true})
+	layout.gap(18)
+	layout.paragraph(textWords(date, pdfStyle{font: fontRegular, size: 13, gray: 0.4}), pdfParagraphOptions{center: true})
+	layout.gap(28)
+	layout.paragraph(textWords(author, pdfStyle{font: fontRegular, size: 11, gray: 0.6}), pdfParagraphOptions{center: true})
+
+	// Each reading as its own section
+	var entries []pdfTOCEntry
+	for _, reading := range readings {
+		if reading.Format != models.ReadingFormatHTML || reading.ContentBody == "" {
+			continue
+		}
+
+		layout.newPage()
+		entry := pdfTOCEntry{title: reading.Title, page: layout.page, top: layout.top}
+		if entry.title == "" {
+			entry.title = "Untitled"
+		}
+		layout.paragraph(textWords(entry.title, pdfStyle{font: fontBold, size: 17}), pdfParagraphOptions{})
+		if reading.Author != "" {
+			layout.gap(4)
+			layout.paragraph(textWords(reading.Author, pdfStyle{font: fontItalic, size: pdfBodyFontSize, gray: 0.4}), pdfParagraphOptions{})
+		}
+		layout.rule()
+
+		renderer := &pdfHTMLRenderer{layout: layout, images: images}
+		if err := renderer.render(reading.ContentBody); err != nil {
+			log.Printf("WARN (PDFRenderer): Failed to render reading %s: %v", reading.ID, err)
+		}
+
+		entries = append(entries, entry)
+		doc.addOutlineEntry(entry.title, entry.page, entry.top)
+	}
+	if len(entries) == 0 {
+		return "", 0, fmt.Errorf("no readings with renderable content")
+	}
+
+	// Table of contents. Page numbers in it depend on its own length, so lay
+	// it out once on scratch pages to count them.
+	titlePage := doc.pages[0]
+	articlePages := doc.pages[1:]
+	tocLength := len(layoutTOC(newPDFDocument(r.pageSize, title, author), entries, func(*pdfPage) int { return 0 }))
+	pageNumbers := make(map[*pdfPage]int, len(articlePages))
+	for i, page := range articlePages {
+		pageNumbers[page] = 2 + tocLength + i
+	}
+	tocPages := layoutTOC(doc, entries, func(page *pdfPage) int { return pageNumbers[page] })
+
+	ordered := make([]*pdfPage, 0, len(doc.pages))
+	ordered = append(ordered, titlePage)
+	ordered = append(ordered, tocPages...)
+	ordered = append(ordered, articlePages...)
+	doc.pages = ordered
+
+	// Page numbers in the footer, except on the title page
+	for i, page := range doc.pages[1:] {
+		number := strconv.Itoa(i + 2)
+		width := textWidth(fontRegular, 8, number)
+		page.drawText(r.pageSize.Height, fontRegular, 8, 0.5, (r.pageSize.Width-width)/2, r.pageSize.Height-layout.margin/2, number)
+	}
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return "", 0, fmt.Errorf("failed to create output directory '%s': %w", outputDir, err)
+	}
+
+	fullOutputFilePath := filepath.Join(outputDir, editionID+".pdf")
+	file, err := os.Create(fullOutputFilePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create pdf file: %w", err)
+	}
+	size, err := doc.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(fullOutputFilePath)
+		return "", 0, fmt.Errorf("failed to write pdf file: %w", err)
+	}
+
+	if len(doc.images) > 0 {
+		log.Printf("INFO (PDFRenderer): Embedded %d images in PDF (color: %t)", len(doc.images), colorImages)
+	}
+	log.Printf("INFO (PDFRenderer): Successfully generated PDF for edition %s: %s (%d articles, %d pages, %s, %d bytes, %s)",
+		editionID, fullOutputFilePath, len(entries), len(doc.pages), r.pageSize.Name, size, time.Since(startTime))
+
+	return fullOutputFilePath, size, nil
+}
+
+// layoutTOC appends the table of contents to doc and returns its pages.
+// Each entry links to the start of its section.
+func layoutTOC(doc *pdfDocument, entries []pdfTOCEntry, pageNumber func(*pdfPage) int) []*pdfPage {
+	firstPage := len(doc.pages)
+	layout := newPDFLayout(doc)
+	layout.newPage()
+	layout.paragraph(textWords("Contents", pdfStyle{font: fontBold, size: 16}), pdfParagraphOptions{})
+	layout.gap(12)
+
+	entryStyle := pdfStyle{font: fontRegular, size: 11}
+	numberColumn := 30.0
+	for _, entry := range entries {
+		lines := layout.wrap(textWords(entry.title, entryStyle), layout.contentWidth()-numberColumn)
+		height := 0.0
+		for _, line := range lines {
+			height += line.height()
+		}
+
+		// Keep each entry on one page so its link area is a single rectangle.
+		layout.reserve(height)
+		entryTop := layout.top
+		var lastBaseline float64
+		for _, line := range lines {
+			layout.drawLine(line, layout.margin)
+			lastBaseline = layout.baseline(line)
+			layout.top += line.height()
+		}
+
+		number := strconv.Itoa(pageNumber(entry.page))
+		numberX := layout.margin + layout.contentWidth() - textWidth(entryStyle.font, entryStyle.size, number)
+		layout.page.drawText(layout.pageHeight(), entryStyle.font, entryStyle.size, 0, numberX, lastBaseline, number)
+		layout.page.linkTo(layout.pageHeight(), layout.margin, entryTop, layout.contentWidth(), height, entry.page, entry.top)
+		layout.gap(5)
+	}
+	return doc.pages[firstPage:]
+}
+
+// pdfImageLoader fetches, flattens and JPEG-encodes images for a document,
+// embedding each distinct source once.
+type pdfImageLoader struct {
+	ctx         context.Context
+	doc         *pdfDocument
+	client      *http.Client
+	stored      *storedImages
+	colorImages bool
+	cache       map[string]*pdfImage // nil values record sources that failed or were skipped
+}
+
+// load returns the embedded image for src, or nil if it cannot be used.
+func (il *pdfImageLoader) load(src string) *pdfImage {
+	if img, ok := il.cache[src]; ok {
+		return img
+	}
+	img, err := il.embed(src)
+	if err != nil {
+		log.Printf("WARN (PDFRenderer): Failed to embed image %s: %v", truncateForLog(src), err)
+	}
+	il.cache[src] = img
+	return img
+}
+
+func (il *pdfImageLoader) embed(src string) (*pdfImage, error) {
+	data, err := il.fetch(src)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	bounds := decoded.Bounds()
+	if bounds.Dx() < minPDFImageSide || bounds.Dy() < minPDFImageSide {
+		return nil, nil
+	}
+
+	// JPEG has no alpha channel, so composite transparent images onto white.
+	flattened := image.NewRGBA(bounds)
+	draw.Draw(flattened, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(flattened, bounds, decoded, bounds.Min, draw.Over)
+
+	var toEncode image.Image = flattened
+	colorSpace := "DeviceRGB"
+	if !il.colorImages {
+		gray := image.NewGray(bounds)
+		draw.Draw(gray, bounds, flattened, bounds.Min, draw.Src)
+		toEncode = gray
+		colorSpace = "DeviceGray"
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, toEncode, &jpeg.Options{Quality: pdfJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return il.doc.addImage(bounds.Dx(), bounds.Dy(), colorSpace, encoded.Bytes()), nil
+}
+
+func (il *pdfImageLoader) fetch(src string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(src, "data:"):
+		return decodeDataURI(src)
+	case strings.HasPrefix(src, models.ReadingImageScheme):
+		img, ok := il.stored.byRef[src]
+		if !ok {
+			return nil, fmt.Errorf("no stored image")
+		}
+		return img.Data, nil
+	case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+		req, err := http.NewRequestWithContext(il.ctx, http.MethodGet, src, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create image request: %w", err)
+		}
+		resp, err := il.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("image download returned status %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxPDFImageBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		if len(data) > maxPDFImageBytes {
+			return nil, fmt.Errorf("image exceeds %d bytes", maxPDFImageBytes)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported image source")
+	}
+}
+
+// decodeDataURI returns the payload of a data: URI.
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("malformed data URI")
+	}
+	meta, payload := uri[len("data:"):comma], uri[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		payload = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+				return -1
+			}
+			return r
+		}, payload)
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed data URI: %w", err)
+	}
+	return []byte(decoded), nil
+}
+
+func truncateForLog(s string) string {
+	if len(s) > 100 {
+		return s[:100] + "..."
+	}
+	return s
+}
@@ -0,0 +1,329 @@
+package ebook
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// pdfDocument is a minimal PDF 1.4 writer: pages with text in the standard
+// fonts, JPEG images, link annotations and a document outline. It holds
+// everything in memory and serializes in one pass in WriteTo.
+type pdfDocument struct {
+	pageSize PageSize
+	title    string
+	author   string
+	pages    []*pdfPage
+	images   []*pdfImage
+	outline  []pdfOutlineEntry
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	images  map[string]bool
+	annots  []pdfAnnotation
+}
+
+// pdfAnnotation is a clickable rectangle that either jumps to a position in
+// the document or opens an external URI.
+type pdfAnnotation struct {
+	rect     [4]float64 // x1, y1, x2, y2 in PDF user space
+	uri      string
+	destPage *pdfPage
+	destTop  float64 // Distance from the top of destPage, in points
+}
+
+type pdfImage struct {
+	name       string
+	width      int
+	height     int
+	colorSpace string
+	jpegData   []byte
+}
+
+type pdfOutlineEntry struct {
+	title   string
+	page    *pdfPage
+	destTop float64
+}
+
+func newPDFDocument(pageSize PageSize, title, author string) *pdfDocument {
+	return &pdfDocument{pageSize: pageSize, title: title, author: author}
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{images: make(map[string]bool)}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+func (d *pdfDocument) addImage(width, height int, colorSpace string, jpegData []byte) *pdfImage {
+	img := &pdfImage{
+		name:       "Im" + strconv.Itoa(len(d.images)+1),
+		width:      width,
+		height:     height,
+		colorSpace: colorSpace,
+		jpegData:   jpegData,
+	}
+	d.images = append(d.images, img)
+	return img
+}
+
+func (d *pdfDocument) addOutlineEntry(title string, page *pdfPage, top float64) {
+	d.outline = append(d.outline, pdfOutlineEntry{title: title, page: page, destTop: top})
+}
+
+// drawText writes already WinAnsi-encoded text with its baseline at (x, baseline),
+// both measured from the top-left corner of the page.
+func (p *pdfPage) drawText(pageHeight float64, font pdfFont, size, gray, x, baseline float64, encoded string) {
+	fmt.Fprintf(&p.content, "BT %s g /%s %s Tf %s %s Td (%s) Tj ET\n",
+		num(gray), font.resourceName(), num(size), num(x), num(pageHeight-baseline), escapePDFString(encoded))
+}
+
+// drawLine strokes a horizontal line at top, measured from the top of the page.
+func (p *pdfPage) drawLine(pageHeight, x1, x2, top, width, gray float64) {
+	fmt.Fprintf(&p.content, "q %s G %s w %s %s m %s %s l S Q\n",
+		num(gray), num(width), num(x1), num(pageHeight-top), num(x2), num(pageHeight-top))
+}
+
+// drawImage places img in the box whose top-left corner is (x, top).
+func (p *pdfPage) drawImage(pageHeight float64, img *pdfImage, x, top, width, height float64) {
+	p.images[img.name] = true
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		num(width), num(height), num(x), num(pageHeight-top-height), img.name)
+}
+
+// linkTo makes the box with top-left corner (x, top) jump to a position in the document.
+func (p *pdfPage) linkTo(pageHeight, x, top, width, height float64, dest *pdfPage, destTop float64) {
+	p.annots = append(p.annots, pdfAnnotation{
+		rect:     [4]float64{x, pageHeight - top - height, x + width, pageHeight - top},
+		destPage: dest,
+		destTop:  destTop,
+	})
+}
+
+// linkURI makes the box with top-left corner (x, top) open an external URI.
+func (p *pdfPage) linkURI(pageHeight, x, top, width, height float64, uri string) {
+	p.annots = append(p.annots, pdfAnnotation{
+		rect: [4]float64{x, pageHeight - top - height, x + width, pageHeight - top},
+		uri:  uri,
+	})
+}
+
+// WriteTo serializes the document.
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		return 0, fmt.Errorf("pdf document has no pages")
+	}
+
+	// Assign object numbers up front so objects can reference each other.
+	next := 1
+	alloc := func() int { n := next; next++; return n }
+
+	catalogObj := alloc()
+	pagesObj := alloc()
+	infoObj := alloc()
+	var fontObjs [pdfFontCount]int
+	for i := range fontObjs {
+		fontObjs[i] = alloc()
+	}
+	imageObjs := make(map[*pdfImage]int, len(d.images))
+	for _, img := range d.images {
+		imageObjs[img] = alloc()
+	}
+	pageObjs := make(map[*pdfPage]int, len(d.pages))
+	contentObjs := make([]int, len(d.pages))
+	annotObjs := make([][]int, len(d.pages))
+	for i, page := range d.pages {
+		pageObjs[page] = alloc()
+		contentObjs[i] = alloc()
+		for range page.annots {
+			annotObjs[i] = append(annotObjs[i], alloc())
+		}
+	}
+	outlineRootObj := 0
+	outlineObjs := make([]int, len(d.outline))
+	if len(d.outline) > 0 {
+		outlineRootObj = alloc()
+		for i := range d.outline {
+			outlineObjs[i] = alloc()
+		}
+	}
+
+	pw := &pdfWriter{offsets: make([]int, next)}
+	pw.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	catalog := fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R", pagesObj)
+	if outlineRootObj != 0 {
+		catalog += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", outlineRootObj)
+	}
+	pw.object(catalogObj, catalog+" >>")
+
+	kids := make([]string, len(d.pages))
+	for i, page := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObjs[page])
+	}
+	pw.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	pw.object(infoObj, fmt.Sprintf("<< /Title %s /Author %s /Producer %s /CreationDate (D:%s) >>",
+		pdfTextString(d.title), pdfTextString(d.author), pdfTextString("Logos"), time.Now().UTC().Format("20060102150405Z")))
+
+	for i, obj := range fontObjs {
+		pw.object(obj, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", pdfFontBaseNames[i]))
+	}
+
+	for _, img := range d.images {
+		header := fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+			img.width, img.height, img.colorSpace, len(img.jpegData))
+		pw.stream(imageObjs[img], header, img.jpegData)
+	}
+
+	var fontResources strings.Builder
+	for i, obj := range fontObjs {
+		fmt.Fprintf(&fontResources, "/%s %d 0 R ", pdfFont(i).resourceName(), obj)
+	}
+
+	for i, page := range d.pages {
+		resources := "<< /Font << " + fontResources.String() + ">>"
+		if len(page.images) > 0 {
+			resources += " /XObject << "
+			for _, img := range d.images {
+				if page.images[img.name] {
+					resources += fmt.Sprintf("/%s %d 0 R ", img.name, imageObjs[img])
+				}
+			}
+			resources += ">>"
+		}
+		resources += " >>"
+
+		pageDict := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R",
+			pagesObj, num(d.pageSize.Width), num(d.pageSize.Height), resources, contentObjs[i])
+		if len(page.annots) > 0 {
+			refs := make([]string, len(page.annots))
+			for j := range page.annots {
+				refs[j] = fmt.Sprintf("%d 0 R", annotObjs[i][j])
+			}
+			pageDict += " /Annots [" + strings.Join(refs, " ") + "]"
+		}
+		pw.object(pageObjs[page], pageDict+" >>")
+
+		compressed, err := deflate(page.content.Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+		pw.stream(contentObjs[i], fmt.Sprintf("<< /Filter /FlateDecode /Length %d >>", len(compressed)), compressed)
+
+		for j, annot := range page.annots {
+			rect := fmt.Sprintf("[%s %s %s %s]", num(annot.rect[0]), num(annot.rect[1]), num(annot.rect[2]), num(annot.rect[3]))
+			var action string
+			if annot.destPage != nil {
+				action = "/Dest " + d.destination(pageObjs, annot.destPage, annot.destTop)
+			} else {
+				action = "/A << /S /URI /URI (" + escapePDFString(annot.uri) + ") >>"
+			}
+			pw.object(annotObjs[i][j], fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect %s /Border [0 0 0] %s >>", rect, action))
+		}
+	}
+
+	if outlineRootObj != 0 {
+		pw.object(outlineRootObj, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>",
+			outlineObjs[0], outlineObjs[len(outlineObjs)-1], len(outlineObjs)))
+		for i, entry := range d.outline {
+			item := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest %s",
+				pdfTextString(entry.title), outlineRootObj, d.destination(pageObjs, entry.page, entry.destTop))
+			if i > 0 {
+				item += fmt.Sprintf(" /Prev %d 0 R", outlineObjs[i-1])
+			}
+			if i < len(outlineObjs)-1 {
+				item += fmt.Sprintf(" /Next %d 0 R", outlineObjs[i+1])
+			}
+			pw.object(outlineObjs[i], item+" >>")
+		}
+	}
+
+	xrefOffset := pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "xref\n0 %d\n0000000000 65535 f \n", next)
+	for n := 1; n < next; n++ {
+		fmt.Fprintf(&pw.buf, "%010d 00000 n \n", pw.offsets[n])
+	}
+	fmt.Fprintf(&pw.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		next, catalogObj, infoObj, xrefOffset)
+
+	return pw.buf.WriteTo(w)
+}
+
+func (d *pdfDocument) destination(pageObjs map[*pdfPage]int, page *pdfPage, top float64) string {
+	return fmt.Sprintf("[%d 0 R /XYZ 0 %s null]", pageObjs[page], num(d.pageSize.Height-top))
+}
+
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (pw *pdfWriter) object(n int, body string) {
+	pw.offsets[n] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+func (pw *pdfWriter) stream(n int, header string, data []byte) {
+	pw.offsets[n] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n%s\nstream\n", n, header)
+	pw.buf.Write(data)
+	pw.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// num formats a coordinate compactly, as PDF does not accept exponent notation.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// escapePDFString escapes a byte string for use inside a PDF literal string.
+func escapePDFString(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '(', ')':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// pdfTextString encodes s as a UTF-16BE hex string, which PDF readers accept
+// for metadata and outline titles in any script.
+func pdfTextString(s string) string {
+	var sb strings.Builder
+	sb.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&sb, "%04X", unit)
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
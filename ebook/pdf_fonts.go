@@ -0,0 +1,166 @@
+package ebook
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// pdfFont identifies one of the standard Type 1 fonts every PDF reader ships
+// with, so nothing needs to be embedded. Text is encoded as WinAnsi (cp1252).
+type pdfFont int
+
+const (
+	fontRegular pdfFont = iota
+	fontBold
+	fontItalic
+	fontBoldItalic
+	fontMono
+	fontMonoBold
+	pdfFontCount
+)
+
+var pdfFontBaseNames = [pdfFontCount]string{
+	"Helvetica",
+	"Helvetica-Bold",
+	"Helvetica-Oblique",
+	"Helvetica-BoldOblique",
+	"Courier",
+	"Courier-Bold",
+}
+
+// resourceName is the name the font is registered under in page resources.
+func (f pdfFont) resourceName() string {
+	return "F" + strconv.Itoa(int(f)+1)
+}
+
+func (f pdfFont) withBold() pdfFont {
+	switch f {
+	case fontRegular:
+		return fontBold
+	case fontItalic:
+		return fontBoldItalic
+	case fontMono:
+		return fontMonoBold
+	}
+	return f
+}
+
+func (f pdfFont) withItalic() pdfFont {
+	switch f {
+	case fontRegular:
+		return fontItalic
+	case fontBold:
+		return fontBoldItalic
+	}
+	return f
+}
+
+// Glyph widths for printable ASCII (0x20-0x7E) in 1/1000 em, from the Adobe
+// AFM files. The oblique variants share the upright metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Widths for the cp1252 punctuation and symbols most often seen in articles.
+var helveticaHighWidths = map[byte]int{
+	0x80: 556, 0x85: 1000, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333, 0x95: 350,
+	0x96: 556, 0x97: 1000, 0x99: 1000, 0xA0: 278, 0xA9: 737, 0xAB: 556, 0xAE: 737,
+	0xB0: 400, 0xB7: 278, 0xBB: 556, 0xC6: 1000, 0xD7: 584, 0xDF: 611, 0xE6: 889, 0xF7: 584,
+}
+
+var helveticaBoldHighWidths = map[byte]int{
+	0x80: 556, 0x85: 1000, 0x91: 278, 0x92: 278, 0x93: 500, 0x94: 500, 0x95: 350,
+	0x96: 556, 0x97: 1000, 0x99: 1000, 0xA0: 278, 0xA9: 737, 0xAB: 556, 0xAE: 737,
+	0xB0: 400, 0xB7: 278, 0xBB: 556, 0xC6: 1000, 0xD7: 584, 0xDF: 611, 0xE6: 889, 0xF7: 584,
+}
+
+// latin1BaseLetters maps accented letters in 0xC0-0xFF to the unaccented
+// letter with the same advance width. Spaces mark entries with no base letter.
+const latin1BaseLetters = "AAAAAA CEEEEIIII" + "DNOOOOO OUUUUY  " + "aaaaaa ceeeeiiii" + "dnooooo ouuuuy y"
+
+const monoGlyphWidth = 600
+
+// glyphWidth returns the advance width of a WinAnsi byte in 1/1000 em.
+func glyphWidth(font pdfFont, b byte) int {
+	if font == fontMono || font == fontMonoBold {
+		return monoGlyphWidth
+	}
+
+	bold := font == fontBold || font == fontBoldItalic
+	if b >= 0x20 && b <= 0x7E {
+		if bold {
+			return helveticaBoldWidths[b-0x20]
+		}
+		return helveticaWidths[b-0x20]
+	}
+
+	high := helveticaHighWidths
+	if bold {
+		high = helveticaBoldHighWidths
+	}
+	if w, ok := high[b]; ok {
+		return w
+	}
+	if b >= 0xC0 {
+		if base := latin1BaseLetters[b-0xC0]; base != ' ' {
+			return glyphWidth(font, base)
+		}
+	}
+	return 556
+}
+
+// textWidth returns the width in points of WinAnsi-encoded text.
+func textWidth(font pdfFont, size float64, encoded string) float64 {
+	total := 0
+	for i := 0; i < len(encoded); i++ {
+		total += glyphWidth(font, encoded[i])
+	}
+	return float64(total) * size / 1000
+}
+
+// encodeWinAnsi converts UTF-8 text to the single-byte encoding used by the
+// standard fonts. Characters outside cp1252 get a close substitute or "?".
+func encodeWinAnsi(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '\u00ad', '\u200b', '\u200c', '\u200d', '\ufeff': // soft hyphen, zero-width characters
+			continue
+		case '\u2002', '\u2003', '\u2009', '\u200a', '\u202f':
+			sb.WriteByte(' ')
+			continue
+		case '\u2010', '\u2011', '\u2012', '\u2212':
+			sb.WriteByte('-')
+			continue
+		case '\u2032':
+			sb.WriteByte('\'')
+			continue
+		case '\u2033':
+			sb.WriteByte('"')
+			continue
+		}
+		if b, ok := charmap.Windows1252.EncodeRune(r); ok {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,583 @@
+package ebook
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	pdfBodyFontSize = 10.5
+	pdfMonoFontSize = 9
+	pdfLineSpacing  = 1.35
+	pdfListIndent   = 14
+	pdfPxToPt       = 0.75 // CSS pixels are 1/96 inch, PDF points 1/72
+)
+
+// pdfStyle is the visual style of a run of text. It is comparable, so runs
+// with the same style on a line can be drawn with a single text operator.
+type pdfStyle struct {
+	font pdfFont
+	size float64
+	gray float64 // 0 is black, 1 is white
+	link string  // External URI, if the run is inside a link
+}
+
+func bodyStyle() pdfStyle {
+	return pdfStyle{font: fontRegular, size: pdfBodyFontSize}
+}
+
+// pdfWord is a unit of WinAnsi-encoded text that is only split across lines
+// when it is wider than a line on its own.
+type pdfWord struct {
+	text      string
+	style     pdfStyle
+	space     bool // Preceded by whitespace, so a line may break before it
+	lineBreak bool // Must start a new line
+}
+
+func (w pdfWord) width() float64 {
+	return textWidth(w.style.font, w.style.size, w.text)
+}
+
+func spaceWidth(style pdfStyle) float64 {
+	return textWidth(style.font, style.size, " ")
+}
+
+// textWords splits text on whitespace into words of a single style.
+func textWords(text string, style pdfStyle) []pdfWord {
+	var words []pdfWord
+	for _, field := range strings.Fields(text) {
+		words = append(words, pdfWord{text: encodeWinAnsi(field), style: style, space: len(words) > 0})
+	}
+	return words
+}
+
+type pdfLine struct {
+	words []pdfWord
+	width float64
+	size  float64 // Largest font size on the line
+}
+
+func (line pdfLine) height() float64 {
+	return line.size * pdfLineSpacing
+}
+
+type pdfParagraphOptions struct {
+	indent float64
+	marker string // Drawn in the indent before the first line, e.g. a list bullet
+	center bool
+}
+
+// pdfLayout flows content down the pages of a pdfDocument, starting a new
+// page whenever the next line or image does not fit.
+type pdfLayout struct {
+	doc        *pdfDocument
+	page       *pdfPage
+	top        float64 // Current position, measured from the top of the page
+	pendingGap float64 // Vertical space owed before the next content
+	margin     float64
+}
+
+func newPDFLayout(doc *pdfDocument) *pdfLayout {
+	return &pdfLayout{
+		doc:    doc,
+		margin: math.Max(28, doc.pageSize.Width*0.08),
+	}
+}
+
+func (l *pdfLayout) pageHeight() float64    { return l.doc.pageSize.Height }
+func (l *pdfLayout) contentWidth() float64  { return l.doc.pageSize.Width - 2*l.margin }
+func (l *pdfLayout) contentHeight() float64 { return l.doc.pageSize.Height - 2*l.margin }
+func (l *pdfLayout) bottom() float64        { return l.doc.pageSize.Height - l.margin }
+
+func (l *pdfLayout) newPage() {
+	l.page = l.doc.addPage()
+	l.top = l.margin
+	l.pendingGap = 0
+}
+
+// gap requests vertical space before the next content. Consecutive gaps
+// collapse to the largest, and gaps at the top of a page are dropped.
+func (l *pdfLayout) gap(height float64) {
+	l.pendingGap = math.Max(l.pendingGap, height)
+}
+
+// reserve makes room for content of the given height, starting a new page
+// if it does not fit on the current one.
+func (l *pdfLayout) reserve(height float64) {
+	if l.page == nil {
+		l.newPage()
+	}
+	atTop := l.top <= l.margin
+	if !atTop {
+		l.top += l.pendingGap
+	}
+	l.pendingGap = 0
+	if !atTop && l.top+height > l.bottom() {
+		l.newPage()
+	}
+}
+
+// wrap breaks words into lines no wider than width.
+func (l *pdfLayout) wrap(words []pdfWord, width float64) []pdfLine {
+	var lines []pdfLine
+	var current pdfLine
+	push := func() {
+		lines = append(lines, current)
+		current = pdfLine{}
+	}
+	add := func(w pdfWord, advance float64) {
+		current.words = append(current.words, w)
+		current.width += advance
+		current.size = math.Max(current.size, w.style.size)
+	}
+
+	for _, cluster := range wordClusters(words) {
+		if cluster[0].lineBreak && len(current.words) > 0 {
+			push()
+		}
+
+		clusterWidth := 0.0
+		for _, w := range cluster {
+			clusterWidth += w.width()
+		}
+		leading := 0.0
+		if len(current.words) > 0 && cluster[0].space {
+			leading = spaceWidth(cluster[0].style)
+		}
+		if len(current.words) > 0 && current.width+leading+clusterWidth > width {
+			push()
+			leading = 0
+		}
+
+		if len(current.words) == 0 && clusterWidth > width {
+			// A single word wider than the line, such as a long URL: break it
+			// wherever it has to be broken.
+			for _, w := range cluster {
+				for current.width+w.width() > width {
+					n := fittingPrefix(w, width-current.width)
+					if n == 0 {
+						push()
+						n = max(1, fittingPrefix(w, width))
+					}
+					piece := w
+					piece.text = w.text[:n]
+					add(piece, piece.width())
+					push()
+					w.text = w.text[n:]
+					w.space = false
+					w.lineBreak = false
+				}
+				add(w, w.width())
+			}
+			continue
+		}
+
+		add(cluster[0], leading+cluster[0].width())
+		for _, w := range cluster[1:] {
+			add(w, w.width())
+		}
+	}
+	if len(current.words) > 0 {
+		push()
+	}
+	return lines
+}
+
+// wordClusters groups words that are not separated by whitespace, such as
+// "<b>bold</b>text", so that a line never breaks between them.
+func wordClusters(words []pdfWord) [][]pdfWord {
+	var clusters [][]pdfWord
+	for i, w := range words {
+		if i == 0 || w.space || w.lineBreak {
+			clusters = append(clusters, []pdfWord{w})
+			continue
+		}
+		last := len(clusters) - 1
+		clusters[last] = append(clusters[last], w)
+	}
+	return clusters
+}
+
+// fittingPrefix returns how many bytes of w fit within width.
+func fittingPrefix(w pdfWord, width float64) int {
+	total := 0.0
+	for i := 0; i < len(w.text); i++ {
+		total += float64(glyphWidth(w.style.font, w.text[i])) * w.style.size / 1000
+		if total > width {
+			return i
+		}
+	}
+	return len(w.text)
+}
+
+// paragraph wraps and draws words, flowing onto new pages as needed.
+func (l *pdfLayout) paragraph(words []pdfWord, opts pdfParagraphOptions) {
+	width := l.contentWidth() - opts.indent
+	for i, line := range l.wrap(words, width) {
+		l.reserve(line.height())
+		x := l.margin + opts.indent
+		if opts.center {
+			x += (width - line.width) / 2
+		}
+		if i == 0 && opts.marker != "" {
+			style := line.words[0].style
+			style.link = ""
+			marker := encodeWinAnsi(opts.marker)
+			markerX := x - textWidth(style.font, style.size, marker) - 4
+			l.page.drawText(l.pageHeight(), style.font, style.size, style.gray, markerX, l.baseline(line), marker)
+		}
+		l.drawLine(line, x)
+		l.top += line.height()
+	}
+}
+
+func (l *pdfLayout) baseline(line pdfLine) float64 {
+	return l.top + line.size*1.05
+}
+
+// drawLine draws a line at the current position, merging adjacent words of
+// the same style into a single run and making linked runs clickable.
+func (l *pdfLayout) drawLine(line pdfLine, x float64) {
+	baseline := l.baseline(line)
+	var run strings.Builder
+	var runStyle pdfStyle
+	runX := x
+
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		text := run.String()
+		l.page.drawText(l.pageHeight(), runStyle.font, runStyle.size, runStyle.gray, runX, baseline, text)
+		runWidth := textWidth(runStyle.font, runStyle.size, text)
+		if runStyle.link != "" {
+			l.page.linkURI(l.pageHeight(), runX, l.top, runWidth, line.height(), runStyle.link)
+		}
+		runX += runWidth
+		run.Reset()
+	}
+
+	for i, w := range line.words {
+		if w.style != runStyle {
+			flush()
+			runStyle = w.style
+		}
+		if i > 0 && w.space {
+			if run.Len() == 0 {
+				runX += spaceWidth(w.style)
+			} else {
+				run.WriteByte(' ')
+			}
+		}
+		run.WriteString(w.text)
+	}
+	flush()
+}
+
+// rule draws a horizontal divider across the content width.
+func (l *pdfLayout) rule() {
+	l.gap(pdfBodyFontSize * 0.5)
+	l.reserve(1)
+	l.page.drawLine(l.pageHeight(), l.margin, l.margin+l.contentWidth(), l.top, 0.5, 0.6)
+	l.gap(pdfBodyFontSize)
+}
+
+// image draws img at its natural size, shrunk to fit the page and centered.
+func (l *pdfLayout) image(img *pdfImage, indent float64) {
+	maxWidth := l.contentWidth() - indent
+	maxHeight := l.contentHeight() * 0.75
+	width := float64(img.width) * pdfPxToPt
+	height := float64(img.height) * pdfPxToPt
+	if scale := math.Min(maxWidth/width, maxHeight/height); scale < 1 {
+		width *= scale
+		height *= scale
+	}
+
+	l.gap(pdfBodyFontSize * 0.5)
+	l.reserve(height)
+	x := l.margin + indent + (maxWidth-width)/2
+	l.page.drawImage(l.pageHeight(), img, x, l.top, width, height)
+	l.top += height
+	l.gap(pdfBodyFontSize * 0.8)
+}
+
+// pdfHTMLRenderer walks an article's HTML and lays it out as paragraphs,
+// headings, lists, quotes, preformatted blocks and images.
+type pdfHTMLRenderer struct {
+	layout       *pdfLayout
+	images       *pdfImageLoader
+	words        []pdfWord
+	pendingSpace bool
+	breakNext    bool
+	indent       float64
+	marker       string
+	preDepth     int
+	lists        []pdfListState
+}
+
+type pdfListState struct {
+	ordered bool
+	next    int
+}
+
+// skippedElements are never rendered, along with everything inside them.
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Iframe: true, atom.Svg: true, atom.Object: true, atom.Embed: true,
+	atom.Video: true, atom.Audio: true, atom.Canvas: true, atom.Template: true,
+	atom.Form: true, atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true,
+}
+
+// blockElements start and end their own paragraph.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Main: true, atom.Aside: true, atom.Nav: true, atom.Figure: true,
+	atom.Address: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Table: true,
+	atom.Tr: true, atom.Center: true, atom.Details: true, atom.Summary: true,
+}
+
+func (r *pdfHTMLRenderer) render(body string) error {
+	root, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.walk(root, bodyStyle())
+	r.flush()
+	return nil
+}
+
+func (r *pdfHTMLRenderer) walk(n *html.Node, style pdfStyle) {
+	switch n.Type {
+	case html.TextNode:
+		r.addText(n.Data, style)
+		return
+	case html.ElementNode:
+		r.element(n, style)
+		return
+	}
+	r.walkChildren(n, style)
+}
+
+func (r *pdfHTMLRenderer) walkChildren(n *html.Node, style pdfStyle) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c, style)
+	}
+}
+
+func (r *pdfHTMLRenderer) element(n *html.Node, style pdfStyle) {
+	if skippedElements[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.flush()
+		style.font = style.font.withBold()
+		style.size = headingSize(n.DataAtom)
+		r.layout.gap(style.size * 0.9)
+		r.walkChildren(n, style)
+		r.flush()
+		r.layout.gap(style.size * 0.4)
+
+	case atom.Strong, atom.B:
+		style.font = style.font.withBold()
+		r.walkChildren(n, style)
+
+	case atom.Em, atom.I, atom.Cite, atom.Var:
+		style.font = style.font.withItalic()
+		r.walkChildren(n, style)
+
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		style.font = fontMono
+		style.size = pdfMonoFontSize
+		r.walkChildren(n, style)
+
+	case atom.A:
+		if href := strings.TrimSpace(attr(n, "href")); isExternalLink(href) {
+			style.link = href
+		}
+		r.walkChildren(n, style)
+
+	case atom.Br:
+		r.breakNext = true
+		r.pendingSpace = false
+
+	case atom.Hr:
+		r.flush()
+		r.layout.rule()
+
+	case atom.Img:
+		r.flush()
+		r.image(n)
+
+	case atom.Pre:
+		r.flush()
+		style.font = fontMono
+		style.size = pdfMonoFontSize
+		r.layout.gap(pdfBodyFontSize * 0.6)
+		r.preDepth++
+		r.walkChildren(n, style)
+		r.preDepth--
+		r.flush()
+		r.layout.gap(pdfBodyFontSize * 0.6)
+
+	case atom.Blockquote:
+		r.flush()
+		style.gray = 0.25
+		style.font = style.font.withItalic()
+		r.indent += pdfListIndent
+		r.layout.gap(pdfBodyFontSize * 0.6)
+		r.walkChildren(n, style)
+		r.flush()
+		r.indent -= pdfListIndent
+		r.layout.gap(pdfBodyFontSize * 0.6)
+
+	case atom.Ul, atom.Ol:
+		r.flush()
+		list := pdfListState{ordered: n.DataAtom == atom.Ol, next: 1}
+		if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+			list.next = start
+		}
+		r.lists = append(r.lists, list)
+		r.indent += pdfListIndent
+		r.walkChildren(n, style)
+		r.flush()
+		r.indent -= pdfListIndent
+		r.lists = r.lists[:len(r.lists)-1]
+		r.layout.gap(pdfBodyFontSize * 0.6)
+
+	case atom.Li:
+		r.flush()
+		r.marker = "•"
+		if len(r.lists) > 0 {
+			list := &r.lists[len(r.lists)-1]
+			if list.ordered {
+				r.marker = strconv.Itoa(list.next) + "."
+				list.next++
+			}
+		}
+		r.walkChildren(n, style)
+		r.flush()
+		r.layout.gap(pdfBodyFontSize * 0.2)
+
+	case atom.Figcaption:
+		r.flush()
+		style.font = style.font.withItalic()
+		style.size = pdfBodyFontSize * 0.85
+		style.gray = 0.4
+		r.walkChildren(n, style)
+		r.flush()
+		r.layout.gap(pdfBodyFontSize * 0.6)
+
+	case atom.Td, atom.Th:
+		r.pendingSpace = true
+		if n.DataAtom == atom.Th {
+			style.font = style.font.withBold()
+		}
+		r.walkChildren(n, style)
+		r.pendingSpace = true
+
+	default:
+		if blockElements[n.DataAtom] {
+			r.flush()
+			r.walkChildren(n, style)
+			r.flush()
+			if n.DataAtom == atom.P || n.DataAtom == atom.Figure || n.DataAtom == atom.Table {
+				r.layout.gap(pdfBodyFontSize * 0.6)
+			}
+			return
+		}
+		r.walkChildren(n, style)
+	}
+}
+
+func (r *pdfHTMLRenderer) addText(text string, style pdfStyle) {
+	if r.preDepth > 0 {
+		text = strings.ReplaceAll(text, "\t", "    ")
+		for i, line := range strings.Split(text, "\n") {
+			word := pdfWord{text: encodeWinAnsi(line), style: style}
+			if i > 0 || r.breakNext {
+				word.lineBreak = true
+				r.breakNext = false
+			}
+			r.words = append(r.words, word)
+		}
+		return
+	}
+
+	if text == "" {
+		return
+	}
+	startsWithSpace := isHTMLSpace(text[0])
+	endsWithSpace := isHTMLSpace(text[len(text)-1])
+	for i, field := range strings.Fields(text) {
+		word := pdfWord{
+			text:  encodeWinAnsi(field),
+			style: style,
+			space: len(r.words) > 0 && (i > 0 || startsWithSpace || r.pendingSpace),
+		}
+		if r.breakNext {
+			word.lineBreak = true
+			r.breakNext = false
+		}
+		r.words = append(r.words, word)
+		r.pendingSpace = false
+	}
+	if endsWithSpace {
+		r.pendingSpace = true
+	}
+}
+
+// flush lays out the words collected so far as a paragraph.
+func (r *pdfHTMLRenderer) flush() {
+	if len(r.words) > 0 {
+		r.layout.paragraph(r.words, pdfParagraphOptions{indent: r.indent, marker: r.marker})
+		r.marker = ""
+	}
+	r.words = nil
+	r.pendingSpace = false
+	r.breakNext = false
+}
+
+func (r *pdfHTMLRenderer) image(n *html.Node) {
+	img := r.images.load(strings.TrimSpace(attr(n, "src")))
+	if img == nil {
+		return
+	}
+	r.layout.image(img, r.indent)
+}
+
+func headingSize(a atom.Atom) float64 {
+	switch a {
+	case atom.H1:
+		return 15
+	case atom.H2:
+		return 13.5
+	case atom.H3:
+		return 12
+	default:
+		return pdfBodyFontSize
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isExternalLink(href string) bool {
+	return strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "mailto:")
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,231 @@
+package ebook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/coreybb/logos/models"
+)
+
+var (
+	startXRefPattern = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	outlinesPattern  = regexp.MustCompile(`/Type /Outlines /First \d+ 0 R /Last \d+ 0 R /Count (\d+)`)
+	mediaBoxPattern  = regexp.MustCompile(`/MediaBox \[0 0 ([\d.]+) ([\d.]+)\]`)
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func testPNGDataURI(t *testing.T, width, height int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(encodePNG(t, img))
+}
+
+// checkXRef verifies that the cross-reference table at startxref lists every
+// object at its actual offset.
+func checkXRef(t *testing.T, data []byte) {
+	t.Helper()
+	m := startXRefPattern.FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("no startxref at the end of the file")
+	}
+	offset, _ := strconv.Atoi(string(m[1]))
+	if offset >= len(data) {
+		t.Fatalf("startxref %d is past the end of the file", offset)
+	}
+
+	var count int
+	table := string(data[offset:])
+	if _, err := fmt.Sscanf(table, "xref\n0 %d\n", &count); err != nil {
+		t.Fatalf("startxref %d doesn't point at an xref table: %v", offset, err)
+	}
+	entries := table[strings.Index(table, "\n0000000000 65535 f \n")+1:]
+	for n := 1; n < count; n++ {
+		entry := entries[20*n : 20*(n+1)]
+		objOffset, err := strconv.Atoi(entry[:10])
+		if err != nil || !strings.HasSuffix(entry, " 00000 n \n") {
+			t.Fatalf("malformed xref entry %d: %q", n, entry)
+		}
+		if want := fmt.Sprintf("%d 0 obj\n", n); !bytes.HasPrefix(data[objOffset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", n, data[objOffset:min(len(data), objOffset+20)])
+		}
+	}
+	if !strings.Contains(table, fmt.Sprintf("trailer\n<< /Size %d ", count)) {
+		t.Errorf("trailer /Size doesn't match the %d xref entries", count)
+	}
+}
+
+func TestPDFRendererRender(t *testing.T) {
+	readings := []models.Reading{
+		{
+			ID:          "r1",
+			Title:       "First (of three)",
+			Author:      "Ada Lovelace",
+			Format:      models.ReadingFormatHTML,
+			ContentBody: `<h2>Intro</h2><p>Body of the <b>first</b> reading.</p><img src="` + testPNGDataURI(t, 60, 40) + `"/>`,
+		},
+		{
+			ID:          "r2",
+			Title:       "Ελληνικά, 日本語 and emoji 😀",
+			Format:      models.ReadingFormatHTML,
+			ContentBody: `<p>Καλημέρα κόσμε — こんにちは世界 🎉 “quoted” café</p><ul><li>één</li><li>два</li></ul>`,
+		},
+		{ID: "skipped", Title: "Not HTML", Format: models.ReadingFormatPDF, ContentBody: "%PDF-"},
+		{
+			ID:          "r3",
+			Format:      models.ReadingFormatHTML,
+			ContentBody: "<p>" + strings.Repeat("A long paragraph that runs over several pages. ", 400) + "</p>",
+		},
+	}
+	wantTitles := []string{"First (of three)", "Ελληνικά, 日本語 and emoji 😀", "Untitled"}
+
+	for _, sizeName := range []string{"A4", "A5", "letter"} {
+		t.Run(sizeName, func(t *testing.T) {
+			pageSize, ok := ParsePageSize(sizeName)
+			if !ok {
+				t.Fatalf("ParsePageSize(%q) failed", sizeName)
+			}
+			dir := t.TempDir()
+			path, size, err := NewPDFRenderer(pageSize).Render(context.Background(), readings,
+				models.EditionMetadata{Title: "Morning Edition", Author: "Logos", Date: "May 1, 2025"}, dir, "edition", true)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading rendered PDF: %v", err)
+			}
+			if int64(len(data)) != size {
+				t.Errorf("Render reported %d bytes, file has %d", size, len(data))
+			}
+
+			if !bytes.HasPrefix(data, []byte("%PDF-")) {
+				t.Fatalf("output starts with %q, want %%PDF-", data[:min(len(data), 8)])
+			}
+			checkXRef(t, data)
+
+			boxes := mediaBoxPattern.FindAllSubmatch(data, -1)
+			if len(boxes) < 4 {
+				t.Errorf("got %d pages, want a title page, contents and at least one page per reading", len(boxes))
+			}
+			for _, box := range boxes {
+				if string(box[1]) != num(pageSize.Width) || string(box[2]) != num(pageSize.Height) {
+					t.Fatalf("page MediaBox is %s×%s, want %s×%s", box[1], box[2], num(pageSize.Width), num(pageSize.Height))
+				}
+			}
+
+			m := outlinesPattern.FindSubmatch(data)
+			if m == nil || string(m[1]) != strconv.Itoa(len(wantTitles)) {
+				t.Errorf("outline root = %q, want /Count %d", m, len(wantTitles))
+			}
+			for _, title := range wantTitles {
+				if !bytes.Contains(data, []byte("/Title "+pdfTextString(title)+" /Parent")) {
+					t.Errorf("no outline entry for %q", title)
+				}
+			}
+			// The table of contents links to every reading.
+			if links := bytes.Count(data, []byte("/Subtype /Link /Rect")); links < len(wantTitles) {
+				t.Errorf("got %d links, want one per reading in the table of contents", links)
+			}
+			if !bytes.Contains(data, []byte("/Subtype /Image /Width 60 /Height 40 /ColorSpace /DeviceRGB")) {
+				t.Errorf("the reading's image wasn't embedded")
+			}
+		})
+	}
+}
+
+func TestPDFRendererRenderErrors(t *testing.T) {
+	renderer := NewPDFRenderer(PageSizeA5)
+	tests := []struct {
+		name     string
+		readings []models.Reading
+	}{
+		{name: "no readings"},
+		{name: "nothing renderable", readings: []models.Reading{
+			{ID: "r1", Title: "PDF", Format: models.ReadingFormatPDF, ContentBody: "%PDF-"},
+			{ID: "r2", Title: "Empty", Format: models.ReadingFormatHTML},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := renderer.Render(context.Background(), tt.readings, models.EditionMetadata{}, t.TempDir(), "edition", false); err == nil {
+				t.Error("Render() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   PageSize
+		wantOK bool
+	}{
+		{name: "A4", want: PageSizeA4, wantOK: true},
+		{name: "a5", want: PageSizeA5, wantOK: true},
+		{name: "LETTER", want: PageSizeLetter, wantOK: true},
+		{name: "legal"},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		got, ok := ParsePageSize(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParsePageSize(%q) = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRenderCover(t *testing.T) {
+	art := image.NewGray(image.Rect(0, 0, 400, 200))
+
+	tests := []struct {
+		name   string
+		images []models.ReadingImage
+	}{
+		{name: "no images"},
+		{name: "only an icon", images: []models.ReadingImage{{ContentType: "image/png", Data: encodePNG(t, image.NewGray(image.Rect(0, 0, 16, 16)))}}},
+		{name: "undecodable image", images: []models.ReadingImage{{ContentType: "image/jpeg", Data: []byte("not a jpeg")}}},
+		{name: "artwork", images: []models.ReadingImage{{ContentType: "image/png", Data: encodePNG(t, art)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := RenderCover(tt.images, "The Weekly", 300, 450)
+			if err != nil {
+				t.Fatalf("RenderCover: %v", err)
+			}
+			cover, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decoding cover: %v", err)
+			}
+			if b := cover.Bounds(); b.Dx() != 300 || b.Dy() != 450 {
+				t.Errorf("cover is %dx%d, want 300x450", b.Dx(), b.Dy())
+			}
+		})
+	}
+
+	if _, err := RenderCover(nil, "seed", 0, 100); err == nil {
+		t.Error("RenderCover with zero width succeeded, want an error")
+	}
+}
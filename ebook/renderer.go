@@ -0,0 +1,49 @@
+package ebook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreybb/logos/models"
+)
+
+// Renderer is the adapter interface for ebook output formats.
+// Implement this to add new formats (KF8/AZW3, HTML bundles, Markdown archives, etc.).
+type Renderer interface {
+	// Format returns the edition format this renderer produces (e.g. "epub").
+	Format() models.EditionFormat
+	// Render writes an ebook of the readings to outputDir, named after editionID,
+	// and returns the path and size of the generated file.
+	Render(
+		ctx context.Context,
+		readings []models.Reading,
+		metadata models.EditionMetadata,
+		outputDir string,
+		editionID string,
+		colorImages bool,
+	) (generatedFilePath string, fileSize int64, err error)
+}
+
+// UnsupportedFormatError is returned when no Renderer is registered for the
+// requested edition format.
+type UnsupportedFormatError struct {
+	Format models.EditionFormat
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported edition format %q: no renderer registered", e.Format)
+}
+
+// validateRenderInput checks the arguments shared by every Renderer.
+func validateRenderInput(readings []models.Reading, outputDir string, editionID string) error {
+	if len(readings) == 0 {
+		return fmt.Errorf("no readings provided")
+	}
+	if outputDir == "" {
+		return fmt.Errorf("output directory cannot be empty")
+	}
+	if editionID == "" {
+		return fmt.Errorf("edition ID cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Feed is the format-independent result of parsing an RSS or Atom document.
+type Feed struct {
+	Title string
+	Link  string
+	Items []Item
+}
+
+// Item is a single entry in a feed.
+type Item struct {
+	GUID        string // Stable identifier: guid/id, falling back to the link
+	Title       string
+	Link        string // Permalink to the full article
+	Author      string
+	ContentHTML string     // Full content if the feed provides it, otherwise the summary
+	PublishedAt *time.Time // nil if the feed did not provide a parseable date
+}
+
+// RSS 2.0 (and RSS 0.9x) document structure.
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+	Items   []rssItem  `xml:"item"` // RSS 1.0 (RDF) places items next to the channel
+}
+
+type rssChannel struct {
+	Title string    `xml:"title"`
+	Link  string    `xml:"link"`
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title          string `xml:"title"`
+	Link           string `xml:"link"`
+	GUID           string `xml:"guid"`
+	Description    string `xml:"description"`
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate        string `xml:"pubDate"`
+	DCDate         string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author         string `xml:"author"`
+	DCCreator      string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+// Atom 1.0 document structure.
+type atomFeed struct {
+	Title   atomText    `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     atomText     `xml:"title"`
+	Links     []atomLink   `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomPerson `xml:"author"`
+	Summary   atomText     `xml:"summary"`
+	Content   atomText     `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// atomText is an Atom text construct, whose encoding depends on its type attribute.
+type atomText struct {
+	Type     string `xml:"type,attr"`
+	CharData string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// HTML returns the text construct as an HTML fragment.
+func (t atomText) HTML() string {
+	switch strings.ToLower(t.Type) {
+	case "xhtml":
+		return strings.TrimSpace(t.InnerXML)
+	case "html", "text/html":
+		return strings.TrimSpace(t.CharData)
+	default: // "text" or unspecified
+		return html.EscapeString(strings.TrimSpace(t.CharData))
+	}
+}
+
+// Plain returns the text construct with markup removed, for titles.
+func (t atomText) Plain() string {
+	if strings.ToLower(t.Type) == "xhtml" {
+		return strings.TrimSpace(stripTags(t.InnerXML))
+	}
+	return strings.TrimSpace(t.CharData)
+}
+
+// Parse detects whether data is an RSS or Atom document and parses it.
+func Parse(data []byte) (*Feed, error) {
+	root, err := rootElementName(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss", "RDF":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	default:
+		return nil, fmt.Errorf("unrecognised feed root element <%s>", root)
+	}
+}
+
+func newDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Tolerate HTML entities and sloppy escaping, but not HTML's void
+	// elements: auto-closing would swallow the text of every RSS <link>.
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charsetReader
+	return decoder
+}
+
+func rootElementName(data []byte) (string, error) {
+	decoder := newDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return "", fmt.Errorf("feed document has no root element")
+			}
+			return "", fmt.Errorf("failed to read feed document: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func parseRSS(data []byte) (*Feed, error) {
+	var doc rssDocument
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode RSS feed: %w", err)
+	}
+
+	rawItems := doc.Channel.Items
+	if len(rawItems) == 0 {
+		rawItems = doc.Items
+	}
+
+	feed := &Feed{
+		Title: strings.TrimSpace(doc.Channel.Title),
+		Link:  strings.TrimSpace(doc.Channel.Link),
+		Items: make([]Item, 0, len(rawItems)),
+	}
+	for _, raw := range rawItems {
+		item := Item{
+			GUID:        strings.TrimSpace(raw.GUID),
+			Title:       strings.TrimSpace(raw.Title),
+			Link:        strings.TrimSpace(raw.Link),
+			Author:      firstNonEmpty(raw.DCCreator, raw.Author),
+			ContentHTML: firstNonEmpty(raw.ContentEncoded, raw.Description),
+			PublishedAt: parseFeedDate(firstNonEmpty(raw.PubDate, raw.DCDate)),
+		}
+		if item.GUID == "" {
+			item.GUID = item.Link
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+func parseAtom(data []byte) (*Feed, error) {
+	var doc atomFeed
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode Atom feed: %w", err)
+	}
+
+	feed := &Feed{
+		Title: doc.Title.Plain(),
+		Link:  alternateLink(doc.Links),
+		Items: make([]Item, 0, len(doc.Entries)),
+	}
+	for _, raw := range doc.Entries {
+		var author string
+		if len(raw.Authors) > 0 {
+			author = firstNonEmpty(raw.Authors[0].Name, raw.Authors[0].Email)
+		}
+		item := Item{
+			GUID:        strings.TrimSpace(raw.ID),
+			Title:       raw.Title.Plain(),
+			Link:        alternateLink(raw.Links),
+			Author:      author,
+			ContentHTML: firstNonEmpty(raw.Content.HTML(), raw.Summary.HTML()),
+			PublishedAt: parseFeedDate(firstNonEmpty(raw.Published, raw.Updated)),
+		}
+		if item.GUID == "" {
+			item.GUID = item.Link
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+// alternateLink picks the link that points at the human-readable page.
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	if len(links) > 0 {
+		return strings.TrimSpace(links[0].Href)
+	}
+	return ""
+}
+
+// parseFeedDate parses the date formats commonly found in RSS and Atom feeds.
+func parseFeedDate(dateStr string) *time.Time {
+	dateStr = strings.TrimSpace(dateStr)
+	if dateStr == "" {
+		return nil
+	}
+	formats := []string{
+		time.RFC3339, time.RFC3339Nano,
+		time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST",
+		time.RFC822Z, time.RFC822, "2 Jan 2006 15:04:05 -0700",
+		"2006-01-02T15:04:05", "2006-01-02",
+	}
+	for _, format := range formats {
+		parsedTime, err := time.Parse(format, dateStr)
+		if err == nil {
+			utcTime := parsedTime.UTC()
+			return &utcTime
+		}
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if trimmed := strings.TrimSpace(v); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+func stripTags(s string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(sb.String())
+}
+
+// charsetReader handles the single-byte encodings still seen in older feeds.
+// encoding/xml handles UTF-8 natively.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		raw, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 0, len(raw)*2)
+		for _, b := range raw {
+			buf = utf8.AppendRune(buf, rune(b))
+		}
+		return bytes.NewReader(buf), nil
+	default:
+		return nil, fmt.Errorf("unsupported feed charset %q", charset)
+	}
+}
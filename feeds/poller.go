@@ -0,0 +1,261 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ingestion"
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+)
+
+const (
+	sourceTypeRSS = "rss"
+
+	defaultFetchTimeout = 30 * time.Second
+	maxFeedBytes        = 10 << 20 // 10 MiB
+	maxItemsPerPoll     = 25
+	userAgent           = "Logos Feed Poller (+https://lakonic.dev)"
+
+	// Items published this long before the previous poll are still considered,
+	// to tolerate feeds that backdate entries or publish late.
+	publishedGracePeriod = 24 * time.Hour
+)
+
+// FetchResult is the outcome of a conditional GET against a feed URL.
+type FetchResult struct {
+	NotModified  bool // The server answered 304; Body is empty
+	Body         []byte
+	ETag         string
+	LastModified string
+}
+
+// Poller fetches registered "rss" reading sources and ingests new items
+// through the same pipeline and content-hash deduplication as email.
+type Poller struct {
+	sourceRepo            *datastore.SourceRepository
+	feedStateRepo         *datastore.FeedStateRepository
+	userReadingSourceRepo *datastore.UserReadingSourceRepository
+	orchestrator          *ingestion.IngestionOrchestrator
+	client                *http.Client
+	minInterval           time.Duration
+}
+
+// NewPoller creates a new Poller. A nil client uses a default client that
+// refuses to connect to private network addresses, since feed URLs are
+// user-supplied. Sources polled more recently than minInterval are skipped
+// by PollDue.
+func NewPoller(
+	sourceRepo *datastore.SourceRepository,
+	feedStateRepo *datastore.FeedStateRepository,
+	userReadingSourceRepo *datastore.UserReadingSourceRepository,
+	orchestrator *ingestion.IngestionOrchestrator,
+	client *http.Client,
+	minInterval time.Duration,
+) *Poller {
+	if client == nil {
+		client = webutil.NewPublicClient(defaultFetchTimeout)
+	}
+	return &Poller{
+		sourceRepo:            sourceRepo,
+		feedStateRepo:         feedStateRepo,
+		userReadingSourceRepo: userReadingSourceRepo,
+		orchestrator:          orchestrator,
+		client:                client,
+		minInterval:           minInterval,
+	}
+}
+
+// PollDue polls every "rss" source whose owner is subscribed and has not been
+// polled within the minimum interval. Returns the number of items ingested.
+func (p *Poller) PollDue(ctx context.Context) (int, error) {
+	sources, err := p.sourceRepo.GetReadingSourcesByType(ctx, sourceTypeRSS)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch rss sources: %w", err)
+	}
+
+	ingested := 0
+	now := time.Now().UTC()
+	for _, source := range sources {
+		state, err := p.feedStateRepo.GetFeedState(ctx, source.ID)
+		if err != nil {
+			log.Printf("ERROR (FeedPoller): Failed to load state for feed %s (%s): %v", source.ID, source.Identifier, err)
+			continue
+		}
+		if state != nil && state.LastPolledAt != nil && now.Sub(*state.LastPolledAt) < p.minInterval {
+			continue
+		}
+
+		count, err := p.pollSource(ctx, source, state)
+		if err != nil {
+			log.Printf("ERROR (FeedPoller): Failed to poll feed %s (%s): %v", source.ID, source.Identifier, err)
+			continue
+		}
+		ingested += count
+	}
+	return ingested, nil
+}
+
+// PollSource polls a single feed regardless of when it was last polled.
+// Returns the number of items ingested.
+func (p *Poller) PollSource(ctx context.Context, source models.ReadingSource) (int, error) {
+	state, err := p.feedStateRepo.GetFeedState(ctx, source.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load feed state: %w", err)
+	}
+	return p.pollSource(ctx, source, state)
+}
+
+func (p *Poller) pollSource(ctx context.Context, source models.ReadingSource, state *models.FeedState) (int, error) {
+	// Sources belong to one user, who only gets items while subscribed.
+	subscribed, err := p.userReadingSourceRepo.IsUserSubscribed(ctx, source.UserID, source.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if !subscribed {
+		return 0, nil
+	}
+
+	if state == nil {
+		state = &models.FeedState{ReadingSourceID: source.ID}
+	}
+	previousPoll := state.LastPolledAt
+	polledAt := time.Now().UTC()
+
+	result, fetchErr := p.Fetch(ctx, source.Identifier, state)
+	state.LastPolledAt = &polledAt
+	if fetchErr != nil {
+		state.LastError = fetchErr.Error()
+		p.saveState(ctx, state)
+		return 0, fetchErr
+	}
+	state.LastError = ""
+	if result.NotModified {
+		log.Printf("INFO (FeedPoller): Feed %s not modified since last poll", source.Identifier)
+		p.saveState(ctx, state)
+		return 0, nil
+	}
+	state.ETag = result.ETag
+	state.LastModified = result.LastModified
+
+	feed, err := Parse(result.Body)
+	if err != nil {
+		state.LastError = err.Error()
+		p.saveState(ctx, state)
+		return 0, err
+	}
+
+	ingested := 0
+	for _, item := range newItems(feed.Items, previousPoll) {
+		content := ingestion.WebContent{
+			HTML:        []byte(item.ContentHTML),
+			URL:         item.Link,
+			Title:       item.Title,
+			Author:      item.Author,
+			PublishedAt: item.PublishedAt,
+		}
+		if content.Author == "" {
+			content.Author = feed.Title
+		}
+		if content.URL == "" {
+			content.URL = source.Identifier
+		}
+
+		reading, err := p.orchestrator.ProcessWebContent(ctx, source.UserID, source.ID, content)
+		if err != nil {
+			log.Printf("WARN (FeedPoller): Failed to ingest item %q from feed %s: %v", item.GUID, source.Identifier, err)
+			continue
+		}
+		log.Printf("INFO (FeedPoller): Ingested item %q from feed %s as reading %s", item.GUID, source.Identifier, reading.ID)
+		ingested++
+	}
+
+	p.saveState(ctx, state)
+	log.Printf("INFO (FeedPoller): Polled feed %s: %d items, %d ingested", source.Identifier, len(feed.Items), ingested)
+	return ingested, nil
+}
+
+func (p *Poller) saveState(ctx context.Context, state *models.FeedState) {
+	if err := p.feedStateRepo.UpsertFeedState(ctx, state); err != nil {
+		log.Printf("WARN (FeedPoller): Failed to save state for feed source %s: %v", state.ReadingSourceID, err)
+	}
+}
+
+// Fetch performs a conditional GET for feedURL using the validators in state.
+// state may be nil for a feed that has never been fetched.
+func (p *Poller) Fetch(ctx context.Context, feedURL string, state *models.FeedState) (*FetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feed request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8, */*;q=0.5")
+	if state != nil {
+		if state.ETag != "" {
+			req.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("feed request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &FetchResult{NotModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed request returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed body: %w", err)
+	}
+	if len(body) > maxFeedBytes {
+		return nil, fmt.Errorf("feed body exceeds %d bytes", maxFeedBytes)
+	}
+
+	return &FetchResult{
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// newItems filters out items with no content, items repeating an earlier
+// GUID in the same feed, and items published well before the previous poll,
+// and caps the number of items per poll. Items without a date are kept;
+// content-hash dedup catches repeats across polls.
+func newItems(items []Item, previousPoll *time.Time) []Item {
+	var selected []Item
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.ContentHTML == "" {
+			continue
+		}
+		if item.GUID != "" {
+			if seen[item.GUID] {
+				continue
+			}
+			seen[item.GUID] = true
+		}
+		if previousPoll != nil && item.PublishedAt != nil && item.PublishedAt.Before(previousPoll.Add(-publishedGracePeriod)) {
+			continue
+		}
+		selected = append(selected, item)
+		if len(selected) == maxItemsPerPoll {
+			break
+		}
+	}
+	return selected
+}
@@ -0,0 +1,247 @@
+package feeds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreybb/logos/models"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Example Blog</title>
+    <link>https://blog.example.com/</link>
+    <item>
+      <title>Second post</title>
+      <link>https://blog.example.com/second</link>
+      <guid>post-2</guid>
+      <dc:creator>Ada</dc:creator>
+      <pubDate>Tue, 03 Jun 2025 09:30:00 +0000</pubDate>
+      <description>Summary only</description>
+      <content:encoded><![CDATA[<p>Full <b>second</b> post</p>]]></content:encoded>
+    </item>
+    <item>
+      <title>First post</title>
+      <link>https://blog.example.com/first</link>
+      <pubDate>Mon, 02 Jun 2025 09:30:00 +0000</pubDate>
+      <description>&lt;p&gt;First post&lt;/p&gt;</description>
+    </item>
+  </channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="text">Example Atom</title>
+  <link rel="self" href="https://atom.example.com/feed.xml"/>
+  <link rel="alternate" href="https://atom.example.com/"/>
+  <entry>
+    <id>urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a</id>
+    <title type="html">Atom &amp;amp; entry</title>
+    <link rel="alternate" href="https://atom.example.com/entry"/>
+    <author><name>Grace</name></author>
+    <updated>2025-06-04T12:00:00Z</updated>
+    <summary>Plain &lt;summary&gt;</summary>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Entry body</p></div></content>
+  </entry>
+</feed>`
+
+// newTestPoller returns a Poller whose client can reach the httptest server.
+// The default client would refuse its loopback address.
+func newTestPoller(server *httptest.Server) *Poller {
+	return NewPoller(nil, nil, nil, nil, server.Client(), time.Hour)
+}
+
+func serveFeed(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchAndParseRSS(t *testing.T) {
+	server := serveFeed(t, "application/rss+xml", testRSS)
+
+	result, err := newTestPoller(server).Fetch(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	feed, err := Parse(result.Body)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if feed.Title != "Example Blog" || feed.Link != "https://blog.example.com/" {
+		t.Errorf("feed = %q %q, want Example Blog https://blog.example.com/", feed.Title, feed.Link)
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Items))
+	}
+
+	first := feed.Items[0]
+	if first.GUID != "post-2" || first.Author != "Ada" || first.Link != "https://blog.example.com/second" {
+		t.Errorf("first item = %+v", first)
+	}
+	if first.ContentHTML != "<p>Full <b>second</b> post</p>" {
+		t.Errorf("content:encoded should win over description, got %q", first.ContentHTML)
+	}
+	if want := time.Date(2025, 6, 3, 9, 30, 0, 0, time.UTC); first.PublishedAt == nil || !first.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", first.PublishedAt, want)
+	}
+
+	second := feed.Items[1]
+	if second.GUID != "https://blog.example.com/first" {
+		t.Errorf("an item without a guid should fall back to its link, got %q", second.GUID)
+	}
+	if second.ContentHTML != "<p>First post</p>" {
+		t.Errorf("ContentHTML = %q, want the description", second.ContentHTML)
+	}
+}
+
+func TestFetchAndParseAtom(t *testing.T) {
+	server := serveFeed(t, "application/atom+xml", testAtom)
+
+	result, err := newTestPoller(server).Fetch(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	feed, err := Parse(result.Body)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if feed.Title != "Example Atom" || feed.Link != "https://atom.example.com/" {
+		t.Errorf("feed = %q %q, want the alternate link", feed.Title, feed.Link)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Items))
+	}
+	item := feed.Items[0]
+	if item.Title != "Atom &amp; entry" {
+		t.Errorf("Title = %q", item.Title)
+	}
+	if item.Author != "Grace" || item.Link != "https://atom.example.com/entry" {
+		t.Errorf("item = %+v", item)
+	}
+	if !strings.Contains(item.ContentHTML, "<p>Entry body</p>") {
+		t.Errorf("ContentHTML = %q, want the xhtml content", item.ContentHTML)
+	}
+	if want := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC); item.PublishedAt == nil || !item.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want the updated date %v", item.PublishedAt, want)
+	}
+}
+
+func TestFetchConditionalGet(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 02 Jun 2025 09:30:00 GMT"
+
+	var gotIfNoneMatch, gotIfModifiedSince string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		if gotIfNoneMatch == etag || gotIfModifiedSince == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(testRSS))
+	}))
+	defer server.Close()
+	poller := newTestPoller(server)
+
+	first, err := poller.Fetch(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("first Fetch: %v", err)
+	}
+	if first.NotModified || first.ETag != etag || first.LastModified != lastModified {
+		t.Fatalf("first Fetch = %+v, want the body and both validators", first)
+	}
+
+	tests := []struct {
+		name  string
+		state models.FeedState
+	}{
+		{"ETag", models.FeedState{ETag: first.ETag}},
+		{"Last-Modified", models.FeedState{LastModified: first.LastModified}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := poller.Fetch(context.Background(), server.URL, &tt.state)
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			if !result.NotModified || len(result.Body) != 0 {
+				t.Errorf("Fetch = %+v, want NotModified", result)
+			}
+			if gotIfNoneMatch != tt.state.ETag || gotIfModifiedSince != tt.state.LastModified {
+				t.Errorf("sent If-None-Match %q and If-Modified-Since %q", gotIfNoneMatch, gotIfModifiedSince)
+			}
+		})
+	}
+}
+
+func TestFetchRejectsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := newTestPoller(server).Fetch(context.Background(), server.URL, nil); err == nil {
+		t.Error("Fetch succeeded against a 404")
+	}
+}
+
+func TestDefaultClientRefusesPrivateAddresses(t *testing.T) {
+	server := serveFeed(t, "application/rss+xml", testRSS)
+
+	poller := NewPoller(nil, nil, nil, nil, nil, time.Hour)
+	_, err := poller.Fetch(context.Background(), server.URL, nil)
+	if err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("Fetch(%s) error = %v, want a non-public address error", server.URL, err)
+	}
+}
+
+func TestNewItems(t *testing.T) {
+	at := func(s string) *time.Time {
+		parsed, _ := time.Parse(time.RFC3339, s)
+		return &parsed
+	}
+	previousPoll := at("2025-06-10T00:00:00Z")
+
+	items := []Item{
+		{GUID: "a", ContentHTML: "<p>a</p>", PublishedAt: at("2025-06-10T06:00:00Z")},
+		{GUID: "a", ContentHTML: "<p>a, repeated</p>", PublishedAt: at("2025-06-10T06:00:00Z")},
+		{GUID: "empty"},
+		{GUID: "old", ContentHTML: "<p>old</p>", PublishedAt: at("2025-06-01T00:00:00Z")},
+		{GUID: "late", ContentHTML: "<p>late</p>", PublishedAt: at("2025-06-09T12:00:00Z")},
+		{GUID: "undated", ContentHTML: "<p>undated</p>"},
+	}
+
+	var got []string
+	for _, item := range newItems(items, previousPoll) {
+		got = append(got, item.ContentHTML)
+	}
+	want := []string{"<p>a</p>", "<p>late</p>", "<p>undated</p>"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("newItems = %v, want %v", got, want)
+	}
+
+	// On the first poll nothing is too old.
+	if n := len(newItems(items, nil)); n != 4 {
+		t.Errorf("first poll kept %d items, want 4", n)
+	}
+
+	many := make([]Item, maxItemsPerPoll+5)
+	for i := range many {
+		many[i] = Item{GUID: string(rune('A' + i)), ContentHTML: "<p>x</p>"}
+	}
+	if n := len(newItems(many, nil)); n != maxItemsPerPoll {
+		t.Errorf("kept %d items, want the cap of %d", n, maxItemsPerPoll)
+	}
+}
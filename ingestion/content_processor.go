@@ -6,15 +6,18 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/coreybb/logos/models"
 	"github.com/go-shiori/go-readability"
 	"github.com/microcosm-cc/bluemonday"
 )
 
 // Holds the results of content processing.
 type ProcessedContent struct {
-	MainHTML       string // The main article HTML, cleaned and extracted.
-	MainText       string // The plain text version of the main article content.
-	ExtractedTitle string // The title extracted by the Readability library.
+	MainHTML        string                // The main article HTML, cleaned and extracted.
+	MainText        string                // The plain text version of the main article content.
+	ExtractedTitle  string                // The title extracted by the Readability library.
+	ExtractedByline string                // The author byline extracted by the Readability library, if any.
+	Images          []models.ReadingImage // Images extracted along with the HTML (e.g., from a PDF), referenced by MainHTML.
 }
 
 // Handles HTML cleaning and main content extraction.
@@ -24,12 +27,22 @@ type ContentProcessor struct {
 }
 
 func NewContentProcessor() *ContentProcessor {
+	htmlPolicy := bluemonday.UGCPolicy()
+	// Keep references to images stored with the reading (see models.ReadingImageScheme).
+	htmlPolicy.AllowURLSchemes(strings.TrimSuffix(models.ReadingImageScheme, ":"))
+
 	return &ContentProcessor{
-		htmlPolicy:      bluemonday.UGCPolicy(),       // For cleaning HTML for Readability
+		htmlPolicy:      htmlPolicy,                   // For cleaning HTML for Readability
 		stripTagsPolicy: bluemonday.StripTagsPolicy(), // For getting plain text from HTML
 	}
 }
 
+// Sanitize cleans HTML without extracting an article from it, for content
+// whose structure should be kept as is, such as the chapters of an ebook.
+func (cp *ContentProcessor) Sanitize(rawHTML string) string {
+	return cp.htmlPolicy.Sanitize(rawHTML)
+}
+
 // Cleans the raw HTML and extracts the main article content.
 // baseURL is used by Readability to resolve relative links if any; can be a placeholder like "http://localhost".
 func (cp *ContentProcessor) Process(rawHTML string, baseURL *url.URL) (*ProcessedContent, error) {
@@ -52,6 +65,7 @@ func (cp *ContentProcessor) Process(rawHTML string, baseURL *url.URL) (*Processe
 		result.MainHTML = article.Content // Readability already performs some cleaning.
 		result.MainText = article.TextContent
 		result.ExtractedTitle = article.Title
+		result.ExtractedByline = article.Byline
 		log.Printf("INFO: ContentProcessor successfully extracted main content. Extracted title: '%s'", result.ExtractedTitle)
 	} else {
 		if err != nil {
@@ -0,0 +1,147 @@
+package ingestion
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Unchanged lines shown around each change in a diff.
+	diffContextLines = 3
+
+	// Past this many line pairs, changed regions aren't aligned line by line
+	// but shown as wholly removed and added, to bound time and memory.
+	maxDiffCells = 4 << 20
+)
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines returns a unified diff of two texts, line by line, or "" if
+// they're equal.
+func diffLines(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return formatUnifiedDiff(ops)
+}
+
+// Aligns two runs of lines by their longest common subsequence.
+func diffMiddle(a, b []string) []diffOp {
+	var ops []diffOp
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// Formats diff operations as hunks with diffContextLines of context.
+func formatUnifiedDiff(ops []diffOp) string {
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of the hunk around it.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for k := first; k < len(ops) && k <= last+2*diffContextLines; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		hunkStart := max(first-diffContextLines, start)
+		hunkEnd := min(last+diffContextLines+1, len(ops))
+
+		// Line numbers are 1-based positions in each text where the hunk starts.
+		aLine, bLine := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, op := range ops[hunkStart:hunkEnd] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+	return sb.String()
+}
@@ -0,0 +1,246 @@
+package ingestion
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/coreybb/logos/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Segments shorter than this (in characters of text, excluding the title)
+// are treated as layout noise rather than articles.
+const minDigestSegmentTextLength = 80
+
+// One article cut out of a digest email.
+type DigestSegment struct {
+	Title string // Text of the heading that introduced the article; may be empty in selector mode
+	HTML  []byte
+}
+
+// Checks a source's split settings, compiling the selector for SplitModeSelector.
+func ValidateSplitSettings(mode models.SplitMode, selector string) error {
+	switch mode {
+	case models.SplitModeNone, models.SplitModeHeadings:
+		return nil
+	case models.SplitModeSelector:
+		if strings.TrimSpace(selector) == "" {
+			return fmt.Errorf("a split selector is required with split mode %q", mode)
+		}
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("invalid split selector %q: %w", selector, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid split mode %q", mode)
+	}
+}
+
+// Splits a digest's HTML into one segment per article according to the
+// source's split settings. Returns nil when the document doesn't contain at
+// least two articles, in which case it should be ingested as a single reading.
+func SplitDigest(rawHTML []byte, mode models.SplitMode, selector string) ([]DigestSegment, error) {
+	if mode == models.SplitModeNone || mode == "" {
+		return nil, nil
+	}
+	if err := ValidateSplitSettings(mode, selector); err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(rawHTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest HTML: %w", err)
+	}
+
+	var segments []DigestSegment
+	if mode == models.SplitModeSelector {
+		segments, err = splitBySelector(doc, selector)
+	} else {
+		segments, err = splitByHeadings(doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) < 2 {
+		return nil, nil
+	}
+	return segments, nil
+}
+
+// Makes one segment of each element matching selector. Matches nested inside
+// another match are part of the outer segment.
+func splitBySelector(doc *html.Node, selector string) ([]DigestSegment, error) {
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid split selector %q: %w", selector, err)
+	}
+
+	matches := cascadia.QueryAll(doc, sel)
+	matched := make(map[*html.Node]bool, len(matches))
+	for _, n := range matches {
+		matched[n] = true
+	}
+
+	var segments []DigestSegment
+	for _, n := range matches {
+		if hasAncestorIn(n, matched) {
+			continue
+		}
+		title := ""
+		if heading := firstHeading(n); heading != nil {
+			title = nodeText(heading)
+		}
+		if segment, ok := renderSegment(title, []*html.Node{n}); ok {
+			segments = append(segments, segment)
+		}
+	}
+	return segments, nil
+}
+
+// Finds the most prominent heading level that occurs more than once and cuts
+// the document at each heading of that level. A segment is the heading's
+// largest enclosing element that holds no other such heading, together with
+// the siblings that follow it up to the next one. This copes with both flat
+// markup and the table-per-story layouts common in newsletters.
+func splitByHeadings(doc *html.Node) ([]DigestSegment, error) {
+	headings := splitHeadings(doc)
+	if len(headings) < 2 {
+		return nil, nil
+	}
+
+	isHeading := make(map[*html.Node]bool, len(headings))
+	for _, h := range headings {
+		isHeading[h] = true
+	}
+
+	var segments []DigestSegment
+	for _, h := range headings {
+		root := h
+		for root.Parent != nil && root.Parent.Type == html.ElementNode && !containsHeadingOtherThan(root.Parent, h, isHeading) {
+			root = root.Parent
+		}
+
+		nodes := []*html.Node{root}
+		for sib := root.NextSibling; sib != nil && !containsHeadingOtherThan(sib, nil, isHeading); sib = sib.NextSibling {
+			nodes = append(nodes, sib)
+		}
+		if segment, ok := renderSegment(nodeText(h), nodes); ok {
+			segments = append(segments, segment)
+		}
+	}
+	return segments, nil
+}
+
+// Returns the headings of the highest level (h1 before h2 before h3) that
+// appears at least twice, in document order.
+func splitHeadings(doc *html.Node) []*html.Node {
+	byLevel := make(map[atom.Atom][]*html.Node)
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.H1 || n.DataAtom == atom.H2 || n.DataAtom == atom.H3) && nodeText(n) != "" {
+			byLevel[n.DataAtom] = append(byLevel[n.DataAtom], n)
+		}
+		return true
+	})
+	for _, level := range []atom.Atom{atom.H1, atom.H2, atom.H3} {
+		if len(byLevel[level]) >= 2 {
+			return byLevel[level]
+		}
+	}
+	return nil
+}
+
+// Renders nodes as a segment, dropping segments with too little text to be an article.
+func renderSegment(title string, nodes []*html.Node) (DigestSegment, bool) {
+	// Rows and cells cut out of a layout table need their table back, or the
+	// HTML parser drops them when the segment is processed on its own.
+	prefix, suffix := "", ""
+	switch nodes[0].DataAtom {
+	case atom.Tr, atom.Tbody, atom.Thead, atom.Tfoot:
+		prefix, suffix = "<table>", "</table>"
+	case atom.Td, atom.Th:
+		prefix, suffix = "<table><tr>", "</tr></table>"
+	}
+
+	var buf bytes.Buffer
+	var text strings.Builder
+	buf.WriteString(prefix)
+	for _, n := range nodes {
+		if err := html.Render(&buf, n); err != nil {
+			return DigestSegment{}, false
+		}
+		text.WriteString(nodeText(n))
+		text.WriteString(" ")
+	}
+	buf.WriteString(suffix)
+	if len(strings.TrimSpace(text.String()))-len(title) < minDigestSegmentTextLength {
+		return DigestSegment{}, false
+	}
+	return DigestSegment{Title: title, HTML: buf.Bytes()}, true
+}
+
+// Reports whether n is or contains a split heading other than self.
+func containsHeadingOtherThan(n, self *html.Node, isHeading map[*html.Node]bool) bool {
+	found := false
+	walk(n, func(c *html.Node) bool {
+		if c != self && isHeading[c] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func hasAncestorIn(n *html.Node, set map[*html.Node]bool) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if set[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func firstHeading(n *html.Node) *html.Node {
+	var found *html.Node
+	walk(n, func(c *html.Node) bool {
+		if found == nil && c.Type == html.ElementNode {
+			switch c.DataAtom {
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				if nodeText(c) != "" {
+					found = c
+				}
+			}
+		}
+		return found == nil
+	})
+	return found
+}
+
+// Returns the visible text of n with whitespace collapsed.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && (c.DataAtom == atom.Script || c.DataAtom == atom.Style) {
+			return false
+		}
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+			sb.WriteString(" ")
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// Visits n and its descendants depth-first in document order. Returning false
+// from visit skips that node's children.
+func walk(n *html.Node, visit func(*html.Node) bool) {
+	if !visit(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
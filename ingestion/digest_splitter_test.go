@@ -0,0 +1,142 @@
+package ingestion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreybb/logos/models"
+)
+
+// Long enough to count as an article.
+var digestBody = strings.Repeat("Lorem ipsum dolor sit amet. ", 4)
+
+func TestSplitDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		mode     models.SplitMode
+		selector string
+		want     []DigestSegment
+	}{
+		{
+			name: "no split mode",
+			html: "<h2>One</h2><p>" + digestBody + "</p><h2>Two</h2><p>" + digestBody + "</p>",
+			mode: models.SplitModeNone,
+		},
+		{
+			name: "unset split mode",
+			html: "<h2>One</h2><p>" + digestBody + "</p><h2>Two</h2><p>" + digestBody + "</p>",
+		},
+		{
+			name: "flat headings",
+			html: "<p>Intro</p><h2>One</h2><p>" + digestBody + "</p><p>More</p><h2>Two</h2><p>" + digestBody + "</p>",
+			mode: models.SplitModeHeadings,
+			want: []DigestSegment{
+				{Title: "One", HTML: []byte("<h2>One</h2><p>" + digestBody + "</p><p>More</p>")},
+				{Title: "Two", HTML: []byte("<h2>Two</h2><p>" + digestBody + "</p>")},
+			},
+		},
+		{
+			name: "most prominent repeated heading level",
+			html: "<h1>Weekly digest</h1><h2>One</h2><h3>Part</h3><p>" + digestBody + "</p><h2>Two</h2><p>" + digestBody + "</p>",
+			mode: models.SplitModeHeadings,
+			want: []DigestSegment{
+				{Title: "One", HTML: []byte("<h2>One</h2><h3>Part</h3><p>" + digestBody + "</p>")},
+				{Title: "Two", HTML: []byte("<h2>Two</h2><p>" + digestBody + "</p>")},
+			},
+		},
+		{
+			name: "table per story",
+			html: "<table><tr><td><h3>One</h3><p>" + digestBody + "</p></td></tr>" +
+				"<tr><td><h3>Two</h3><p>" + digestBody + "</p></td></tr></table>",
+			mode: models.SplitModeHeadings,
+			want: []DigestSegment{
+				{Title: "One", HTML: []byte("<table><tr><td><h3>One</h3><p>" + digestBody + "</p></td></tr></table>")},
+				{Title: "Two", HTML: []byte("<table><tr><td><h3>Two</h3><p>" + digestBody + "</p></td></tr></table>")},
+			},
+		},
+		{
+			name: "short sections are dropped",
+			html: "<h2>One</h2><p>" + digestBody + "</p><h2>Ad</h2><p>Buy now</p><h2>Two</h2><p>" + digestBody + "</p>",
+			mode: models.SplitModeHeadings,
+			want: []DigestSegment{
+				{Title: "One", HTML: []byte("<h2>One</h2><p>" + digestBody + "</p>")},
+				{Title: "Two", HTML: []byte("<h2>Two</h2><p>" + digestBody + "</p>")},
+			},
+		},
+		{
+			name: "a single article isn't split",
+			html: "<h2>One</h2><p>" + digestBody + "</p><h2>Ad</h2><p>Buy now</p>",
+			mode: models.SplitModeHeadings,
+		},
+		{
+			name: "no repeated heading",
+			html: "<h1>Only</h1><p>" + digestBody + "</p>",
+			mode: models.SplitModeHeadings,
+		},
+		{
+			name:     "selector with nested matches",
+			html:     `<div class="story"><h3>One</h3><p>` + digestBody + `</p><div class="story">quote</div></div><div class="story"><p>` + digestBody + `</p></div>`,
+			mode:     models.SplitModeSelector,
+			selector: "div.story",
+			want: []DigestSegment{
+				{Title: "One", HTML: []byte(`<div class="story"><h3>One</h3><p>` + digestBody + `</p><div class="story">quote</div></div>`)},
+				{Title: "", HTML: []byte(`<div class="story"><p>` + digestBody + `</p></div>`)},
+			},
+		},
+		{
+			name:     "selector matching table cells",
+			html:     `<table><tr><td class="a"><p>` + digestBody + `</p></td><td class="a"><p>` + digestBody + `</p></td></tr></table>`,
+			mode:     models.SplitModeSelector,
+			selector: "td.a",
+			want: []DigestSegment{
+				{HTML: []byte(`<table><tr><td class="a"><p>` + digestBody + `</p></td></tr></table>`)},
+				{HTML: []byte(`<table><tr><td class="a"><p>` + digestBody + `</p></td></tr></table>`)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitDigest([]byte(tt.html), tt.mode, tt.selector)
+			if err != nil {
+				t.Fatalf("SplitDigest: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitDigest()\n got: %s\nwant: %s", formatSegments(got), formatSegments(tt.want))
+			}
+		})
+	}
+}
+
+func formatSegments(segments []DigestSegment) string {
+	var parts []string
+	for _, s := range segments {
+		parts = append(parts, "["+s.Title+"] "+string(s.HTML))
+	}
+	return strings.Join(parts, "\n      ")
+}
+
+func TestSplitDigestInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     models.SplitMode
+		selector string
+	}{
+		{name: "selector mode without a selector", mode: models.SplitModeSelector, selector: " "},
+		{name: "invalid selector", mode: models.SplitModeSelector, selector: "div[class="},
+		{name: "unknown mode", mode: "paragraphs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSplitSettings(tt.mode, tt.selector); err == nil {
+				t.Errorf("ValidateSplitSettings(%q, %q) succeeded, want an error", tt.mode, tt.selector)
+			}
+			if _, err := SplitDigest([]byte("<p>x</p>"), tt.mode, tt.selector); err == nil {
+				t.Errorf("SplitDigest(%q, %q) succeeded, want an error", tt.mode, tt.selector)
+			}
+		})
+	}
+}
@@ -60,9 +60,31 @@ func MatchAttachmentByContentType(attachment *enmime.Part, pFormat PrioritizedFo
 // without conversion to HTML, and not typically processed by ContentProcessor.
 func IsDirectReadingFormat(format models.ReadingFormat) bool {
 	switch format {
-	case models.ReadingFormatPDF, models.ReadingFormatEPUB, models.ReadingFormatMOBI:
+	case models.ReadingFormatMOBI:
 		return true
 	default:
 		return false
 	}
 }
+
+// formatContentTypes maps attachment formats to the MIME type an original
+// file is served with.
+var formatContentTypes = map[models.ReadingFormat]string{
+	models.ReadingFormatPDF:  "application/pdf",
+	models.ReadingFormatEPUB: "application/epub+zip",
+	models.ReadingFormatMOBI: "application/x-mobipocket-ebook",
+	models.ReadingFormatDOCX: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	models.ReadingFormatRTF:  "application/rtf",
+	models.ReadingFormatMD:   "text/markdown",
+	models.ReadingFormatTXT:  "text/plain",
+	models.ReadingFormatHTML: "text/html",
+}
+
+// ContentTypeForFormat returns the MIME type for a reading format, or
+// application/octet-stream if it is unknown.
+func ContentTypeForFormat(format models.ReadingFormat) string {
+	if contentType, ok := formatContentTypes[format]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,85 @@
+package ingestion
+
+import (
+	"log"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+	"github.com/jhillyerd/enmime"
+)
+
+// Matches cid: URLs inside src attributes and CSS url() values.
+var cidRefRegex = regexp.MustCompile(`(?i)cid:([^"'\s>)]+)`)
+
+// Collects the image parts of an email that carry a Content-ID, keyed by that ID.
+// Senders are inconsistent about marking such parts inline, so attachments and
+// other parts are checked as well. When several parts share a Content-ID, the
+// first one wins, preferring inline parts over attachments.
+func collectInlineImages(env *enmime.Envelope) map[string]models.ReadingImage {
+	images := make(map[string]models.ReadingImage)
+	for _, parts := range [][]*enmime.Part{env.Inlines, env.OtherParts, env.Attachments} {
+		for _, part := range parts {
+			if part.ContentID == "" || len(part.Content) == 0 {
+				continue
+			}
+			if _, dup := images[part.ContentID]; dup {
+				continue
+			}
+			contentType, _, err := mime.ParseMediaType(part.ContentType)
+			if err != nil || !strings.HasPrefix(contentType, "image/") {
+				continue
+			}
+			hash, err := webutil.GenerateHash(string(part.Content))
+			if err != nil {
+				log.Printf("WARN (collectInlineImages): Failed to hash inline image %s: %v", part.ContentID, err)
+				continue
+			}
+			images[part.ContentID] = models.ReadingImage{
+				ContentHash: hash,
+				ContentType: contentType,
+				Data:        part.Content,
+			}
+		}
+	}
+	return images
+}
+
+// Replaces cid: references in html with references to the matching stored
+// images. References without a matching part are left untouched.
+func rewriteCIDReferences(html string, images map[string]models.ReadingImage) string {
+	if len(images) == 0 {
+		return html
+	}
+	return cidRefRegex.ReplaceAllStringFunc(html, func(match string) string {
+		contentID := match[len("cid:"):]
+		if unescaped, err := url.PathUnescape(contentID); err == nil {
+			contentID = unescaped
+		}
+		image, ok := images[contentID]
+		if !ok {
+			return match
+		}
+		return image.Ref()
+	})
+}
+
+// Returns the images that html still refers to after processing, once each.
+// Readability drops boilerplate such as footers, and their images with it.
+func referencedImages(html string, images map[string]models.ReadingImage) []models.ReadingImage {
+	var referenced []models.ReadingImage
+	seen := make(map[string]struct{})
+	for _, image := range images {
+		if _, dup := seen[image.ContentHash]; dup {
+			continue
+		}
+		if strings.Contains(html, image.Ref()) {
+			referenced = append(referenced, image)
+			seen[image.ContentHash] = struct{}{}
+		}
+	}
+	return referenced
+}
@@ -0,0 +1,140 @@
+package ingestion
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+	"github.com/jhillyerd/enmime"
+)
+
+func testImage(t *testing.T, data string) models.ReadingImage {
+	t.Helper()
+	hash, err := webutil.GenerateHash(data)
+	if err != nil {
+		t.Fatalf("hashing image: %v", err)
+	}
+	return models.ReadingImage{ContentHash: hash, ContentType: "image/png", Data: []byte(data)}
+}
+
+func TestCollectInlineImages(t *testing.T) {
+	env := &enmime.Envelope{
+		Inlines: []*enmime.Part{
+			{ContentID: "logo@example.com", ContentType: "image/png", Content: []byte("inline logo")},
+			{ContentID: "empty@example.com", ContentType: "image/png"},
+			{ContentType: "image/png", Content: []byte("no content id")},
+		},
+		OtherParts: []*enmime.Part{
+			{ContentID: "text@example.com", ContentType: "text/plain", Content: []byte("not an image")},
+			{ContentID: "broken@example.com", ContentType: "image/png; =", Content: []byte("bad media type")},
+			{ContentID: "photo@example.com", ContentType: "image/jpeg; name=photo.jpg", Content: []byte("photo")},
+		},
+		Attachments: []*enmime.Part{
+			// Same Content-ID as an inline part; the inline part wins.
+			{ContentID: "logo@example.com", ContentType: "image/gif", Content: []byte("attached logo")},
+		},
+	}
+
+	got := collectInlineImages(env)
+
+	var ids []string
+	for id := range got {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if want := []string{"logo@example.com", "photo@example.com"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Content-IDs = %v, want %v", ids, want)
+	}
+	if logo := got["logo@example.com"]; string(logo.Data) != "inline logo" || logo.ContentType != "image/png" {
+		t.Errorf("duplicate Content-ID resolved to %s %q, want the inline part", logo.ContentType, logo.Data)
+	}
+	if photo := got["photo@example.com"]; photo.ContentType != "image/jpeg" || photo.ContentHash != testImage(t, "photo").ContentHash {
+		t.Errorf("photo = %s %s, want image/jpeg with the content's hash", photo.ContentType, photo.ContentHash)
+	}
+}
+
+func TestRewriteCIDReferences(t *testing.T) {
+	logo := testImage(t, "logo")
+	photo := testImage(t, "photo")
+	images := map[string]models.ReadingImage{
+		"logo@example.com":  logo,
+		"photo@example.com": photo,
+		"alias@example.com": logo, // Same content under a second Content-ID
+	}
+
+	tests := []struct {
+		name   string
+		html   string
+		images map[string]models.ReadingImage
+		want   string
+	}{
+		{
+			name:   "src attributes",
+			html:   `<img src="cid:logo@example.com"><img src='cid:photo@example.com' alt="">`,
+			images: images,
+			want:   `<img src="` + logo.Ref() + `"><img src='` + photo.Ref() + `' alt="">`,
+		},
+		{
+			name:   "CSS url and unquoted attribute",
+			html:   `<td style="background:url(cid:photo@example.com)"><img src=cid:logo@example.com>`,
+			images: images,
+			want:   `<td style="background:url(` + photo.Ref() + `)"><img src=` + logo.Ref() + `>`,
+		},
+		{
+			name:   "scheme is case-insensitive and IDs may be escaped",
+			html:   `<img src="CID:logo%40example.com">`,
+			images: images,
+			want:   `<img src="` + logo.Ref() + `">`,
+		},
+		{
+			name:   "missing Content-ID is left alone",
+			html:   `<img src="cid:missing@example.com"><img src="cid:logo@example.com">`,
+			images: images,
+			want:   `<img src="cid:missing@example.com"><img src="` + logo.Ref() + `">`,
+		},
+		{
+			name:   "duplicate content under two Content-IDs",
+			html:   `<img src="cid:logo@example.com"><img src="cid:alias@example.com">`,
+			images: images,
+			want:   `<img src="` + logo.Ref() + `"><img src="` + logo.Ref() + `">`,
+		},
+		{
+			name: "no images",
+			html: `<img src="cid:logo@example.com">`,
+			want: `<img src="cid:logo@example.com">`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriteCIDReferences(tt.html, tt.images); got != tt.want {
+				t.Errorf("rewriteCIDReferences(%q)\n got: %q\nwant: %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferencedImages(t *testing.T) {
+	logo := testImage(t, "logo")
+	photo := testImage(t, "photo")
+	footer := testImage(t, "footer")
+	images := map[string]models.ReadingImage{
+		"logo@example.com":   logo,
+		"alias@example.com":  logo,
+		"photo@example.com":  photo,
+		"footer@example.com": footer,
+	}
+	html := rewriteCIDReferences(`<img src="cid:logo@example.com"><img src="cid:alias@example.com"><img src="cid:photo@example.com">`, images)
+
+	var got []string
+	for _, image := range referencedImages(html, images) {
+		got = append(got, string(image.Data))
+	}
+	sort.Strings(got)
+	// The footer image was dropped with its markup; the logo is stored once.
+	if want := []string{"logo", "photo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("referenced images = %q, want %q", got, want)
+	}
+}
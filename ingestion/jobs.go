@@ -0,0 +1,193 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/models"
+)
+
+const (
+	DefaultJobWorkers        = 2
+	DefaultJobMaxAttempts    = 5
+	DefaultJobRetryBaseDelay = time.Minute
+	DefaultJobRetryMaxDelay  = 6 * time.Hour
+
+	// jobLease is how long a claimed job is reserved for its worker. A job
+	// still running when its lease expires is assumed abandoned (e.g., the
+	// instance was stopped) and may be claimed again.
+	jobLease = 10 * time.Minute
+
+	// jobPollInterval is how often idle workers look for due jobs, such as
+	// retries, that no Notify call announced.
+	jobPollInterval = 15 * time.Second
+)
+
+// ErrJobNotRetryable marks a job failure that retrying can't fix, such as an
+// unparseable message. The job goes straight to the dead-letter state.
+var ErrJobNotRetryable = errors.New("ingestion job cannot succeed on retry")
+
+// JobProcessor runs a claimed job through ingestion.
+type JobProcessor func(ctx context.Context, job *models.IngestionJob) error
+
+// JobRetryPolicy controls how often and how long failed jobs are retried.
+type JobRetryPolicy struct {
+	MaxAttempts int           // Attempts, including the first, before a job is dead-lettered
+	BaseDelay   time.Duration // Wait after the first failed attempt; doubles after each further failure
+	MaxDelay    time.Duration // Upper bound on the wait between attempts
+}
+
+// Backoff returns how long to wait after the given number of failed attempts.
+func (p JobRetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// WorkerPool claims ingestion jobs from the ingestion_jobs table and runs
+// them through a JobProcessor, recording the outcome. Any number of
+// instances can run pools against the same table.
+type WorkerPool struct {
+	repo    *datastore.IngestionJobRepository
+	process JobProcessor
+	policy  JobRetryPolicy
+	workers int
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func NewWorkerPool(repo *datastore.IngestionJobRepository, process JobProcessor, policy JobRetryPolicy, workers int) *WorkerPool {
+	return &WorkerPool{
+		repo:    repo,
+		process: process,
+		policy:  policy,
+		workers: max(workers, 1),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Start launches the workers. They run until Shutdown is called.
+func (p *WorkerPool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	for i := 0; i < p.workers; i++ {
+		p.done.Add(1)
+		go func() {
+			defer p.done.Done()
+			p.work(ctx)
+		}()
+	}
+	log.Printf("INFO (IngestionWorkers): Started %d ingestion workers", p.workers)
+}
+
+// Shutdown stops the workers from claiming further jobs and waits for jobs
+// in progress to finish, or for ctx to be done. Jobs cut short are claimed
+// again once their lease expires.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		p.done.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("ingestion workers did not stop: %w", ctx.Err())
+	}
+}
+
+// Notify wakes an idle worker to claim a newly enqueued job without waiting
+// for the next poll.
+func (p *WorkerPool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default: // A wake-up is already pending
+	}
+}
+
+func (p *WorkerPool) work(ctx context.Context) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		job, err := p.repo.ClaimIngestionJob(ctx, time.Now().UTC(), jobLease)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("ERROR (IngestionWorkers): Failed to claim ingestion job: %v", err)
+		}
+		if job != nil {
+			p.run(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Processes a claimed job and records the outcome. A job in progress isn't
+// interrupted by shutdown, only bounded by its lease.
+func (p *WorkerPool) run(ctx context.Context, job *models.IngestionJob) {
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobLease)
+	defer cancel()
+
+	if job.Attempts > p.policy.MaxAttempts {
+		// The lease on the final attempt expired without an outcome.
+		p.markDead(jobCtx, job, fmt.Sprintf("abandoned after %d attempts; last error: %s", p.policy.MaxAttempts, job.LastError))
+		return
+	}
+
+	log.Printf("INFO (IngestionWorkers): Processing ingestion job %s for UserID %s (attempt %d of %d)", job.ID, job.UserID, job.Attempts, p.policy.MaxAttempts)
+	err := p.processSafely(jobCtx, job)
+	switch {
+	case err == nil:
+		if err := p.repo.MarkIngestionJobSucceeded(jobCtx, job.ID, time.Now().UTC()); err != nil {
+			log.Printf("ERROR (IngestionWorkers): Processed ingestion job %s but failed to mark it succeeded: %v", job.ID, err)
+		}
+	case errors.Is(err, ErrJobNotRetryable):
+		p.markDead(jobCtx, job, err.Error())
+	case job.Attempts >= p.policy.MaxAttempts:
+		p.markDead(jobCtx, job, fmt.Sprintf("gave up after %d attempts: %v", job.Attempts, err))
+	default:
+		delay := p.policy.Backoff(job.Attempts)
+		log.Printf("WARN (IngestionWorkers): Ingestion job %s failed (attempt %d of %d), retrying in %s: %v", job.ID, job.Attempts, p.policy.MaxAttempts, delay, err)
+		if err := p.repo.ScheduleIngestionJobRetry(jobCtx, job.ID, err.Error(), time.Now().UTC().Add(delay)); err != nil {
+			log.Printf("ERROR (IngestionWorkers): Failed to schedule retry of ingestion job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// Runs the processor, turning a panic into an error so that one bad message
+// can't take down the worker.
+func (p *WorkerPool) processSafely(ctx context.Context, job *models.IngestionJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing ingestion job: %v", r)
+		}
+	}()
+	return p.process(ctx, job)
+}
+
+func (p *WorkerPool) markDead(ctx context.Context, job *models.IngestionJob, reason string) {
+	log.Printf("WARN (IngestionWorkers): Moving ingestion job %s for UserID %s to dead letter: %s", job.ID, job.UserID, reason)
+	if err := p.repo.MarkIngestionJobDead(ctx, job.ID, reason, time.Now().UTC()); err != nil {
+		log.Printf("ERROR (IngestionWorkers): Failed to mark ingestion job %s dead: %v", job.ID, err)
+	}
+}
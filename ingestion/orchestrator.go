@@ -5,51 +5,143 @@ import (
 	"fmt"
 	"log"
 	"mime"
-	"net/http"
 	"strings"
 	"time"
 
+	"github.com/coreybb/logos/conversion"
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
+	"github.com/coreybb/logos/webutil"
+	"github.com/google/uuid"
 	"github.com/jhillyerd/enmime"
 )
 
+// How far back new readings are compared against for near-duplicates.
+const nearDuplicateWindow = 30 * 24 * time.Hour
+
 // Coordinates the various steps of processing inbound content.
 type IngestionOrchestrator struct {
 	ReadingRepo    *datastore.ReadingRepository
 	SourceRepo     *datastore.SourceRepository
+	RawEmailRepo   *datastore.RawEmailRepository // Optional; archives inbound email for reprocessing
+	Blobs          storage.BlobStore             // Holds reading content, images and originals; the database only has their keys
 	Pipeline       *ContentPipelineService
 	ReadingBuilder *ReadingBuilder
+	// Fingerprint similarity (0 to 1) at or above which a new reading is
+	// linked to an earlier one as a near-duplicate. 0 disables linking.
+	NearDuplicateThreshold float64
+}
+
+// Describes HTML content fetched from the web rather than received by email,
+// such as a feed item or a saved article.
+type WebContent struct {
+	HTML        []byte
+	URL         string     // Where the content lives; used as the base URL for relative links
+	Title       string     // Preferred title; falls back to the extracted title when empty
+	Author      string     // Preferred author; falls back to the extracted byline when empty
+	PublishedAt *time.Time // Optional
 }
 
 // Creates a new IngestionOrchestrator.
 func NewIngestionOrchestrator(
 	readingRepo *datastore.ReadingRepository,
 	sourceRepo *datastore.SourceRepository,
+	rawEmailRepo *datastore.RawEmailRepository,
+	blobs storage.BlobStore,
 	pipeline *ContentPipelineService,
 	readingBuilder *ReadingBuilder,
 ) *IngestionOrchestrator {
 	return &IngestionOrchestrator{
-		ReadingRepo:    readingRepo,
-		SourceRepo:     sourceRepo,
-		Pipeline:       pipeline,
-		ReadingBuilder: readingBuilder,
+		ReadingRepo:            readingRepo,
+		SourceRepo:             sourceRepo,
+		RawEmailRepo:           rawEmailRepo,
+		Blobs:                  blobs,
+		Pipeline:               pipeline,
+		ReadingBuilder:         readingBuilder,
+		NearDuplicateThreshold: DefaultNearDuplicateThreshold,
 	}
 }
 
 // Identifies content, converts, builds models, stores, and links to user.
-// The http.ResponseWriter is passed through for now for error handling consistency,
-// but ideally, this layer shouldn't directly write HTTP responses.
+// rawMIME, when given, is archived and linked to the readings so that they can
+// be rebuilt later by ReprocessReadings.
 func (io *IngestionOrchestrator) ProcessInboundEmail(
-	w http.ResponseWriter, ctx context.Context,
+	ctx context.Context,
 	userID, actualSenderEmail, webhookSubject string,
-	env *enmime.Envelope, messageIDFromMIME string,
+	env *enmime.Envelope, messageIDFromMIME, rawMIME string,
 ) error {
+	built, isDigest, err := io.buildEmailReadings(ctx, io.ReadingBuilder, userID, actualSenderEmail, webhookSubject, env, messageIDFromMIME)
+	if err != nil {
+		return err
+	}
+	rawEmailID := io.archiveRawEmail(ctx, userID, actualSenderEmail, webhookSubject, rawMIME, messageIDFromMIME)
+
+	stored := 0
+	for i := range built {
+		reading := &built[i].reading
+		reading.RawEmailID = rawEmailID
+		if rawEmailID != nil {
+			reading.RawEmailPart = &built[i].part
+		}
+		// Process persistence (deduplication, storing, DB record creation)
+		err = io.processReadingPersistenceAndDeduplication(ctx, reading, built[i].content, built[i].format, userID, messageIDFromMIME)
+		if err != nil {
+			continue // Already logged by the helper method
+		}
+		io.linkReadingToUser(ctx, userID, reading.ID, messageIDFromMIME)
+		stored++
+	}
+
+	if !isDigest {
+		return err
+	}
+	if stored == 0 {
+		return fmt.Errorf("none of the %d articles in the digest could be stored (Message-ID: %s)", len(built), messageIDFromMIME)
+	}
+	log.Printf("INFO (IngestionOrchestrator): Stored %d of %d digest articles for UserID %s (Message-ID: %s)", stored, len(built), userID, messageIDFromMIME)
+	return nil
+}
+
+// A reading built from an email, with the content to store for it.
+type emailReading struct {
+	reading models.Reading
+	content []byte
+	format  models.ReadingFormat
+	part    int // 0 for the whole email, or the article's position from 1 in a split digest, counting any that were skipped
+}
+
+// Identifies the primary content of an email, runs it through the pipeline
+// and builds the reading, or one reading per article if the sender's digests
+// are split. Nothing is stored, although rb may create the sender's source.
+func (io *IngestionOrchestrator) buildEmailReadings(
+	ctx context.Context,
+	rb *ReadingBuilder,
+	userID, actualSenderEmail, webhookSubject string,
+	env *enmime.Envelope, messageIDFromMIME string,
+) (built []emailReading, isDigest bool, err error) {
 	rawContentBytes, originalIdentifiedFormat, originalFileName, isAttachment, err := io.identifyPrimaryContent(env)
 	if err != nil {
 		// Error already logged by identifyPrimaryContent if it's from there directly.
 		// The caller (HandleInbound) will use this error to call handleProcessingError.
-		return fmt.Errorf("no usable primary content found for UserID %s (Message-ID: %s): %w", userID, messageIDFromMIME, err)
+		return nil, false, fmt.Errorf("no usable primary content found for UserID %s (Message-ID: %s): %w", userID, messageIDFromMIME, err)
+	}
+
+	// Inline images are referenced from the HTML body by Content-ID, which
+	// means nothing outside the email; point them at the stored copies instead.
+	var inlineImages map[string]models.ReadingImage
+	if !isAttachment && originalIdentifiedFormat == models.ReadingFormatHTML {
+		inlineImages = collectInlineImages(env)
+		if len(inlineImages) > 0 {
+			log.Printf("INFO (IngestionOrchestrator): Found %d inline images for UserID %s (Message-ID: %s)", len(inlineImages), userID, messageIDFromMIME)
+			rawContentBytes = []byte(rewriteCIDReferences(string(rawContentBytes), inlineImages))
+		}
+
+		// Digests from sources with a split mode become one reading per article.
+		if segments := io.splitDigestFromSender(ctx, userID, actualSenderEmail, rawContentBytes, messageIDFromMIME); len(segments) > 0 {
+			built, err = io.buildDigestReadings(ctx, rb, userID, actualSenderEmail, webhookSubject, env, segments, inlineImages, messageIDFromMIME)
+			return built, true, err
+		}
 	}
 
 	var reading models.Reading
@@ -59,45 +151,242 @@ func (io *IngestionOrchestrator) ProcessInboundEmail(
 
 	if isAttachment {
 		finalContentToStore, finalFormatForReading, processedHTMLDataForBuilder, err = io.processAttachedFile(
-			rawContentBytes, originalIdentifiedFormat, originalFileName, userID, messageIDFromMIME,
+			ctx, rawContentBytes, originalIdentifiedFormat, originalFileName, userID, messageIDFromMIME,
 		)
 	} else {
 		finalContentToStore, finalFormatForReading, processedHTMLDataForBuilder, err = io.processEmailBody(
-			rawContentBytes, originalIdentifiedFormat, userID, messageIDFromMIME,
+			ctx, rawContentBytes, originalIdentifiedFormat, userID, messageIDFromMIME,
 		)
 	}
 
 	if err != nil {
 		log.Printf("ERROR (IngestionOrchestrator): Failed to process primary content (format: %s, isAttachment: %t) for UserID %s (Message-ID: %s): %v", originalIdentifiedFormat, isAttachment, userID, messageIDFromMIME, err)
-		return fmt.Errorf("failed to process email content: %w", err)
+		return nil, false, fmt.Errorf("failed to process email content: %w", err)
 	}
 
 	if finalFormatForReading == models.ReadingFormatHTML && processedHTMLDataForBuilder != nil {
-		reading, err = io.ReadingBuilder.BuildFromHTML(ctx, actualSenderEmail, webhookSubject, env, processedHTMLDataForBuilder, messageIDFromMIME)
+		reading, err = rb.BuildFromHTML(ctx, userID, actualSenderEmail, webhookSubject, env, processedHTMLDataForBuilder, messageIDFromMIME)
 	} else {
-		reading, err = io.ReadingBuilder.BuildFromFile(ctx, actualSenderEmail, webhookSubject, env, finalContentToStore, finalFormatForReading, originalFileName, messageIDFromMIME)
+		reading, err = rb.BuildFromFile(ctx, userID, actualSenderEmail, webhookSubject, env, finalContentToStore, finalFormatForReading, originalFileName, messageIDFromMIME)
 	}
 	if err != nil { // This is the error from ReadingBuilder
 		log.Printf("ERROR (IngestionOrchestrator): Failed to build Reading model for UserID %s (Message-ID: %s): %v", userID, messageIDFromMIME, err)
-		return fmt.Errorf("failed to build reading model: %w", err)
+		return nil, false, fmt.Errorf("failed to build reading model: %w", err)
+	}
+	if finalFormatForReading == models.ReadingFormatHTML {
+		reading.Images = referencedImages(string(finalContentToStore), inlineImages)
+		if processedHTMLDataForBuilder != nil {
+			reading.Images = append(reading.Images, processedHTMLDataForBuilder.Images...)
+		}
+	}
+	// Documents name their own authors (e.g., an EPUB's creators), unlike email bodies.
+	if isAttachment && processedHTMLDataForBuilder != nil && processedHTMLDataForBuilder.ExtractedByline != "" {
+		reading.Author = processedHTMLDataForBuilder.ExtractedByline
+	}
+	// Attachments converted to HTML (e.g., PDFs and EPUBs) stay downloadable as sent.
+	if isAttachment && finalFormatForReading != originalIdentifiedFormat {
+		reading.Original = &models.ReadingOriginal{
+			FileName:    originalFileName,
+			Format:      originalIdentifiedFormat,
+			ContentType: ContentTypeForFormat(originalIdentifiedFormat),
+			Data:        rawContentBytes,
+		}
 	}
 
-	// Process persistence (deduplication, storing, DB record creation)
-	err = io.processReadingPersistenceAndDeduplication(ctx, &reading, finalContentToStore, finalFormatForReading, userID, messageIDFromMIME)
+	return []emailReading{{reading: reading, content: finalContentToStore, format: finalFormatForReading}}, false, nil
+}
+
+// Archives an email as received. The archive is keyed on the email's content
+// hash, so a retried job or an email delivered twice reuses the first copy
+// instead of compressing and writing it again. Returns the archived email's
+// ID, or nil if there is nothing to archive or archiving failed, which is
+// logged but doesn't stop ingestion.
+func (io *IngestionOrchestrator) archiveRawEmail(ctx context.Context, userID, actualSenderEmail, webhookSubject, rawMIME, messageIDFromMIME string) *string {
+	if io.RawEmailRepo == nil || rawMIME == "" {
+		return nil
+	}
+	contentHash, err := webutil.GenerateHash(rawMIME)
+	if err != nil {
+		log.Printf("WARN (IngestionOrchestrator): Failed to hash raw email for UserID %s (Message-ID: %s): %v. Not archiving.", userID, messageIDFromMIME, err)
+		return nil
+	}
+
+	existingID, err := io.RawEmailRepo.GetRawEmailIDByContentHash(ctx, userID, contentHash)
 	if err != nil {
-		// Error is already logged by the helper method
-		return err // Propagate the error
+		log.Printf("WARN (IngestionOrchestrator): Failed to look up archived email for UserID %s (Message-ID: %s): %v", userID, messageIDFromMIME, err)
+		return nil
+	}
+	if existingID != "" {
+		log.Printf("INFO (IngestionOrchestrator): Raw email already archived as %s for UserID %s (Message-ID: %s)", existingID, userID, messageIDFromMIME)
+		return &existingID
+	}
+
+	rawEmail := models.RawEmail{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		CreatedAt:   time.Now().UTC(),
+		MessageID:   messageIDFromMIME,
+		SenderEmail: actualSenderEmail,
+		Subject:     webhookSubject,
+		ContentHash: contentHash,
+		RawMIME:     rawMIME,
+	}
+	if err := io.RawEmailRepo.CreateRawEmail(ctx, &rawEmail); err != nil {
+		log.Printf("WARN (IngestionOrchestrator): Failed to archive raw email for UserID %s (Message-ID: %s): %v", userID, messageIDFromMIME, err)
+		return nil
 	}
+	log.Printf("INFO (IngestionOrchestrator): Archived raw email %s (%d bytes) for UserID %s (Message-ID: %s)", rawEmail.ID, rawEmail.MIMESize, userID, messageIDFromMIME)
+	return &rawEmail.ID
+}
 
-	// Link User to Reading
+// Links a stored reading to the user who received it. Failure is logged but
+// not returned as a fatal error for the whole ingestion.
+func (io *IngestionOrchestrator) linkReadingToUser(ctx context.Context, userID, readingID, messageIDFromMIME string) {
 	receivedAt := time.Now().UTC()
-	if errLink := io.ReadingRepo.AddUserReading(ctx, userID, reading.ID, receivedAt); errLink != nil {
-		log.Printf("ERROR (IngestionOrchestrator): Failed to link Reading %s to User %s (Message-ID %s): %v", reading.ID, userID, messageIDFromMIME, errLink)
-		// This is not returned as a fatal error for the whole ingestion.
-	} else {
-		log.Printf("INFO (IngestionOrchestrator): Linked Reading %s to User %s (Message-ID %s)", reading.ID, userID, messageIDFromMIME)
+	if errLink := io.ReadingRepo.AddUserReading(ctx, userID, readingID, receivedAt); errLink != nil {
+		log.Printf("ERROR (IngestionOrchestrator): Failed to link Reading %s to User %s (Message-ID %s): %v", readingID, userID, messageIDFromMIME, errLink)
+		return
 	}
-	return nil
+	log.Printf("INFO (IngestionOrchestrator): Linked Reading %s to User %s (Message-ID %s)", readingID, userID, messageIDFromMIME)
+}
+
+// Splits an HTML email body according to the split settings of the user's
+// source for the sender. Returns nil when the sender has no source yet,
+// splitting is off, or the body doesn't contain several articles.
+func (io *IngestionOrchestrator) splitDigestFromSender(ctx context.Context, userID, actualSenderEmail string, htmlBytes []byte, messageIDFromMIME string) []DigestSegment {
+	if actualSenderEmail == "" {
+		return nil
+	}
+	source, err := io.SourceRepo.GetSourceByIdentifierAndType(ctx, userID, actualSenderEmail, "email")
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			log.Printf("WARN (IngestionOrchestrator): Failed to look up source for sender '%s' (Message-ID: %s): %v. Not splitting.", actualSenderEmail, messageIDFromMIME, err)
+		}
+		return nil
+	}
+	if source.SplitMode == models.SplitModeNone || source.SplitMode == "" {
+		return nil
+	}
+
+	segments, err := SplitDigest(htmlBytes, source.SplitMode, source.SplitSelector)
+	if err != nil {
+		log.Printf("WARN (IngestionOrchestrator): Failed to split digest from source %s (Message-ID: %s): %v. Ingesting as one reading.", source.ID, messageIDFromMIME, err)
+		return nil
+	}
+	if len(segments) == 0 {
+		log.Printf("INFO (IngestionOrchestrator): Source %s splits by %s, but no separate articles were found (Message-ID: %s). Ingesting as one reading.", source.ID, source.SplitMode, messageIDFromMIME)
+		return nil
+	}
+	log.Printf("INFO (IngestionOrchestrator): Split digest from source %s into %d articles by %s (Message-ID: %s)", source.ID, len(segments), source.SplitMode, messageIDFromMIME)
+	return segments
+}
+
+// Builds a reading for each article of a split digest. Articles that fail
+// are skipped; an error is returned only if none could be built.
+func (io *IngestionOrchestrator) buildDigestReadings(
+	ctx context.Context,
+	rb *ReadingBuilder,
+	userID, actualSenderEmail, webhookSubject string,
+	env *enmime.Envelope,
+	segments []DigestSegment,
+	inlineImages map[string]models.ReadingImage,
+	messageIDFromMIME string,
+) ([]emailReading, error) {
+	subject := env.GetHeader("Subject")
+	if subject == "" {
+		subject = webhookSubject
+	}
+
+	var built []emailReading
+	for i, segment := range segments {
+		content, format, processed, err := io.processEmailBody(ctx, segment.HTML, models.ReadingFormatHTML, userID, messageIDFromMIME)
+		if err != nil || format != models.ReadingFormatHTML || processed == nil {
+			log.Printf("WARN (IngestionOrchestrator): Skipping digest article %d of %d (Message-ID: %s): content could not be processed (err: %v)", i+1, len(segments), messageIDFromMIME, err)
+			continue
+		}
+
+		// The heading that introduced the article is a better title than
+		// whatever Readability guesses from a fragment.
+		switch {
+		case segment.Title != "":
+			processed.ExtractedTitle = segment.Title
+		case processed.ExtractedTitle == "" && subject != "":
+			processed.ExtractedTitle = fmt.Sprintf("%s (%d of %d)", subject, i+1, len(segments))
+		}
+
+		reading, err := rb.BuildFromHTML(ctx, userID, actualSenderEmail, webhookSubject, env, processed, messageIDFromMIME)
+		if err != nil {
+			log.Printf("WARN (IngestionOrchestrator): Skipping digest article %d of %d (Message-ID: %s): failed to build reading: %v", i+1, len(segments), messageIDFromMIME, err)
+			continue
+		}
+		reading.Images = referencedImages(string(content), inlineImages)
+		built = append(built, emailReading{reading: reading, content: content, format: format, part: i + 1})
+	}
+
+	if len(built) == 0 {
+		return nil, fmt.Errorf("none of the %d articles in the digest could be processed (Message-ID: %s)", len(segments), messageIDFromMIME)
+	}
+	return built, nil
+}
+
+// Parses a raw MIME message and runs it through ProcessInboundEmail.
+// Used for messages that enter ingestion outside the inbound webhook request,
+// such as held emails released by the user.
+func (io *IngestionOrchestrator) ProcessRawEmail(
+	ctx context.Context,
+	userID, actualSenderEmail, subject, rawMIME string,
+) error {
+	env, err := enmime.ReadEnvelope(strings.NewReader(rawMIME))
+	if err != nil {
+		return fmt.Errorf("failed to parse raw MIME for UserID %s: %w", userID, err)
+	}
+	messageIDFromMIME := env.GetHeader("Message-ID")
+	if subject == "" {
+		subject = env.GetHeader("Subject")
+	}
+	return io.ProcessInboundEmail(ctx, userID, actualSenderEmail, subject, env, messageIDFromMIME, rawMIME)
+}
+
+// Runs web content through the pipeline, builds and deduplicates the reading,
+// and links it to the user. The reading is attributed to sourceID, which must
+// be one of the user's sources.
+func (io *IngestionOrchestrator) ProcessWebContent(
+	ctx context.Context,
+	userID string,
+	sourceID string,
+	content WebContent,
+) (*models.Reading, error) {
+	if len(content.HTML) == 0 {
+		return nil, fmt.Errorf("web content from %s is empty", content.URL)
+	}
+
+	pipelineOutput, err := io.Pipeline.ProcessContent(ctx, ContentInput{
+		Bytes:            content.HTML,
+		OriginalFormat:   models.ReadingFormatHTML,
+		OriginalFileName: "web_content.html",
+		BaseURL:          content.URL,
+	})
+	if err != nil {
+		log.Printf("ERROR (IngestionOrchestrator): ContentPipelineService failed for web content %s: %v", content.URL, err)
+		return nil, fmt.Errorf("failed to process web content: %w", err)
+	}
+	if pipelineOutput.ProcessedData == nil {
+		return nil, fmt.Errorf("web content from %s did not yield processable HTML", content.URL)
+	}
+
+	reading, err := io.ReadingBuilder.BuildFromWebContent(userID, sourceID, content, pipelineOutput.ProcessedData)
+	if err != nil {
+		log.Printf("ERROR (IngestionOrchestrator): Failed to build Reading model for web content %s: %v", content.URL, err)
+		return nil, fmt.Errorf("failed to build reading model: %w", err)
+	}
+
+	err = io.processReadingPersistenceAndDeduplication(ctx, &reading, pipelineOutput.FinalContentBytes, pipelineOutput.FinalFormat, userID, content.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	io.linkReadingToUser(ctx, userID, reading.ID, content.URL)
+	return &reading, nil
 }
 
 // Examines the email envelope and determines the main content to process.
@@ -119,12 +408,28 @@ func (io *IngestionOrchestrator) identifyPrimaryContent(env *enmime.Envelope) (r
 	// If no HTML body, check plain text body
 	if env.Text != "" {
 		log.Printf("INFO (identifyPrimaryContent): No suitable attachments or HTML body. Using TEXT body as primary content. Size: %d bytes.", len(env.Text))
-		return []byte(env.Text), models.ReadingFormatTXT, "email_body.txt", false, nil
+		return []byte(textBody(env)), models.ReadingFormatTXT, "email_body.txt", false, nil
 	}
 
 	return nil, "", "", false, fmt.Errorf("no processable content (attachments, HTML body, or Text body) found in the email")
 }
 
+// Returns the plain text body, with the soft line breaks of format=flowed
+// text (RFC 3676) removed so that its paragraphs can be reflowed.
+func textBody(env *enmime.Envelope) string {
+	if env.Root == nil {
+		return env.Text
+	}
+	part := env.Root.BreadthMatchFirst(func(p *enmime.Part) bool {
+		return p.ContentType == "text/plain" && p.FileName == ""
+	})
+	if part == nil || !strings.EqualFold(part.ContentTypeParams["format"], "flowed") {
+		return env.Text
+	}
+	log.Printf("INFO (IngestionOrchestrator): Unwrapping format=flowed text body.")
+	return conversion.UnwrapFlowed(env.Text, strings.EqualFold(part.ContentTypeParams["delsp"], "yes"))
+}
+
 // Loops through attachments and selects the best one based on predefined priorities.
 func (io *IngestionOrchestrator) findPriorityAttachment(env *enmime.Envelope) (contentBytes []byte, format models.ReadingFormat, fileName string, found bool) {
 	priorityOrder := []PrioritizedFormat{
@@ -179,18 +484,16 @@ func (io *IngestionOrchestrator) checkAttachmentAgainstPriority(
 
 // Handles an identified attachment, attempting conversion and processing.
 func (io *IngestionOrchestrator) processAttachedFile(
-	attachmentBytes []byte, originalFormat models.ReadingFormat, originalFileName, userID, messageIDFromMIME string,
+	ctx context.Context, attachmentBytes []byte, originalFormat models.ReadingFormat, originalFileName, userID, messageIDFromMIME string,
 ) ([]byte, models.ReadingFormat, *ProcessedContent, error) {
 	log.Printf("INFO (processAttachedFile): Processing attachment: Name='%s', Format='%s', Size=%d bytes, UserID=%s, Message-ID=%s",
 		originalFileName, originalFormat, len(attachmentBytes), userID, messageIDFromMIME)
 
-	ctx := context.TODO() // Or pass appropriate context down
-
-	if IsDirectReadingFormat(originalFormat) { // Handles PDF, EPUB, MOBI
+	if IsDirectReadingFormat(originalFormat) { // Handles MOBI
 		return io.handleDirectFormatAttachment(attachmentBytes, originalFormat, originalFileName)
 	}
 
-	// For ALL other types (TXT, DOCX, RTF, MD, HTML, etc.), use the ContentPipelineService
+	// For ALL other types (PDF, EPUB, TXT, DOCX, RTF, MD, HTML, etc.), use the ContentPipelineService
 	contentIn := ContentInput{
 		Bytes:            attachmentBytes,
 		OriginalFormat:   originalFormat,
@@ -217,10 +520,9 @@ func (io *IngestionOrchestrator) handleDirectFormatAttachment(
 
 // Handles an email body, which could be HTML or plain text.
 func (io *IngestionOrchestrator) processEmailBody(
-	bodyBytes []byte, originalFormat models.ReadingFormat, userID, messageIDFromMIME string,
+	ctx context.Context, bodyBytes []byte, originalFormat models.ReadingFormat, userID, messageIDFromMIME string,
 ) ([]byte, models.ReadingFormat, *ProcessedContent, error) {
 	log.Printf("INFO (processEmailBody): Processing email body (Original Format: %s), UserID %s (Message-ID: %s)", originalFormat, userID, messageIDFromMIME)
-	ctx := context.TODO() // Or pass appropriate context down
 
 	contentIn := ContentInput{
 		Bytes:          bodyBytes,
@@ -250,7 +552,10 @@ func (io *IngestionOrchestrator) processReadingPersistenceAndDeduplication(
 	userID string,
 	messageIDFromMIME string,
 ) error {
-	existingReading, errDb := io.ReadingRepo.GetReadingByContentHash(ctx, reading.ContentHash)
+	// Deduplication is per user: another user's copy of the same content is
+	// never reused, so their sources and metadata can't leak into this one's.
+	reading.UserID = userID
+	existingReading, errDb := io.ReadingRepo.GetReadingByContentHash(ctx, userID, reading.ContentHash)
 	if errDb != nil {
 		log.Printf("ERROR (IngestionOrchestrator): Failed to check for existing reading by hash %s for UserID %s (Message-ID: %s): %v", reading.ContentHash, userID, messageIDFromMIME, errDb)
 		return fmt.Errorf("failed to check for duplicate content: %w", errDb)
@@ -259,6 +564,7 @@ func (io *IngestionOrchestrator) processReadingPersistenceAndDeduplication(
 	isNewReading := existingReading == nil
 
 	if isNewReading {
+		io.linkNearDuplicate(ctx, reading, messageIDFromMIME)
 		err := io.persistNewReading(ctx, reading, contentToStore, formatForStorage, userID, messageIDFromMIME)
 		if err != nil {
 			return err // Error is already logged by persistNewReading
@@ -286,9 +592,14 @@ func (io *IngestionOrchestrator) persistNewReading(
 ) error {
 	log.Printf("INFO (IngestionOrchestrator): Content hash %s not found. Processing as new reading. (UserID %s, Message-ID %s)", reading.ContentHash, userID, messageIDFromMIME)
 
-	// Store content body in the reading for DB persistence
-	reading.ContentBody = string(contentToStore)
-	reading.StoragePath = fmt.Sprintf("readings/%s/%s.%s", userID, reading.ID, string(formatForStorage))
+	if err := setReadingBody(reading, contentToStore, formatForStorage); err != nil {
+		log.Printf("ERROR (IngestionOrchestrator): Failed to hash content body for ReadingID %s, UserID %s (Message-ID: %s): %v", reading.ID, userID, messageIDFromMIME, err)
+		return err
+	}
+	if err := io.storeReadingFiles(ctx, reading, formatForStorage); err != nil {
+		log.Printf("ERROR (IngestionOrchestrator): Failed to store files for ReadingID %s, UserID %s (Message-ID: %s): %v", reading.ID, userID, messageIDFromMIME, err)
+		return err
+	}
 
 	if errDbCreate := io.ReadingRepo.CreateReading(ctx, reading); errDbCreate != nil {
 		log.Printf("ERROR (IngestionOrchestrator): Failed to create NEW Reading DB record for ReadingID %s, UserID %s (Message-ID: %s): %v", reading.ID, userID, messageIDFromMIME, errDbCreate)
@@ -298,3 +609,74 @@ func (io *IngestionOrchestrator) persistNewReading(
 	log.Printf("INFO (IngestionOrchestrator): Created NEW Reading DB record: ID=%s, Format=%s, UserID=%s (Message-ID %s)", reading.ID, reading.Format, userID, messageIDFromMIME)
 	return nil
 }
+
+// Stores content in the reading and sets the body hash and storage path that
+// go with it. The content is written to the blob store by storeReadingFiles.
+func setReadingBody(reading *models.Reading, content []byte, format models.ReadingFormat) error {
+	reading.ContentBody = string(content)
+	bodyHash, err := webutil.GenerateHash(reading.ContentBody)
+	if err != nil {
+		return fmt.Errorf("failed to hash content body: %w", err)
+	}
+	reading.BodyHash = bodyHash
+	reading.StoragePath = storage.ReadingKey(reading.UserID, reading.ID, format)
+	return nil
+}
+
+// Writes a reading's content, in the given format, to the blob store at its
+// StoragePath, along with its inline images and the original it was converted
+// from, and sets their storage keys. The database only records the keys.
+func (io *IngestionOrchestrator) storeReadingFiles(ctx context.Context, reading *models.Reading, format models.ReadingFormat) error {
+	if err := io.Blobs.Put(ctx, reading.StoragePath, []byte(reading.ContentBody), ContentTypeForFormat(format)); err != nil {
+		return fmt.Errorf("failed to store reading content: %w", err)
+	}
+	for i := range reading.Images {
+		image := &reading.Images[i]
+		image.StorageKey = storage.ReadingImageKey(reading.UserID, reading.ID, image.ContentHash)
+		if err := io.Blobs.Put(ctx, image.StorageKey, image.Data, image.ContentType); err != nil {
+			return fmt.Errorf("failed to store reading image %s: %w", image.ContentHash, err)
+		}
+	}
+	if original := reading.Original; original != nil {
+		original.StorageKey = storage.ReadingOriginalKey(reading.UserID, reading.ID, original.Format)
+		if err := io.Blobs.Put(ctx, original.StorageKey, original.Data, original.ContentType); err != nil {
+			return fmt.Errorf("failed to store reading original: %w", err)
+		}
+	}
+	return nil
+}
+
+// Links a new reading to the most similar recent reading whose fingerprint is
+// at least NearDuplicateThreshold alike. Chains are avoided by linking to the
+// earlier reading's own original when it is itself a duplicate. Failures are
+// logged and the reading is stored unlinked.
+func (io *IngestionOrchestrator) linkNearDuplicate(ctx context.Context, reading *models.Reading, messageIDFromMIME string) {
+	if reading.SimHash == nil || io.NearDuplicateThreshold <= 0 {
+		return
+	}
+
+	candidates, err := io.ReadingRepo.GetFingerprintsSince(ctx, reading.UserID, time.Now().UTC().Add(-nearDuplicateWindow))
+	if err != nil {
+		log.Printf("WARN (IngestionOrchestrator): Failed to fetch fingerprints for near-duplicate check of Reading %s (Message-ID %s): %v", reading.ID, messageIDFromMIME, err)
+		return
+	}
+
+	var match *datastore.ReadingFingerprint
+	bestSimilarity := 0.0
+	for i := range candidates {
+		similarity := SimHashSimilarity(*reading.SimHash, candidates[i].SimHash)
+		if similarity >= io.NearDuplicateThreshold && similarity > bestSimilarity {
+			match, bestSimilarity = &candidates[i], similarity
+		}
+	}
+	if match == nil {
+		return
+	}
+
+	originalID := match.ID
+	if match.DuplicateOf != nil {
+		originalID = *match.DuplicateOf
+	}
+	reading.DuplicateOf = &originalID
+	log.Printf("INFO (IngestionOrchestrator): Reading %s is a near-duplicate of Reading %s (similarity %.2f, Message-ID %s)", reading.ID, originalID, bestSimilarity, messageIDFromMIME)
+}
@@ -15,6 +15,7 @@ type ContentInput struct {
 	Bytes            []byte
 	OriginalFormat   models.ReadingFormat
 	OriginalFileName string // Optional: for context, e.g., base URL for HTML processing
+	BaseURL          string // Optional: real URL the content was fetched from; takes precedence over OriginalFileName for resolving links
 	// Optional context for logging within pipeline, if needed in future
 	// UserID           string
 	// MessageIDFromMIME string
@@ -22,7 +23,7 @@ type ContentInput struct {
 
 // Holds the result of processing through the ContentPipelineService.
 type PipelineOutput struct {
-	FinalContentBytes []byte               // The actual bytes to be stored (e.g., cleaned HTML or original PDF)
+	FinalContentBytes []byte               // The actual bytes to be stored (e.g., cleaned HTML or original MOBI)
 	FinalFormat       models.ReadingFormat // The format of FinalContentBytes
 	ProcessedData     *ProcessedContent    // Result from ContentProcessor if HTML was processed; nil otherwise.
 }
@@ -49,6 +50,7 @@ func (ps *ContentPipelineService) processHTMLWithContentProcessor(
 	htmlBytes []byte,
 	originalFileNameForBaseURL string, // Used to create a placeholder base URL for ContentProcessor
 	originalFormatHint models.ReadingFormat, // For logging context
+	baseURL string, // Optional: real source URL, used instead of the placeholder when set
 ) (processedHTMLContentBytes []byte, processedData *ProcessedContent, err error) { // err for unexpected errors
 	if len(htmlBytes) == 0 {
 		log.Printf("WARN (ContentPipelineService.processHTML): Input HTML bytes are empty for %s. Skipping ContentProcessor.", originalFileNameForBaseURL)
@@ -64,6 +66,11 @@ func (ps *ContentPipelineService) processHTMLWithContentProcessor(
 		// For now, assume it's for attachment-originated HTML.
 		placeholderBaseURL, _ = url.Parse("file://" + filepath.ToSlash(originalFileNameForBaseURL))
 	}
+	if baseURL != "" {
+		if parsedBaseURL, parseErr := url.Parse(baseURL); parseErr == nil {
+			placeholderBaseURL = parsedBaseURL
+		}
+	}
 
 	extractedData, procErr := ps.ContentProcessor.Process(string(htmlBytes), placeholderBaseURL)
 
@@ -87,6 +94,66 @@ func (ps *ContentPipelineService) processHTMLWithContentProcessor(
 	return []byte(extractedData.MainHTML), extractedData, nil
 }
 
+// processPDF extracts reflowable HTML, text and images from a PDF. The PDF's
+// own layout already separates the body from everything else, so the result
+// is not run through ContentProcessor. If extraction fails (e.g., an encrypted
+// or scanned document), the original PDF is returned unchanged.
+func (ps *ContentPipelineService) processPDF(input ContentInput) PipelineOutput {
+	log.Printf("INFO (ContentPipelineService): Extracting text from PDF '%s'.", input.OriginalFileName)
+	content, err := conversion.ExtractPDF(input.Bytes)
+	if err != nil {
+		log.Printf("WARN (ContentPipelineService): PDF extraction failed for '%s': %v. Keeping the original PDF.", input.OriginalFileName, err)
+		return PipelineOutput{
+			FinalContentBytes: input.Bytes,
+			FinalFormat:       models.ReadingFormatPDF,
+			ProcessedData:     nil,
+		}
+	}
+
+	log.Printf("INFO (ContentPipelineService): Extracted PDF '%s'. Title: '%s', Images: %d.", input.OriginalFileName, content.Title, len(content.Images))
+	return PipelineOutput{
+		FinalContentBytes: content.HTML,
+		FinalFormat:       models.ReadingFormatHTML,
+		ProcessedData: &ProcessedContent{
+			MainHTML:       string(content.HTML),
+			MainText:       content.Text,
+			ExtractedTitle: content.Title,
+			Images:         content.Images,
+		},
+	}
+}
+
+// processEPUB extracts an EPUB's chapters as one HTML reading, keeping chapter
+// boundaries as sections. The chapters are sanitized but, like PDFs, not run
+// through Readability, which would keep only one of them. If extraction
+// fails (e.g., a DRM-protected book), the original EPUB is returned unchanged.
+func (ps *ContentPipelineService) processEPUB(input ContentInput) PipelineOutput {
+	log.Printf("INFO (ContentPipelineService): Extracting chapters from EPUB '%s'.", input.OriginalFileName)
+	content, err := conversion.ExtractEPUB(input.Bytes)
+	if err != nil {
+		log.Printf("WARN (ContentPipelineService): EPUB extraction failed for '%s': %v. Keeping the original EPUB.", input.OriginalFileName, err)
+		return PipelineOutput{
+			FinalContentBytes: input.Bytes,
+			FinalFormat:       models.ReadingFormatEPUB,
+			ProcessedData:     nil,
+		}
+	}
+
+	mainHTML := ps.ContentProcessor.Sanitize(string(content.HTML))
+	log.Printf("INFO (ContentPipelineService): Extracted EPUB '%s'. Title: '%s', Chapters: %d, Images: %d.", input.OriginalFileName, content.Title, len(content.Chapters), len(content.Images))
+	return PipelineOutput{
+		FinalContentBytes: []byte(mainHTML),
+		FinalFormat:       models.ReadingFormatHTML,
+		ProcessedData: &ProcessedContent{
+			MainHTML:        mainHTML,
+			MainText:        content.Text,
+			ExtractedTitle:  content.Title,
+			ExtractedByline: content.Author,
+			Images:          content.Images,
+		},
+	}
+}
+
 // ProcessContent takes raw content bytes and its original format,
 // attempts conversion to HTML if applicable, and then processes
 // the HTML to extract the main article content.
@@ -94,6 +161,13 @@ func (ps *ContentPipelineService) ProcessContent(
 	ctx context.Context,
 	input ContentInput,
 ) (PipelineOutput, error) {
+	switch input.OriginalFormat {
+	case models.ReadingFormatPDF:
+		return ps.processPDF(input), nil
+	case models.ReadingFormatEPUB:
+		return ps.processEPUB(input), nil
+	}
+
 	needsHTMLConversion := false
 	attemptConverter := false
 
@@ -107,10 +181,11 @@ func (ps *ContentPipelineService) ProcessContent(
 
 	htmlBytesToProcess := input.Bytes
 	currentFormat := input.OriginalFormat
+	var convertedImages []models.ReadingImage // Images extracted by the converter, e.g. from a DOCX
 
 	if attemptConverter {
 		log.Printf("INFO (ContentPipelineService): Format %s attempting conversion via Converter for '%s'.", input.OriginalFormat, input.OriginalFileName)
-		convertedBytes, newFmt, convErr := ps.Converter.ToHTML(input.Bytes, input.OriginalFormat)
+		convertedBytes, images, newFmt, convErr := ps.Converter.ToHTMLWithImages(ctx, input.Bytes, input.OriginalFormat)
 
 		if convErr != nil {
 			log.Printf("WARN (ContentPipelineService): Converter.ToHTML failed for '%s' (OriginalFormat: %s): %v. Proceeding with original content.",
@@ -119,7 +194,8 @@ func (ps *ContentPipelineService) ProcessContent(
 		} else {
 			// Conversion succeeded (or was a pass-through), update current state
 			htmlBytesToProcess = convertedBytes
-			currentFormat = newFmt // This could be HTML (for DOCX, MD, RTF, TXT->pre) or original (if PDF pass-through in ToHTML)
+			convertedImages = images
+			currentFormat = newFmt // This could be HTML (for DOCX, MD, RTF, TXT) or original (if PDF pass-through in ToHTML)
 			if newFmt == input.OriginalFormat && string(convertedBytes) == string(input.Bytes) {
 				log.Printf("INFO (ContentPipelineService): Converter.ToHTML resulted in no change for '%s' (Format: %s).", input.OriginalFileName, input.OriginalFormat)
 			} else {
@@ -137,7 +213,7 @@ func (ps *ContentPipelineService) ProcessContent(
 			// It was already HTML, so originalFormatHintForLog is also correct.
 		}
 
-		processedHTMLBytes, procData, procErr := ps.processHTMLWithContentProcessor(htmlBytesToProcess, input.OriginalFileName, originalFormatHintForLog)
+		processedHTMLBytes, procData, procErr := ps.processHTMLWithContentProcessor(htmlBytesToProcess, input.OriginalFileName, originalFormatHintForLog, input.BaseURL)
 		if procErr != nil {
 			// processHTMLWithContentProcessor handles its internal fallbacks and logs.
 			// An error here would be for unexpected issues not handled by fallbacks.
@@ -148,6 +224,14 @@ func (ps *ContentPipelineService) ProcessContent(
 				ProcessedData:     procData, // Might be partially filled from fallback in helper
 			}, procErr // Propagate the unexpected error
 		}
+		if procData != nil && len(convertedImages) > 0 {
+			// Keep only the images that survived content extraction
+			imagesByHash := make(map[string]models.ReadingImage, len(convertedImages))
+			for _, image := range convertedImages {
+				imagesByHash[image.ContentHash] = image
+			}
+			procData.Images = referencedImages(string(processedHTMLBytes), imagesByHash)
+		}
 		return PipelineOutput{
 			FinalContentBytes: processedHTMLBytes,
 			FinalFormat:       models.ReadingFormatHTML,
@@ -156,7 +240,7 @@ func (ps *ContentPipelineService) ProcessContent(
 	}
 
 	// If not HTML (either originally or after conversion attempts), return the content as is.
-	// This path is taken for PDF, EPUB, MOBI directly, or for TXT/DOCX/MD/RTF if their conversion
+	// This path is taken for MOBI directly, or for TXT/DOCX/MD/RTF if their conversion
 	// via ps.Converter.ToHTML failed or didn't result in HTML.
 	log.Printf("INFO (ContentPipelineService): Content '%s' (Format: %s) is not HTML or did not convert to HTML. Returning as is.", input.OriginalFileName, currentFormat)
 	return PipelineOutput{
@@ -18,6 +18,9 @@ import (
 // Responsible for constructing a models.Reading object.
 type ReadingBuilder struct {
 	sourceRepo *datastore.SourceRepository
+	// Only look up senders' sources, leaving a reading's SourceID as the nil
+	// UUID instead of creating a missing source.
+	lookupSourcesOnly bool
 }
 
 // Creates a new ReadingBuilder.
@@ -25,9 +28,18 @@ func NewReadingBuilder(sourceRepo *datastore.SourceRepository) *ReadingBuilder {
 	return &ReadingBuilder{sourceRepo: sourceRepo}
 }
 
+// Returns a copy of the builder that never creates sources, for rebuilding
+// readings whose source is already known.
+func (rb *ReadingBuilder) withoutSourceCreation() *ReadingBuilder {
+	lookupOnly := *rb
+	lookupOnly.lookupSourcesOnly = true
+	return &lookupOnly
+}
+
 // Constructs a models.Reading from processed HTML content (e.g., from an email body).
 func (rb *ReadingBuilder) BuildFromHTML(
 	ctx context.Context,
+	userID string, // Recipient; the reading and the sender's source belong to them
 	actualSenderEmail string,
 	webhookSubject string,
 	env *enmime.Envelope, // Parsed MIME message, for headers like From, Date, Subject (fallback)
@@ -41,13 +53,13 @@ func (rb *ReadingBuilder) BuildFromHTML(
 		return reading, fmt.Errorf("processedContent cannot be nil for BuildFromHTML")
 	}
 
-	contentHash, err := webutil.GenerateHash(processedContent.MainHTML)
+	contentHash, err := contentHashForHTML(processedContent.MainHTML)
 	if err != nil {
 		log.Printf("ERROR (ReadingBuilder - HTML): Failed to hash content for Message-ID '%s': %v", messageIDFromMIME, err)
 		return reading, fmt.Errorf("failed to generate content hash: %w", err)
 	}
 
-	readingSourceID, _ := rb.determineSourceIDFromSenderEmail(ctx, actualSenderEmail, messageIDFromMIME)
+	readingSourceID, _ := rb.determineSourceIDFromSenderEmail(ctx, userID, actualSenderEmail, messageIDFromMIME)
 
 	readingTitle := processedContent.ExtractedTitle
 	if readingTitle == "" { // Fallback to email subject if Readability didn't find a title
@@ -67,6 +79,7 @@ func (rb *ReadingBuilder) BuildFromHTML(
 
 	reading = models.Reading{
 		ID:          readingID,
+		UserID:      userID,
 		SourceID:    readingSourceID,
 		Author:      extractAuthorFromEnv(env),
 		CreatedAt:   time.Now().UTC(),
@@ -75,6 +88,7 @@ func (rb *ReadingBuilder) BuildFromHTML(
 		PublishedAt: extractPublishedDateFromEnv(env),
 		Title:       readingTitle,
 		Format:      models.ReadingFormatHTML, // Use prefixed constant
+		SimHash:     simHashText(processedContent.MainText),
 		// StoragePath will be set by the caller after successful storage.
 	}
 	return reading, nil
@@ -83,6 +97,7 @@ func (rb *ReadingBuilder) BuildFromHTML(
 // Constructs a models.Reading from a direct file attachment.
 func (rb *ReadingBuilder) BuildFromFile(
 	ctx context.Context,
+	userID string, // Recipient; the reading and the sender's source belong to them
 	actualSenderEmail string,
 	webhookSubject string,
 	env *enmime.Envelope, // Parsed MIME message, for headers like From, Date, Subject. Can be nil if not from email.
@@ -104,7 +119,7 @@ func (rb *ReadingBuilder) BuildFromFile(
 		return reading, fmt.Errorf("failed to generate content hash for file: %w", err)
 	}
 
-	readingSourceID, _ := rb.determineSourceIDFromSenderEmail(ctx, actualSenderEmail, messageIDFromMIME)
+	readingSourceID, _ := rb.determineSourceIDFromSenderEmail(ctx, userID, actualSenderEmail, messageIDFromMIME)
 
 	readingTitle := strings.TrimSuffix(originalFileName, filepath.Ext(originalFileName))
 	if readingTitle == "" { // Fallback to email subject if filename is weird or empty
@@ -145,6 +160,7 @@ func (rb *ReadingBuilder) BuildFromFile(
 
 	reading = models.Reading{
 		ID:          readingID,
+		UserID:      userID,
 		SourceID:    readingSourceID,
 		Author:      extractAuthorFromEnv(env),
 		CreatedAt:   time.Now().UTC(),
@@ -158,8 +174,66 @@ func (rb *ReadingBuilder) BuildFromFile(
 	return reading, nil
 }
 
-// Looks up a reading source by the sender's email.
-func (rb *ReadingBuilder) determineSourceIDFromSenderEmail(ctx context.Context, senderEmail string, messageIDFromMIME string) (string, error) {
+// Constructs a models.Reading from processed HTML fetched from the web (e.g., a feed item).
+// The source is already known, so no sender lookup is performed.
+func (rb *ReadingBuilder) BuildFromWebContent(
+	userID string,
+	sourceID string,
+	content WebContent,
+	processedContent *ProcessedContent, // Output from ContentProcessor
+) (models.Reading, error) {
+
+	var reading models.Reading
+
+	if processedContent == nil {
+		return reading, fmt.Errorf("processedContent cannot be nil for BuildFromWebContent")
+	}
+
+	contentHash, err := contentHashForHTML(processedContent.MainHTML)
+	if err != nil {
+		log.Printf("ERROR (ReadingBuilder - Web): Failed to hash content for URL '%s': %v", content.URL, err)
+		return reading, fmt.Errorf("failed to generate content hash: %w", err)
+	}
+
+	readingTitle := strings.TrimSpace(content.Title)
+	if readingTitle == "" {
+		readingTitle = processedContent.ExtractedTitle
+	}
+	if readingTitle == "" {
+		readingTitle = content.URL
+	}
+	if readingTitle == "" {
+		log.Printf("WARN (ReadingBuilder - Web): Web content has no discernible title. Defaulting title.")
+		readingTitle = "Untitled Reading"
+	}
+
+	author := strings.TrimSpace(content.Author)
+	if author == "" {
+		author = strings.TrimSpace(processedContent.ExtractedByline)
+	}
+
+	reading = models.Reading{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		SourceID:    sourceID,
+		Author:      author,
+		CreatedAt:   time.Now().UTC(),
+		ContentHash: contentHash,
+		Excerpt:     generateExcerptFromText(processedContent.MainText),
+		PublishedAt: content.PublishedAt,
+		Title:       readingTitle,
+		Format:      models.ReadingFormatHTML,
+		SimHash:     simHashText(processedContent.MainText),
+	}
+	if reading.Excerpt == "" {
+		reading.Excerpt = readingTitle
+	}
+	return reading, nil
+}
+
+// Looks up the user's reading source for the sender's email, creating it if
+// needed unless the builder only looks sources up.
+func (rb *ReadingBuilder) determineSourceIDFromSenderEmail(ctx context.Context, userID, senderEmail string, messageIDFromMIME string) (string, error) {
 	if rb.sourceRepo == nil {
 		log.Printf("WARN (ReadingBuilder): SourceRepository not available. Cannot determine source ID for sender '%s' (Message-ID: '%s')", senderEmail, messageIDFromMIME)
 		return uuid.Nil.String(), fmt.Errorf("sourcerepository not initialized")
@@ -168,12 +242,16 @@ func (rb *ReadingBuilder) determineSourceIDFromSenderEmail(ctx context.Context,
 		return uuid.Nil.String(), nil
 	}
 
-	source, err := rb.sourceRepo.GetSourceByIdentifierAndType(ctx, senderEmail, "email")
+	source, err := rb.sourceRepo.GetSourceByIdentifierAndType(ctx, userID, senderEmail, "email")
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
-			log.Printf("INFO (ReadingBuilder): No 'email' type ReadingSource found for sender '%s'. Auto-creating. (Message-ID: '%s')", senderEmail, messageIDFromMIME)
+			if rb.lookupSourcesOnly {
+				return uuid.Nil.String(), nil
+			}
+			log.Printf("INFO (ReadingBuilder): No 'email' type ReadingSource found for sender '%s' and UserID %s. Auto-creating. (Message-ID: '%s')", senderEmail, userID, messageIDFromMIME)
 			newSource := models.ReadingSource{
 				ID:         uuid.NewString(),
+				UserID:     userID,
 				CreatedAt:  time.Now().UTC(),
 				Name:       senderEmail,
 				Type:       "email",
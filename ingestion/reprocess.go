@@ -0,0 +1,268 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
+	"github.com/jhillyerd/enmime"
+)
+
+// ReprocessStatus is the outcome of reprocessing one reading.
+type ReprocessStatus string
+
+const (
+	ReprocessStatusUpdated   ReprocessStatus = "updated"   // Rebuilt and saved
+	ReprocessStatusChanged   ReprocessStatus = "changed"   // Dry run; rebuilding would change the reading
+	ReprocessStatusUnchanged ReprocessStatus = "unchanged" // Rebuilding yields the stored reading
+	ReprocessStatusSkipped   ReprocessStatus = "skipped"   // Can't be rebuilt; see Reason
+	ReprocessStatusFailed    ReprocessStatus = "failed"    // Rebuilding or saving failed; see Reason
+)
+
+// FieldChange is a reading field whose value differs after reprocessing.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// ReprocessResult describes what reprocessing did, or in a dry run would do,
+// to one reading.
+type ReprocessResult struct {
+	ReadingID string          `json:"reading_id"`
+	Title     string          `json:"title"`
+	Status    ReprocessStatus `json:"status"`
+	Reason    string          `json:"reason,omitempty"`
+	Changes   []FieldChange   `json:"changes,omitempty"`
+	BodyDiff  string          `json:"body_diff,omitempty"` // Unified diff of the stored and rebuilt body
+}
+
+// ReprocessReport lists the outcome for each selected reading.
+type ReprocessReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Results []ReprocessResult `json:"results"`
+}
+
+// Counts returns how many readings ended with each status.
+func (r *ReprocessReport) Counts() map[ReprocessStatus]int {
+	counts := make(map[ReprocessStatus]int)
+	for _, result := range r.Results {
+		counts[result.Status]++
+	}
+	return counts
+}
+
+// Readings rebuilt from one archived email, keyed by the ID of the stored
+// reading each one replaces.
+type rebuiltEmail struct {
+	readings map[string]emailReading
+	err      error
+}
+
+// ReprocessReadings rebuilds the selected readings from their archived
+// emails with the current ContentPipelineService and ReadingBuilder, and
+// saves any that changed. With dryRun, nothing is saved and the report shows
+// what would change. Readings that weren't built from an archived email are
+// skipped.
+func (io *IngestionOrchestrator) ReprocessReadings(ctx context.Context, selection datastore.ReadingSelection, dryRun bool) (*ReprocessReport, error) {
+	if io.RawEmailRepo == nil {
+		return nil, errors.New("raw email archive is not configured")
+	}
+	readings, err := io.ReadingRepo.GetSelectedReadings(ctx, selection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select readings to reprocess: %w", err)
+	}
+
+	report := &ReprocessReport{DryRun: dryRun, Results: []ReprocessResult{}}
+	rebuilt := make(map[string]*rebuiltEmail)
+	for _, stored := range readings {
+		result := ReprocessResult{ReadingID: stored.ID, Title: stored.Title}
+		if stored.RawEmailID == nil {
+			result.Status = ReprocessStatusSkipped
+			result.Reason = "reading was not built from an archived email"
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		email, ok := rebuilt[*stored.RawEmailID]
+		if !ok {
+			email = io.rebuildRawEmail(ctx, *stored.RawEmailID)
+			rebuilt[*stored.RawEmailID] = email
+		}
+		if email.err != nil {
+			result.Status = ReprocessStatusFailed
+			result.Reason = email.err.Error()
+			report.Results = append(report.Results, result)
+			continue
+		}
+		candidate, ok := email.readings[stored.ID]
+		if !ok {
+			result.Status = ReprocessStatusSkipped
+			result.Reason = "reading no longer matches an article of its email"
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		report.Results = append(report.Results, io.reprocessReading(ctx, stored, candidate, dryRun))
+	}
+
+	counts := report.Counts()
+	log.Printf("INFO (IngestionOrchestrator): Reprocessed %d readings (dry run: %t): %d updated, %d changed, %d unchanged, %d skipped, %d failed",
+		len(report.Results), dryRun, counts[ReprocessStatusUpdated], counts[ReprocessStatusChanged], counts[ReprocessStatusUnchanged], counts[ReprocessStatusSkipped], counts[ReprocessStatusFailed])
+	return report, nil
+}
+
+// Rebuilds the readings of an archived email and pairs them with the stored
+// readings it produced. Nothing is stored; the rebuilt readings keep the
+// stored readings' sources, so none are created for the sender.
+func (io *IngestionOrchestrator) rebuildRawEmail(ctx context.Context, rawEmailID string) *rebuiltEmail {
+	rawEmail, err := io.RawEmailRepo.GetRawEmailByID(ctx, rawEmailID)
+	if err != nil {
+		return &rebuiltEmail{err: fmt.Errorf("failed to load archived email: %w", err)}
+	}
+	env, err := enmime.ReadEnvelope(strings.NewReader(rawEmail.RawMIME))
+	if err != nil {
+		return &rebuiltEmail{err: fmt.Errorf("failed to parse archived email: %w", err)}
+	}
+
+	built, isDigest, err := io.buildEmailReadings(ctx, io.ReadingBuilder.withoutSourceCreation(), rawEmail.UserID, rawEmail.SenderEmail, rawEmail.Subject, env, rawEmail.MessageID)
+	if err != nil {
+		return &rebuiltEmail{err: err}
+	}
+	stored, err := io.ReadingRepo.GetReadingsByRawEmailID(ctx, rawEmailID)
+	if err != nil {
+		return &rebuiltEmail{err: fmt.Errorf("failed to load readings of archived email: %w", err)}
+	}
+
+	email := &rebuiltEmail{readings: make(map[string]emailReading, len(stored))}
+	for _, reading := range stored {
+		if candidate, ok := pairRebuiltReading(reading, built, isDigest, len(stored)); ok {
+			email.readings[reading.ID] = candidate
+		}
+	}
+	return email
+}
+
+// Finds the rebuilt version of a stored reading: the one built from the same
+// part of the email. Articles of a digest that were skipped or deduplicated
+// at ingestion have no stored reading, so readings can't be paired by order.
+// Readings archived before parts were recorded are paired by content, or, if
+// the email still yields one reading and only one was stored, with it.
+func pairRebuiltReading(stored models.Reading, built []emailReading, isDigest bool, storedCount int) (emailReading, bool) {
+	if stored.RawEmailPart != nil {
+		for _, candidate := range built {
+			if candidate.part == *stored.RawEmailPart {
+				return candidate, true
+			}
+		}
+		return emailReading{}, false
+	}
+	if !isDigest && storedCount == 1 {
+		return built[0], true
+	}
+	for _, candidate := range built {
+		if candidate.reading.ContentHash == stored.ContentHash {
+			return candidate, true
+		}
+	}
+	return emailReading{}, false
+}
+
+// Compares a stored reading with its rebuilt version and, unless dryRun,
+// saves the rebuilt content in its place.
+func (io *IngestionOrchestrator) reprocessReading(ctx context.Context, stored models.Reading, rebuilt emailReading, dryRun bool) ReprocessResult {
+	result := ReprocessResult{ReadingID: stored.ID, Title: stored.Title}
+
+	// The rebuilt reading takes the place of the stored one, keeping its
+	// identity, source and dates.
+	reading := rebuilt.reading
+	reading.ID = stored.ID
+	reading.UserID = stored.UserID
+	reading.SourceID = stored.SourceID
+	reading.CreatedAt = stored.CreatedAt
+	reading.PublishedAt = stored.PublishedAt
+	reading.DuplicateOf = stored.DuplicateOf
+	reading.RawEmailID = stored.RawEmailID
+	reading.RawEmailPart = &rebuilt.part
+	if err := setReadingBody(&reading, rebuilt.content, rebuilt.format); err != nil {
+		result.Status = ReprocessStatusFailed
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.Changes = readingChanges(stored, reading)
+	if reading.BodyHash != stored.BodyHash {
+		if err := storage.LoadReadingBody(ctx, io.Blobs, &stored); err != nil {
+			result.Status = ReprocessStatusFailed
+			result.Reason = err.Error()
+			return result
+		}
+		result.BodyDiff = diffLines(stored.ContentBody, reading.ContentBody)
+	}
+	switch {
+	case len(result.Changes) == 0 && result.BodyDiff == "":
+		result.Status = ReprocessStatusUnchanged
+		return result
+	case dryRun:
+		result.Status = ReprocessStatusChanged
+		return result
+	}
+
+	if reading.ContentHash != stored.ContentHash {
+		existing, err := io.ReadingRepo.GetReadingByContentHash(ctx, reading.UserID, reading.ContentHash)
+		if err != nil {
+			result.Status = ReprocessStatusFailed
+			result.Reason = err.Error()
+			return result
+		}
+		if existing != nil && existing.ID != reading.ID {
+			result.Status = ReprocessStatusSkipped
+			result.Reason = fmt.Sprintf("rebuilt content duplicates reading %s", existing.ID)
+			return result
+		}
+	}
+
+	if err := io.storeReadingFiles(ctx, &reading, rebuilt.format); err != nil {
+		log.Printf("ERROR (IngestionOrchestrator): Failed to store files for reprocessed Reading %s: %v", reading.ID, err)
+		result.Status = ReprocessStatusFailed
+		result.Reason = err.Error()
+		return result
+	}
+	if err := io.ReadingRepo.ReplaceReadingContent(ctx, &reading); err != nil {
+		log.Printf("ERROR (IngestionOrchestrator): Failed to save reprocessed Reading %s: %v", reading.ID, err)
+		result.Status = ReprocessStatusFailed
+		result.Reason = err.Error()
+		return result
+	}
+	if stored.StoragePath != reading.StoragePath {
+		// The format changed, so the content now lives under a new key.
+		if err := io.Blobs.Delete(ctx, stored.StoragePath); err != nil {
+			log.Printf("WARN (IngestionOrchestrator): Failed to delete previous content %s of Reading %s: %v", stored.StoragePath, reading.ID, err)
+		}
+	}
+	log.Printf("INFO (IngestionOrchestrator): Reprocessed Reading %s (%d fields changed, body changed: %t)", reading.ID, len(result.Changes), result.BodyDiff != "")
+	result.Status = ReprocessStatusUpdated
+	return result
+}
+
+// Lists the metadata fields that differ between a stored reading and its
+// rebuilt version. The body is compared separately.
+func readingChanges(before, after models.Reading) []FieldChange {
+	var changes []FieldChange
+	compare := func(field, b, a string) {
+		if b != a {
+			changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+		}
+	}
+	compare("title", before.Title, after.Title)
+	compare("author", before.Author, after.Author)
+	compare("excerpt", before.Excerpt, after.Excerpt)
+	compare("format", string(before.Format), string(after.Format))
+	compare("content_hash", before.ContentHash, after.ContentHash)
+	return changes
+}
@@ -0,0 +1,111 @@
+package ingestion
+
+import (
+	"testing"
+
+	"github.com/coreybb/logos/models"
+)
+
+func TestPairRebuiltReading(t *testing.T) {
+	part := func(n int) *int { return &n }
+	article := func(n int, hash string) emailReading {
+		return emailReading{reading: models.Reading{Title: hash, ContentHash: hash}, part: n}
+	}
+	// Article 2 was skipped when the digest was rebuilt, and article 1 now
+	// has different content.
+	digest := []emailReading{article(1, "one-rebuilt"), article(3, "three")}
+
+	tests := []struct {
+		name        string
+		stored      models.Reading
+		built       []emailReading
+		isDigest    bool
+		storedCount int
+		want        string // Title of the paired rebuilt reading; empty if unpaired
+	}{
+		{
+			name:        "digest article by part",
+			stored:      models.Reading{ContentHash: "one", RawEmailPart: part(1)},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 2,
+			want:        "one-rebuilt",
+		},
+		{
+			name:        "digest article after a skipped one",
+			stored:      models.Reading{ContentHash: "three", RawEmailPart: part(3)},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 2,
+			want:        "three",
+		},
+		{
+			name:        "digest article no longer built",
+			stored:      models.Reading{ContentHash: "two", RawEmailPart: part(2)},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 2,
+		},
+		{
+			name:        "whole email is not paired with an article",
+			stored:      models.Reading{ContentHash: "whole", RawEmailPart: part(0)},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 1,
+		},
+		{
+			name:        "article is not paired with the whole email",
+			stored:      models.Reading{ContentHash: "one", RawEmailPart: part(1)},
+			built:       []emailReading{article(0, "whole")},
+			storedCount: 1,
+		},
+		{
+			name:        "whole email",
+			stored:      models.Reading{ContentHash: "whole", RawEmailPart: part(0)},
+			built:       []emailReading{article(0, "whole-rebuilt")},
+			storedCount: 1,
+			want:        "whole-rebuilt",
+		},
+		{
+			name:        "unrecorded part of a single reading",
+			stored:      models.Reading{ContentHash: "whole"},
+			built:       []emailReading{article(0, "whole-rebuilt")},
+			storedCount: 1,
+			want:        "whole-rebuilt",
+		},
+		{
+			name:        "unrecorded part paired by content",
+			stored:      models.Reading{ContentHash: "three"},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 2,
+			want:        "three",
+		},
+		{
+			name:        "unrecorded part with changed content",
+			stored:      models.Reading{ContentHash: "one"},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 2,
+		},
+		{
+			name:        "unrecorded part of one surviving digest article",
+			stored:      models.Reading{ContentHash: "one"},
+			built:       digest,
+			isDigest:    true,
+			storedCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pairRebuiltReading(tt.stored, tt.built, tt.isDigest, tt.storedCount)
+			if ok != (tt.want != "") {
+				t.Fatalf("pairRebuiltReading() paired = %t, want %t", ok, tt.want != "")
+			}
+			if got.reading.Title != tt.want {
+				t.Errorf("pairRebuiltReading() = %q, want %q", got.reading.Title, tt.want)
+			}
+		})
+	}
+}
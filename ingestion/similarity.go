@@ -0,0 +1,180 @@
+package ingestion
+
+import (
+	"bytes"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/coreybb/logos/webutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// Near-duplicates are readings whose SimHash fingerprints agree on at
+	// least this fraction of bits.
+	DefaultNearDuplicateThreshold = 0.9
+
+	// Texts shorter than this many words don't get a fingerprint; a handful
+	// of shingles says little about whether two texts are the same article.
+	minFingerprintWords = 40
+	shingleSize         = 3
+)
+
+// Query parameters that identify the recipient or the campaign rather than
+// the content. Per-recipient values would otherwise make every copy of an
+// article hash differently. Only names specific to a tracking service are
+// listed; generic ones such as "e", "r" or "token" also carry content
+// identifiers and link signatures.
+var trackingParams = map[string]bool{
+	// Ad and social click IDs
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "twclid": true,
+	// Mailchimp
+	"mc_cid": true, "mc_eid": true,
+	// HubSpot
+	"_hsenc": true, "_hsmi": true, "hsctatracking": true,
+	// Marketo, Kit (ConvertKit), Omeda, Vero and others
+	"mkt_tok": true, "ck_subscriber_id": true, "oly_anon_id": true, "oly_enc_id": true,
+	"vero_conv": true, "vero_id": true, "rb_clickid": true, "s_cid": true,
+}
+
+// Matches a personalised salutation at the start of a newsletter, e.g.
+// "Hi Ada," or "Good morning, Ada!".
+var greetingRegex = regexp.MustCompile(`(?i)^\s*(hi|hello|hey|dear|greetings|good (morning|afternoon|evening))\b[^.!?,:\n]{0,40}[,!:]`)
+
+// Generates the content hash for processed HTML. The HTML is normalized
+// first so copies of an article that differ only in tracking links, tracking
+// pixels or the greeting hash the same.
+func contentHashForHTML(mainHTML string) (string, error) {
+	return webutil.GenerateHash(normalizeHTMLForHash(mainHTML))
+}
+
+// Strips recipient-specific details from HTML. The result is only used for
+// hashing; stored content is left as received. Falls back to the input if it
+// can't be parsed.
+func normalizeHTMLForHash(rawHTML string) string {
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return rawHTML
+	}
+
+	var pixels []*html.Node
+	greetingChecked := false
+	walk(doc, func(n *html.Node) bool {
+		switch n.Type {
+		case html.ElementNode:
+			if n.DataAtom == atom.Img && isTrackingPixel(n) {
+				pixels = append(pixels, n)
+				return false
+			}
+			for i, attr := range n.Attr {
+				if attr.Key == "href" || attr.Key == "src" {
+					n.Attr[i].Val = stripTrackingParams(attr.Val)
+				}
+			}
+		case html.TextNode:
+			if !greetingChecked && strings.TrimSpace(n.Data) != "" {
+				greetingChecked = true
+				n.Data = greetingRegex.ReplaceAllString(n.Data, "")
+			}
+		}
+		return true
+	})
+	for _, pixel := range pixels {
+		pixel.Parent.RemoveChild(pixel)
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return rawHTML
+	}
+	return buf.String()
+}
+
+// Removes tracking query parameters (including all utm_* parameters) from a URL.
+func stripTrackingParams(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.RawQuery == "" {
+		return rawURL
+	}
+	query := parsed.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+			query.Del(key)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// Reports whether an <img> is a tracking pixel: at most 1x1, or hidden.
+func isTrackingPixel(n *html.Node) bool {
+	var width, height string
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "width":
+			width = attr.Val
+		case "height":
+			height = attr.Val
+		case "style":
+			style := strings.ReplaceAll(strings.ToLower(attr.Val), " ", "")
+			if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+				return true
+			}
+		}
+	}
+	return isTinyDimension(width) && isTinyDimension(height)
+}
+
+func isTinyDimension(v string) bool {
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "px"))
+	return err == nil && size <= 1
+}
+
+// Computes a 64-bit SimHash of text over overlapping word shingles, so texts
+// that share most of their wording get fingerprints that differ in few bits.
+// Returns nil for texts too short to fingerprint meaningfully.
+func simHashText(text string) *int64 {
+	text = greetingRegex.ReplaceAllString(text, "")
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < minFingerprintWords {
+		return nil
+	}
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	signed := int64(fingerprint) // Stored in a signed bigint column
+	return &signed
+}
+
+// SimHashSimilarity returns the fraction of bits two fingerprints agree on,
+// from 0 to 1.
+func SimHashSimilarity(a, b int64) float64 {
+	return 1 - float64(bits.OnesCount64(uint64(a^b)))/64
+}
@@ -0,0 +1,53 @@
+package ingestion
+
+import "testing"
+
+func TestStripTrackingParams(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "utm parameters",
+			url:  "https://example.com/post?id=7&utm_source=newsletter&UTM_Medium=email",
+			want: "https://example.com/post?id=7",
+		},
+		{
+			name: "known tracking names",
+			url:  "https://example.com/post?mc_cid=abc&mc_eid=def&fbclid=ghi&_hsenc=jkl",
+			want: "https://example.com/post",
+		},
+		{
+			name: "generic names are kept",
+			url:  "https://example.com/post?e=42&r=7&token=signed",
+			want: "https://example.com/post?e=42&r=7&token=signed",
+		},
+		{
+			name: "no query",
+			url:  "https://example.com/post",
+			want: "https://example.com/post",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripTrackingParams(tt.url); got != tt.want {
+				t.Errorf("stripTrackingParams(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimHashSimilarity(t *testing.T) {
+	const a int64 = 0x0f0f0f0f0f0f0f0f
+	if got := SimHashSimilarity(a, a); got != 1 {
+		t.Errorf("SimHashSimilarity(a, a) = %v, want 1", got)
+	}
+	if got := SimHashSimilarity(a, ^a); got != 0 {
+		t.Errorf("SimHashSimilarity(a, ^a) = %v, want 0", got)
+	}
+	if got := SimHashSimilarity(a, a^0xff); got != 1-8.0/64 {
+		t.Errorf("SimHashSimilarity with 8 differing bits = %v, want %v", got, 1-8.0/64)
+	}
+}
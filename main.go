@@ -9,17 +9,25 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/coreybb/logos/api"
+	"github.com/coreybb/logos/articles"
+	"github.com/coreybb/logos/conversion"
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/delivery"
 	"github.com/coreybb/logos/ebook"
+	"github.com/coreybb/logos/feeds"
+	"github.com/coreybb/logos/ingestion"
+	"github.com/coreybb/logos/oidc"
 	"github.com/coreybb/logos/processing"
 	rh "github.com/coreybb/logos/route-handlers"
 	"github.com/coreybb/logos/scheduler"
+	"github.com/coreybb/logos/storage"
 	"github.com/coreybb/logos/webhooks"
+	"github.com/coreybb/logos/webutil"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/lib/pq"
 )
@@ -29,6 +37,9 @@ const (
 	defaultDatabaseURL  = "user=postgres password=password dbname=logos host=localhost port=5432 sslmode=disable"
 	defaultSendGridFrom = "deliver@lakonic.dev"
 	defaultSendGridName = "Logos"
+	defaultFeedInterval = 30 * time.Minute
+	defaultPDFPageSize  = "A5"
+	defaultBlobStoreDir = "data/blobs"
 	dbPingTimeout       = 5 * time.Second
 	shutdownTimeout     = 15 * time.Second
 	dbMaxOpenConns      = 25
@@ -37,17 +48,48 @@ const (
 )
 
 type config struct {
-	port              string
-	databaseURL       string
-	sendGridAPIKey    string
-	sendGridFromEmail string
-	sendGridFromName  string
+	port                 string
+	databaseURL          string
+	sendGridAPIKey       string
+	sendGridFromEmail    string
+	sendGridFromName     string
+	feedPollInterval     time.Duration
+	pdfPageSize          ebook.PageSize
+	inboundSecret        string
+	inboundPublicKey     string
+	tickSecret           string
+	tickOIDCAudience     string
+	tickOIDCEmail        string
+	tickOIDCIssuer       string
+	tickOIDCJWKSURL      string
+	adminSecret          string
+	downloadLinkSecret   string
+	autoMigrate          bool
+	retryPolicy          delivery.RetryPolicy
+	nearDupThreshold     float64
+	conversionBackend    conversion.Backend
+	blobStore            storage.Config
+	ingestionWorkers     int
+	ingestionRetryPolicy ingestion.JobRetryPolicy
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reprocess" {
+		if err := runReprocessCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Reprocessing failed: %v", err)
+		}
+		return
+	}
+
 	cfg := loadConfig()
 
-	db, err := setupDatabase(cfg.databaseURL)
+	db, err := setupDatabase(cfg.databaseURL, cfg.autoMigrate)
 	if err != nil {
 		log.Fatalf("Database setup failed: %v", err)
 	}
@@ -62,37 +104,65 @@ func main() {
 	editionTemplateRepo := datastore.NewEditionTemplateRepository(db)
 	userReadingSourceRepo := datastore.NewUserReadingSourceRepository(db)
 	editionTemplateSourceRepo := datastore.NewEditionTemplateSourceRepository(db)
+	editionTemplateDestinationRepo := datastore.NewEditionTemplateDestinationRepository(db)
 	allowedSenderRepo := datastore.NewAllowedSenderRepository(db)
 	deliveryAttemptRepo := datastore.NewDeliveryAttemptRepository(db)
+	heldEmailRepo := datastore.NewHeldEmailRepository(db)
+	feedStateRepo := datastore.NewFeedStateRepository(db)
+	apiTokenRepo := datastore.NewAPITokenRepository(db)
+	ingestionJobRepo := datastore.NewIngestionJobRepository(db)
+	rawEmailRepo := datastore.NewRawEmailRepository(db)
+	editionFileRepo := datastore.NewEditionFileRepository(db)
+
+	blobs, err := storage.New(cfg.blobStore)
+	if err != nil {
+		log.Fatalf("Blob store setup failed: %v", err)
+	}
 
-	// Initialize ebook generator
-	editionGenerator := ebook.NewEditionGenerator()
-
-	// Initialize edition processor
+	// Initialize edition processor with a renderer per ebook format
 	editionProcessor := processing.NewEditionProcessor(
 		editionRepo,
 		readingRepo,
 		deliveryRepo,
 		editionTemplateRepo,
-		editionGenerator,
+		editionFileRepo,
+		blobs,
+		ebook.NewEPUBRenderer(),
+		ebook.NewPDFRenderer(cfg.pdfPageSize),
 	)
 
 	// Initialize delivery system
 	emailProvider := delivery.NewEmailDeliveryProvider(cfg.sendGridAPIKey, cfg.sendGridFromEmail, cfg.sendGridFromName)
-	deliveryService := delivery.NewDeliveryService(deliveryRepo, destinationRepo, deliveryAttemptRepo, emailProvider)
+	webhookProvider := delivery.NewWebhookDeliveryProvider()
+	deliveryService := delivery.NewDeliveryService(deliveryRepo, destinationRepo, deliveryAttemptRepo, blobs, emailProvider, webhookProvider)
+	deliveryRetrier := delivery.NewRetrier(deliveryService, deliveryRepo, cfg.retryPolicy)
 
 	userHandler := rh.NewUserHandler(userRepo)
-	editionHandler := rh.NewEditionHandler(editionRepo, editionProcessor, deliveryService)
-	readingHandler := rh.NewReadingHandler(readingRepo)
-	deliveryHandler := rh.NewDeliveryHandler(deliveryRepo)
+	editionHandler := rh.NewEditionHandler(editionRepo, editionTemplateRepo, editionTemplateDestinationRepo, readingRepo, destinationRepo, editionFileRepo, blobs, editionProcessor, deliveryService)
+	editionHandler.DownloadLinkSecret = []byte(cfg.downloadLinkSecret)
+	deliveryHandler := rh.NewDeliveryHandler(deliveryRepo, editionRepo, destinationRepo, deliveryService)
 	sourceHandler := rh.NewSourceHandler(sourceRepo)
 	destinationHandler := rh.NewDestinationHandler(destinationRepo)
 	editionTemplateHandler := rh.NewEditionTemplateHandler(editionTemplateRepo)
-	userReadingSourceHandler := rh.NewUserReadingSourceHandler(userReadingSourceRepo)
-	editionTemplateSourceHandler := rh.NewEditionTemplateSourceHandler(editionTemplateSourceRepo)
-	allowedSenderHandler := rh.NewAllowedSenderHandler(allowedSenderRepo)
-
-	inboundEmailHandler := webhooks.NewInboundEmailHandler(readingRepo, sourceRepo, allowedSenderRepo)
+	userReadingSourceHandler := rh.NewUserReadingSourceHandler(userReadingSourceRepo, sourceRepo)
+	editionTemplateSourceHandler := rh.NewEditionTemplateSourceHandler(editionTemplateSourceRepo, editionTemplateRepo, sourceRepo)
+	editionTemplateDestinationHandler := rh.NewEditionTemplateDestinationHandler(editionTemplateDestinationRepo, editionTemplateRepo, destinationRepo)
+	converter, err := conversion.NewConverter(cfg.conversionBackend)
+	if err != nil {
+		log.Fatalf("Converter setup failed: %v", err)
+	}
+	inboundEmailHandler := webhooks.NewInboundEmailHandler(readingRepo, sourceRepo, allowedSenderRepo, userRepo, heldEmailRepo, ingestionJobRepo, rawEmailRepo, blobs, converter, inboundVerifiers(cfg)...)
+	inboundEmailHandler.Orchestrator.NearDuplicateThreshold = cfg.nearDupThreshold
+	ingestionWorkers := ingestion.NewWorkerPool(ingestionJobRepo, inboundEmailHandler.ProcessJob, cfg.ingestionRetryPolicy, cfg.ingestionWorkers)
+	inboundEmailHandler.Workers = ingestionWorkers
+	allowedSenderHandler := rh.NewAllowedSenderHandler(allowedSenderRepo, userRepo, heldEmailRepo, inboundEmailHandler.Orchestrator)
+	apiTokenHandler := rh.NewAPITokenHandler(apiTokenRepo)
+	opdsHandler := rh.NewOPDSHandler(userRepo, editionRepo, editionTemplateRepo, editionFileRepo, readingRepo, blobs)
+	adminHandler := rh.NewAdminHandler(inboundEmailHandler.Orchestrator)
+
+	// Saved articles share the email ingestion pipeline
+	articleSaver := articles.NewSaver(sourceRepo, userReadingSourceRepo, inboundEmailHandler.Orchestrator, nil)
+	readingHandler := rh.NewReadingHandler(readingRepo, articleSaver, blobs)
 
 	apiRouter := api.SetupRoutes(
 		userHandler,
@@ -104,27 +174,54 @@ func main() {
 		editionTemplateHandler,
 		userReadingSourceHandler,
 		editionTemplateSourceHandler,
+		editionTemplateDestinationHandler,
 		allowedSenderHandler,
+		apiTokenHandler,
+		apiTokenRepo,
+	)
+
+	// Initialize feed poller, sharing the email ingestion pipeline
+	feedPoller := feeds.NewPoller(
+		sourceRepo,
+		feedStateRepo,
+		userReadingSourceRepo,
+		inboundEmailHandler.Orchestrator,
+		nil,
+		cfg.feedPollInterval,
 	)
 
 	// Initialize scheduler
 	editionScheduler := scheduler.New(
 		editionTemplateRepo,
 		editionTemplateSourceRepo,
+		editionTemplateDestinationRepo,
 		editionRepo,
 		readingRepo,
-		destinationRepo,
 		editionProcessor,
 		deliveryService,
+		feedPoller,
+		deliveryRetrier,
 	)
+	editionScheduler.NearDuplicateThreshold = cfg.nearDupThreshold
 
 	mainRouter := chi.NewRouter()
 	mainRouter.Mount("/", apiRouter)
 
 	mainRouter.Post("/webhooks/inbound-email", inboundEmailHandler.HandleInbound)
-	mainRouter.Post("/scheduler/tick", editionScheduler.HandleTick)
+	mainRouter.Get(rh.DownloadsPath+"/{id}", webutil.MakeHandler(editionHandler.HandleDownloadEditionFile))
+	mainRouter.Mount(rh.OPDSPath, api.SetupOPDSRoutes(opdsHandler))
+	tickAuth := scheduler.NewTickAuthenticator(cfg.tickSecret, tickOIDCVerifier(cfg))
+	mainRouter.With(tickAuth.Require).Post("/scheduler/tick", editionScheduler.HandleTick)
+	mainRouter.With(api.RequireAdminSecret(cfg.adminSecret)).Post("/admin/reprocess", webutil.MakeHandler(adminHandler.HandleReprocess))
 
+	ingestionWorkers.Start()
 	startServer(cfg.port, mainRouter)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := ingestionWorkers.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Ingestion workers shutdown failed: %v", err)
+	}
 }
 
 func loadConfig() config {
@@ -133,10 +230,16 @@ func loadConfig() config {
 		port = defaultPort
 	}
 
-	dbURL := os.Getenv("DB_CONNECTION_STRING")
-	if dbURL == "" {
-		dbURL = defaultDatabaseURL
-		log.Println("WARNING: DB_CONNECTION_STRING not set, using default local connection string.")
+	dbURL := databaseURLFromEnv()
+
+	autoMigrate := false
+	if v := os.Getenv("DB_AUTO_MIGRATE"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("WARNING: Invalid DB_AUTO_MIGRATE %q, migrations will not run on startup.", v)
+		} else {
+			autoMigrate = parsed
+		}
 	}
 
 	sendGridAPIKey := os.Getenv("SENDGRID_API_KEY")
@@ -154,16 +257,257 @@ func loadConfig() config {
 		sendGridName = defaultSendGridName
 	}
 
+	feedPollInterval := defaultFeedInterval
+	if v := os.Getenv("FEED_POLL_INTERVAL"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid FEED_POLL_INTERVAL %q, using default %s.", v, defaultFeedInterval)
+		} else {
+			feedPollInterval = parsed
+		}
+	}
+
+	retryPolicy := delivery.RetryPolicy{
+		MaxAttempts: delivery.DefaultMaxAttempts,
+		BaseDelay:   delivery.DefaultRetryBaseDelay,
+		MaxDelay:    delivery.DefaultRetryMaxDelay,
+	}
+	if v := os.Getenv("DELIVERY_MAX_ATTEMPTS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid DELIVERY_MAX_ATTEMPTS %q, using default %d.", v, delivery.DefaultMaxAttempts)
+		} else {
+			retryPolicy.MaxAttempts = parsed
+		}
+	}
+	if v := os.Getenv("DELIVERY_RETRY_BASE_DELAY"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid DELIVERY_RETRY_BASE_DELAY %q, using default %s.", v, delivery.DefaultRetryBaseDelay)
+		} else {
+			retryPolicy.BaseDelay = parsed
+		}
+	}
+	if v := os.Getenv("DELIVERY_RETRY_MAX_DELAY"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid DELIVERY_RETRY_MAX_DELAY %q, using default %s.", v, delivery.DefaultRetryMaxDelay)
+		} else {
+			retryPolicy.MaxDelay = parsed
+		}
+	}
+
+	ingestionWorkers := ingestion.DefaultJobWorkers
+	if v := os.Getenv("INGESTION_WORKERS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid INGESTION_WORKERS %q, using default %d.", v, ingestion.DefaultJobWorkers)
+		} else {
+			ingestionWorkers = parsed
+		}
+	}
+
+	ingestionRetryPolicy := ingestion.JobRetryPolicy{
+		MaxAttempts: ingestion.DefaultJobMaxAttempts,
+		BaseDelay:   ingestion.DefaultJobRetryBaseDelay,
+		MaxDelay:    ingestion.DefaultJobRetryMaxDelay,
+	}
+	if v := os.Getenv("INGESTION_MAX_ATTEMPTS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid INGESTION_MAX_ATTEMPTS %q, using default %d.", v, ingestion.DefaultJobMaxAttempts)
+		} else {
+			ingestionRetryPolicy.MaxAttempts = parsed
+		}
+	}
+	if v := os.Getenv("INGESTION_RETRY_BASE_DELAY"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid INGESTION_RETRY_BASE_DELAY %q, using default %s.", v, ingestion.DefaultJobRetryBaseDelay)
+		} else {
+			ingestionRetryPolicy.BaseDelay = parsed
+		}
+	}
+	if v := os.Getenv("INGESTION_RETRY_MAX_DELAY"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid INGESTION_RETRY_MAX_DELAY %q, using default %s.", v, ingestion.DefaultJobRetryMaxDelay)
+		} else {
+			ingestionRetryPolicy.MaxDelay = parsed
+		}
+	}
+
+	nearDupThreshold := ingestion.DefaultNearDuplicateThreshold
+	if v := os.Getenv("NEAR_DUPLICATE_THRESHOLD"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Printf("WARNING: Invalid NEAR_DUPLICATE_THRESHOLD %q (expected 0 to 1), using default %.2f.", v, ingestion.DefaultNearDuplicateThreshold)
+		} else {
+			nearDupThreshold = parsed
+		}
+	}
+
+	pdfPageSizeName := os.Getenv("PDF_PAGE_SIZE")
+	if pdfPageSizeName == "" {
+		pdfPageSizeName = defaultPDFPageSize
+	}
+	pdfPageSize, ok := ebook.ParsePageSize(pdfPageSizeName)
+	if !ok {
+		log.Printf("WARNING: Unknown PDF_PAGE_SIZE %q (expected A4, A5 or Letter), using %s.", pdfPageSizeName, defaultPDFPageSize)
+		pdfPageSize, _ = ebook.ParsePageSize(defaultPDFPageSize)
+	}
+
+	inboundSecret := os.Getenv("INBOUND_WEBHOOK_SECRET")
+	inboundPublicKey := os.Getenv("SENDGRID_WEBHOOK_PUBLIC_KEY")
+	if inboundSecret == "" && inboundPublicKey == "" {
+		log.Println("WARNING: Neither INBOUND_WEBHOOK_SECRET nor SENDGRID_WEBHOOK_PUBLIC_KEY is set. All inbound email webhooks will be rejected.")
+	}
+
+	tickSecret := os.Getenv("SCHEDULER_TICK_SECRET")
+	tickOIDCAudience := os.Getenv("SCHEDULER_OIDC_AUDIENCE")
+	tickOIDCJWKSURL := os.Getenv("SCHEDULER_OIDC_JWKS_URL")
+	if tickOIDCJWKSURL == "" {
+		tickOIDCJWKSURL = oidc.GoogleJWKSURL
+	}
+	if tickSecret == "" && tickOIDCAudience == "" {
+		log.Println("WARNING: Neither SCHEDULER_TICK_SECRET nor SCHEDULER_OIDC_AUDIENCE is set. All /scheduler/tick requests will be rejected.")
+	}
+
+	adminSecret := os.Getenv("ADMIN_SECRET")
+	if adminSecret == "" {
+		log.Println("WARNING: ADMIN_SECRET not set. All /admin requests will be rejected.")
+	}
+
+	downloadLinkSecret := os.Getenv("DOWNLOAD_LINK_SECRET")
+	if downloadLinkSecret == "" {
+		log.Println("WARNING: DOWNLOAD_LINK_SECRET not set. Expiring edition download links are disabled.")
+	}
+
 	return config{
-		port:              port,
-		databaseURL:       dbURL,
-		sendGridAPIKey:    sendGridAPIKey,
-		sendGridFromEmail: sendGridFrom,
-		sendGridFromName:  sendGridName,
+		port:                 port,
+		databaseURL:          dbURL,
+		sendGridAPIKey:       sendGridAPIKey,
+		sendGridFromEmail:    sendGridFrom,
+		sendGridFromName:     sendGridName,
+		feedPollInterval:     feedPollInterval,
+		pdfPageSize:          pdfPageSize,
+		inboundSecret:        inboundSecret,
+		inboundPublicKey:     inboundPublicKey,
+		tickSecret:           tickSecret,
+		tickOIDCAudience:     tickOIDCAudience,
+		tickOIDCEmail:        os.Getenv("SCHEDULER_OIDC_EMAIL"),
+		tickOIDCIssuer:       os.Getenv("SCHEDULER_OIDC_ISSUER"),
+		tickOIDCJWKSURL:      tickOIDCJWKSURL,
+		adminSecret:          adminSecret,
+		downloadLinkSecret:   downloadLinkSecret,
+		autoMigrate:          autoMigrate,
+		retryPolicy:          retryPolicy,
+		nearDupThreshold:     nearDupThreshold,
+		conversionBackend:    conversionBackendFromEnv(),
+		blobStore:            blobStoreConfigFromEnv(),
+		ingestionWorkers:     ingestionWorkers,
+		ingestionRetryPolicy: ingestionRetryPolicy,
+	}
+}
+
+func databaseURLFromEnv() string {
+	dbURL := os.Getenv("DB_CONNECTION_STRING")
+	if dbURL == "" {
+		dbURL = defaultDatabaseURL
+		log.Println("WARNING: DB_CONNECTION_STRING not set, using default local connection string.")
+	}
+	return dbURL
+}
+
+func conversionBackendFromEnv() conversion.Backend {
+	v := os.Getenv("CONVERSION_BACKEND")
+	if v == "" {
+		return conversion.BackendBuiltin
+	}
+	backend, ok := conversion.ParseBackend(v)
+	if !ok {
+		log.Printf("WARNING: Unknown CONVERSION_BACKEND %q (expected builtin or pandoc), using %s.", v, conversion.BackendBuiltin)
+		return conversion.BackendBuiltin
+	}
+	return backend
+}
+
+// blobStoreConfigFromEnv reads the blob store settings. Files are kept on local
+// disk unless BLOB_STORE_BACKEND selects S3.
+func blobStoreConfigFromEnv() storage.Config {
+	cfg := storage.Config{Backend: storage.BackendLocal, LocalDir: os.Getenv("BLOB_STORE_DIR")}
+	if v := os.Getenv("BLOB_STORE_BACKEND"); v != "" {
+		backend, ok := storage.ParseBackend(v)
+		if !ok {
+			log.Printf("WARNING: Unknown BLOB_STORE_BACKEND %q (expected local or s3), using %s.", v, storage.BackendLocal)
+		} else {
+			cfg.Backend = backend
+		}
+	}
+	if cfg.LocalDir == "" {
+		cfg.LocalDir = defaultBlobStoreDir
+	}
+
+	cfg.S3 = storage.S3Config{
+		Endpoint:        os.Getenv("BLOB_S3_ENDPOINT"),
+		Region:          os.Getenv("BLOB_S3_REGION"),
+		Bucket:          os.Getenv("BLOB_S3_BUCKET"),
+		AccessKeyID:     os.Getenv("BLOB_S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("BLOB_S3_SECRET_ACCESS_KEY"),
+	}
+	if v := os.Getenv("BLOB_S3_PATH_STYLE"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("WARNING: Invalid BLOB_S3_PATH_STYLE %q, using virtual-hosted addressing.", v)
+		} else {
+			cfg.S3.PathStyle = parsed
+		}
+	}
+	return cfg
+}
+
+// tickOIDCVerifier builds the identity token verifier for the scheduler tick
+// endpoint, or returns nil when no OIDC audience is configured.
+func tickOIDCVerifier(cfg config) *oidc.Verifier {
+	if cfg.tickOIDCAudience == "" {
+		return nil
+	}
+	if cfg.tickOIDCEmail == "" {
+		log.Println("WARNING: SCHEDULER_OIDC_EMAIL not set. Any identity token from the issuer for the tick audience will be accepted.")
+	}
+
+	issuers := oidc.GoogleIssuers
+	if cfg.tickOIDCIssuer != "" {
+		issuers = []string{cfg.tickOIDCIssuer}
+	}
+	verifier, err := oidc.NewVerifier(oidc.Config{
+		Issuers:  issuers,
+		Audience: cfg.tickOIDCAudience,
+		Email:    cfg.tickOIDCEmail,
+	}, oidc.NewRemoteKeySet(cfg.tickOIDCJWKSURL, nil))
+	if err != nil {
+		log.Fatalf("Invalid scheduler OIDC configuration: %v", err)
+	}
+	return verifier
+}
+
+// inboundVerifiers builds the verifiers the inbound email webhook must pass.
+func inboundVerifiers(cfg config) []webhooks.InboundVerifier {
+	var verifiers []webhooks.InboundVerifier
+	if cfg.inboundSecret != "" {
+		verifiers = append(verifiers, webhooks.NewSharedSecretVerifier(cfg.inboundSecret))
 	}
+	if cfg.inboundPublicKey != "" {
+		signatureVerifier, err := webhooks.NewSignatureVerifier(cfg.inboundPublicKey)
+		if err != nil {
+			log.Fatalf("Invalid SENDGRID_WEBHOOK_PUBLIC_KEY: %v", err)
+		}
+		verifiers = append(verifiers, signatureVerifier)
+	}
+	return verifiers
 }
 
-func setupDatabase(connStr string) (*sql.DB, error) {
+func setupDatabase(connStr string, migrate bool) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
@@ -182,6 +526,20 @@ func setupDatabase(connStr string) (*sql.DB, error) {
 	}
 
 	log.Println("Database connection successful")
+
+	if migrate {
+		migrator, err := datastore.NewMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load migrations: %w", err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		log.Printf("Database schema up to date (%d migrations applied)", applied)
+	}
 	return db, nil
 }
 
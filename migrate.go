@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+)
+
+const migrateUsage = "usage: logos migrate status|up|down [steps]"
+
+// runMigrateCommand implements the "logos migrate" subcommand against the
+// database named by DB_CONNECTION_STRING.
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	steps := 1
+	switch args[0] {
+	case "status", "up":
+		if len(args) > 1 {
+			return errors.New(migrateUsage)
+		}
+	case "down":
+		if len(args) > 2 {
+			return errors.New(migrateUsage)
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid step count %q: must be a positive integer", args[1])
+			}
+			steps = n
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+
+	db, err := setupDatabase(databaseURLFromEnv(), false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := datastore.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+	case "down":
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// APIToken is a per-user credential for the REST API. Only the SHA-256 hash
+// of the token is stored; the plaintext is shown once when it is created.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -10,3 +10,12 @@ type DeliveryDestination struct {
 	Name      string    `json:"name"`
 	Type      string    `json:"type"` // email, api, webhook
 }
+
+// WebhookDestination holds the type-specific details of a "webhook"
+// destination. Secret signs every payload sent to URL and is never
+// serialized back to clients after creation.
+type WebhookDestination struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"-"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// EditionFile is a generated ebook file for an edition. Every generation is
+// kept, so an edition has a history of files, newest last.
+type EditionFile struct {
+	ID          string        `json:"id"`
+	EditionID   string        `json:"edition_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Format      EditionFormat `json:"format"`
+	StorageKey  string        `json:"storage_key"`  // Blob store key of the file
+	FileSize    int           `json:"file_size"`    // In bytes
+	ContentHash string        `json:"content_hash"` // SHA-256 of the file; used as its ETag
+}
@@ -23,7 +23,7 @@ type EditionTemplate struct {
 	Name             string        `json:"name"`
 	Description      string        `json:"description,omitempty"`
 	Format           EditionFormat `json:"format"`            // Corresponds to edition_format ENUM ('epub', 'mobi', 'pdf')
-	DeliveryInterval string        `json:"delivery_interval"` // Corresponds to edition_delivery_interval ENUM ('hourly', 'daily', 'weekly', 'monthly')
+	DeliveryInterval string        `json:"delivery_interval"` // Corresponds to edition_delivery_interval ENUM ('every_five_minutes', 'hourly', 'daily', 'weekly', 'monthly')
 	DeliveryTime     string        `json:"delivery_time"`     // SQL TIME type, represented as "HH:MM:SS" string
 	IsRecurring      bool          `json:"is_recurring"`
 	ColorImages      bool          `json:"color_images"` // If true, images are kept in color; otherwise converted to grayscale
@@ -40,3 +40,18 @@ func IsValidEditionFormat(formatStr string) (EditionFormat, bool) {
 		return "", false
 	}
 }
+
+// ContentType returns the MIME type of files in the format, or
+// application/octet-stream if it is unknown.
+func (f EditionFormat) ContentType() string {
+	switch f {
+	case EditionFormatEPUB:
+		return "application/epub+zip"
+	case EditionFormatMOBI:
+		return "application/x-mobipocket-ebook"
+	case EditionFormatPDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
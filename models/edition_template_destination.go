@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// EditionTemplateDestination represents the association between an edition
+// template and a delivery destination, indicating that editions generated
+// from this template should be delivered there.
+type EditionTemplateDestination struct {
+	EditionTemplateID     string    `json:"edition_template_id"`
+	DeliveryDestinationID string    `json:"delivery_destination_id"`
+	CreatedAt             time.Time `json:"created_at"`
+}
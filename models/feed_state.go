@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// FeedState tracks polling state for an "rss" reading source, including the
+// validators used for conditional GET requests.
+type FeedState struct {
+	ReadingSourceID string     `json:"reading_source_id"`
+	ETag            string     `json:"etag,omitempty"`
+	LastModified    string     `json:"last_modified,omitempty"`
+	LastPolledAt    *time.Time `json:"last_polled_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
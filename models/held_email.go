@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// HeldEmailStatus defines the set of allowed statuses for a HeldEmail.
+type HeldEmailStatus string
+
+const (
+	HeldEmailStatusRejected    HeldEmailStatus = "rejected"
+	HeldEmailStatusQuarantined HeldEmailStatus = "quarantined"
+	HeldEmailStatusReleased    HeldEmailStatus = "released"
+)
+
+// IsValidHeldEmailStatus checks if the provided string is a valid HeldEmailStatus.
+func IsValidHeldEmailStatus(statusStr string) (HeldEmailStatus, bool) {
+	switch s := HeldEmailStatus(statusStr); s {
+	case HeldEmailStatusRejected, HeldEmailStatusQuarantined, HeldEmailStatusReleased:
+		return s, true
+	default:
+		return "", false
+	}
+}
+
+// HeldEmail represents an inbound email that was not ingested because its
+// sender did not pass the user's ingestion policy. The raw MIME is kept so
+// the message can be released into the normal ingestion pipeline later.
+type HeldEmail struct {
+	ID          string          `json:"id"`
+	UserID      string          `json:"user_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	SenderEmail string          `json:"sender_email"`
+	Subject     string          `json:"subject"`
+	MessageID   string          `json:"message_id,omitempty"`
+	Reason      string          `json:"reason"`
+	Status      HeldEmailStatus `json:"status"`
+	RawMIME     string          `json:"-"`
+	ReleasedAt  *time.Time      `json:"released_at,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// IngestionJobStatus defines the set of allowed statuses for an IngestionJob.
+type IngestionJobStatus string
+
+const (
+	IngestionJobStatusPending   IngestionJobStatus = "pending"
+	IngestionJobStatusRunning   IngestionJobStatus = "running"
+	IngestionJobStatusSucceeded IngestionJobStatus = "succeeded"
+	IngestionJobStatusFailed    IngestionJobStatus = "failed" // Waiting to be retried
+	IngestionJobStatusDead      IngestionJobStatus = "dead"   // Out of attempts, or failed permanently
+)
+
+// IngestionJob is an inbound email waiting to be, or having been, run through
+// the ingestion pipeline. The webhook stores the raw MIME as a job and
+// acknowledges; workers claim and process jobs in the background.
+type IngestionJob struct {
+	ID          string             `json:"id"`
+	UserID      string             `json:"user_id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Sender      string             `json:"sender"` // The webhook's from field; the worker prefers the MIME headers
+	Subject     string             `json:"subject"`
+	RawMIME     string             `json:"-"`
+	Status      IngestionJobStatus `json:"status"`
+	Attempts    int                `json:"attempts"`
+	RunAfter    time.Time          `json:"run_after"`
+	LockedUntil *time.Time         `json:"locked_until,omitempty"`
+	LastError   string             `json:"last_error,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RawEmail is an inbound email archived as received, so the readings it
+// produced can be rebuilt when the ingestion pipeline improves. The MIME is
+// stored compressed; RawMIME holds it decompressed.
+type RawEmail struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	MessageID   string    `json:"message_id,omitempty"`
+	SenderEmail string    `json:"sender_email"`
+	Subject     string    `json:"subject"`
+	ContentHash string    `json:"content_hash"` // SHA-256 of RawMIME; an email delivered twice is archived once
+	MIMESize    int       `json:"mime_size"`    // Size of RawMIME in bytes, before compression
+	RawMIME     string    `json:"-"`
+}
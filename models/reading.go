@@ -17,15 +17,23 @@ const (
 )
 
 type Reading struct {
-	ID          string        `json:"id"`
-	SourceID    string        `json:"reading_source_id"`
-	Author      string        `json:"author,omitempty"`
-	CreatedAt   time.Time     `json:"created_at"`
-	ContentHash string        `json:"content_hash"`
-	ContentBody string        `json:"-"`
-	Excerpt     string        `json:"excerpt"`
-	PublishedAt *time.Time    `json:"published_at,omitempty"`
-	StoragePath string        `json:"storage_path"`
-	Title       string        `json:"title"`
-	Format      ReadingFormat `json:"format"`
+	ID           string           `json:"id"`
+	UserID       string           `json:"user_id"`
+	SourceID     string           `json:"reading_source_id"`
+	Author       string           `json:"author,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	ContentHash  string           `json:"content_hash"`
+	ContentBody  string           `json:"-"` // Kept in the blob store at StoragePath; loaded only where needed
+	BodyHash     string           `json:"-"` // SHA-256 of ContentBody exactly as stored
+	Excerpt      string           `json:"excerpt"`
+	PublishedAt  *time.Time       `json:"published_at,omitempty"`
+	StoragePath  string           `json:"storage_path"`
+	Title        string           `json:"title"`
+	Format       ReadingFormat    `json:"format"`
+	SimHash      *int64           `json:"-"`                      // Similarity fingerprint of the text; nil for non-HTML or very short readings
+	DuplicateOf  *string          `json:"duplicate_of,omitempty"` // Earlier reading this one is a near-duplicate of
+	RawEmailID   *string          `json:"raw_email_id,omitempty"` // Archived email the reading was built from, if any
+	RawEmailPart *int             `json:"-"`                      // Part of that email it was built from: 0 for the whole email, or the article's position from 1 in a split digest
+	Images       []ReadingImage   `json:"-"`                      // Inline images referenced from ContentBody; loaded only for rendering
+	Original     *ReadingOriginal `json:"-"`                      // File the reading was converted from, if kept; stored with the reading
 }
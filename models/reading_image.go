@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ReadingImageScheme prefixes references to images stored with a reading.
+// Ingestion rewrites cid: URLs in email HTML to ReadingImageScheme followed
+// by the image's content hash, so the reference stays stable across
+// duplicate deliveries of the same newsletter.
+const ReadingImageScheme = "logos-image:"
+
+// ReadingImage is an image received inline with a reading, such as a
+// newsletter graphic attached to the email as a cid: part.
+type ReadingImage struct {
+	ReadingID   string    `json:"reading_id"`
+	ContentHash string    `json:"content_hash"`
+	ContentType string    `json:"content_type"`
+	Data        []byte    `json:"-"` // Loaded from StorageKey for images kept in the blob store
+	StorageKey  string    `json:"-"` // Object key in the blob store; empty for images stored in the database
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Ref returns the URL the reading's HTML uses to refer to the image.
+func (i ReadingImage) Ref() string {
+	return ReadingImageScheme + i.ContentHash
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ReadingOriginal is the file a reading was converted from, such as a PDF
+// attachment whose text became the reading's HTML. It is kept so the
+// original layout can still be downloaded.
+type ReadingOriginal struct {
+	ReadingID   string        `json:"reading_id"`
+	FileName    string        `json:"file_name"`
+	Format      ReadingFormat `json:"format"`
+	ContentType string        `json:"content_type"`
+	Data        []byte        `json:"-"` // Loaded from StorageKey for files kept in the blob store
+	StorageKey  string        `json:"-"` // Object key in the blob store; empty for files stored in the database
+	CreatedAt   time.Time     `json:"created_at"`
+}
@@ -2,10 +2,33 @@ package models
 
 import "time"
 
+// SplitMode controls whether HTML content from a source is split into one
+// reading per article, for digests that carry several stories per email.
+type SplitMode string
+
+const (
+	SplitModeNone     SplitMode = "none"     // One reading per email
+	SplitModeHeadings SplitMode = "headings" // Split at the repeated heading level that introduces each article
+	SplitModeSelector SplitMode = "selector" // One reading per element matching SplitSelector
+)
+
+// IsValidSplitMode checks if the provided string is a valid SplitMode.
+func IsValidSplitMode(modeStr string) (SplitMode, bool) {
+	switch m := SplitMode(modeStr); m {
+	case SplitModeNone, SplitModeHeadings, SplitModeSelector:
+		return m, true
+	default:
+		return "", false
+	}
+}
+
 type ReadingSource struct {
-	ID         string    `json:"id"`
-	CreatedAt  time.Time `json:"created_at"`
-	Name       string    `json:"name"`
-	Type       string    `json:"type"`       // email, rss, api
-	Identifier string    `json:"identifier"` // e.g., sender email for "email" type, feed URL for "rss"
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`                     // email, rss, api
+	Identifier    string    `json:"identifier"`               // e.g., sender email for "email" type, feed URL for "rss"
+	SplitMode     SplitMode `json:"split_mode"`               // Defaults to SplitModeNone
+	SplitSelector string    `json:"split_selector,omitempty"` // CSS selector used with SplitModeSelector
 }
@@ -2,9 +2,30 @@ package models
 
 import "time"
 
+// IngestionPolicy controls how inbound email from senders that are not on a
+// user's allowed sender list is handled.
+type IngestionPolicy string
+
+const (
+	IngestionPolicyOpen       IngestionPolicy = "open"       // Accept mail from any sender
+	IngestionPolicyAllowlist  IngestionPolicy = "allowlist"  // Reject mail from unknown senders
+	IngestionPolicyQuarantine IngestionPolicy = "quarantine" // Hold mail from unknown senders for review
+)
+
+// IsValidIngestionPolicy checks if the provided string is a valid IngestionPolicy.
+func IsValidIngestionPolicy(policyStr string) (IngestionPolicy, bool) {
+	switch p := IngestionPolicy(policyStr); p {
+	case IngestionPolicyOpen, IngestionPolicyAllowlist, IngestionPolicyQuarantine:
+		return p, true
+	default:
+		return "", false
+	}
+}
+
 type User struct {
-	ID         string    `json:"id"`
-	CreatedAt  time.Time `json:"created_at"`
-	Email      string    `json:"email"`
-	EmailToken string    `json:"-"` // Not exposed in API responses
+	ID              string          `json:"id"`
+	CreatedAt       time.Time       `json:"created_at"`
+	Email           string          `json:"email"`
+	EmailToken      string          `json:"-"` // Not exposed in API responses
+	IngestionPolicy IngestionPolicy `json:"ingestion_policy"`
 }
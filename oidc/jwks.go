@@ -0,0 +1,146 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// GoogleJWKSURL serves the keys Google signs OIDC identity tokens with.
+	GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// jwksCacheTTL is how long fetched keys are trusted before a refresh.
+	jwksCacheTTL = time.Hour
+	// jwksMinRefresh stops unknown key IDs from triggering a fetch per request.
+	jwksMinRefresh = time.Minute
+	maxJWKSBytes   = 1 << 20
+)
+
+// KeySet resolves the RSA public key a token was signed with by key ID.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeySet is a fixed set of keys, e.g. parsed from a local JWKS document.
+type StaticKeySet map[string]*rsa.PublicKey
+
+func (s StaticKeySet) Key(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("no key with id %q", kid)
+	}
+	return key, nil
+}
+
+// RemoteKeySet fetches a JWKS document over HTTP and caches its keys. The
+// document is refetched when the cache expires or an unknown key ID appears,
+// which is how providers roll their signing keys.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      StaticKeySet
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RemoteKeySet{url: url, client: client}
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetchedAt)
+	if key, ok := s.keys[kid]; ok && age < jwksCacheTTL {
+		return key, nil
+	}
+	if s.keys == nil || age >= jwksMinRefresh {
+		keys, err := s.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+	return s.keys.Key(ctx, kid)
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (StaticKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint %s returned status %d", s.url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS from %s: %w", s.url, err)
+	}
+	return ParseJWKS(body)
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// ParseJWKS extracts the RSA signing keys from a JWKS document. Keys of other
+// types or uses are skipped.
+func ParseJWKS(data []byte) (StaticKeySet, error) {
+	var doc jwks
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(StaticKeySet, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != algRS256) {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %q: %w", k.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("unsupported exponent for key %q", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no RSA signing keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,158 @@
+// Package oidc verifies OpenID Connect identity tokens, such as the ones
+// Google Cloud Scheduler attaches to HTTP targets for a service account.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	algRS256 = "RS256"
+
+	// clockSkew tolerates small differences between our clock and the issuer's.
+	clockSkew = time.Minute
+)
+
+// GoogleIssuers are the issuer values Google uses in identity tokens.
+var GoogleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// Config describes which tokens a Verifier accepts.
+type Config struct {
+	// Issuers lists acceptable "iss" values.
+	Issuers []string
+	// Audience must appear in the token's "aud" claim.
+	Audience string
+	// Email, if set, must match the token's verified "email" claim,
+	// e.g. the scheduler's service account.
+	Email string
+}
+
+// Claims are the identity token claims the verifier checks.
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	Expiry        int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	NotBefore     int64    `json:"nbf"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+}
+
+// Verifier checks RS256-signed identity tokens against a key set and Config.
+type Verifier struct {
+	config Config
+	keys   KeySet
+	now    func() time.Time
+}
+
+func NewVerifier(config Config, keys KeySet) (*Verifier, error) {
+	if len(config.Issuers) == 0 {
+		return nil, errors.New("at least one issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("audience is required")
+	}
+	return &Verifier{config: config, keys: keys, now: time.Now}, nil
+}
+
+// Verify checks the token's signature and claims and returns the claims.
+func (v *Verifier) Verify(ctx context.Context, rawToken string) (*Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	if header.Alg != algRS256 {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve signing key: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("token signature does not match")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if err := v.checkClaims(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *Verifier) checkClaims(claims *Claims) error {
+	now := v.now()
+	if !slices.Contains(v.config.Issuers, claims.Issuer) {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !slices.Contains(claims.Audience, v.config.Audience) {
+		return fmt.Errorf("token audience %v does not include %q", []string(claims.Audience), v.config.Audience)
+	}
+	if claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)) {
+		return errors.New("token has expired")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if claims.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return errors.New("token was issued in the future")
+	}
+	if v.config.Email != "" {
+		if !claims.EmailVerified || !strings.EqualFold(claims.Email, v.config.Email) {
+			return fmt.Errorf("token email %q is not the expected account", claims.Email)
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, into any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+// audience accepts the "aud" claim as either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("aud must be a string or array of strings: %w", err)
+	}
+	*a = multiple
+	return nil
+}
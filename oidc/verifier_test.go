@@ -0,0 +1,261 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://accounts.google.com"
+	testAudience = "https://logos.example.com/scheduler/tick"
+	testEmail    = "scheduler@project.iam.gserviceaccount.com"
+)
+
+// testKey is an RSA signing key published under kid in the test JWKS.
+type testKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return testKey{kid: kid, key: key}
+}
+
+func (k testKey) jwk() jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: k.kid,
+		Use: "sig",
+		Alg: algRS256,
+		N:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+	}
+}
+
+// sign returns a compact JWT with the given header algorithm and claims.
+// Anything other than RS256 gets a bogus signature.
+func (k testKey) sign(t *testing.T, alg string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": k.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	if alg != algRS256 {
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString([]byte("not a signature"))
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// jwksServer serves the keys it holds as a JWKS document and counts fetches.
+type jwksServer struct {
+	*httptest.Server
+	keys    atomic.Value // []testKey
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...testKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.keys.Store(keys)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		var doc jwks
+		for _, k := range s.keys.Load().([]testKey) {
+			doc.Keys = append(doc.Keys, k.jwk())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func validClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss":            testIssuer,
+		"sub":            "1234567890",
+		"aud":            testAudience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          testEmail,
+		"email_verified": true,
+	}
+}
+
+func newTestVerifier(t *testing.T, server *jwksServer) *Verifier {
+	t.Helper()
+	verifier, err := NewVerifier(Config{Issuers: GoogleIssuers, Audience: testAudience, Email: testEmail}, NewRemoteKeySet(server.URL, server.Client()))
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	return verifier
+}
+
+func TestVerify(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	otherKey := newTestKey(t, "key-1") // Same kid, different key
+	server := newJWKSServer(t, key)
+	verifier := newTestVerifier(t, server)
+	now := time.Now()
+
+	with := func(changes map[string]any) map[string]any {
+		claims := validClaims(now)
+		for name, value := range changes {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"valid token", key.sign(t, algRS256, validClaims(now)), ""},
+		{"audience array", key.sign(t, algRS256, with(map[string]any{"aud": []string{"other", testAudience}})), ""},
+		{"email differs in case", key.sign(t, algRS256, with(map[string]any{"email": strings.ToUpper(testEmail)})), ""},
+		{"wrong audience", key.sign(t, algRS256, with(map[string]any{"aud": "https://other.example.com"})), "audience"},
+		{"wrong issuer", key.sign(t, algRS256, with(map[string]any{"iss": "https://evil.example.com"})), "issuer"},
+		{"expired", key.sign(t, algRS256, with(map[string]any{"exp": now.Add(-2 * clockSkew).Unix()})), "expired"},
+		{"no expiry", key.sign(t, algRS256, with(map[string]any{"exp": nil})), "expired"},
+		{"not valid yet", key.sign(t, algRS256, with(map[string]any{"nbf": now.Add(2 * clockSkew).Unix()})), "not valid yet"},
+		{"wrong email", key.sign(t, algRS256, with(map[string]any{"email": "someone@example.com"})), "expected account"},
+		{"unverified email", key.sign(t, algRS256, with(map[string]any{"email_verified": false})), "expected account"},
+		{"alg none", key.sign(t, "none", validClaims(now)), "unsupported signing algorithm"},
+		{"alg HS256", key.sign(t, "HS256", validClaims(now)), "unsupported signing algorithm"},
+		{"signed by another key", otherKey.sign(t, algRS256, validClaims(now)), "signature does not match"},
+		{"not a JWT", "abc.def", "not a JWT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Subject != "1234567890" {
+					t.Errorf("Subject = %q", claims.Subject)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedClaims(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	verifier := newTestVerifier(t, newJWKSServer(t, key))
+
+	parts := strings.Split(key.sign(t, algRS256, validClaims(time.Now())), ".")
+	forged := validClaims(time.Now())
+	forged["email"] = "attacker@example.com"
+	payload, _ := json.Marshal(forged)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	if _, err := verifier.Verify(context.Background(), strings.Join(parts, ".")); err == nil {
+		t.Error("Verify accepted a token whose claims were changed after signing")
+	}
+}
+
+func TestRemoteKeySetRefetchesUnknownKeyID(t *testing.T) {
+	oldKey := newTestKey(t, "old")
+	newKey := newTestKey(t, "new")
+	server := newJWKSServer(t, oldKey)
+	keySet := NewRemoteKeySet(server.URL, server.Client())
+	verifier, err := NewVerifier(Config{Issuers: GoogleIssuers, Audience: testAudience}, keySet)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := verifier.Verify(ctx, oldKey.sign(t, algRS256, validClaims(time.Now()))); err != nil {
+		t.Fatalf("Verify with the published key: %v", err)
+	}
+	if _, err := verifier.Verify(ctx, oldKey.sign(t, algRS256, validClaims(time.Now()))); err != nil {
+		t.Fatalf("Verify with a cached key: %v", err)
+	}
+	if n := server.fetches.Load(); n != 1 {
+		t.Fatalf("fetched the JWKS %d times, want 1 while the key is cached", n)
+	}
+
+	// The issuer rolls its keys.
+	server.keys.Store([]testKey{oldKey, newKey})
+
+	// An unknown kid right after a fetch doesn't hit the JWKS endpoint again.
+	if _, err := verifier.Verify(ctx, newKey.sign(t, algRS256, validClaims(time.Now()))); err == nil {
+		t.Fatal("Verify accepted a key id that was not fetched yet")
+	}
+	if n := server.fetches.Load(); n != 1 {
+		t.Fatalf("fetched the JWKS %d times, want 1 within the minimum refresh interval", n)
+	}
+
+	keySet.mu.Lock()
+	keySet.fetchedAt = keySet.fetchedAt.Add(-jwksMinRefresh)
+	keySet.mu.Unlock()
+
+	if _, err := verifier.Verify(ctx, newKey.sign(t, algRS256, validClaims(time.Now()))); err != nil {
+		t.Fatalf("Verify with a rolled key: %v", err)
+	}
+	if n := server.fetches.Load(); n != 2 {
+		t.Errorf("fetched the JWKS %d times, want a refetch for the unknown key id", n)
+	}
+}
+
+func TestNewVerifierRequiresIssuerAndAudience(t *testing.T) {
+	if _, err := NewVerifier(Config{Audience: testAudience}, StaticKeySet{}); err == nil {
+		t.Error("NewVerifier accepted a config without issuers")
+	}
+	if _, err := NewVerifier(Config{Issuers: GoogleIssuers}, StaticKeySet{}); err == nil {
+		t.Error("NewVerifier accepted a config without an audience")
+	}
+}
+
+func TestParseJWKSSkipsNonSigningKeys(t *testing.T) {
+	key := newTestKey(t, "sig")
+	enc := key.jwk()
+	enc.Kid, enc.Use = "enc", "enc"
+	ec := jwk{Kty: "EC", Kid: "ec"}
+	data, _ := json.Marshal(jwks{Keys: []jwk{key.jwk(), enc, ec}})
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	if len(keys) != 1 || keys["sig"] == nil || keys["sig"].N.Cmp(key.key.N) != 0 {
+		t.Errorf("ParseJWKS = %v, want only the signing key", keys)
+	}
+
+	if _, err := ParseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"ec"}]}`)); err == nil {
+		t.Error("ParseJWKS accepted a document without RSA signing keys")
+	}
+}
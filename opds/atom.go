@@ -0,0 +1,178 @@
+package opds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	atomNamespace       = "http://www.w3.org/2005/Atom"
+	dcNamespace         = "http://purl.org/dc/terms/"
+	opdsNamespace       = "http://opds-spec.org/2010/catalog"
+	openSearchNamespace = "http://a9.com/-/spec/opensearch/1.1/"
+	threadNamespace     = "http://purl.org/syndication/thread/1.0"
+)
+
+type atomFeed struct {
+	XMLName      xml.Name    `xml:"feed"`
+	Xmlns        string      `xml:"xmlns,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string      `xml:"xmlns:opds,attr"`
+	XmlnsSearch  string      `xml:"xmlns:opensearch,attr,omitempty"`
+	XmlnsThread  string      `xml:"xmlns:thr,attr,omitempty"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	Author       *atomAuthor `xml:"author,omitempty"`
+	TotalResults string      `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage string      `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   string      `xml:"opensearch:startIndex,omitempty"`
+	Links        []atomLink  `xml:"link"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+	Count  string `xml:"thr:count,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID       string        `xml:"id"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+	Issued   string        `xml:"dc:issued,omitempty"`
+	Author   *atomAuthor   `xml:"author,omitempty"`
+	Category *atomCategory `xml:"category,omitempty"`
+	Content  *atomText     `xml:"content,omitempty"`
+	Links    []atomLink    `xml:"link"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Atom serializes the catalog as an OPDS 1.2 Atom feed.
+func (c *Catalog) Atom() ([]byte, error) {
+	selfType := AtomAcquisitionType
+	if c.IsNavigation() {
+		selfType = AtomNavigationType
+	}
+
+	feed := atomFeed{
+		Xmlns:     atomNamespace,
+		XmlnsDC:   dcNamespace,
+		XmlnsOPDS: opdsNamespace,
+		ID:        c.ID,
+		Title:     c.Title,
+		Updated:   atomTime(c.Updated),
+		Links: []atomLink{
+			{Rel: "self", Href: c.Self, Type: selfType},
+			{Rel: "start", Href: c.Start, Type: AtomNavigationType},
+		},
+	}
+	if c.Author != "" {
+		feed.Author = &atomAuthor{Name: c.Author}
+	}
+	if c.Up != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "up", Href: c.Up, Type: AtomNavigationType})
+	}
+	if p := c.Page; p != nil {
+		feed.XmlnsSearch = openSearchNamespace
+		feed.TotalResults = strconv.Itoa(p.TotalItems)
+		feed.ItemsPerPage = strconv.Itoa(p.ItemsPerPage)
+		feed.StartIndex = strconv.Itoa((p.Number-1)*p.ItemsPerPage + 1)
+		for _, l := range p.links() {
+			feed.Links = append(feed.Links, atomLink{Rel: l.rel, Href: l.href, Type: AtomAcquisitionType})
+		}
+	}
+
+	for _, nav := range c.Navigation {
+		// Readers show thr:count next to the link as the number of books.
+		feed.XmlnsThread = threadNamespace
+		entry := atomEntry{
+			ID:      nav.ID,
+			Title:   nav.Title,
+			Updated: atomTime(nav.Updated),
+			Links: []atomLink{{
+				Rel:   "subsection",
+				Href:  nav.Href,
+				Type:  AtomAcquisitionType,
+				Count: strconv.Itoa(nav.Count),
+			}},
+		}
+		if nav.Summary != "" {
+			entry.Content = &atomText{Type: "text", Text: nav.Summary}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	for _, pub := range c.Publications {
+		feed.Entries = append(feed.Entries, publicationEntry(pub))
+	}
+
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return nil, fmt.Errorf("failed to encode OPDS feed: %w", err)
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+func publicationEntry(pub Publication) atomEntry {
+	entry := atomEntry{
+		ID:      pub.ID,
+		Title:   pub.Title,
+		Updated: atomTime(pub.Updated),
+	}
+	if !pub.Published.IsZero() {
+		entry.Issued = pub.Published.UTC().Format("2006-01-02")
+	}
+	if pub.Author != "" {
+		entry.Author = &atomAuthor{Name: pub.Author}
+	}
+	if pub.Category != "" {
+		entry.Category = &atomCategory{Term: pub.Category, Label: pub.Category}
+	}
+	if pub.Summary != "" {
+		entry.Content = &atomText{Type: "text", Text: pub.Summary}
+	}
+	if pub.Cover != nil {
+		entry.Links = append(entry.Links, atomLink{Rel: RelImage, Href: pub.Cover.Href, Type: pub.Cover.Type})
+	}
+	if pub.Thumbnail != nil {
+		entry.Links = append(entry.Links, atomLink{Rel: RelThumbnail, Href: pub.Thumbnail.Href, Type: pub.Thumbnail.Type})
+	}
+	for _, acq := range pub.Acquisitions {
+		link := atomLink{Rel: RelAcquisition, Href: acq.Href, Type: acq.Type}
+		if acq.Length > 0 {
+			link.Length = strconv.FormatInt(acq.Length, 10)
+		}
+		entry.Links = append(entry.Links, link)
+	}
+	return entry
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,125 @@
+// Package opds builds OPDS catalogs, the feeds e-reader apps such as KOReader,
+// Moon+ Reader and Thorium browse to download books. A Catalog describes one
+// page of a catalog independently of its serialization; Atom renders it as
+// OPDS 1.2 and JSON as OPDS 2.0.
+package opds
+
+import "time"
+
+// Media types of catalog documents.
+const (
+	AtomNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AtomAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	JSONType            = "application/opds+json"
+)
+
+// Link relations shared by both serializations.
+const (
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+)
+
+// Catalog is one page of a navigation or acquisition feed. Every href is an
+// absolute URL pointing at the same serialization as the catalog itself.
+type Catalog struct {
+	ID      string // Stable URI identifying the feed, e.g. "urn:uuid:..."
+	Title   string
+	Author  string
+	Updated time.Time
+	Self    string
+	Start   string // The catalog's root
+	Up      string // The parent feed, if any
+
+	// A navigation feed lists Navigation; an acquisition feed lists
+	// Publications, split into pages by Page.
+	Navigation   []Navigation
+	Publications []Publication
+	Page         *Page
+
+	// Groups preview the publications behind navigation links. Only OPDS 2.0
+	// has groups, so Atom leaves them out.
+	Groups []Group
+}
+
+// Navigation links to another feed in the catalog.
+type Navigation struct {
+	ID      string
+	Title   string
+	Summary string
+	Href    string
+	Updated time.Time
+	Count   int // Number of publications behind the link
+}
+
+// Group is a titled selection of publications with a link to the full feed.
+type Group struct {
+	Title        string
+	Href         string
+	Count        int // Number of publications behind Href
+	Publications []Publication
+}
+
+// Page places an acquisition feed within a paginated list.
+type Page struct {
+	Number       int // 1-based
+	ItemsPerPage int
+	TotalItems   int
+	First        string
+	Previous     string // Empty on the first page
+	Next         string // Empty on the last page
+	Last         string
+}
+
+// Publication is a book that can be downloaded from the catalog.
+type Publication struct {
+	ID           string // Stable URI, e.g. "urn:uuid:..."
+	Title        string
+	Author       string
+	Summary      string
+	Category     string
+	Published    time.Time
+	Updated      time.Time
+	Acquisitions []Acquisition
+	Cover        *Image
+	Thumbnail    *Image
+}
+
+// Acquisition is a link to download a publication in one format.
+type Acquisition struct {
+	Href   string
+	Type   string
+	Length int64 // Size in bytes
+}
+
+// Image is a cover image of a publication.
+type Image struct {
+	Href   string
+	Type   string
+	Width  int
+	Height int
+}
+
+// IsNavigation reports whether the catalog is a navigation feed rather than
+// an acquisition feed.
+func (c *Catalog) IsNavigation() bool {
+	return len(c.Publications) == 0 && len(c.Navigation) > 0
+}
+
+type pageLink struct{ rel, href string }
+
+// Returns the first, previous, next and last links that the page has.
+func (p *Page) links() []pageLink {
+	var links []pageLink
+	for _, l := range []pageLink{
+		{"first", p.First},
+		{"previous", p.Previous},
+		{"next", p.Next},
+		{"last", p.Last},
+	} {
+		if l.href != "" {
+			links = append(links, l)
+		}
+	}
+	return links
+}
@@ -0,0 +1,156 @@
+package opds
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Groups       []jsonGroup       `json:"groups,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified,omitempty"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+type jsonLink struct {
+	Rel        string              `json:"rel,omitempty"`
+	Href       string              `json:"href"`
+	Type       string              `json:"type,omitempty"`
+	Title      string              `json:"title,omitempty"`
+	Width      int                 `json:"width,omitempty"`
+	Height     int                 `json:"height,omitempty"`
+	Properties *jsonLinkProperties `json:"properties,omitempty"`
+}
+
+type jsonLinkProperties struct {
+	NumberOfItems int `json:"numberOfItems"`
+}
+
+type jsonGroup struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Publications []jsonPublication `json:"publications"`
+}
+
+type jsonPublication struct {
+	Metadata jsonPublicationMetadata `json:"metadata"`
+	Links    []jsonLink              `json:"links"`
+	Images   []jsonLink              `json:"images,omitempty"`
+}
+
+type jsonPublicationMetadata struct {
+	Identifier  string            `json:"identifier"`
+	Title       string            `json:"title"`
+	Author      []jsonContributor `json:"author,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Subject     []string          `json:"subject,omitempty"`
+	Published   string            `json:"published,omitempty"`
+	Modified    string            `json:"modified,omitempty"`
+}
+
+type jsonContributor struct {
+	Name string `json:"name"`
+}
+
+// JSON serializes the catalog as an OPDS 2.0 feed.
+func (c *Catalog) JSON() ([]byte, error) {
+	feed := jsonFeed{
+		Metadata: jsonFeedMetadata{Title: c.Title, Modified: jsonTime(c.Updated)},
+		Links: []jsonLink{
+			{Rel: "self", Href: c.Self, Type: JSONType},
+			{Rel: "start", Href: c.Start, Type: JSONType},
+		},
+	}
+	if c.Up != "" {
+		feed.Links = append(feed.Links, jsonLink{Rel: "up", Href: c.Up, Type: JSONType})
+	}
+	if p := c.Page; p != nil {
+		feed.Metadata.NumberOfItems = p.TotalItems
+		feed.Metadata.ItemsPerPage = p.ItemsPerPage
+		feed.Metadata.CurrentPage = p.Number
+		for _, l := range p.links() {
+			feed.Links = append(feed.Links, jsonLink{Rel: l.rel, Href: l.href, Type: JSONType})
+		}
+	}
+
+	for _, nav := range c.Navigation {
+		feed.Navigation = append(feed.Navigation, jsonLink{
+			Rel:        "subsection",
+			Href:       nav.Href,
+			Type:       JSONType,
+			Title:      nav.Title,
+			Properties: &jsonLinkProperties{NumberOfItems: nav.Count},
+		})
+	}
+	for _, group := range c.Groups {
+		g := jsonGroup{
+			Metadata:     jsonFeedMetadata{Title: group.Title, NumberOfItems: group.Count},
+			Links:        []jsonLink{{Rel: "self", Href: group.Href, Type: JSONType}},
+			Publications: []jsonPublication{},
+		}
+		for _, pub := range group.Publications {
+			g.Publications = append(g.Publications, jsonPublicationOf(pub))
+		}
+		feed.Groups = append(feed.Groups, g)
+	}
+	for _, pub := range c.Publications {
+		feed.Publications = append(feed.Publications, jsonPublicationOf(pub))
+	}
+	// A feed needs at least one collection, so an empty acquisition feed
+	// still lists its (empty) publications.
+	if feed.Navigation == nil && feed.Groups == nil && feed.Publications == nil {
+		feed.Publications = []jsonPublication{}
+	}
+
+	data, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OPDS feed: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func jsonPublicationOf(pub Publication) jsonPublication {
+	out := jsonPublication{
+		Metadata: jsonPublicationMetadata{
+			Identifier:  pub.ID,
+			Title:       pub.Title,
+			Description: pub.Summary,
+			Modified:    jsonTime(pub.Updated),
+		},
+	}
+	if pub.Author != "" {
+		out.Metadata.Author = []jsonContributor{{Name: pub.Author}}
+	}
+	if pub.Category != "" {
+		out.Metadata.Subject = []string{pub.Category}
+	}
+	if !pub.Published.IsZero() {
+		out.Metadata.Published = jsonTime(pub.Published)
+	}
+	for _, acq := range pub.Acquisitions {
+		out.Links = append(out.Links, jsonLink{Rel: RelAcquisition, Href: acq.Href, Type: acq.Type})
+	}
+	for _, img := range []*Image{pub.Cover, pub.Thumbnail} {
+		if img != nil {
+			out.Images = append(out.Images, jsonLink{Href: img.Href, Type: img.Type, Width: img.Width, Height: img.Height})
+		}
+	}
+	return out
+}
+
+func jsonTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
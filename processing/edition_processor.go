@@ -2,7 +2,9 @@ package processing
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
@@ -13,6 +15,7 @@ import (
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/ebook"
 	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
 	"github.com/google/uuid"
 )
 
@@ -23,35 +26,64 @@ type EditionProcessor struct {
 	ReadingRepo         *datastore.ReadingRepository
 	DeliveryRepo        *datastore.DeliveryRepository
 	EditionTemplateRepo *datastore.EditionTemplateRepository
-	Generator           *ebook.EditionGenerator
+	EditionFileRepo     *datastore.EditionFileRepository
+	Blobs               storage.BlobStore // Holds reading content and images; generated editions are kept here for delivery
+	renderers           map[models.EditionFormat]ebook.Renderer
 }
 
-// NewEditionProcessor creates a new EditionProcessor.
+// NewEditionProcessor creates a new EditionProcessor. Each renderer is
+// registered under the format it produces; a later renderer for the same
+// format replaces an earlier one.
 func NewEditionProcessor(
 	editionRepo *datastore.EditionRepository,
 	readingRepo *datastore.ReadingRepository,
 	deliveryRepo *datastore.DeliveryRepository,
 	editionTemplateRepo *datastore.EditionTemplateRepository,
-	generator *ebook.EditionGenerator,
+	editionFileRepo *datastore.EditionFileRepository,
+	blobs storage.BlobStore,
+	renderers ...ebook.Renderer,
 ) *EditionProcessor {
+	rendererMap := make(map[models.EditionFormat]ebook.Renderer, len(renderers))
+	for _, r := range renderers {
+		rendererMap[r.Format()] = r
+	}
 	return &EditionProcessor{
 		EditionRepo:         editionRepo,
 		ReadingRepo:         readingRepo,
 		DeliveryRepo:        deliveryRepo,
 		EditionTemplateRepo: editionTemplateRepo,
-		Generator:           generator,
+		EditionFileRepo:     editionFileRepo,
+		Blobs:               blobs,
+		renderers:           rendererMap,
 	}
 }
 
-// ProcessAndGenerateEdition fetches an edition's content, generates the ebook,
-// and creates a pending delivery record.
+// SupportsFormat reports whether a renderer is registered for format.
+func (ep *EditionProcessor) SupportsFormat(format models.EditionFormat) bool {
+	_, ok := ep.renderers[format]
+	return ok
+}
+
+// ProcessAndGenerateEdition fetches an edition's content, generates the ebook
+// with the renderer registered for targetFormat, and creates a pending delivery
+// record for each destination. The ebook is generated once and shared by the
+// deliveries, which are sent and retried independently. Returns an
+// *ebook.UnsupportedFormatError if no renderer is registered.
 func (ep *EditionProcessor) ProcessAndGenerateEdition(
 	ctx context.Context,
 	editionID string,
 	targetFormat models.EditionFormat,
-	deliveryDestinationID string,
+	deliveryDestinationIDs []string,
 	colorImages bool,
-) (*models.Delivery, error) {
+) ([]models.Delivery, error) {
+	if len(deliveryDestinationIDs) == 0 {
+		return nil, fmt.Errorf("no delivery destinations given for edition %s", editionID)
+	}
+	renderer, ok := ep.renderers[targetFormat]
+	if !ok {
+		return nil, &ebook.UnsupportedFormatError{Format: targetFormat}
+	}
+
 	// 1. Fetch Edition
 	edition, err := ep.EditionRepo.GetEditionByID(ctx, editionID)
 	if err != nil {
@@ -70,6 +102,23 @@ func (ep *EditionProcessor) ProcessAndGenerateEdition(
 		return nil, fmt.Errorf("no readings found for edition %s, cannot generate ebook", editionID)
 	}
 
+	// Content and inline images are only needed for rendering, so they are
+	// loaded here rather than with every reading. A reading whose content
+	// is missing is left out; without images the edition still renders.
+	images, err := ep.ReadingRepo.GetReadingImagesForEdition(ctx, editionID)
+	if err != nil {
+		log.Printf("WARN (EditionProcessor): Failed to fetch inline images for edition %s: %v", editionID, err)
+	}
+	for i := range readings {
+		if err := storage.LoadReadingBody(ctx, ep.Blobs, &readings[i]); err != nil {
+			log.Printf("WARN (EditionProcessor): Leaving reading %s out of edition %s: %v", readings[i].ID, editionID, err)
+		}
+		readings[i].Images = images[readings[i].ID]
+		if err := storage.LoadReadingImages(ctx, ep.Blobs, readings[i].Images); err != nil {
+			log.Printf("WARN (EditionProcessor): Failed to load inline images for edition %s: %v", editionID, err)
+		}
+	}
+
 	// 3. Prepare EditionMetadata
 	var authors []string
 	authorSet := make(map[string]struct{})
@@ -92,15 +141,19 @@ func (ep *EditionProcessor) ProcessAndGenerateEdition(
 		Date:   edition.CreatedAt.Format("January 2, 2006"),
 	}
 
-	// 4. Generate the EPUB
-	outputDir := os.TempDir()
-	log.Printf("INFO (EditionProcessor): Generating edition %s (%s) with %d readings", edition.ID, edition.Name, len(readings))
+	// 4. Generate the ebook. Renderers write to a local directory; the file
+	// is then moved into the blob store, where delivery reads it from.
+	outputDir, err := os.MkdirTemp("", "logos-edition-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output directory for edition %s: %w", editionID, err)
+	}
+	defer os.RemoveAll(outputDir)
+	log.Printf("INFO (EditionProcessor): Generating %s edition %s (%s) with %d readings", targetFormat, edition.ID, edition.Name, len(readings))
 
-	generatedFilePath, fileSize, genErr := ep.Generator.GenerateEdition(
+	generatedFilePath, _, genErr := renderer.Render(
 		ctx,
 		readings,
 		metadata,
-		targetFormat,
 		outputDir,
 		edition.ID,
 		colorImages,
@@ -109,24 +162,61 @@ func (ep *EditionProcessor) ProcessAndGenerateEdition(
 		return nil, fmt.Errorf("failed to generate ebook for edition %s: %w", editionID, genErr)
 	}
 
-	// 5. Create Delivery record
-	newDelivery := models.Delivery{
-		ID:                    uuid.NewString(),
-		EditionID:             editionID,
-		DeliveryDestinationID: deliveryDestinationID,
-		CreatedAt:             time.Now().UTC(),
-		Format:                targetFormat,
-		FilePath:              generatedFilePath,
-		FileSize:              int(fileSize),
-		Status:                models.DeliveryStatusPending,
+	fileBytes, err := os.ReadFile(generatedFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated ebook for edition %s: %w", editionID, err)
 	}
-
-	err = ep.DeliveryRepo.CreateDelivery(ctx, &newDelivery)
+	editionFile, err := ep.storeEditionFile(ctx, edition, targetFormat, fileBytes)
 	if err != nil {
-		log.Printf("ERROR (EditionProcessor): Generated ebook for edition %s at %s, but failed to create delivery record: %v", editionID, generatedFilePath, err)
-		return nil, fmt.Errorf("failed to create delivery record for edition %s after generation: %w", editionID, err)
+		return nil, err
+	}
+	fileKey := editionFile.StorageKey
+
+	// 5. Create a Delivery record per destination
+	deliveries := make([]models.Delivery, 0, len(deliveryDestinationIDs))
+	for _, destinationID := range deliveryDestinationIDs {
+		newDelivery := models.Delivery{
+			ID:                    uuid.NewString(),
+			EditionID:             editionID,
+			DeliveryDestinationID: destinationID,
+			CreatedAt:             time.Now().UTC(),
+			Format:                targetFormat,
+			FilePath:              fileKey,
+			FileSize:              len(fileBytes),
+			Status:                models.DeliveryStatusPending,
+		}
+
+		err = ep.DeliveryRepo.CreateDelivery(ctx, &newDelivery)
+		if err != nil {
+			log.Printf("ERROR (EditionProcessor): Stored ebook for edition %s at %s, but failed to create delivery record for destination %s: %v", editionID, fileKey, destinationID, err)
+			return nil, fmt.Errorf("failed to create delivery record for edition %s after generation: %w", editionID, err)
+		}
+		deliveries = append(deliveries, newDelivery)
 	}
 
-	log.Printf("INFO (EditionProcessor): Successfully processed edition %s. Ebook: %s, Delivery pending: %s", editionID, generatedFilePath, newDelivery.ID)
-	return &newDelivery, nil
+	log.Printf("INFO (EditionProcessor): Successfully processed edition %s. Ebook: %s, Deliveries pending: %d", editionID, fileKey, len(deliveries))
+	return deliveries, nil
+}
+
+// Stores a generated ebook in the blob store and records it in the edition's
+// file history.
+func (ep *EditionProcessor) storeEditionFile(ctx context.Context, edition *models.Edition, format models.EditionFormat, data []byte) (*models.EditionFile, error) {
+	sum := sha256.Sum256(data)
+	file := models.EditionFile{
+		ID:          uuid.NewString(),
+		EditionID:   edition.ID,
+		CreatedAt:   time.Now().UTC(),
+		Format:      format,
+		FileSize:    len(data),
+		ContentHash: hex.EncodeToString(sum[:]),
+	}
+	file.StorageKey = storage.EditionFileKey(edition.UserID, edition.ID, file.ID, format)
+
+	if err := ep.Blobs.Put(ctx, file.StorageKey, data, format.ContentType()); err != nil {
+		return nil, fmt.Errorf("failed to store ebook for edition %s: %w", edition.ID, err)
+	}
+	if err := ep.EditionFileRepo.CreateEditionFile(ctx, &file); err != nil {
+		return nil, fmt.Errorf("failed to record ebook file for edition %s: %w", edition.ID, err)
+	}
+	return &file, nil
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/coreybb/logos/conversion"
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ingestion"
+	"github.com/coreybb/logos/storage"
+)
+
+const reprocessUsage = "usage: logos reprocess [-reading id] [-source id] [-since date] [-until date] [-dry-run]"
+
+// runReprocessCommand implements the "logos reprocess" subcommand, which
+// rebuilds readings from their archived emails with the current ingestion
+// pipeline, against the database named by DB_CONNECTION_STRING.
+func runReprocessCommand(args []string) error {
+	flags := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	readingID := flags.String("reading", "", "reprocess the reading with this ID")
+	sourceID := flags.String("source", "", "reprocess the readings from this source")
+	since := flags.String("since", "", "reprocess readings created at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := flags.String("until", "", "reprocess readings created before this date (YYYY-MM-DD or RFC 3339)")
+	dryRun := flags.Bool("dry-run", false, "show what would change without saving")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errors.New(reprocessUsage)
+	}
+
+	selection := datastore.ReadingSelection{ReadingID: *readingID, SourceID: *sourceID}
+	var err error
+	if selection.Since, err = parseReprocessDate(*since); err != nil {
+		return err
+	}
+	if selection.Until, err = parseReprocessDate(*until); err != nil {
+		return err
+	}
+	if selection.IsEmpty() {
+		return errors.New(reprocessUsage)
+	}
+
+	db, err := setupDatabase(databaseURLFromEnv(), false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	converter, err := conversion.NewConverter(conversionBackendFromEnv())
+	if err != nil {
+		return err
+	}
+	blobs, err := storage.New(blobStoreConfigFromEnv())
+	if err != nil {
+		return err
+	}
+	sourceRepo := datastore.NewSourceRepository(db)
+	orchestrator := ingestion.NewIngestionOrchestrator(
+		datastore.NewReadingRepository(db),
+		sourceRepo,
+		datastore.NewRawEmailRepository(db),
+		blobs,
+		ingestion.NewContentPipelineService(converter, ingestion.NewContentProcessor()),
+		ingestion.NewReadingBuilder(sourceRepo),
+	)
+
+	report, err := orchestrator.ReprocessReadings(context.Background(), selection, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range report.Results {
+		fmt.Printf("%s\t%s\t%s\n", result.ReadingID, result.Status, result.Title)
+		if result.Reason != "" {
+			fmt.Printf("  %s\n", result.Reason)
+		}
+		for _, change := range result.Changes {
+			fmt.Printf("  %s: %q -> %q\n", change.Field, change.Before, change.After)
+		}
+		if result.BodyDiff != "" {
+			fmt.Print(result.BodyDiff)
+		}
+	}
+
+	counts := report.Counts()
+	if report.DryRun {
+		fmt.Printf("Dry run: %d reading(s) would change, %d unchanged, %d skipped, %d failed\n",
+			counts[ingestion.ReprocessStatusChanged], counts[ingestion.ReprocessStatusUnchanged], counts[ingestion.ReprocessStatusSkipped], counts[ingestion.ReprocessStatusFailed])
+	} else {
+		fmt.Printf("Updated %d reading(s), %d unchanged, %d skipped, %d failed\n",
+			counts[ingestion.ReprocessStatusUpdated], counts[ingestion.ReprocessStatusUnchanged], counts[ingestion.ReprocessStatusSkipped], counts[ingestion.ReprocessStatusFailed])
+	}
+	return nil
+}
+
+// Parses a date given as YYYY-MM-DD (midnight UTC) or RFC 3339. Returns nil
+// for "".
+func parseReprocessDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q: expected YYYY-MM-DD or RFC 3339", value)
+}
@@ -0,0 +1,77 @@
+package routehandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ingestion"
+	"github.com/coreybb/logos/webutil"
+	"github.com/google/uuid"
+)
+
+// AdminHandler holds dependencies for operator maintenance endpoints. Its
+// routes act across users and must be guarded by the admin secret.
+type AdminHandler struct {
+	Orchestrator *ingestion.IngestionOrchestrator
+}
+
+// NewAdminHandler creates a new AdminHandler.
+func NewAdminHandler(orchestrator *ingestion.IngestionOrchestrator) *AdminHandler {
+	return &AdminHandler{Orchestrator: orchestrator}
+}
+
+// reprocessRequest selects the readings to reprocess: one reading, a
+// source's readings, or readings created in a time range. Criteria combine.
+type reprocessRequest struct {
+	ReadingID string     `json:"reading_id"`
+	SourceID  string     `json:"source_id"`
+	Since     *time.Time `json:"since"`
+	Until     *time.Time `json:"until"`
+	DryRun    bool       `json:"dry_run"`
+}
+
+// HandleReprocess rebuilds the selected readings from their archived emails
+// with the current ingestion pipeline. With dry_run, nothing is saved and the
+// response shows what would change.
+func (h *AdminHandler) HandleReprocess(w http.ResponseWriter, r *http.Request) error {
+	var req reprocessRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+	}
+	defer r.Body.Close()
+
+	selection := datastore.ReadingSelection{
+		ReadingID: req.ReadingID,
+		SourceID:  req.SourceID,
+		Since:     req.Since,
+		Until:     req.Until,
+	}
+	if selection.IsEmpty() {
+		return webutil.ErrBadRequest("At least one of reading_id, source_id, since or until is required")
+	}
+	if req.ReadingID != "" {
+		if _, err := uuid.Parse(req.ReadingID); err != nil {
+			return webutil.ErrBadRequest("Invalid reading_id format")
+		}
+	}
+	if req.SourceID != "" {
+		if _, err := uuid.Parse(req.SourceID); err != nil {
+			return webutil.ErrBadRequest("Invalid source_id format")
+		}
+	}
+	if req.Since != nil && req.Until != nil && !req.Since.Before(*req.Until) {
+		return webutil.ErrBadRequest("since must be before until")
+	}
+
+	report, err := h.Orchestrator.ReprocessReadings(r.Context(), selection, req.DryRun)
+	if err != nil {
+		return fmt.Errorf("failed to reprocess readings: %w", err)
+	}
+	webutil.RespondWithJSON(w, http.StatusOK, report)
+	return nil
+}
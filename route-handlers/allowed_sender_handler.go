@@ -2,27 +2,44 @@ package routehandlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ingestion"
 	"github.com/coreybb/logos/models"
 	"github.com/coreybb/logos/webutil"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
-// AllowedSenderHandler holds dependencies for managing a user's allowed sender whitelist.
+// AllowedSenderHandler holds dependencies for managing a user's allowed sender whitelist,
+// ingestion policy, and the emails held back by that policy.
 type AllowedSenderHandler struct {
-	Repo *datastore.AllowedSenderRepository
+	Repo          *datastore.AllowedSenderRepository
+	UserRepo      *datastore.UserRepository
+	HeldEmailRepo *datastore.HeldEmailRepository
+	Orchestrator  *ingestion.IngestionOrchestrator
 }
 
 // NewAllowedSenderHandler creates a new AllowedSenderHandler.
-func NewAllowedSenderHandler(repo *datastore.AllowedSenderRepository) *AllowedSenderHandler {
-	return &AllowedSenderHandler{Repo: repo}
+func NewAllowedSenderHandler(
+	repo *datastore.AllowedSenderRepository,
+	userRepo *datastore.UserRepository,
+	heldEmailRepo *datastore.HeldEmailRepository,
+	orchestrator *ingestion.IngestionOrchestrator,
+) *AllowedSenderHandler {
+	return &AllowedSenderHandler{
+		Repo:          repo,
+		UserRepo:      userRepo,
+		HeldEmailRepo: heldEmailRepo,
+		Orchestrator:  orchestrator,
+	}
 }
 
 type createAllowedSenderRequest struct {
@@ -30,6 +47,14 @@ type createAllowedSenderRequest struct {
 	EmailPattern string `json:"email_pattern"`
 }
 
+type ingestionPolicyPayload struct {
+	IngestionPolicy string `json:"ingestion_policy"`
+}
+
+type releaseHeldEmailRequest struct {
+	AllowSender bool `json:"allow_sender"` // Also add the sender to the allowed sender list
+}
+
 // HandleCreateAllowedSender adds a new allowed sender rule for a user.
 // Example route: POST /api/users/{userID}/allowed-senders
 func (h *AllowedSenderHandler) HandleCreateAllowedSender(w http.ResponseWriter, r *http.Request) error {
@@ -37,6 +62,9 @@ func (h *AllowedSenderHandler) HandleCreateAllowedSender(w http.ResponseWriter,
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid userID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	var req createAllowedSenderRequest
 	decoder := json.NewDecoder(r.Body)
@@ -84,6 +112,9 @@ func (h *AllowedSenderHandler) HandleDeleteAllowedSender(w http.ResponseWriter,
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid userID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 	if _, err := uuid.Parse(senderID); err != nil {
 		return webutil.ErrBadRequest("Invalid allowed sender ID format in path")
 	}
@@ -110,6 +141,9 @@ func (h *AllowedSenderHandler) HandleGetAllowedSenders(w http.ResponseWriter, r
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid userID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	senders, err := h.Repo.GetAllowedSendersByUserID(r.Context(), userID)
 	if err != nil {
@@ -120,3 +154,172 @@ func (h *AllowedSenderHandler) HandleGetAllowedSenders(w http.ResponseWriter, r
 	webutil.RespondWithJSON(w, http.StatusOK, senders)
 	return nil
 }
+
+// HandleGetIngestionPolicy returns the user's ingestion policy.
+// Example route: GET /api/users/{userID}/allowed-senders/policy
+func (h *AllowedSenderHandler) HandleGetIngestionPolicy(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	policy, err := h.UserRepo.GetIngestionPolicy(r.Context(), userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "user not found") {
+			return webutil.ErrNotFound("User not found.")
+		}
+		log.Printf("ERROR: Failed to get ingestion policy for user %s: %v", userID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve ingestion policy", err)
+	}
+
+	webutil.RespondWithJSON(w, http.StatusOK, ingestionPolicyPayload{IngestionPolicy: string(policy)})
+	return nil
+}
+
+// HandleUpdateIngestionPolicy sets the user's ingestion policy.
+// Example route: PUT /api/users/{userID}/allowed-senders/policy
+func (h *AllowedSenderHandler) HandleUpdateIngestionPolicy(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	var req ingestionPolicyPayload
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+	}
+	defer r.Body.Close()
+
+	policy, ok := models.IsValidIngestionPolicy(strings.ToLower(req.IngestionPolicy))
+	if !ok {
+		return webutil.ErrBadRequest(fmt.Sprintf("Invalid ingestion_policy value. Must be one of: %s, %s, %s",
+			models.IngestionPolicyOpen, models.IngestionPolicyAllowlist, models.IngestionPolicyQuarantine))
+	}
+
+	err := h.UserRepo.UpdateIngestionPolicy(r.Context(), userID, policy)
+	if err != nil {
+		if strings.Contains(err.Error(), "user not found") {
+			return webutil.ErrNotFound("User not found.")
+		}
+		log.Printf("ERROR: Failed to update ingestion policy for user %s: %v", userID, err)
+		return webutil.ErrInternalServerWrap("Failed to update ingestion policy", err)
+	}
+
+	log.Printf("INFO: Ingestion policy for user %s set to %s", userID, policy)
+	webutil.RespondWithJSON(w, http.StatusOK, ingestionPolicyPayload{IngestionPolicy: string(policy)})
+	return nil
+}
+
+// HandleGetHeldEmails lists emails rejected or quarantined by the user's ingestion policy.
+// An optional status query parameter filters by rejected, quarantined or released.
+// Example route: GET /api/users/{userID}/allowed-senders/held?status=quarantined
+func (h *AllowedSenderHandler) HandleGetHeldEmails(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	// Without a filter, held emails in every status are listed
+	var status models.HeldEmailStatus
+	if statusStr := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status"))); statusStr != "" {
+		validStatus, ok := models.IsValidHeldEmailStatus(statusStr)
+		if !ok {
+			return webutil.ErrBadRequest(fmt.Sprintf("Invalid status value. Must be one of: %s, %s, %s",
+				models.HeldEmailStatusRejected, models.HeldEmailStatusQuarantined, models.HeldEmailStatusReleased))
+		}
+		status = validStatus
+	}
+
+	heldEmails, err := h.HeldEmailRepo.GetHeldEmailsByUserID(r.Context(), userID, status)
+	if err != nil {
+		log.Printf("ERROR: Failed to get held emails for user %s: %v", userID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve held emails", err)
+	}
+
+	webutil.RespondWithJSON(w, http.StatusOK, heldEmails)
+	return nil
+}
+
+// HandleReleaseHeldEmail runs a held email through the ingestion pipeline and marks it released.
+// If allow_sender is set in the body, the sender is also added to the allowed sender list.
+// Example route: POST /api/users/{userID}/allowed-senders/held/{heldEmailID}/release
+func (h *AllowedSenderHandler) HandleReleaseHeldEmail(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	heldEmailID := chi.URLParam(r, "heldEmailID")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+	if _, err := uuid.Parse(heldEmailID); err != nil {
+		return webutil.ErrBadRequest("Invalid held email ID format in path")
+	}
+
+	var req releaseHeldEmailRequest
+	if r.ContentLength > 0 {
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+		}
+		defer r.Body.Close()
+	}
+
+	held, err := h.HeldEmailRepo.GetHeldEmailByID(r.Context(), heldEmailID, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "held email not found") {
+			return webutil.ErrNotFound("Held email not found.")
+		}
+		log.Printf("ERROR: Failed to get held email %s for user %s: %v", heldEmailID, userID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve held email", err)
+	}
+	if held.Status == models.HeldEmailStatusReleased {
+		return webutil.ErrConflict("Held email has already been released.")
+	}
+
+	if err := h.Orchestrator.ProcessRawEmail(r.Context(), userID, held.SenderEmail, held.Subject, held.RawMIME); err != nil {
+		log.Printf("ERROR: Failed to ingest released email %s for user %s: %v", heldEmailID, userID, err)
+		return webutil.ErrInternalServerWrap("Failed to ingest released email", err)
+	}
+
+	releasedAt := time.Now().UTC()
+	if err := h.HeldEmailRepo.MarkHeldEmailReleased(r.Context(), heldEmailID, userID, releasedAt); err != nil {
+		log.Printf("ERROR: Ingested held email %s for user %s but failed to mark it released: %v", heldEmailID, userID, err)
+		return webutil.ErrInternalServerWrap("Failed to mark held email as released", err)
+	}
+	held.Status = models.HeldEmailStatusReleased
+	held.ReleasedAt = &releasedAt
+
+	if req.AllowSender {
+		sender := models.AllowedSender{
+			ID:           uuid.NewString(),
+			UserID:       userID,
+			CreatedAt:    releasedAt,
+			Name:         held.SenderEmail,
+			EmailPattern: strings.ToLower(held.SenderEmail),
+		}
+		if err := h.Repo.CreateAllowedSender(r.Context(), &sender); err != nil {
+			// The email itself was released; failing to allowlist the sender is not fatal.
+			log.Printf("WARN: Released held email %s but failed to allow sender %s for user %s: %v", heldEmailID, held.SenderEmail, userID, err)
+		} else {
+			log.Printf("INFO: Allowed sender created for user %s on release: %s", userID, sender.EmailPattern)
+		}
+	}
+
+	log.Printf("INFO: Held email %s released for user %s (sender %s)", heldEmailID, userID, held.SenderEmail)
+	webutil.RespondWithJSON(w, http.StatusOK, held)
+	return nil
+}
@@ -0,0 +1,150 @@
+package routehandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/webutil"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// apiTokenBytes is the amount of randomness in a generated API token
+// (64 hex characters).
+const apiTokenBytes = 32
+
+// APITokenHandler holds dependencies for managing a user's API tokens.
+type APITokenHandler struct {
+	Repo *datastore.APITokenRepository
+}
+
+// NewAPITokenHandler creates a new APITokenHandler.
+func NewAPITokenHandler(repo *datastore.APITokenRepository) *APITokenHandler {
+	return &APITokenHandler{Repo: repo}
+}
+
+type createAPITokenRequest struct {
+	Name string `json:"name"`
+}
+
+// apiTokenResponse includes the plaintext token, which is only available in
+// the response that created it.
+type apiTokenResponse struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+// newAPIToken generates a token for a user and returns the record to store
+// alongside the plaintext to hand back to the client.
+func newAPIToken(userID, name string) (*models.APIToken, string, error) {
+	plaintext, err := webutil.GenerateRandomToken(apiTokenBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	tokenHash, err := webutil.GenerateHash(plaintext)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to hash API token: %w", err)
+	}
+	token := &models.APIToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+		Name:      name,
+		TokenHash: tokenHash,
+	}
+	return token, plaintext, nil
+}
+
+// HandleCreateAPIToken issues a new API token for a user.
+// Example route: POST /api/users/{userID}/tokens
+func (h *APITokenHandler) HandleCreateAPIToken(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	var req createAPITokenRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+	}
+	defer r.Body.Close()
+
+	if strings.TrimSpace(req.Name) == "" {
+		return webutil.ErrBadRequest("Name is required")
+	}
+
+	token, plaintext, err := newAPIToken(userID, req.Name)
+	if err != nil {
+		return webutil.ErrInternalServerWrap("Failed to generate API token", err)
+	}
+	if err := h.Repo.CreateAPIToken(r.Context(), token); err != nil {
+		log.Printf("ERROR: Failed to create API token for user %s: %v", userID, err)
+		return webutil.ErrInternalServerWrap("Failed to create API token", err)
+	}
+
+	log.Printf("INFO: API token created for user %s: ID=%s, Name=%s", userID, token.ID, token.Name)
+	webutil.RespondWithJSON(w, http.StatusCreated, apiTokenResponse{APIToken: *token, Token: plaintext})
+	return nil
+}
+
+// HandleGetAPITokens lists a user's API tokens without their secrets.
+// Example route: GET /api/users/{userID}/tokens
+func (h *APITokenHandler) HandleGetAPITokens(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	tokens, err := h.Repo.GetAPITokensByUserID(r.Context(), userID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get API tokens for user %s: %v", userID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve API tokens", err)
+	}
+
+	webutil.RespondWithJSON(w, http.StatusOK, tokens)
+	return nil
+}
+
+// HandleDeleteAPIToken revokes one of a user's API tokens.
+// Example route: DELETE /api/users/{userID}/tokens/{id}
+func (h *APITokenHandler) HandleDeleteAPIToken(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	tokenID := chi.URLParam(r, "id")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid userID format in path")
+	}
+	if _, err := uuid.Parse(tokenID); err != nil {
+		return webutil.ErrBadRequest("Invalid API token ID format in path")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	err := h.Repo.DeleteAPIToken(r.Context(), tokenID, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "API token not found") {
+			return webutil.ErrNotFound("API token not found.")
+		}
+		log.Printf("ERROR: Failed to delete API token %s for user %s: %v", tokenID, userID, err)
+		return webutil.ErrInternalServerWrap("Failed to delete API token", err)
+	}
+
+	log.Printf("INFO: API token %s revoked for user %s", tokenID, userID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
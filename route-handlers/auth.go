@@ -0,0 +1,42 @@
+package routehandlers
+
+import (
+	"net/http"
+
+	"github.com/coreybb/logos/webutil"
+)
+
+// authenticatedUserID returns the ID of the user the request was
+// authenticated as by the API token middleware.
+func authenticatedUserID(r *http.Request) (string, error) {
+	userID, ok := webutil.AuthenticatedUserID(r.Context())
+	if !ok {
+		return "", webutil.ErrUnauthorized("Authentication required")
+	}
+	return userID, nil
+}
+
+// authorizeUser checks that userID, taken from a path, query or body, belongs
+// to the authenticated user.
+func authorizeUser(r *http.Request, userID string) error {
+	authUserID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
+	if userID != authUserID {
+		return webutil.ErrForbidden("You do not have access to this user's resources")
+	}
+	return nil
+}
+
+// resolveUserID returns the user a request acts on: the authenticated user
+// when userID is empty, otherwise userID once it is authorized.
+func resolveUserID(r *http.Request, userID string) (string, error) {
+	if userID == "" {
+		return authenticatedUserID(r)
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/delivery"
 	"github.com/coreybb/logos/models"
 	"github.com/coreybb/logos/webutil"
 	"github.com/go-chi/chi/v5"
@@ -17,11 +19,14 @@ import (
 )
 
 type DeliveryHandler struct {
-	Repo *datastore.DeliveryRepository
+	Repo            *datastore.DeliveryRepository
+	EditionRepo     *datastore.EditionRepository
+	DestinationRepo *datastore.DestinationRepository
+	DeliveryService *delivery.DeliveryService
 }
 
-func NewDeliveryHandler(repo *datastore.DeliveryRepository) *DeliveryHandler {
-	return &DeliveryHandler{Repo: repo}
+func NewDeliveryHandler(repo *datastore.DeliveryRepository, editionRepo *datastore.EditionRepository, destinationRepo *datastore.DestinationRepository, deliveryService *delivery.DeliveryService) *DeliveryHandler {
+	return &DeliveryHandler{Repo: repo, EditionRepo: editionRepo, DestinationRepo: destinationRepo, DeliveryService: deliveryService}
 }
 
 type createDeliveryRequest struct {
@@ -71,6 +76,19 @@ func (h *DeliveryHandler) HandleCreateDelivery(w http.ResponseWriter, r *http.Re
 	if _, err := uuid.Parse(req.DeliveryDestinationID); err != nil {
 		return webutil.ErrBadRequest("Invalid delivery_destination_id format")
 	}
+	if err := h.authorizeEdition(r, req.EditionID); err != nil {
+		return err
+	}
+	destination, err := h.DestinationRepo.GetDestinationByID(r.Context(), req.DeliveryDestinationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Delivery destination not found")
+		}
+		return fmt.Errorf("failed to retrieve delivery destination %s: %w", req.DeliveryDestinationID, err)
+	}
+	if err := authorizeUser(r, destination.UserID); err != nil {
+		return err
+	}
 
 	newDelivery := models.Delivery{
 		ID:                    uuid.NewString(),
@@ -85,7 +103,7 @@ func (h *DeliveryHandler) HandleCreateDelivery(w http.ResponseWriter, r *http.Re
 		StartedAt:             nil,
 	}
 
-	err := h.Repo.CreateDelivery(r.Context(), &newDelivery)
+	err = h.Repo.CreateDelivery(r.Context(), &newDelivery)
 	if err != nil {
 		return fmt.Errorf("failed to create delivery for edition %s: %w", req.EditionID, err)
 	}
@@ -107,6 +125,9 @@ func (h *DeliveryHandler) HandleGetDelivery(w http.ResponseWriter, r *http.Reque
 		}
 		return fmt.Errorf("failed to retrieve delivery %s: %w", deliveryID, err)
 	}
+	if err := h.authorizeEdition(r, delivery.EditionID); err != nil {
+		return err
+	}
 
 	webutil.RespondWithJSON(w, http.StatusOK, delivery)
 	return nil
@@ -117,6 +138,16 @@ func (h *DeliveryHandler) HandleUpdateDeliveryStatus(w http.ResponseWriter, r *h
 	if _, err := uuid.Parse(deliveryID); err != nil {
 		return webutil.ErrBadRequest("Invalid delivery ID format")
 	}
+	existing, err := h.Repo.GetDeliveryByID(r.Context(), deliveryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || strings.Contains(err.Error(), "delivery not found") {
+			return webutil.ErrNotFound("Delivery not found")
+		}
+		return fmt.Errorf("failed to retrieve delivery %s: %w", deliveryID, err)
+	}
+	if err := h.authorizeEdition(r, existing.EditionID); err != nil {
+		return err
+	}
 
 	var req updateDeliveryStatusRequest
 	decoder := json.NewDecoder(r.Body)
@@ -142,7 +173,7 @@ func (h *DeliveryHandler) HandleUpdateDeliveryStatus(w http.ResponseWriter, r *h
 		completedAt = &now
 	}
 
-	err := h.Repo.UpdateDeliveryStatus(r.Context(), deliveryID, deliveryStatus, startedAt, completedAt)
+	err = h.Repo.UpdateDeliveryStatus(r.Context(), deliveryID, deliveryStatus, startedAt, completedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) || strings.Contains(err.Error(), "not found") {
 			return webutil.ErrNotFound("Delivery not found for status update")
@@ -161,3 +192,51 @@ func (h *DeliveryHandler) HandleUpdateDeliveryStatus(w http.ResponseWriter, r *h
 	webutil.RespondWithJSON(w, http.StatusOK, updatedDelivery)
 	return nil
 }
+
+// HandleRetryDelivery immediately re-attempts a failed, pending or stalled delivery,
+// regardless of its backoff or how many attempts it has used, and responds
+// with the delivery's resulting state.
+func (h *DeliveryHandler) HandleRetryDelivery(w http.ResponseWriter, r *http.Request) error {
+	deliveryID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return webutil.ErrBadRequest("Invalid delivery ID format")
+	}
+	existing, err := h.Repo.GetDeliveryByID(r.Context(), deliveryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Delivery not found")
+		}
+		return fmt.Errorf("failed to retrieve delivery %s: %w", deliveryID, err)
+	}
+	if err := h.authorizeEdition(r, existing.EditionID); err != nil {
+		return err
+	}
+
+	if err := h.DeliveryService.RetryDelivery(r.Context(), existing); err != nil {
+		if errors.Is(err, delivery.ErrDeliveryNotRetryable) {
+			return webutil.ErrConflict(fmt.Sprintf("Delivery cannot be retried while %s or without a generated ebook", existing.Status))
+		}
+		// The failure is recorded on the delivery; report its new state below.
+		log.Printf("WARN (DeliveryHandler): Manual retry of delivery %s failed: %v", deliveryID, err)
+	}
+
+	updated, err := h.Repo.GetDeliveryByID(r.Context(), deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch delivery %s after retry: %w", deliveryID, err)
+	}
+	webutil.RespondWithJSON(w, http.StatusOK, updated)
+	return nil
+}
+
+// authorizeEdition checks that the edition a delivery belongs to is owned by
+// the authenticated user.
+func (h *DeliveryHandler) authorizeEdition(r *http.Request, editionID string) error {
+	edition, err := h.EditionRepo.GetEditionByID(r.Context(), editionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Edition not found")
+		}
+		return fmt.Errorf("failed to retrieve edition %s: %w", editionID, err)
+	}
+	return authorizeUser(r, edition.UserID)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/coreybb/logos/datastore"
@@ -25,7 +26,7 @@ func NewDestinationHandler(repo *datastore.DestinationRepository) *DestinationHa
 }
 
 type createDestinationRequest struct {
-	UserID    string `json:"user_id"` // TODO: Get from auth context
+	UserID    string `json:"user_id,omitempty"` // Defaults to the authenticated user
 	Name      string `json:"name"`
 	Type      string `json:"type"` // email, api, webhook
 	IsDefault bool   `json:"is_default"`
@@ -34,16 +35,36 @@ type createDestinationRequest struct {
 	EmailAddress string `json:"email_address,omitempty"`
 	// ApiEndpoint string `json:"api_endpoint,omitempty"`
 	// ApiKey      string `json:"api_key,omitempty"`
-	// WebhookURL  string `json:"webhook_url,omitempty"`
+	WebhookURL string `json:"webhook_url,omitempty"`
 }
 
 type deliveryDestinationResponse struct {
 	models.DeliveryDestination
 	EmailAddress string `json:"email_address,omitempty"`
 	// ApiEndpoint string `json:"api_endpoint,omitempty"`
-	// WebhookURL  string `json:"webhook_url,omitempty"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// WebhookSecret is only returned when the destination is created.
+	WebhookSecret string `json:"webhook_secret,omitempty"`
 }
 
+// authorizeDestination checks that a delivery destination belongs to the
+// authenticated user.
+func authorizeDestination(r *http.Request, repo *datastore.DestinationRepository, destinationID string) error {
+	dest, err := repo.GetDestinationByID(r.Context(), destinationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Delivery destination not found")
+		}
+		log.Printf("ERROR: Failed to get delivery destination %s: %v", destinationID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve delivery destination", err)
+	}
+	return authorizeUser(r, dest.UserID)
+}
+
+// webhookSecretBytes is the size of generated webhook signing secrets
+// (64 hex characters).
+const webhookSecretBytes = 32
+
 func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *http.Request) error {
 	var req createDestinationRequest
 	decoder := json.NewDecoder(r.Body)
@@ -54,8 +75,9 @@ func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *h
 	defer r.Body.Close()
 
 	// Validation
-	if req.UserID == "" { // TODO: Replace with auth context
-		return webutil.ErrBadRequest("UserID is required")
+	userID, err := resolveUserID(r, req.UserID)
+	if err != nil {
+		return err
 	}
 	if req.Name == "" {
 		return webutil.ErrBadRequest("Destination name is required")
@@ -67,14 +89,14 @@ func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *h
 
 	baseDest := models.DeliveryDestination{
 		ID:        uuid.NewString(),
-		UserID:    req.UserID, // Use provided for now
+		UserID:    userID,
 		CreatedAt: time.Now().UTC(),
 		IsDefault: req.IsDefault,
 		Name:      req.Name,
 		Type:      req.Type,
 	}
 
-	var err error
+	var webhook models.WebhookDestination
 	switch req.Type {
 	case "email":
 		if req.EmailAddress == "" {
@@ -85,8 +107,16 @@ func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *h
 		// err = h.Repo.CreateApiDestination(r.Context(), &baseDest, req.ApiEndpoint, req.ApiKey)
 		err = errors.New("API destination type not yet implemented") // Placeholder
 	case "webhook":
-		// err = h.Repo.CreateWebhookDestination(r.Context(), &baseDest, req.WebhookURL)
-		err = errors.New("Webhook destination type not yet implemented") // Placeholder
+		webhookURL, parseErr := url.Parse(req.WebhookURL)
+		if parseErr != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return webutil.ErrBadRequest("webhook_url must be an http(s) URL for type 'webhook'")
+		}
+		secret, tokenErr := webutil.GenerateRandomToken(webhookSecretBytes)
+		if tokenErr != nil {
+			return webutil.ErrInternalServerWrap("Failed to generate webhook secret", tokenErr)
+		}
+		webhook = models.WebhookDestination{URL: webhookURL.String(), Secret: secret}
+		err = h.Repo.CreateWebhookDestination(r.Context(), &baseDest, &webhook)
 	default:
 		// Should be caught by validation, but belts and suspenders
 		err = fmt.Errorf("unhandled destination type: %s", req.Type)
@@ -100,7 +130,7 @@ func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *h
 			return webutil.ErrBadRequestWrap("Failed to create destination due to invalid reference", err)
 		}
 		// Check if it's one of the placeholder errors
-		if err.Error() == "API destination type not yet implemented" || err.Error() == "Webhook destination type not yet implemented" {
+		if err.Error() == "API destination type not yet implemented" {
 			return webutil.NewHTTPErrorWrap(http.StatusNotImplemented, "Destination type not yet implemented", err)
 		}
 		// Generic internal error
@@ -112,9 +142,14 @@ func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *h
 		DeliveryDestination: baseDest, // Start with base info
 	}
 	// Add type-specific info based on request
-	if req.Type == "email" {
+	switch req.Type {
+	case "email":
 		responsePayload.EmailAddress = req.EmailAddress
-	} // Add cases for other types
+	case "webhook":
+		// The secret is shown once so the receiver can verify signatures.
+		responsePayload.WebhookURL = webhook.URL
+		responsePayload.WebhookSecret = webhook.Secret
+	}
 
 	log.Printf("INFO: Destination created: ID=%s, Name=%s, Type=%s", baseDest.ID, baseDest.Name, baseDest.Type)
 	webutil.RespondWithJSON(w, http.StatusCreated, responsePayload)
@@ -122,9 +157,9 @@ func (h *DestinationHandler) HandleCreateDestination(w http.ResponseWriter, r *h
 }
 
 func (h *DestinationHandler) HandleGetDestinations(w http.ResponseWriter, r *http.Request) error {
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		return webutil.ErrBadRequest("user_id query parameter is required")
+	userID, err := resolveUserID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		return err
 	}
 
 	destinations, err := h.Repo.GetDestinationsByUserID(r.Context(), userID)
@@ -144,30 +179,37 @@ func (h *DestinationHandler) HandleGetDestinationByID(w http.ResponseWriter, r *
 		return webutil.ErrBadRequest("Invalid destination ID format")
 	}
 
-	// Need to determine the type to call the correct repo method.
-	// Option 1: Fetch base first, then fetch details (2 queries)
-	// Option 2: Try fetching each type detail until one succeeds (inefficient)
-	// Option 3: Create a repo method that joins dynamically (complex SQL)
-	// Option 4: Return only base info here, have separate endpoints for details?
-
-	// Let's try fetching email details as an example. A real implementation
-	// would need a more robust way to handle multiple types.
-	baseDest, email, err := h.Repo.GetEmailDestinationDetails(r.Context(), destID)
-
+	// Fetch the base record first, then the details for its type.
+	baseDest, err := h.Repo.GetDestinationByID(r.Context(), destID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) || err.Error() == "email destination not found" {
-			// Could try fetching other types here, or just return 404
-			// Assuming for now only email exists, so 404 is appropriate if email lookup fails
+		if errors.Is(err, sql.ErrNoRows) {
 			return webutil.ErrNotFound("Destination not found")
 		}
-		// Otherwise, it's an internal error
-		log.Printf("ERROR: Failed to get destination details for %s: %v", destID, err)
+		log.Printf("ERROR: Failed to get destination %s: %v", destID, err)
 		return webutil.ErrInternalServerWrap("Failed to retrieve destination details", err)
 	}
+	if err := authorizeUser(r, baseDest.UserID); err != nil {
+		return err
+	}
 
 	responsePayload := deliveryDestinationResponse{
 		DeliveryDestination: *baseDest,
-		EmailAddress:        email,
+	}
+	switch baseDest.Type {
+	case "email":
+		_, email, err := h.Repo.GetEmailDestinationDetails(r.Context(), destID)
+		if err != nil {
+			log.Printf("ERROR: Failed to get email destination details for %s: %v", destID, err)
+			return webutil.ErrInternalServerWrap("Failed to retrieve destination details", err)
+		}
+		responsePayload.EmailAddress = email
+	case "webhook":
+		_, webhook, err := h.Repo.GetWebhookDestinationDetails(r.Context(), destID)
+		if err != nil {
+			log.Printf("ERROR: Failed to get webhook destination details for %s: %v", destID, err)
+			return webutil.ErrInternalServerWrap("Failed to retrieve destination details", err)
+		}
+		responsePayload.WebhookURL = webhook.URL
 	}
 
 	webutil.RespondWithJSON(w, http.StatusOK, responsePayload)
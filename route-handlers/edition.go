@@ -13,28 +13,59 @@ import (
 
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/delivery"
+	"github.com/coreybb/logos/ebook"
 	"github.com/coreybb/logos/models"
 	"github.com/coreybb/logos/processing"
+	"github.com/coreybb/logos/storage"
 	"github.com/coreybb/logos/webutil"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 // Dfines the (optional) payload for the generate endpoint.
-// We might want to allow specifying format or destination if not using template defaults.
+// Without overrides, the edition's template decides the format and destinations.
 type generateEditionRequest struct {
 	Format                string `json:"format"`                  // e.g., "mobi", "epub", "pdf" - overrides template's default
-	DeliveryDestinationID string `json:"delivery_destination_id"` // Optional: specific destination
+	DeliveryDestinationID string `json:"delivery_destination_id"` // Optional: deliver only here instead of the template's destinations
 }
 
 type EditionHandler struct {
-	Repo            *datastore.EditionRepository
-	Processor       *processing.EditionProcessor
-	DeliveryService *delivery.DeliveryService
+	Repo                    *datastore.EditionRepository
+	TemplateRepo            *datastore.EditionTemplateRepository
+	TemplateDestinationRepo *datastore.EditionTemplateDestinationRepository
+	ReadingRepo             *datastore.ReadingRepository
+	DestinationRepo         *datastore.DestinationRepository
+	FileRepo                *datastore.EditionFileRepository
+	Blobs                   storage.BlobStore
+	Processor               *processing.EditionProcessor
+	DeliveryService         *delivery.DeliveryService
+	// Signs expiring download links to edition files. Links are disabled
+	// when empty.
+	DownloadLinkSecret []byte
 }
 
-func NewEditionHandler(repo *datastore.EditionRepository, processor *processing.EditionProcessor, deliveryService *delivery.DeliveryService) *EditionHandler {
-	return &EditionHandler{Repo: repo, Processor: processor, DeliveryService: deliveryService}
+func NewEditionHandler(
+	repo *datastore.EditionRepository,
+	templateRepo *datastore.EditionTemplateRepository,
+	templateDestinationRepo *datastore.EditionTemplateDestinationRepository,
+	readingRepo *datastore.ReadingRepository,
+	destinationRepo *datastore.DestinationRepository,
+	fileRepo *datastore.EditionFileRepository,
+	blobs storage.BlobStore,
+	processor *processing.EditionProcessor,
+	deliveryService *delivery.DeliveryService,
+) *EditionHandler {
+	return &EditionHandler{
+		Repo:                    repo,
+		TemplateRepo:            templateRepo,
+		TemplateDestinationRepo: templateDestinationRepo,
+		ReadingRepo:             readingRepo,
+		DestinationRepo:         destinationRepo,
+		FileRepo:                fileRepo,
+		Blobs:                   blobs,
+		Processor:               processor,
+		DeliveryService:         deliveryService,
+	}
 }
 
 type createEditionRequest struct {
@@ -48,12 +79,9 @@ type addReadingToEditionRequest struct {
 }
 
 func (h *EditionHandler) HandleGetEditions(w http.ResponseWriter, r *http.Request) error {
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		return webutil.ErrBadRequest("user_id query parameter is required")
-	}
-	if _, err := uuid.Parse(userID); err != nil {
-		return webutil.ErrBadRequest("Invalid user_id format in query parameter")
+	userID, err := resolveUserID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		return err
 	}
 
 	editions, err := h.Repo.GetEditionsByUserID(r.Context(), userID)
@@ -73,6 +101,10 @@ func (h *EditionHandler) HandleGenerateEditionDocument(w http.ResponseWriter, r
 	if _, err := uuid.Parse(editionID); err != nil {
 		return webutil.ErrBadRequest("Invalid edition ID format")
 	}
+	edition, err := h.authorizeEdition(r, editionID)
+	if err != nil {
+		return err
+	}
 
 	var req generateEditionRequest
 	// Allow empty body for defaults, or body to specify overrides
@@ -88,58 +120,107 @@ func (h *EditionHandler) HandleGenerateEditionDocument(w http.ResponseWriter, r
 		defer r.Body.Close()
 	}
 
-	// For now, we need to determine the target format and destination ID.
-	// This logic would be more complex: fetch edition, then its template, then user's default destination.
-	// Let's use request parameters if provided, otherwise hardcode defaults for now.
+	template, err := h.editionTemplate(r, edition)
+	if err != nil {
+		return err
+	}
 
-	var targetFormat models.EditionFormat
+	// The request may override the template's format. Editions without a
+	// template are generated as EPUB.
+	targetFormat := models.EditionFormatEPUB
+	colorImages := false
+	if template != nil {
+		targetFormat = template.Format
+		colorImages = template.ColorImages
+	}
 	if req.Format != "" {
 		validFormat, ok := models.IsValidEditionFormat(req.Format)
 		if !ok {
 			return webutil.ErrBadRequest(fmt.Sprintf("Invalid format value. Must be one of: %s, %s, %s", models.EditionFormatEPUB, models.EditionFormatMOBI, models.EditionFormatPDF))
 		}
 		targetFormat = validFormat
-	} else {
-		// TODO: Fetch from Edition's template or a default
-		targetFormat = models.EditionFormatMOBI // Default for now
-	}
-
-	deliveryDestinationID := req.DeliveryDestinationID
-	if deliveryDestinationID == "" {
-		// TODO: Fetch user's default destination ID or from template
-		// For now, this will cause an error in ProcessAndGenerateEdition if it requires one.
-		// Or ProcessAndGenerateEdition can have its own default.
-		// Let's assume ProcessAndGenerateEdition needs it.
-		// We'll use a placeholder UUID if not provided.
-		// In a real scenario, you'd fetch the user's default or the template's default.
-		// This is a temporary measure to allow the call to proceed.
-		// A proper implementation would fetch this or make it truly optional in the processor.
-		// Forcing it via request or having a default in processor might be better.
-		// For this handler, let's return an error if not provided, to be explicit.
-		return webutil.ErrBadRequest("delivery_destination_id is required for now if not derived from template/user defaults")
-	}
-	if _, err := uuid.Parse(deliveryDestinationID); err != nil {
-		return webutil.ErrBadRequest("Invalid delivery_destination_id format")
-	}
-
-	generatedDelivery, err := h.Processor.ProcessAndGenerateEdition(r.Context(), editionID, targetFormat, deliveryDestinationID, false)
+	}
+	if !h.Processor.SupportsFormat(targetFormat) {
+		return webutil.ErrBadRequest(fmt.Sprintf("Edition format %q is not supported yet", targetFormat))
+	}
+
+	deliveryDestinationIDs, err := h.deliveryDestinationIDs(r, edition, req.DeliveryDestinationID)
 	if err != nil {
+		return err
+	}
+
+	deliveries, err := h.Processor.ProcessAndGenerateEdition(r.Context(), editionID, targetFormat, deliveryDestinationIDs, colorImages)
+	if err != nil {
+		var unsupportedErr *ebook.UnsupportedFormatError
+		if errors.As(err, &unsupportedErr) {
+			return webutil.ErrBadRequest(unsupportedErr.Error())
+		}
 		if strings.Contains(err.Error(), "not found") {
 			return webutil.ErrNotFound(err.Error())
 		}
 		return fmt.Errorf("failed to process and generate edition %s: %w", editionID, err)
 	}
 
-	// Execute delivery (send the ebook). Non-fatal: the ebook was generated
-	// regardless of whether delivery succeeds.
-	if deliverErr := h.DeliveryService.ExecuteDelivery(r.Context(), generatedDelivery); deliverErr != nil {
-		log.Printf("WARN (EditionHandler): Delivery %s failed after generation: %v", generatedDelivery.ID, deliverErr)
+	// Execute each delivery (send the ebook). Non-fatal: the ebook was
+	// generated regardless of whether delivery succeeds, and failed
+	// deliveries are left to the retrier.
+	for i := range deliveries {
+		if deliverErr := h.DeliveryService.ExecuteDelivery(r.Context(), &deliveries[i]); deliverErr != nil {
+			log.Printf("WARN (EditionHandler): Delivery %s failed after generation: %v", deliveries[i].ID, deliverErr)
+		}
 	}
 
-	webutil.RespondWithJSON(w, http.StatusAccepted, generatedDelivery)
+	webutil.RespondWithJSON(w, http.StatusAccepted, deliveries)
 	return nil
 }
 
+// deliveryDestinationIDs returns the destination named in a generate request,
+// after checking that it belongs to the edition's owner, or else the
+// destinations the edition's template delivers to.
+func (h *EditionHandler) deliveryDestinationIDs(r *http.Request, edition *models.Edition, requestedID string) ([]string, error) {
+	if requestedID == "" {
+		ids, err := h.TemplateDestinationRepo.GetDeliveryDestinationIDs(r.Context(), edition.EditionTemplateID, edition.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve delivery destinations for edition %s: %w", edition.ID, err)
+		}
+		if len(ids) == 0 {
+			return nil, webutil.ErrBadRequest("The edition's template has no delivery destinations and there is no default destination; set delivery_destination_id")
+		}
+		return ids, nil
+	}
+
+	if _, err := uuid.Parse(requestedID); err != nil {
+		return nil, webutil.ErrBadRequest("Invalid delivery_destination_id format")
+	}
+	destination, err := h.DestinationRepo.GetDestinationByID(r.Context(), requestedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webutil.ErrNotFound("Delivery destination not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve delivery destination %s: %w", requestedID, err)
+	}
+	if destination.UserID != edition.UserID {
+		return nil, webutil.ErrForbidden("You do not have access to this delivery destination")
+	}
+	return []string{requestedID}, nil
+}
+
+// editionTemplate loads the template an edition was created from, or returns
+// nil if the edition has none.
+func (h *EditionHandler) editionTemplate(r *http.Request, edition *models.Edition) (*models.EditionTemplate, error) {
+	if edition.EditionTemplateID == "" {
+		return nil, nil
+	}
+	template, err := h.TemplateRepo.GetEditionTemplateByID(r.Context(), edition.EditionTemplateID, edition.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve edition template %s: %w", edition.EditionTemplateID, err)
+	}
+	return template, nil
+}
+
 func (h *EditionHandler) HandleCreateEdition(w http.ResponseWriter, r *http.Request) error {
 	var req createEditionRequest
 	decoder := json.NewDecoder(r.Body)
@@ -153,11 +234,9 @@ func (h *EditionHandler) HandleCreateEdition(w http.ResponseWriter, r *http.Requ
 	if req.Name == "" {
 		return webutil.ErrBadRequest("Edition name is required")
 	}
-	if req.UserID == "" {
-		return webutil.ErrBadRequest("UserID is required")
-	}
-	if _, err := uuid.Parse(req.UserID); err != nil {
-		return webutil.ErrBadRequest("Invalid UserID format")
+	userID, err := resolveUserID(r, req.UserID)
+	if err != nil {
+		return err
 	}
 	if req.EditionTemplateID == "" {
 		return webutil.ErrBadRequest("EditionTemplateID is required")
@@ -165,16 +244,26 @@ func (h *EditionHandler) HandleCreateEdition(w http.ResponseWriter, r *http.Requ
 	if _, err := uuid.Parse(req.EditionTemplateID); err != nil {
 		return webutil.ErrBadRequest("Invalid EditionTemplateID format")
 	}
+	templateOwnerID, err := h.TemplateRepo.GetEditionTemplateOwnerID(r.Context(), req.EditionTemplateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Edition template not found")
+		}
+		return fmt.Errorf("failed to retrieve edition template %s: %w", req.EditionTemplateID, err)
+	}
+	if templateOwnerID != userID {
+		return webutil.ErrForbidden("You do not have access to this edition template")
+	}
 
 	newEdition := models.Edition{
 		ID:                uuid.NewString(),
-		UserID:            req.UserID,
+		UserID:            userID,
 		Name:              req.Name,
 		EditionTemplateID: req.EditionTemplateID,
 		CreatedAt:         time.Now().UTC(),
 	}
 
-	err := h.Repo.CreateEdition(r.Context(), &newEdition, req.EditionTemplateID)
+	err = h.Repo.CreateEdition(r.Context(), &newEdition, req.EditionTemplateID)
 	if err != nil {
 		// TODO: Check for specific DB errors e.g. FK violation for edition_template_id
 		return fmt.Errorf("failed to create edition '%s' for user %s: %w", newEdition.Name, newEdition.UserID, err)
@@ -190,12 +279,9 @@ func (h *EditionHandler) HandleGetEdition(w http.ResponseWriter, r *http.Request
 		return webutil.ErrBadRequest("Invalid edition ID format")
 	}
 
-	edition, err := h.Repo.GetEditionByID(r.Context(), editionID)
+	edition, err := h.authorizeEdition(r, editionID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) || strings.Contains(err.Error(), "edition not found") {
-			return webutil.ErrNotFound("Edition not found")
-		}
-		return fmt.Errorf("failed to retrieve edition %s: %w", editionID, err)
+		return err
 	}
 
 	webutil.RespondWithJSON(w, http.StatusOK, edition)
@@ -208,9 +294,9 @@ func (h *EditionHandler) HandleGetEditionReadings(w http.ResponseWriter, r *http
 		return webutil.ErrBadRequest("Invalid edition ID format")
 	}
 
-	// Optional: Check if edition exists first
-	// _, err := h.Repo.GetEditionByID(r.Context(), editionID)
-	// if err != nil { ... }
+	if _, err := h.authorizeEdition(r, editionID); err != nil {
+		return err
+	}
 
 	readings, err := h.Repo.GetReadingsForEdition(r.Context(), editionID)
 	if err != nil {
@@ -244,7 +330,19 @@ func (h *EditionHandler) HandleAddReadingToEdition(w http.ResponseWriter, r *htt
 		return webutil.ErrBadRequest("Invalid reading_id format")
 	}
 
-	err := h.Repo.AddReadingToEdition(r.Context(), editionID, req.ReadingID)
+	edition, err := h.authorizeEdition(r, editionID)
+	if err != nil {
+		return err
+	}
+	hasReading, err := h.ReadingRepo.UserHasReading(r.Context(), edition.UserID, req.ReadingID)
+	if err != nil {
+		return fmt.Errorf("failed to check access to reading %s: %w", req.ReadingID, err)
+	}
+	if !hasReading {
+		return webutil.ErrForbidden("You do not have access to this reading")
+	}
+
+	err = h.Repo.AddReadingToEdition(r.Context(), editionID, req.ReadingID)
 	if err != nil {
 		// This could be an FK violation if reading_id or edition_id doesn't exist.
 		// The datastore's ON CONFLICT handles duplicates, so that won't error.
@@ -256,3 +354,19 @@ func (h *EditionHandler) HandleAddReadingToEdition(w http.ResponseWriter, r *htt
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
+
+// authorizeEdition loads an edition and checks that it belongs to the
+// authenticated user.
+func (h *EditionHandler) authorizeEdition(r *http.Request, editionID string) (*models.Edition, error) {
+	edition, err := h.Repo.GetEditionByID(r.Context(), editionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || strings.Contains(err.Error(), "edition not found") {
+			return nil, webutil.ErrNotFound("Edition not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve edition %s: %w", editionID, err)
+	}
+	if err := authorizeUser(r, edition.UserID); err != nil {
+		return nil, err
+	}
+	return edition, nil
+}
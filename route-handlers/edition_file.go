@@ -0,0 +1,232 @@
+package routehandlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
+	"github.com/coreybb/logos/webutil"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDownloadLinkTTL = 24 * time.Hour
+	maxDownloadLinkTTL     = 7 * 24 * time.Hour
+
+	// DownloadsPath is where signed download links point. It is served
+	// outside the API, without an API token.
+	DownloadsPath = "/downloads/edition-files"
+)
+
+// Defines the (optional) payload for creating a download link.
+type createDownloadLinkRequest struct {
+	Format    string `json:"format"`     // Link the latest file in this format; any format if empty
+	ExpiresIn int    `json:"expires_in"` // Seconds until the link expires; defaults to a day
+}
+
+// downloadLinkResponse is a signed link to an edition file.
+type downloadLinkResponse struct {
+	URL       string             `json:"url"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	File      models.EditionFile `json:"file"`
+}
+
+// HandleGetEditionFiles lists the files generated for an edition, newest
+// first.
+func (h *EditionHandler) HandleGetEditionFiles(w http.ResponseWriter, r *http.Request) error {
+	editionID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(editionID); err != nil {
+		return webutil.ErrBadRequest("Invalid edition ID format")
+	}
+	if _, err := h.authorizeEdition(r, editionID); err != nil {
+		return err
+	}
+
+	files, err := h.FileRepo.GetEditionFilesByEditionID(r.Context(), editionID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve files for edition %s: %w", editionID, err)
+	}
+	webutil.RespondWithJSON(w, http.StatusOK, files)
+	return nil
+}
+
+// HandleGetEditionFile downloads the latest file generated for an edition, in
+// the format given by the "format" query parameter or, without one, in
+// whichever format was generated last. Range requests and conditional
+// requests against the file's ETag are supported.
+func (h *EditionHandler) HandleGetEditionFile(w http.ResponseWriter, r *http.Request) error {
+	editionID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(editionID); err != nil {
+		return webutil.ErrBadRequest("Invalid edition ID format")
+	}
+	edition, err := h.authorizeEdition(r, editionID)
+	if err != nil {
+		return err
+	}
+
+	file, err := h.latestEditionFile(r, editionID, r.URL.Query().Get("format"))
+	if err != nil {
+		return err
+	}
+	return serveEditionFile(w, r, h.Blobs, edition, file)
+}
+
+// HandleCreateEditionDownloadLink creates an expiring link to the latest file
+// generated for an edition, which can be downloaded without an API token —
+// e.g., to fetch an issue by hand when a Kindle rejects the email.
+func (h *EditionHandler) HandleCreateEditionDownloadLink(w http.ResponseWriter, r *http.Request) error {
+	editionID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(editionID); err != nil {
+		return webutil.ErrBadRequest("Invalid edition ID format")
+	}
+	if _, err := h.authorizeEdition(r, editionID); err != nil {
+		return err
+	}
+	if len(h.DownloadLinkSecret) == 0 {
+		return webutil.ErrNotFound("Download links are not enabled")
+	}
+
+	var req createDownloadLinkRequest
+	// Allow empty body for defaults
+	if r.ContentLength > 0 {
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+		}
+		defer r.Body.Close()
+	}
+
+	ttl := defaultDownloadLinkTTL
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+		if ttl <= 0 || ttl > maxDownloadLinkTTL {
+			return webutil.ErrBadRequest(fmt.Sprintf("expires_in must be between 1 and %d seconds", int(maxDownloadLinkTTL.Seconds())))
+		}
+	}
+
+	file, err := h.latestEditionFile(r, editionID, req.Format)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	link := fmt.Sprintf("%s%s/%s?expires=%s&signature=%s",
+		requestBaseURL(r), DownloadsPath, file.ID, expires, signDownloadLink(h.DownloadLinkSecret, file.ID, expires))
+
+	webutil.RespondWithJSON(w, http.StatusCreated, downloadLinkResponse{URL: link, ExpiresAt: expiresAt, File: *file})
+	return nil
+}
+
+// HandleDownloadEditionFile serves an edition file through a signed download
+// link. It needs no API token; the link's signature and expiry stand in for
+// one.
+func (h *EditionHandler) HandleDownloadEditionFile(w http.ResponseWriter, r *http.Request) error {
+	fileID := chi.URLParam(r, "id")
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+	if _, err := uuid.Parse(fileID); err != nil || len(h.DownloadLinkSecret) == 0 {
+		return webutil.ErrNotFound("Download link not found")
+	}
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(signDownloadLink(h.DownloadLinkSecret, fileID, expires))) {
+		return webutil.ErrForbidden("Invalid download link")
+	}
+	if time.Now().Unix() > expiresUnix {
+		return webutil.ErrForbidden("Download link has expired")
+	}
+
+	file, err := h.FileRepo.GetEditionFileByID(r.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Edition file not found")
+		}
+		return fmt.Errorf("failed to retrieve edition file %s: %w", fileID, err)
+	}
+	edition, err := h.Repo.GetEditionByID(r.Context(), file.EditionID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve edition %s: %w", file.EditionID, err)
+	}
+	return serveEditionFile(w, r, h.Blobs, edition, file)
+}
+
+// Looks up the latest file of an edition in a format given by name, or in any
+// format if the name is empty.
+func (h *EditionHandler) latestEditionFile(r *http.Request, editionID, formatName string) (*models.EditionFile, error) {
+	var format models.EditionFormat
+	if formatName != "" {
+		validFormat, ok := models.IsValidEditionFormat(formatName)
+		if !ok {
+			return nil, webutil.ErrBadRequest(fmt.Sprintf("Invalid format value. Must be one of: %s, %s, %s", models.EditionFormatEPUB, models.EditionFormatMOBI, models.EditionFormatPDF))
+		}
+		format = validFormat
+	}
+
+	file, err := h.FileRepo.GetLatestEditionFile(r.Context(), editionID, format)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webutil.ErrNotFound("No file has been generated for this edition")
+		}
+		return nil, fmt.Errorf("failed to retrieve file for edition %s: %w", editionID, err)
+	}
+	return file, nil
+}
+
+// Writes an edition file as an attachment. http.ServeContent answers range
+// and conditional requests; the file's content hash is its ETag.
+func serveEditionFile(w http.ResponseWriter, r *http.Request, blobs storage.BlobStore, edition *models.Edition, file *models.EditionFile) error {
+	object, err := blobs.Get(r.Context(), file.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return webutil.ErrNotFound("Edition file is no longer stored")
+		}
+		return fmt.Errorf("failed to load edition file %s: %w", file.ID, err)
+	}
+
+	// Only names that can't be mistaken for paths are offered to the client.
+	fileName := strings.NewReplacer("/", "-", `\`, "-").Replace(strings.TrimSpace(edition.Name))
+	if fileName == "" {
+		fileName = "edition-" + edition.ID
+	}
+	fileName += "." + string(file.Format)
+
+	w.Header().Set(webutil.HeaderContentType, file.Format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("ETag", `"`+file.ContentHash+`"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "", file.CreatedAt, bytes.NewReader(object.Data))
+	return nil
+}
+
+// Signs a download link to an edition file that expires at the given Unix
+// time.
+func signDownloadLink(secret []byte, fileID, expires string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fileID + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Returns the scheme and host the client used to reach the server, honoring
+// the X-Forwarded-Proto header set by proxies such as Cloud Run's.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,134 @@
+package routehandlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/webutil"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// EditionTemplateDestinationHandler holds dependencies for managing where
+// editions generated from a template are delivered.
+type EditionTemplateDestinationHandler struct {
+	Repo            *datastore.EditionTemplateDestinationRepository
+	TemplateRepo    *datastore.EditionTemplateRepository
+	DestinationRepo *datastore.DestinationRepository
+}
+
+// NewEditionTemplateDestinationHandler creates a new EditionTemplateDestinationHandler.
+func NewEditionTemplateDestinationHandler(repo *datastore.EditionTemplateDestinationRepository, templateRepo *datastore.EditionTemplateRepository, destinationRepo *datastore.DestinationRepository) *EditionTemplateDestinationHandler {
+	return &EditionTemplateDestinationHandler{Repo: repo, TemplateRepo: templateRepo, DestinationRepo: destinationRepo}
+}
+
+// authorizeTemplate checks that the template belongs to the authenticated user.
+func (h *EditionTemplateDestinationHandler) authorizeTemplate(r *http.Request, templateID string) error {
+	ownerID, err := h.TemplateRepo.GetEditionTemplateOwnerID(r.Context(), templateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Edition template not found.")
+		}
+		log.Printf("ERROR: Failed to get owner of template %s: %v", templateID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve edition template", err)
+	}
+	return authorizeUser(r, ownerID)
+}
+
+// HandleAddDestinationToTemplate delivers editions generated from a template
+// to a destination, in addition to any destinations already assigned.
+// Example route: POST /api/edition-templates/{templateID}/destinations/{destinationID}
+func (h *EditionTemplateDestinationHandler) HandleAddDestinationToTemplate(w http.ResponseWriter, r *http.Request) error {
+	templateID := chi.URLParam(r, "templateID")
+	destinationID := chi.URLParam(r, "destinationID")
+
+	if _, err := uuid.Parse(templateID); err != nil {
+		return webutil.ErrBadRequest("Invalid templateID format in path")
+	}
+	if _, err := uuid.Parse(destinationID); err != nil {
+		return webutil.ErrBadRequest("Invalid destinationID format in path")
+	}
+	if err := h.authorizeTemplate(r, templateID); err != nil {
+		return err
+	}
+	// Templates may only deliver to the same user's destinations
+	if err := authorizeDestination(r, h.DestinationRepo, destinationID); err != nil {
+		return err
+	}
+
+	createdAt := time.Now().UTC()
+	err := h.Repo.AddDestinationToTemplate(r.Context(), templateID, destinationID, createdAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key constraint") {
+			log.Printf("WARN: Attempt to add non-existent destination %s to template %s (or vice-versa): %v", destinationID, templateID, err)
+			return webutil.ErrNotFound("Edition template or delivery destination not found.")
+		}
+		log.Printf("ERROR: Failed to add destination %s to template %s: %v", destinationID, templateID, err)
+		return webutil.ErrInternalServerWrap(fmt.Sprintf("Failed to add destination to template: %v", err), err)
+	}
+
+	log.Printf("INFO: Destination %s added to template %s", destinationID, templateID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// HandleRemoveDestinationFromTemplate stops delivering a template's editions
+// to a destination. Once none are left, editions go to the user's default
+// destination again.
+// Example route: DELETE /api/edition-templates/{templateID}/destinations/{destinationID}
+func (h *EditionTemplateDestinationHandler) HandleRemoveDestinationFromTemplate(w http.ResponseWriter, r *http.Request) error {
+	templateID := chi.URLParam(r, "templateID")
+	destinationID := chi.URLParam(r, "destinationID")
+
+	if _, err := uuid.Parse(templateID); err != nil {
+		return webutil.ErrBadRequest("Invalid templateID format in path")
+	}
+	if _, err := uuid.Parse(destinationID); err != nil {
+		return webutil.ErrBadRequest("Invalid destinationID format in path")
+	}
+	if err := h.authorizeTemplate(r, templateID); err != nil {
+		return err
+	}
+
+	err := h.Repo.RemoveDestinationFromTemplate(r.Context(), templateID, destinationID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no destination assignment found") {
+			log.Printf("INFO: Attempt to remove destination %s from template %s where no assignment existed.", destinationID, templateID)
+			return webutil.ErrNotFound("Destination assignment not found.")
+		}
+		log.Printf("ERROR: Failed to remove destination %s from template %s: %v", destinationID, templateID, err)
+		return webutil.ErrInternalServerWrap(fmt.Sprintf("Failed to remove destination from template: %v", err), err)
+	}
+
+	log.Printf("INFO: Destination %s removed from template %s", destinationID, templateID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// HandleGetTemplateDestinations retrieves all delivery destinations assigned to a template.
+// Example route: GET /api/edition-templates/{templateID}/destinations
+func (h *EditionTemplateDestinationHandler) HandleGetTemplateDestinations(w http.ResponseWriter, r *http.Request) error {
+	templateID := chi.URLParam(r, "templateID")
+
+	if _, err := uuid.Parse(templateID); err != nil {
+		return webutil.ErrBadRequest("Invalid templateID format in path")
+	}
+	if err := h.authorizeTemplate(r, templateID); err != nil {
+		return err
+	}
+
+	destinations, err := h.Repo.GetDestinationsForTemplate(r.Context(), templateID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get destinations for template %s: %v", templateID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve template destinations", err)
+	}
+
+	webutil.RespondWithJSON(w, http.StatusOK, destinations)
+	return nil
+}
@@ -57,11 +57,9 @@ func (h *EditionTemplateHandler) HandleCreateEditionTemplate(w http.ResponseWrit
 	}
 	defer r.Body.Close()
 
-	if req.UserID == "" {
-		return webutil.ErrBadRequest("UserID is required")
-	}
-	if _, err := uuid.Parse(req.UserID); err != nil {
-		return webutil.ErrBadRequest("Invalid UserID format")
+	userID, err := resolveUserID(r, req.UserID)
+	if err != nil {
+		return err
 	}
 	if strings.TrimSpace(req.Name) == "" {
 		return webutil.ErrBadRequest("Template name is required")
@@ -81,7 +79,7 @@ func (h *EditionTemplateHandler) HandleCreateEditionTemplate(w http.ResponseWrit
 
 	newTemplate := models.EditionTemplate{
 		ID:               uuid.NewString(),
-		UserID:           req.UserID,
+		UserID:           userID,
 		CreatedAt:        time.Now().UTC(),
 		Name:             req.Name,
 		Description:      req.Description,
@@ -91,14 +89,14 @@ func (h *EditionTemplateHandler) HandleCreateEditionTemplate(w http.ResponseWrit
 		IsRecurring:      req.IsRecurring,
 	}
 
-	err := h.Repo.CreateEditionTemplate(r.Context(), &newTemplate)
+	err = h.Repo.CreateEditionTemplate(r.Context(), &newTemplate)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "cannot be empty") {
-			log.Printf("INFO: Validation error creating edition template for user %s: %v", req.UserID, err)
+			log.Printf("INFO: Validation error creating edition template for user %s: %v", userID, err)
 			// Return a 400 Bad Request for validation errors from the repo
 			return webutil.ErrBadRequestWrap(fmt.Sprintf("Failed to create edition template: %v", err), err)
 		} else {
-			log.Printf("ERROR: Failed to create edition template for user %s: %v", req.UserID, err)
+			log.Printf("ERROR: Failed to create edition template for user %s: %v", userID, err)
 			// Return a generic 500 Internal Server Error for other DB errors
 			return webutil.ErrInternalServerWrap("Failed to create edition template", err)
 		}
@@ -122,6 +120,9 @@ func (h *EditionTemplateHandler) HandleGetEditionTemplateByID(w http.ResponseWri
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	template, err := h.Repo.GetEditionTemplateByID(r.Context(), templateID, userID)
 	if err != nil {
@@ -147,6 +148,9 @@ func (h *EditionTemplateHandler) HandleGetEditionTemplatesByUserID(w http.Respon
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	templates, err := h.Repo.GetEditionTemplatesByUserID(r.Context(), userID)
 	if err != nil {
@@ -170,6 +174,9 @@ func (h *EditionTemplateHandler) HandleUpdateEditionTemplate(w http.ResponseWrit
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	var req updateEditionTemplateRequest
 	decoder := json.NewDecoder(r.Body)
@@ -244,6 +251,9 @@ func (h *EditionTemplateHandler) HandleDeleteEditionTemplate(w http.ResponseWrit
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	err := h.Repo.DeleteEditionTemplate(r.Context(), templateID, userID)
 	if err != nil {
@@ -1,6 +1,8 @@
 package routehandlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,12 +18,27 @@ import (
 // EditionTemplateSourceHandler holds dependencies for managing the association
 // between edition templates and reading sources.
 type EditionTemplateSourceHandler struct {
-	Repo *datastore.EditionTemplateSourceRepository
+	Repo         *datastore.EditionTemplateSourceRepository
+	TemplateRepo *datastore.EditionTemplateRepository
+	SourceRepo   *datastore.SourceRepository
 }
 
 // NewEditionTemplateSourceHandler creates a new EditionTemplateSourceHandler.
-func NewEditionTemplateSourceHandler(repo *datastore.EditionTemplateSourceRepository) *EditionTemplateSourceHandler {
-	return &EditionTemplateSourceHandler{Repo: repo}
+func NewEditionTemplateSourceHandler(repo *datastore.EditionTemplateSourceRepository, templateRepo *datastore.EditionTemplateRepository, sourceRepo *datastore.SourceRepository) *EditionTemplateSourceHandler {
+	return &EditionTemplateSourceHandler{Repo: repo, TemplateRepo: templateRepo, SourceRepo: sourceRepo}
+}
+
+// authorizeTemplate checks that the template belongs to the authenticated user.
+func (h *EditionTemplateSourceHandler) authorizeTemplate(r *http.Request, templateID string) error {
+	ownerID, err := h.TemplateRepo.GetEditionTemplateOwnerID(r.Context(), templateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Edition template not found.")
+		}
+		log.Printf("ERROR: Failed to get owner of template %s: %v", templateID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve edition template", err)
+	}
+	return authorizeUser(r, ownerID)
 }
 
 // HandleAddSourceToTemplate associates a reading source with an edition template.
@@ -36,6 +53,13 @@ func (h *EditionTemplateSourceHandler) HandleAddSourceToTemplate(w http.Response
 	if _, err := uuid.Parse(sourceID); err != nil {
 		return webutil.ErrBadRequest("Invalid sourceID format in path")
 	}
+	if err := h.authorizeTemplate(r, templateID); err != nil {
+		return err
+	}
+	// Templates may only draw on the same user's sources
+	if err := authorizeSource(r, h.SourceRepo, sourceID); err != nil {
+		return err
+	}
 
 	createdAt := time.Now().UTC()
 	err := h.Repo.AddSourceToTemplate(r.Context(), templateID, sourceID, createdAt)
@@ -65,6 +89,9 @@ func (h *EditionTemplateSourceHandler) HandleRemoveSourceFromTemplate(w http.Res
 	if _, err := uuid.Parse(sourceID); err != nil {
 		return webutil.ErrBadRequest("Invalid sourceID format in path")
 	}
+	if err := h.authorizeTemplate(r, templateID); err != nil {
+		return err
+	}
 
 	err := h.Repo.RemoveSourceFromTemplate(r.Context(), templateID, sourceID)
 	if err != nil {
@@ -89,6 +116,9 @@ func (h *EditionTemplateSourceHandler) HandleGetTemplateSources(w http.ResponseW
 	if _, err := uuid.Parse(templateID); err != nil {
 		return webutil.ErrBadRequest("Invalid templateID format in path")
 	}
+	if err := h.authorizeTemplate(r, templateID); err != nil {
+		return err
+	}
 
 	sources, err := h.Repo.GetSourcesForTemplate(r.Context(), templateID)
 	if err != nil {
@@ -0,0 +1,507 @@
+package routehandlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ebook"
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/opds"
+	"github.com/coreybb/logos/storage"
+	"github.com/coreybb/logos/webutil"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// OPDSPath is where the catalogs are served, outside the API. Each user's
+	// catalog lives under OPDSPath/{feed token}, since e-reader apps can't
+	// send an API token.
+	OPDSPath = "/opds"
+
+	opdsPageSize         = 25
+	opdsGroupPreviewSize = 5 // Newest editions of each magazine on the OPDS 2.0 start page
+	opdsAuthor           = "Logos"
+
+	coverWidth      = 600
+	coverHeight     = 900
+	thumbnailWidth  = 200
+	thumbnailHeight = 300
+)
+
+// OPDSHandler serves a user's generated editions as OPDS catalogs: OPDS 1.2
+// (Atom) under OPDSPath/{token}/ and OPDS 2.0 (JSON) under
+// OPDSPath/{token}/v2. Editions are grouped by the magazine they were
+// generated from, and only editions with a stored file are listed.
+type OPDSHandler struct {
+	UserRepo     *datastore.UserRepository
+	EditionRepo  *datastore.EditionRepository
+	TemplateRepo *datastore.EditionTemplateRepository
+	FileRepo     *datastore.EditionFileRepository
+	ReadingRepo  *datastore.ReadingRepository
+	Blobs        storage.BlobStore
+}
+
+func NewOPDSHandler(
+	userRepo *datastore.UserRepository,
+	editionRepo *datastore.EditionRepository,
+	templateRepo *datastore.EditionTemplateRepository,
+	fileRepo *datastore.EditionFileRepository,
+	readingRepo *datastore.ReadingRepository,
+	blobs storage.BlobStore,
+) *OPDSHandler {
+	return &OPDSHandler{
+		UserRepo:     userRepo,
+		EditionRepo:  editionRepo,
+		TemplateRepo: templateRepo,
+		FileRepo:     fileRepo,
+		ReadingRepo:  readingRepo,
+		Blobs:        blobs,
+	}
+}
+
+// opdsLibrary is what a catalog is built from: the user's magazines and the
+// editions that have files, newest first.
+type opdsLibrary struct {
+	user      *models.User
+	base      string // Absolute URL of the user's catalog root, without a trailing slash
+	templates []models.EditionTemplate
+	editions  []opdsEdition
+}
+
+type opdsEdition struct {
+	models.Edition
+	Files []models.EditionFile // Latest file in each format
+}
+
+// opdsFlavor holds what differs between the OPDS 1.2 and 2.0 catalogs.
+type opdsFlavor struct {
+	prefix      string // Path of the catalog root below the user's base
+	groups      bool   // Whether the start page previews each magazine's editions
+	contentType func(*opds.Catalog) string
+	encode      func(*opds.Catalog) ([]byte, error)
+}
+
+var (
+	opdsAtom = opdsFlavor{
+		prefix: "",
+		contentType: func(c *opds.Catalog) string {
+			if c.IsNavigation() {
+				return opds.AtomNavigationType
+			}
+			return opds.AtomAcquisitionType
+		},
+		encode: (*opds.Catalog).Atom,
+	}
+	opdsJSON = opdsFlavor{
+		prefix:      "/v2",
+		groups:      true,
+		contentType: func(*opds.Catalog) string { return opds.JSONType },
+		encode:      (*opds.Catalog).JSON,
+	}
+)
+
+// HandleGetNavigationFeed serves the OPDS 1.2 start page: all editions, then
+// one entry per magazine.
+// Example route: GET /opds/{token}/
+func (h *OPDSHandler) HandleGetNavigationFeed(w http.ResponseWriter, r *http.Request) error {
+	return h.serveNavigation(w, r, opdsAtom)
+}
+
+// HandleGetAcquisitionFeed serves a page of editions as OPDS 1.2, optionally
+// limited to one magazine by the "template" query parameter.
+// Example route: GET /opds/{token}/editions?template={id}&page=2
+func (h *OPDSHandler) HandleGetAcquisitionFeed(w http.ResponseWriter, r *http.Request) error {
+	return h.serveEditions(w, r, opdsAtom)
+}
+
+// HandleGetNavigationFeedJSON serves the OPDS 2.0 start page, which also
+// previews the newest editions of each magazine as groups.
+// Example route: GET /opds/{token}/v2
+func (h *OPDSHandler) HandleGetNavigationFeedJSON(w http.ResponseWriter, r *http.Request) error {
+	return h.serveNavigation(w, r, opdsJSON)
+}
+
+// HandleGetAcquisitionFeedJSON is HandleGetAcquisitionFeed for OPDS 2.0.
+// Example route: GET /opds/{token}/v2/editions?template={id}&page=2
+func (h *OPDSHandler) HandleGetAcquisitionFeedJSON(w http.ResponseWriter, r *http.Request) error {
+	return h.serveEditions(w, r, opdsJSON)
+}
+
+// HandleDownloadFile serves an edition file listed in the catalog, with the
+// same range and ETag support as the API's download endpoint.
+// Example route: GET /opds/{token}/files/{id}
+func (h *OPDSHandler) HandleDownloadFile(w http.ResponseWriter, r *http.Request) error {
+	user, err := h.feedUser(r)
+	if err != nil {
+		return err
+	}
+	fileID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(fileID); err != nil {
+		return webutil.ErrNotFound("Edition file not found")
+	}
+
+	file, err := h.FileRepo.GetEditionFileByID(r.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Edition file not found")
+		}
+		return fmt.Errorf("failed to retrieve edition file %s: %w", fileID, err)
+	}
+	edition, err := h.userEdition(r, user, file.EditionID)
+	if err != nil {
+		return err
+	}
+	return serveEditionFile(w, r, h.Blobs, edition, file)
+}
+
+// HandleGetCover serves an edition's cover image.
+// Example route: GET /opds/{token}/editions/{id}/cover
+func (h *OPDSHandler) HandleGetCover(w http.ResponseWriter, r *http.Request) error {
+	return h.serveCover(w, r, coverWidth, coverHeight)
+}
+
+// HandleGetThumbnail serves a small version of an edition's cover image.
+// Example route: GET /opds/{token}/editions/{id}/thumbnail
+func (h *OPDSHandler) HandleGetThumbnail(w http.ResponseWriter, r *http.Request) error {
+	return h.serveCover(w, r, thumbnailWidth, thumbnailHeight)
+}
+
+func (h *OPDSHandler) serveNavigation(w http.ResponseWriter, r *http.Request, flavor opdsFlavor) error {
+	lib, err := h.loadLibrary(r)
+	if err != nil {
+		return err
+	}
+
+	root := lib.base + flavor.prefix
+	catalog := &opds.Catalog{
+		ID:      "urn:logos:catalog:" + lib.user.ID,
+		Title:   "Logos editions",
+		Author:  opdsAuthor,
+		Updated: lib.updated(""),
+		Self:    root,
+		Start:   root,
+		Navigation: []opds.Navigation{{
+			ID:      "urn:logos:catalog:" + lib.user.ID + ":editions",
+			Title:   "All editions",
+			Summary: "Every edition, newest first",
+			Href:    root + "/editions",
+			Updated: lib.updated(""),
+			Count:   len(lib.editions),
+		}},
+	}
+
+	for _, template := range lib.templates {
+		editions := lib.editionsOf(template.ID)
+		if len(editions) == 0 {
+			continue
+		}
+		href := root + "/editions?" + url.Values{"template": {template.ID}}.Encode()
+		catalog.Navigation = append(catalog.Navigation, opds.Navigation{
+			ID:      "urn:uuid:" + template.ID,
+			Title:   template.Name,
+			Summary: template.Description,
+			Href:    href,
+			Updated: lib.updated(template.ID),
+			Count:   len(editions),
+		})
+		if flavor.groups {
+			group := opds.Group{Title: template.Name, Href: href, Count: len(editions)}
+			for _, edition := range editions[:min(len(editions), opdsGroupPreviewSize)] {
+				group.Publications = append(group.Publications, lib.publication(edition, template.Name))
+			}
+			catalog.Groups = append(catalog.Groups, group)
+		}
+	}
+
+	return writeCatalog(w, catalog, flavor)
+}
+
+func (h *OPDSHandler) serveEditions(w http.ResponseWriter, r *http.Request, flavor opdsFlavor) error {
+	lib, err := h.loadLibrary(r)
+	if err != nil {
+		return err
+	}
+
+	page := 1
+	if v := r.URL.Query().Get("page"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			return webutil.ErrBadRequest("page must be a positive integer")
+		}
+		page = parsed
+	}
+
+	root := lib.base + flavor.prefix
+	query := url.Values{}
+	title := "All editions"
+	feedID := "urn:logos:catalog:" + lib.user.ID + ":editions"
+	editions := lib.editions
+	templateID := r.URL.Query().Get("template")
+	if templateID != "" {
+		template := lib.template(templateID)
+		if template == nil {
+			return webutil.ErrNotFound("Magazine not found")
+		}
+		query.Set("template", templateID)
+		title = template.Name
+		feedID = "urn:uuid:" + template.ID
+		editions = lib.editionsOf(templateID)
+	}
+
+	lastPage := max(1, (len(editions)+opdsPageSize-1)/opdsPageSize)
+	if page > lastPage {
+		return webutil.ErrNotFound("Page not found")
+	}
+	pageURL := func(n int) string {
+		query.Set("page", strconv.Itoa(n))
+		return root + "/editions?" + query.Encode()
+	}
+
+	catalog := &opds.Catalog{
+		ID:      feedID,
+		Title:   title,
+		Author:  opdsAuthor,
+		Updated: lib.updated(templateID),
+		Self:    pageURL(page),
+		Start:   root,
+		Up:      root,
+		Page: &opds.Page{
+			Number:       page,
+			ItemsPerPage: opdsPageSize,
+			TotalItems:   len(editions),
+			First:        pageURL(1),
+			Last:         pageURL(lastPage),
+		},
+	}
+	if page > 1 {
+		catalog.Page.Previous = pageURL(page - 1)
+	}
+	if page < lastPage {
+		catalog.Page.Next = pageURL(page + 1)
+	}
+
+	start := (page - 1) * opdsPageSize
+	for _, edition := range editions[start:min(len(editions), start+opdsPageSize)] {
+		var category string
+		if template := lib.template(edition.EditionTemplateID); template != nil {
+			category = template.Name
+		}
+		catalog.Publications = append(catalog.Publications, lib.publication(edition, category))
+	}
+
+	return writeCatalog(w, catalog, flavor)
+}
+
+// Serves a cover from the blob store, rendering and storing it on first
+// request. The cover is the edition's largest image or, without one, a tile
+// colored after its magazine.
+func (h *OPDSHandler) serveCover(w http.ResponseWriter, r *http.Request, width, height int) error {
+	user, err := h.feedUser(r)
+	if err != nil {
+		return err
+	}
+	editionID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(editionID); err != nil {
+		return webutil.ErrNotFound("Edition not found")
+	}
+	edition, err := h.userEdition(r, user, editionID)
+	if err != nil {
+		return err
+	}
+
+	key := storage.EditionCoverKey(user.ID, edition.ID, width, height)
+	var data []byte
+	modTime := edition.CreatedAt
+	object, err := h.Blobs.Get(r.Context(), key)
+	switch {
+	case err == nil:
+		data, modTime = object.Data, object.ModTime
+	case errors.Is(err, storage.ErrNotFound):
+		imagesByReading, err := h.ReadingRepo.GetReadingImagesForEdition(r.Context(), edition.ID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve images for edition %s: %w", edition.ID, err)
+		}
+		var images []models.ReadingImage
+		for _, readingImages := range imagesByReading {
+			images = append(images, readingImages...)
+		}
+		if err := storage.LoadReadingImages(r.Context(), h.Blobs, images); err != nil {
+			// Images that didn't load are left out of the cover.
+			log.Printf("WARNING (OPDS): Failed to load images for cover of edition %s: %v", edition.ID, err)
+		}
+		data, err = ebook.RenderCover(images, edition.EditionTemplateID, width, height)
+		if err != nil {
+			return fmt.Errorf("failed to render cover for edition %s: %w", edition.ID, err)
+		}
+		if err := h.Blobs.Put(r.Context(), key, data, "image/jpeg"); err != nil {
+			// The cover is still served; it is rendered again next time.
+			log.Printf("WARNING (OPDS): Failed to store cover %s: %v", key, err)
+		}
+	default:
+		return fmt.Errorf("failed to load cover %s: %w", key, err)
+	}
+
+	w.Header().Set(webutil.HeaderContentType, "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+	return nil
+}
+
+// Finds the user whose feed token is in the path. Unknown tokens get 404, so
+// that the catalog's existence isn't revealed.
+func (h *OPDSHandler) feedUser(r *http.Request) (*models.User, error) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		return nil, webutil.ErrNotFound("Catalog not found")
+	}
+	tokenHash, err := webutil.GenerateHash(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash feed token: %w", err)
+	}
+	user, err := h.UserRepo.GetUserByFeedTokenHash(r.Context(), tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webutil.ErrNotFound("Catalog not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve user by feed token: %w", err)
+	}
+	return user, nil
+}
+
+// Retrieves an edition, answering 404 if it belongs to another user.
+func (h *OPDSHandler) userEdition(r *http.Request, user *models.User, editionID string) (*models.Edition, error) {
+	edition, err := h.EditionRepo.GetEditionByID(r.Context(), editionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webutil.ErrNotFound("Edition not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve edition %s: %w", editionID, err)
+	}
+	if edition.UserID != user.ID {
+		return nil, webutil.ErrNotFound("Edition not found")
+	}
+	return edition, nil
+}
+
+func (h *OPDSHandler) loadLibrary(r *http.Request) (*opdsLibrary, error) {
+	user, err := h.feedUser(r)
+	if err != nil {
+		return nil, err
+	}
+	ctx := r.Context()
+
+	templates, err := h.TemplateRepo.GetEditionTemplatesByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve edition templates for user %s: %w", user.ID, err)
+	}
+	editions, err := h.EditionRepo.GetEditionsByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve editions for user %s: %w", user.ID, err)
+	}
+	files, err := h.FileRepo.GetLatestEditionFilesByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve edition files for user %s: %w", user.ID, err)
+	}
+
+	lib := &opdsLibrary{
+		user:      user,
+		base:      opdsCatalogURL(r, chi.URLParam(r, "token")),
+		templates: templates,
+	}
+	for _, edition := range editions {
+		if editionFiles := files[edition.ID]; len(editionFiles) > 0 {
+			lib.editions = append(lib.editions, opdsEdition{Edition: edition, Files: editionFiles})
+		}
+	}
+	return lib, nil
+}
+
+func (lib *opdsLibrary) template(templateID string) *models.EditionTemplate {
+	for i := range lib.templates {
+		if lib.templates[i].ID == templateID {
+			return &lib.templates[i]
+		}
+	}
+	return nil
+}
+
+func (lib *opdsLibrary) editionsOf(templateID string) []opdsEdition {
+	var editions []opdsEdition
+	for _, edition := range lib.editions {
+		if edition.EditionTemplateID == templateID {
+			editions = append(editions, edition)
+		}
+	}
+	return editions
+}
+
+// Returns when the newest file was generated, among the editions of one
+// magazine or, for an empty templateID, all editions.
+func (lib *opdsLibrary) updated(templateID string) time.Time {
+	var latest time.Time
+	for _, edition := range lib.editions {
+		if templateID != "" && edition.EditionTemplateID != templateID {
+			continue
+		}
+		for _, file := range edition.Files {
+			if file.CreatedAt.After(latest) {
+				latest = file.CreatedAt
+			}
+		}
+	}
+	if latest.IsZero() {
+		return lib.user.CreatedAt
+	}
+	return latest
+}
+
+func (lib *opdsLibrary) publication(edition opdsEdition, category string) opds.Publication {
+	editionURL := lib.base + "/editions/" + edition.ID
+	pub := opds.Publication{
+		ID:        "urn:uuid:" + edition.ID,
+		Title:     edition.Name,
+		Category:  category,
+		Published: edition.CreatedAt,
+		Updated:   edition.CreatedAt,
+		Cover:     &opds.Image{Href: editionURL + "/cover", Type: "image/jpeg", Width: coverWidth, Height: coverHeight},
+		Thumbnail: &opds.Image{Href: editionURL + "/thumbnail", Type: "image/jpeg", Width: thumbnailWidth, Height: thumbnailHeight},
+	}
+	for _, file := range edition.Files {
+		if file.CreatedAt.After(pub.Updated) {
+			pub.Updated = file.CreatedAt
+		}
+		pub.Acquisitions = append(pub.Acquisitions, opds.Acquisition{
+			Href:   lib.base + "/files/" + file.ID,
+			Type:   file.Format.ContentType(),
+			Length: int64(file.FileSize),
+		})
+	}
+	return pub
+}
+
+func writeCatalog(w http.ResponseWriter, catalog *opds.Catalog, flavor opdsFlavor) error {
+	data, err := flavor.encode(catalog)
+	if err != nil {
+		return err
+	}
+	w.Header().Set(webutil.HeaderContentType, flavor.contentType(catalog))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+	return nil
+}
+
+// Returns the absolute URL of the OPDS 1.2 catalog behind a feed token. The
+// OPDS 2.0 catalog is at the same URL plus "/v2".
+func opdsCatalogURL(r *http.Request, token string) string {
+	return requestBaseURL(r) + OPDSPath + "/" + url.PathEscape(token)
+}
@@ -5,12 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/coreybb/logos/articles"
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
 	"github.com/coreybb/logos/webutil"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -18,18 +22,31 @@ import (
 
 // Holds dependencies for reading route handlers.
 type ReadingHandler struct {
-	Repo *datastore.ReadingRepository
+	Repo         *datastore.ReadingRepository
+	ArticleSaver *articles.Saver
+	Blobs        storage.BlobStore // Holds original files of readings
 }
 
 // Creates a new ReadingHandler.
-func NewReadingHandler(repo *datastore.ReadingRepository) *ReadingHandler {
-	return &ReadingHandler{Repo: repo}
+func NewReadingHandler(repo *datastore.ReadingRepository, articleSaver *articles.Saver, blobs storage.BlobStore) *ReadingHandler {
+	return &ReadingHandler{Repo: repo, ArticleSaver: articleSaver, Blobs: blobs}
 }
 
+// saveURLRequest defines the payload for saving a web page as a reading.
+type saveURLRequest struct {
+	URL string `json:"url"`
+}
+
+// HandleGetReadings lists the authenticated user's readings.
 func (h *ReadingHandler) HandleGetReadings(w http.ResponseWriter, r *http.Request) error {
-	readings, err := h.Repo.GetReadings(r.Context())
+	userID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
+
+	readings, err := h.Repo.GetReadingsByUserID(r.Context(), userID)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve readings: %w", err)
+		return fmt.Errorf("failed to retrieve readings for user %s: %w", userID, err)
 	}
 	if readings == nil {
 		readings = []models.Reading{}
@@ -43,6 +60,10 @@ func (h *ReadingHandler) HandleGetReading(w http.ResponseWriter, r *http.Request
 	if _, err := uuid.Parse(readingID); err != nil {
 		return webutil.ErrBadRequest("Invalid reading ID format")
 	}
+	userID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
 
 	reading, err := h.Repo.GetReadingByID(r.Context(), readingID)
 	if err != nil {
@@ -51,16 +72,69 @@ func (h *ReadingHandler) HandleGetReading(w http.ResponseWriter, r *http.Request
 		}
 		return fmt.Errorf("failed to retrieve reading %s: %w", readingID, err)
 	}
+	hasReading, err := h.Repo.UserHasReading(r.Context(), userID, readingID)
+	if err != nil {
+		return fmt.Errorf("failed to check access to reading %s: %w", readingID, err)
+	}
+	if !hasReading {
+		return webutil.ErrForbidden("You do not have access to this reading")
+	}
 
 	webutil.RespondWithJSON(w, http.StatusOK, reading)
 	return nil
 }
 
+// HandleGetReadingOriginal downloads the file a reading was converted from,
+// such as the PDF attachment behind a reading whose text was extracted.
+func (h *ReadingHandler) HandleGetReadingOriginal(w http.ResponseWriter, r *http.Request) error {
+	readingID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(readingID); err != nil {
+		return webutil.ErrBadRequest("Invalid reading ID format")
+	}
+	userID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
+
+	hasReading, err := h.Repo.UserHasReading(r.Context(), userID, readingID)
+	if err != nil {
+		return fmt.Errorf("failed to check access to reading %s: %w", readingID, err)
+	}
+	if !hasReading {
+		return webutil.ErrForbidden("You do not have access to this reading")
+	}
+
+	original, err := h.Repo.GetReadingOriginal(r.Context(), readingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Reading has no original file")
+		}
+		return fmt.Errorf("failed to retrieve original file for reading %s: %w", readingID, err)
+	}
+	if err := storage.LoadReadingOriginal(r.Context(), h.Blobs, original); err != nil {
+		return err
+	}
+
+	fileName := original.FileName
+	if fileName == "" {
+		fileName = readingID + "." + string(original.Format)
+	}
+	w.Header().Set("Content-Type", original.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(original.Data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(original.Data)
+	return nil
+}
+
 func (h *ReadingHandler) HandleGetUserReadings(w http.ResponseWriter, r *http.Request) error {
 	userID := chi.URLParam(r, "id")
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid user ID format")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	readings, err := h.Repo.GetReadingsByUserID(r.Context(), userID)
 	if err != nil {
@@ -73,7 +147,13 @@ func (h *ReadingHandler) HandleGetUserReadings(w http.ResponseWriter, r *http.Re
 	return nil
 }
 
+// HandleCreateReading stores a reading and links it to the authenticated user.
 func (h *ReadingHandler) HandleCreateReading(w http.ResponseWriter, r *http.Request) error {
+	userID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
+
 	var req models.Reading
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
@@ -110,13 +190,54 @@ func (h *ReadingHandler) HandleCreateReading(w http.ResponseWriter, r *http.Requ
 	req.ID = uuid.NewString()
 	req.CreatedAt = time.Now().UTC()
 
-	err := h.Repo.CreateReading(r.Context(), &req)
+	err = h.Repo.CreateReading(r.Context(), &req)
 	if err != nil {
 		// TODO: Check for specific DB errors like unique constraint on ContentHash if applicable
 		return fmt.Errorf("failed to create reading '%s': %w", req.Title, err)
 	}
+	if err := h.Repo.AddUserReading(r.Context(), userID, req.ID, req.CreatedAt); err != nil {
+		return fmt.Errorf("failed to link reading %s to user %s: %w", req.ID, userID, err)
+	}
 
 	// log.Printf("INFO: Reading created (via direct API): ID=%s, Title=%s", req.ID, req.Title)
 	webutil.RespondWithJSON(w, http.StatusCreated, req)
 	return nil
 }
+
+// HandleSaveURL fetches a web page and stores it as a reading for the user,
+// attributed to their "Saved articles" source.
+func (h *ReadingHandler) HandleSaveURL(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid user ID format")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	var req saveURLRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+	}
+	defer r.Body.Close()
+
+	if strings.TrimSpace(req.URL) == "" {
+		return webutil.ErrBadRequest("Missing required field (url)")
+	}
+
+	reading, err := h.ArticleSaver.SaveURL(r.Context(), userID, req.URL)
+	if err != nil {
+		if errors.Is(err, articles.ErrInvalidURL) {
+			return webutil.ErrBadRequest("url must be an absolute http or https URL")
+		}
+		if errors.Is(err, articles.ErrUnfetchable) {
+			return webutil.NewHTTPErrorWrap(http.StatusUnprocessableEntity, "The page could not be fetched as HTML", err)
+		}
+		return fmt.Errorf("failed to save %s for user %s: %w", req.URL, userID, err)
+	}
+
+	webutil.RespondWithJSON(w, http.StatusCreated, reading)
+	return nil
+}
@@ -4,11 +4,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/ingestion"
 	"github.com/coreybb/logos/models"
 	"github.com/coreybb/logos/webutil"
 	"github.com/go-chi/chi/v5"
@@ -24,12 +28,58 @@ func NewSourceHandler(repo *datastore.SourceRepository) *SourceHandler {
 }
 
 type createReadingSourceRequest struct {
-	Name       string `json:"name"`
-	Type       string `json:"type"` // e.g., email, rss, api
-	Identifier string `json:"identifier"`
+	Name          string `json:"name"`
+	Type          string `json:"type"` // e.g., email, rss, api
+	Identifier    string `json:"identifier"`
+	SplitMode     string `json:"split_mode,omitempty"`     // Optional: none (default), headings or selector
+	SplitSelector string `json:"split_selector,omitempty"` // Required with split_mode "selector"
 }
 
+type sourceSplitPayload struct {
+	SplitMode     string `json:"split_mode"`
+	SplitSelector string `json:"split_selector,omitempty"`
+}
+
+// parseSplitSettings validates split settings from a request. An empty mode means none.
+func parseSplitSettings(modeStr, selector string) (models.SplitMode, string, error) {
+	if modeStr == "" {
+		modeStr = string(models.SplitModeNone)
+	}
+	mode, ok := models.IsValidSplitMode(strings.ToLower(modeStr))
+	if !ok {
+		return "", "", webutil.ErrBadRequest(fmt.Sprintf("Invalid split_mode value. Must be one of: %s, %s, %s",
+			models.SplitModeNone, models.SplitModeHeadings, models.SplitModeSelector))
+	}
+	if mode != models.SplitModeSelector {
+		selector = ""
+	}
+	selector = strings.TrimSpace(selector)
+	if err := ingestion.ValidateSplitSettings(mode, selector); err != nil {
+		return "", "", webutil.ErrBadRequest(err.Error())
+	}
+	return mode, selector, nil
+}
+
+// authorizeSource checks that a reading source belongs to the authenticated user.
+func authorizeSource(r *http.Request, repo *datastore.SourceRepository, sourceID string) error {
+	ownerID, err := repo.GetReadingSourceOwnerID(r.Context(), sourceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Reading source not found")
+		}
+		log.Printf("ERROR: Failed to get owner of reading source %s: %v", sourceID, err)
+		return webutil.ErrInternalServerWrap("Failed to retrieve reading source", err)
+	}
+	return authorizeUser(r, ownerID)
+}
+
+// HandleCreateSource creates a reading source owned by the authenticated user.
 func (h *SourceHandler) HandleCreateSource(w http.ResponseWriter, r *http.Request) error {
+	userID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
+
 	var req createReadingSourceRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
@@ -46,32 +96,56 @@ func (h *SourceHandler) HandleCreateSource(w http.ResponseWriter, r *http.Reques
 	if !validTypes[req.Type] {
 		return webutil.ErrBadRequest("Invalid source type")
 	}
+	if req.Type == "rss" {
+		feedURL, err := url.Parse(req.Identifier)
+		if err != nil || (feedURL.Scheme != "http" && feedURL.Scheme != "https") || feedURL.Host == "" {
+			return webutil.ErrBadRequest("Identifier for an rss source must be an http(s) feed URL")
+		}
+	}
+
+	splitMode, splitSelector, err := parseSplitSettings(req.SplitMode, req.SplitSelector)
+	if err != nil {
+		return err
+	}
 
 	newSource := models.ReadingSource{
-		ID:         uuid.NewString(),
-		CreatedAt:  time.Now().UTC(),
-		Name:       req.Name,
-		Type:       req.Type,
-		Identifier: req.Identifier,
+		ID:            uuid.NewString(),
+		UserID:        userID,
+		CreatedAt:     time.Now().UTC(),
+		Name:          req.Name,
+		Type:          req.Type,
+		Identifier:    req.Identifier,
+		SplitMode:     splitMode,
+		SplitSelector: splitSelector,
 	}
 
-	err := h.Repo.CreateReadingSource(r.Context(), &newSource)
+	newID := newSource.ID
+	err = h.Repo.CreateReadingSource(r.Context(), &newSource)
 	if err != nil {
 		// Log the detailed error internally
 		log.Printf("ERROR: Failed to create reading source '%s': %v", req.Name, err)
 		// Return a generic internal server error to the client
 		return webutil.ErrInternalServerWrap("Failed to create reading source", err)
 	}
+	if newSource.ID != newID {
+		return webutil.ErrConflict(fmt.Sprintf("A source with this identifier and type already exists: %s", newSource.ID))
+	}
 
 	log.Printf("INFO: Reading Source created: ID=%s, Name=%s", newSource.ID, newSource.Name)
 	webutil.RespondWithJSON(w, http.StatusCreated, newSource)
 	return nil
 }
 
+// HandleGetSources lists the authenticated user's reading sources.
 func (h *SourceHandler) HandleGetSources(w http.ResponseWriter, r *http.Request) error {
-	sources, err := h.Repo.GetReadingSources(r.Context())
+	userID, err := authenticatedUserID(r)
+	if err != nil {
+		return err
+	}
+
+	sources, err := h.Repo.GetReadingSourcesByUserID(r.Context(), userID)
 	if err != nil {
-		log.Printf("ERROR: Failed to get reading sources: %v", err)
+		log.Printf("ERROR: Failed to get reading sources for user %s: %v", userID, err)
 		return webutil.ErrInternalServerWrap("Failed to retrieve reading sources", err)
 	}
 
@@ -87,6 +161,9 @@ func (h *SourceHandler) HandleGetUnassignedSources(w http.ResponseWriter, r *htt
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid userID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	sources, err := h.Repo.GetUnassignedSourcesByUserID(r.Context(), userID)
 	if err != nil {
@@ -113,7 +190,51 @@ func (h *SourceHandler) HandleGetSourceByID(w http.ResponseWriter, r *http.Reque
 		log.Printf("ERROR: Failed to get reading source %s: %v", sourceID, err)
 		return webutil.ErrInternalServerWrap("Failed to retrieve reading source", err)
 	}
+	if err := authorizeUser(r, source.UserID); err != nil {
+		return err
+	}
 
 	webutil.RespondWithJSON(w, http.StatusOK, source)
 	return nil
 }
+
+// HandleUpdateSourceSplit sets whether HTML emails from a source are split
+// into one reading per article, by heading structure or by a CSS selector.
+// Example route: PUT /api/sources/{id}/split
+func (h *SourceHandler) HandleUpdateSourceSplit(w http.ResponseWriter, r *http.Request) error {
+	sourceID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(sourceID); err != nil {
+		return webutil.ErrBadRequest("Invalid source ID format")
+	}
+	if err := authorizeSource(r, h.Repo, sourceID); err != nil {
+		return err
+	}
+
+	var req sourceSplitPayload
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return webutil.ErrBadRequest("Invalid request payload: " + err.Error())
+	}
+	defer r.Body.Close()
+
+	if req.SplitMode == "" {
+		return webutil.ErrBadRequest("Missing required field (split_mode)")
+	}
+	mode, selector, err := parseSplitSettings(req.SplitMode, req.SplitSelector)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Repo.UpdateSplitMode(r.Context(), sourceID, mode, selector); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("Reading source not found")
+		}
+		log.Printf("ERROR: Failed to update split mode for reading source %s: %v", sourceID, err)
+		return webutil.ErrInternalServerWrap("Failed to update split mode", err)
+	}
+
+	log.Printf("INFO: Split mode for reading source %s set to %s", sourceID, mode)
+	webutil.RespondWithJSON(w, http.StatusOK, sourceSplitPayload{SplitMode: string(mode), SplitSelector: selector})
+	return nil
+}
@@ -24,18 +24,29 @@ func NewUserHandler(repo *datastore.UserRepository) *UserHandler {
 	return &UserHandler{Repo: repo}
 }
 
+// HandleGetUsers lists the users visible to the caller, which is only the
+// authenticated user.
 func (h *UserHandler) HandleGetUsers(w http.ResponseWriter, r *http.Request) error {
-	users, err := h.Repo.GetUsers(r.Context())
+	userID, err := authenticatedUserID(r)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve users: %w", err)
+		return err
 	}
-	if users == nil {
-		users = []models.User{}
+
+	user, err := h.Repo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user %s: %w", userID, err)
 	}
-	webutil.RespondWithJSON(w, http.StatusOK, users)
+	webutil.RespondWithJSON(w, http.StatusOK, []models.User{*user})
 	return nil
 }
 
+// createUserResponse includes the user's first API token, which is only
+// returned once.
+type createUserResponse struct {
+	models.User
+	APIToken string `json:"api_token"`
+}
+
 func (h *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) error {
 	var requestData struct {
 		Email string `json:"email"`
@@ -65,13 +76,18 @@ func (h *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) e
 		return fmt.Errorf("failed to generate email token: %w", err)
 	}
 
-	err = h.Repo.CreateUser(r.Context(), &newUser, emailToken)
+	apiToken, plaintext, err := newAPIToken(newUser.ID, "default")
+	if err != nil {
+		return err
+	}
+
+	err = h.Repo.CreateUser(r.Context(), &newUser, emailToken, apiToken)
 	if err != nil {
 		// TODO: Could be refined to return a 409 Conflict if we detect unique constraint violation.
 		return fmt.Errorf("failed to create user %s: %w", newUser.Email, err)
 	}
 
-	webutil.RespondWithJSON(w, http.StatusCreated, newUser)
+	webutil.RespondWithJSON(w, http.StatusCreated, createUserResponse{User: newUser, APIToken: plaintext})
 	return nil
 }
 
@@ -80,6 +96,9 @@ func (h *UserHandler) HandleGetUser(w http.ResponseWriter, r *http.Request) erro
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid user ID format")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	user, err := h.Repo.GetUserByID(r.Context(), userID)
 	if err != nil {
@@ -91,4 +110,74 @@ func (h *UserHandler) HandleGetUser(w http.ResponseWriter, r *http.Request) erro
 
 	webutil.RespondWithJSON(w, http.StatusOK, user)
 	return nil
-}
\ No newline at end of file
+}
+
+// feedTokenBytes is the amount of randomness in a generated feed token.
+const feedTokenBytes = 32
+
+// feedTokenResponse includes the plaintext feed token, which is only
+// returned once, and the catalog URLs built from it.
+type feedTokenResponse struct {
+	FeedToken   string `json:"feed_token"`
+	OPDSURL     string `json:"opds_url"`
+	OPDSJSONURL string `json:"opds_json_url"`
+}
+
+// HandleCreateFeedToken issues a token for the user's OPDS catalog, replacing
+// any earlier one. E-reader apps can't send API tokens, so the feed token is
+// part of the catalog URL and grants read access to the user's editions only.
+// Example route: POST /api/users/{id}/feed-token
+func (h *UserHandler) HandleCreateFeedToken(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid user ID format")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	token, err := webutil.GenerateRandomToken(feedTokenBytes)
+	if err != nil {
+		return fmt.Errorf("failed to generate feed token: %w", err)
+	}
+	tokenHash, err := webutil.GenerateHash(token)
+	if err != nil {
+		return fmt.Errorf("failed to hash feed token: %w", err)
+	}
+	if err := h.Repo.SetFeedTokenHash(r.Context(), userID, &tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("User not found")
+		}
+		return fmt.Errorf("failed to store feed token for user %s: %w", userID, err)
+	}
+
+	catalogURL := opdsCatalogURL(r, token)
+	webutil.RespondWithJSON(w, http.StatusCreated, feedTokenResponse{
+		FeedToken:   token,
+		OPDSURL:     catalogURL,
+		OPDSJSONURL: catalogURL + "/v2",
+	})
+	return nil
+}
+
+// HandleDeleteFeedToken revokes the user's feed token, disabling the OPDS
+// catalog until a new token is issued.
+// Example route: DELETE /api/users/{id}/feed-token
+func (h *UserHandler) HandleDeleteFeedToken(w http.ResponseWriter, r *http.Request) error {
+	userID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(userID); err != nil {
+		return webutil.ErrBadRequest("Invalid user ID format")
+	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetFeedTokenHash(r.Context(), userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webutil.ErrNotFound("User not found")
+		}
+		return fmt.Errorf("failed to revoke feed token for user %s: %w", userID, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -15,15 +15,16 @@ import (
 
 // UserReadingSourceHandler holds dependencies for user-reading source association handlers.
 type UserReadingSourceHandler struct {
-	Repo *datastore.UserReadingSourceRepository
-	// Potentially UserRepository and SourceRepository if we want to validate existence
-	// of user and source before attempting to create the link, though FK constraints
-	// in the DB will also catch this.
+	Repo       *datastore.UserReadingSourceRepository
+	SourceRepo *datastore.SourceRepository // Sources are per user; only the owner may subscribe
+	// Potentially UserRepository if we want to validate existence of the user
+	// before attempting to create the link, though FK constraints in the DB
+	// will also catch this.
 }
 
 // NewUserReadingSourceHandler creates a new UserReadingSourceHandler.
-func NewUserReadingSourceHandler(repo *datastore.UserReadingSourceRepository) *UserReadingSourceHandler {
-	return &UserReadingSourceHandler{Repo: repo}
+func NewUserReadingSourceHandler(repo *datastore.UserReadingSourceRepository, sourceRepo *datastore.SourceRepository) *UserReadingSourceHandler {
+	return &UserReadingSourceHandler{Repo: repo, SourceRepo: sourceRepo}
 }
 
 // HandleSubscribeUserToSource handles a request for a user to subscribe to a reading source.
@@ -36,9 +37,15 @@ func (h *UserReadingSourceHandler) HandleSubscribeUserToSource(w http.ResponseWr
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 	if _, err := uuid.Parse(sourceID); err != nil {
 		return webutil.ErrBadRequest("Invalid SourceID format in path")
 	}
+	if err := authorizeSource(r, h.SourceRepo, sourceID); err != nil {
+		return err
+	}
 
 	createdAt := time.Now().UTC()
 	err := h.Repo.SubscribeUserToSource(r.Context(), userID, sourceID, createdAt)
@@ -68,6 +75,9 @@ func (h *UserReadingSourceHandler) HandleUnsubscribeUserFromSource(w http.Respon
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 	if _, err := uuid.Parse(sourceID); err != nil {
 		return webutil.ErrBadRequest("Invalid SourceID format in path")
 	}
@@ -97,6 +107,9 @@ func (h *UserReadingSourceHandler) HandleGetUserSubscribedSources(w http.Respons
 	if _, err := uuid.Parse(userID); err != nil {
 		return webutil.ErrBadRequest("Invalid UserID format in path")
 	}
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
 
 	sources, err := h.Repo.GetUserSubscribedSources(r.Context(), userID)
 	if err != nil {
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/coreybb/logos/oidc"
+)
+
+// TickAuthenticator guards the tick endpoint. A request is accepted if its
+// bearer token equals the configured secret, or if it is an identity token
+// for the scheduler's service account that the OIDC verifier accepts.
+type TickAuthenticator struct {
+	secret   []byte
+	verifier *oidc.Verifier
+}
+
+// NewTickAuthenticator creates a TickAuthenticator. Either check may be
+// disabled by passing "" or nil; with both disabled every request is rejected.
+func NewTickAuthenticator(secret string, verifier *oidc.Verifier) *TickAuthenticator {
+	return &TickAuthenticator{secret: []byte(secret), verifier: verifier}
+}
+
+// Require is a middleware that responds 401 to unauthenticated requests
+// without calling next.
+func (a *TickAuthenticator) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.authenticate(r); err != nil {
+			log.Printf("WARN (Scheduler): Rejected tick from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *TickAuthenticator) authenticate(r *http.Request) error {
+	if len(a.secret) == 0 && a.verifier == nil {
+		return errors.New("no tick authentication is configured")
+	}
+
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return errors.New("missing bearer token")
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+
+	if len(a.secret) > 0 && subtle.ConstantTimeCompare([]byte(token), a.secret) == 1 {
+		return nil
+	}
+	if a.verifier == nil {
+		return errors.New("bearer token does not match the tick secret")
+	}
+
+	claims, err := a.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return fmt.Errorf("identity token rejected: %w", err)
+	}
+	log.Printf("INFO (Scheduler): Tick authenticated as %s", claims.Email)
+	return nil
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coreybb/logos/oidc"
+)
+
+const (
+	testAudience       = "https://logos.example.com/scheduler/tick"
+	testServiceAccount = "scheduler@project.iam.gserviceaccount.com"
+)
+
+// newTestIdentity serves a one-key JWKS from httptest and returns a verifier
+// for it, plus a function that signs identity tokens for an email.
+func newTestIdentity(t *testing.T) (*oidc.Verifier, func(email string) string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	verifier, err := oidc.NewVerifier(
+		oidc.Config{Issuers: oidc.GoogleIssuers, Audience: testAudience, Email: testServiceAccount},
+		oidc.NewRemoteKeySet(server.URL, server.Client()),
+	)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	sign := func(email string) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+		claims, _ := json.Marshal(map[string]any{
+			"iss":            oidc.GoogleIssuers[0],
+			"aud":            testAudience,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"email":          email,
+			"email_verified": true,
+		})
+		signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("SignPKCS1v15: %v", err)
+		}
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+	return verifier, sign
+}
+
+func TestTickAuthenticator(t *testing.T) {
+	verifier, sign := newTestIdentity(t)
+
+	tests := []struct {
+		name          string
+		authenticator *TickAuthenticator
+		authorization string
+		wantStatus    int
+	}{
+		{"unconfigured rejects a bearer token", NewTickAuthenticator("", nil), "Bearer anything", http.StatusUnauthorized},
+		{"unconfigured rejects no token", NewTickAuthenticator("", nil), "", http.StatusUnauthorized},
+		{"shared secret", NewTickAuthenticator("tick-secret", nil), "Bearer tick-secret", http.StatusOK},
+		{"wrong shared secret", NewTickAuthenticator("tick-secret", nil), "Bearer guess", http.StatusUnauthorized},
+		{"missing bearer token", NewTickAuthenticator("tick-secret", verifier), "", http.StatusUnauthorized},
+		{"identity token for the service account", NewTickAuthenticator("", verifier), "Bearer " + sign(testServiceAccount), http.StatusOK},
+		{"identity token alongside a secret", NewTickAuthenticator("tick-secret", verifier), "bearer " + sign(testServiceAccount), http.StatusOK},
+		{"identity token for another account", NewTickAuthenticator("", verifier), "Bearer " + sign("someone@example.com"), http.StatusUnauthorized},
+		{"identity token without OIDC configured", NewTickAuthenticator("tick-secret", nil), "Bearer " + sign(testServiceAccount), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			r := httptest.NewRequest(http.MethodPost, "/scheduler/tick", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			tt.authenticator.Require(next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
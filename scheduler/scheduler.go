@@ -9,41 +9,58 @@ import (
 
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/delivery"
+	"github.com/coreybb/logos/feeds"
+	"github.com/coreybb/logos/ingestion"
 	"github.com/coreybb/logos/models"
 	"github.com/coreybb/logos/processing"
 	"github.com/google/uuid"
 )
 
+// How far back earlier editions are checked for readings the user already
+// received.
+const deliveredFingerprintWindow = 30 * 24 * time.Hour
+
 // Scheduler checks recurring edition templates and triggers
 // edition creation, ebook generation, and delivery.
 type Scheduler struct {
-	editionTemplateRepo       *datastore.EditionTemplateRepository
-	editionTemplateSourceRepo *datastore.EditionTemplateSourceRepository
-	editionRepo               *datastore.EditionRepository
-	readingRepo               *datastore.ReadingRepository
-	destinationRepo           *datastore.DestinationRepository
-	editionProcessor          *processing.EditionProcessor
-	deliveryService           *delivery.DeliveryService
+	editionTemplateRepo            *datastore.EditionTemplateRepository
+	editionTemplateSourceRepo      *datastore.EditionTemplateSourceRepository
+	editionTemplateDestinationRepo *datastore.EditionTemplateDestinationRepository
+	editionRepo                    *datastore.EditionRepository
+	readingRepo                    *datastore.ReadingRepository
+	editionProcessor               *processing.EditionProcessor
+	deliveryService                *delivery.DeliveryService
+	feedPoller                     *feeds.Poller
+	deliveryRetrier                *delivery.Retrier
+	// Fingerprint similarity (0 to 1) at or above which a reading is left out
+	// of an edition as a near-duplicate of one the user already received.
+	// 0 only collapses readings linked as duplicates at ingestion.
+	NearDuplicateThreshold float64
 }
 
 // New creates a new Scheduler with all required dependencies.
 func New(
 	editionTemplateRepo *datastore.EditionTemplateRepository,
 	editionTemplateSourceRepo *datastore.EditionTemplateSourceRepository,
+	editionTemplateDestinationRepo *datastore.EditionTemplateDestinationRepository,
 	editionRepo *datastore.EditionRepository,
 	readingRepo *datastore.ReadingRepository,
-	destinationRepo *datastore.DestinationRepository,
 	editionProcessor *processing.EditionProcessor,
 	deliveryService *delivery.DeliveryService,
+	feedPoller *feeds.Poller,
+	deliveryRetrier *delivery.Retrier,
 ) *Scheduler {
 	return &Scheduler{
-		editionTemplateRepo:       editionTemplateRepo,
-		editionTemplateSourceRepo: editionTemplateSourceRepo,
-		editionRepo:               editionRepo,
-		readingRepo:               readingRepo,
-		destinationRepo:           destinationRepo,
-		editionProcessor:          editionProcessor,
-		deliveryService:           deliveryService,
+		editionTemplateRepo:            editionTemplateRepo,
+		editionTemplateSourceRepo:      editionTemplateSourceRepo,
+		editionTemplateDestinationRepo: editionTemplateDestinationRepo,
+		editionRepo:                    editionRepo,
+		readingRepo:                    readingRepo,
+		editionProcessor:               editionProcessor,
+		deliveryService:                deliveryService,
+		feedPoller:                     feedPoller,
+		deliveryRetrier:                deliveryRetrier,
+		NearDuplicateThreshold:         ingestion.DefaultNearDuplicateThreshold,
 	}
 }
 
@@ -63,9 +80,31 @@ func (s *Scheduler) HandleTick(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "OK: processed %d templates", processed)
 }
 
-// Tick runs a single scheduler cycle: checks all recurring templates
-// and processes any that are due. Returns the number of templates processed.
+// Tick runs a single scheduler cycle: polls any due RSS/Atom feeds, retries
+// failed deliveries whose backoff has elapsed, then checks all recurring
+// templates and processes any that are due.
+// Returns the number of templates processed.
 func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	// Poll feeds first so new items can make it into this cycle's editions.
+	// A feed failure should never block edition delivery.
+	if s.feedPoller != nil {
+		ingested, err := s.feedPoller.PollDue(ctx)
+		if err != nil {
+			log.Printf("ERROR (Scheduler): Feed polling failed: %v", err)
+		} else if ingested > 0 {
+			log.Printf("INFO (Scheduler): Ingested %d new feed items", ingested)
+		}
+	}
+
+	if s.deliveryRetrier != nil {
+		delivered, err := s.deliveryRetrier.RetryDue(ctx)
+		if err != nil {
+			log.Printf("ERROR (Scheduler): Delivery retries failed: %v", err)
+		} else if delivered > 0 {
+			log.Printf("INFO (Scheduler): Delivered %d previously failed deliveries", delivered)
+		}
+	}
+
 	templates, err := s.editionTemplateRepo.GetAllRecurringTemplates(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch recurring templates: %w", err)
@@ -109,6 +148,12 @@ func (s *Scheduler) processTemplate(ctx context.Context, template *models.Editio
 		return false
 	}
 
+	// Don't create an edition that can never be rendered
+	if !s.editionProcessor.SupportsFormat(template.Format) {
+		log.Printf("WARN (Scheduler): No renderer for format %q, skipping template %s", template.Format, template.ID)
+		return false
+	}
+
 	// 4. Get source IDs assigned to this template
 	sourceIDs, err := s.editionTemplateSourceRepo.GetSourceIDsForTemplate(ctx, template.ID)
 	if err != nil {
@@ -126,22 +171,30 @@ func (s *Scheduler) processTemplate(ctx context.Context, template *models.Editio
 		return false
 	}
 
+	// 6. Leave out near-duplicates, both within the batch and of readings
+	// delivered in earlier editions. Without the earlier fingerprints only the
+	// batch is collapsed.
+	received, err := s.readingRepo.GetDeliveredFingerprintsSince(ctx, template.UserID, now.Add(-deliveredFingerprintWindow))
+	if err != nil {
+		log.Printf("WARN (Scheduler): Failed to get delivered fingerprints for user %s: %v", template.UserID, err)
+	}
+	readings = collapseDuplicates(readings, received, s.NearDuplicateThreshold)
 	if len(readings) == 0 {
 		return false
 	}
 
-	// 5. Get the user's default delivery destination
-	defaultDest, err := s.destinationRepo.GetDefaultDestinationByUserID(ctx, template.UserID)
+	// 7. Get the destinations to deliver to
+	destinationIDs, err := s.editionTemplateDestinationRepo.GetDeliveryDestinationIDs(ctx, template.ID, template.UserID)
 	if err != nil {
-		log.Printf("ERROR (Scheduler): Failed to get default destination for user %s: %v", template.UserID, err)
+		log.Printf("ERROR (Scheduler): Failed to get destinations for template %s: %v", template.ID, err)
 		return false
 	}
-	if defaultDest == nil {
-		log.Printf("WARN (Scheduler): No default destination for user %s, skipping template %s", template.UserID, template.ID)
+	if len(destinationIDs) == 0 {
+		log.Printf("WARN (Scheduler): No destinations for template %s and no default destination for user %s, skipping", template.ID, template.UserID)
 		return false
 	}
 
-	// 6. Create a new edition
+	// 8. Create a new edition
 	editionName := fmt.Sprintf("%s - %s", template.Name, now.Format("Jan 2, 2006"))
 	edition := models.Edition{
 		ID:                uuid.NewString(),
@@ -156,7 +209,7 @@ func (s *Scheduler) processTemplate(ctx context.Context, template *models.Editio
 		return false
 	}
 
-	// 7. Add all readings to the edition
+	// 9. Add all readings to the edition
 	for _, reading := range readings {
 		if err := s.editionRepo.AddReadingToEdition(ctx, edition.ID, reading.ID); err != nil {
 			log.Printf("ERROR (Scheduler): Failed to add reading %s to edition %s: %v", reading.ID, edition.ID, err)
@@ -166,24 +219,80 @@ func (s *Scheduler) processTemplate(ctx context.Context, template *models.Editio
 	log.Printf("INFO (Scheduler): Created edition %s (%s) with %d readings for user %s",
 		edition.ID, editionName, len(readings), template.UserID)
 
-	// 8. Generate the ebook
-	generatedDelivery, err := s.editionProcessor.ProcessAndGenerateEdition(ctx, edition.ID, template.Format, defaultDest.ID, template.ColorImages)
+	// 10. Generate the ebook, with a pending delivery per destination
+	generatedDeliveries, err := s.editionProcessor.ProcessAndGenerateEdition(ctx, edition.ID, template.Format, destinationIDs, template.ColorImages)
 	if err != nil {
 		log.Printf("ERROR (Scheduler): Failed to generate ebook for edition %s: %v", edition.ID, err)
 		return false
 	}
 
-	// 9. Execute delivery
-	if err := s.deliveryService.ExecuteDelivery(ctx, generatedDelivery); err != nil {
-		log.Printf("ERROR (Scheduler): Delivery failed for edition %s: %v", edition.ID, err)
+	// 11. Execute each delivery. A failed delivery is left for the retrier and
+	// doesn't hold back the others.
+	delivered := 0
+	for i := range generatedDeliveries {
+		if err := s.deliveryService.ExecuteDelivery(ctx, &generatedDeliveries[i]); err != nil {
+			log.Printf("ERROR (Scheduler): Delivery %s of edition %s to destination %s failed: %v",
+				generatedDeliveries[i].ID, edition.ID, generatedDeliveries[i].DeliveryDestinationID, err)
+			continue
+		}
+		delivered++
+	}
+	if delivered == 0 {
 		return false
 	}
 
-	log.Printf("INFO (Scheduler): Successfully delivered edition %s (%s) to user %s",
-		edition.ID, editionName, template.UserID)
+	log.Printf("INFO (Scheduler): Successfully delivered edition %s (%s) to %d of %d destinations for user %s",
+		edition.ID, editionName, delivered, len(generatedDeliveries), template.UserID)
 	return true
 }
 
+// collapseDuplicates keeps one reading per group of near-duplicates, in the
+// order readings were received, and drops readings the user has already
+// received. A reading linked as a duplicate belongs to the group of its
+// original, whether or not the original itself is in readings. A reading
+// counts as received when its group matches a delivered reading's, or when
+// its fingerprint is at least threshold alike to a delivered one.
+func collapseDuplicates(readings []models.Reading, delivered []datastore.ReadingFingerprint, threshold float64) []models.Reading {
+	seen := make(map[string]bool, len(readings)+2*len(delivered))
+	for _, fp := range delivered {
+		seen[fp.ID] = true
+		if fp.DuplicateOf != nil {
+			seen[*fp.DuplicateOf] = true
+		}
+	}
+
+	collapsed := make([]models.Reading, 0, len(readings))
+	for _, reading := range readings {
+		group := reading.ID
+		if reading.DuplicateOf != nil {
+			group = *reading.DuplicateOf
+		}
+		if seen[group] || isDeliveredNearDuplicate(reading, delivered, threshold) {
+			continue
+		}
+		seen[group] = true
+		collapsed = append(collapsed, reading)
+	}
+	if dropped := len(readings) - len(collapsed); dropped > 0 {
+		log.Printf("INFO (Scheduler): Collapsed %d near-duplicate readings", dropped)
+	}
+	return collapsed
+}
+
+// Reports whether the reading's fingerprint is at least threshold alike to
+// that of a delivered reading.
+func isDeliveredNearDuplicate(reading models.Reading, delivered []datastore.ReadingFingerprint, threshold float64) bool {
+	if reading.SimHash == nil || threshold <= 0 {
+		return false
+	}
+	for _, fp := range delivered {
+		if ingestion.SimHashSimilarity(*reading.SimHash, fp.SimHash) >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
 // isDue determines whether a template should fire based on its interval,
 // delivery time, and when it last ran.
 func isDue(interval string, deliveryTime string, since time.Time, now time.Time) bool {
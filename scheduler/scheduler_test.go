@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreybb/logos/datastore"
+	"github.com/coreybb/logos/models"
+)
+
+func TestCollapseDuplicates(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	hash := func(h int64) *int64 { return &h }
+	const fingerprint int64 = 0x5a5a5a5a5a5a5a5a
+	const unrelated = ^fingerprint
+
+	tests := []struct {
+		name      string
+		readings  []models.Reading
+		delivered []datastore.ReadingFingerprint
+		threshold float64
+		want      []string
+	}{
+		{
+			name: "duplicates within the batch keep the first received",
+			readings: []models.Reading{
+				{ID: "a"},
+				{ID: "b", DuplicateOf: ptr("a")},
+				{ID: "c", DuplicateOf: ptr("x")},
+				{ID: "d", DuplicateOf: ptr("x")},
+			},
+			threshold: 0.9,
+			want:      []string{"a", "c"},
+		},
+		{
+			name: "duplicate of a delivered original",
+			readings: []models.Reading{
+				{ID: "b", DuplicateOf: ptr("a")},
+				{ID: "c"},
+			},
+			delivered: []datastore.ReadingFingerprint{{ID: "a", SimHash: unrelated}},
+			threshold: 0.9,
+			want:      []string{"c"},
+		},
+		{
+			name: "duplicate of the same original as a delivered duplicate",
+			readings: []models.Reading{
+				{ID: "c", DuplicateOf: ptr("a")},
+			},
+			delivered: []datastore.ReadingFingerprint{{ID: "b", SimHash: unrelated, DuplicateOf: ptr("a")}},
+			threshold: 0.9,
+			want:      []string{},
+		},
+		{
+			name: "unlinked reading similar to a delivered one",
+			readings: []models.Reading{
+				{ID: "b", SimHash: hash(fingerprint ^ 0b11)},
+				{ID: "c", SimHash: hash(unrelated)},
+			},
+			delivered: []datastore.ReadingFingerprint{{ID: "a", SimHash: fingerprint}},
+			threshold: 0.9,
+			want:      []string{"c"},
+		},
+		{
+			name: "similarity check disabled",
+			readings: []models.Reading{
+				{ID: "b", SimHash: hash(fingerprint)},
+			},
+			delivered: []datastore.ReadingFingerprint{{ID: "a", SimHash: fingerprint}},
+			threshold: 0,
+			want:      []string{"b"},
+		},
+		{
+			name: "no delivered fingerprints",
+			readings: []models.Reading{
+				{ID: "a", SimHash: hash(fingerprint)},
+				{ID: "b", SimHash: hash(fingerprint)},
+			},
+			threshold: 0.9,
+			want:      []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collapsed := collapseDuplicates(tt.readings, tt.delivered, tt.threshold)
+			got := make([]string, 0, len(collapsed))
+			for _, reading := range collapsed {
+				got = append(got, reading.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collapseDuplicates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
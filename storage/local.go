@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Content types of file extensions that the mime package may not know.
+var localContentTypes = map[string]string{
+	".epub": "application/epub+zip",
+	".mobi": "application/x-mobipocket-ebook",
+	".md":   "text/markdown; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+}
+
+// LocalStore keeps objects as files under a root directory, one file per key.
+// Content types aren't recorded; they are inferred from the key's extension
+// or, failing that, from the content.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a LocalStore rooted at dir, creating dir if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, errors.New("local blob store directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob store directory %s: %w", dir, err)
+	}
+	return &LocalStore{root: dir}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory for blob %s: %w", key, err)
+	}
+
+	// Write to a temporary file and rename it into place, so that readers
+	// never see a partly written object.
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob %s: %w", key, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (*Object, error) {
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, s.wrapError(key, err)
+	}
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, s.wrapError(key, err)
+	}
+	return &Object{ObjectInfo: s.info(key, stat, data), Data: data}, nil
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ObjectInfo{}, s.wrapError(key, err)
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return ObjectInfo{}, s.wrapError(key, err)
+	}
+
+	// The first 512 bytes are all that content sniffing looks at.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return ObjectInfo{}, s.wrapError(key, err)
+	}
+	return s.info(key, stat, head[:n]), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) filePath(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStore) info(key string, stat fs.FileInfo, head []byte) ObjectInfo {
+	ext := path.Ext(key)
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = localContentTypes[ext]
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+	return ObjectInfo{
+		Key:         key,
+		Size:        stat.Size(),
+		ContentType: contentType,
+		ModTime:     stat.ModTime().UTC(),
+		ETag:        fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()),
+	}
+}
+
+func (s *LocalStore) wrapError(key string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return fmt.Errorf("failed to read blob %s: %w", key, err)
+}
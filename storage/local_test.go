@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	ctx := context.Background()
+	const key = "editions/user/edition/file.epub"
+	data := []byte("ebook bytes")
+
+	if err := store.Put(ctx, key, data, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if onDisk, err := os.ReadFile(filepath.Join(root, "editions", "user", "edition", "file.epub")); err != nil || string(onDisk) != string(data) {
+		t.Fatalf("file on disk = %q, %v", onDisk, err)
+	}
+
+	obj, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(obj.Data) != string(data) || obj.Size != int64(len(data)) || obj.ContentType != "application/epub+zip" || obj.ETag == "" {
+		t.Errorf("Get = %+v", obj.ObjectInfo)
+	}
+
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info != obj.ObjectInfo {
+		t.Errorf("Stat = %+v, want %+v", info, obj.ObjectInfo)
+	}
+
+	// Put replaces the object and leaves no temporary files behind.
+	if err := store.Put(ctx, key, []byte("v2"), ""); err != nil {
+		t.Fatalf("second Put: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "editions", "user", "edition"))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries after replacing, want 1", len(entries))
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+}
+
+func TestLocalStoreSniffsUnknownExtensions(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "readings/u/r/images/abc123", []byte("\x89PNG\r\n\x1a\n rest"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	info, err := store.Stat(ctx, "readings/u/r/images/abc123")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.ContentType != "image/png" {
+		t.Errorf("ContentType = %q, want the sniffed image/png", info.ContentType)
+	}
+}
+
+func TestLocalStoreRejectsKeysOutsideRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "blobs")
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"", "..", "../escape", "a/../../escape", "/etc/passwd", `a\b`, "a//b", "a/./b", "a/"} {
+		if err := store.Put(ctx, key, []byte("x"), ""); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key error", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) error = %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded, want an invalid key error", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape")); err == nil {
+		t.Error("a key escaped the store's root")
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreybb/logos/models"
+)
+
+// The database only records where reading content, images and originals are
+// in the blob store. These load them for the readings that need them. Rows
+// stored before content moved to the blob store come back from the database
+// with their payload and are left as they are.
+
+// LoadReadingBody sets reading.ContentBody from the object at its StoragePath.
+func LoadReadingBody(ctx context.Context, blobs BlobStore, reading *models.Reading) error {
+	if reading.ContentBody != "" {
+		return nil
+	}
+	object, err := blobs.Get(ctx, reading.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to load content of reading %s: %w", reading.ID, err)
+	}
+	reading.ContentBody = string(object.Data)
+	return nil
+}
+
+// LoadReadingImages sets the Data of each image from the object at its
+// StorageKey. Images that fail to load are left without data, and the first
+// error is returned.
+func LoadReadingImages(ctx context.Context, blobs BlobStore, images []models.ReadingImage) error {
+	var firstErr error
+	for i := range images {
+		image := &images[i]
+		if image.Data != nil || image.StorageKey == "" {
+			continue
+		}
+		object, err := blobs.Get(ctx, image.StorageKey)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to load image %s of reading %s: %w", image.ContentHash, image.ReadingID, err)
+			}
+			continue
+		}
+		image.Data = object.Data
+	}
+	return firstErr
+}
+
+// LoadReadingOriginal sets original.Data from the object at its StorageKey.
+func LoadReadingOriginal(ctx context.Context, blobs BlobStore, original *models.ReadingOriginal) error {
+	if original.Data != nil || original.StorageKey == "" {
+		return nil
+	}
+	object, err := blobs.Get(ctx, original.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to load original file of reading %s: %w", original.ReadingID, err)
+	}
+	original.Data = object.Data
+	return nil
+}
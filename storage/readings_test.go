@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coreybb/logos/models"
+)
+
+func TestLoadReadingFiles(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	ctx := context.Background()
+	put := func(key, data string) {
+		t.Helper()
+		if err := store.Put(ctx, key, []byte(data), ""); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	bodyKey := ReadingKey("user", "reading", models.ReadingFormatHTML)
+	imageKey := ReadingImageKey("user", "reading", "hash")
+	originalKey := ReadingOriginalKey("user", "reading", models.ReadingFormatPDF)
+	put(bodyKey, "<p>from the blob store</p>")
+	put(imageKey, "image bytes")
+	put(originalKey, "%PDF-1.7")
+
+	t.Run("body", func(t *testing.T) {
+		reading := models.Reading{ID: "reading", StoragePath: bodyKey}
+		if err := LoadReadingBody(ctx, store, &reading); err != nil {
+			t.Fatalf("LoadReadingBody: %v", err)
+		}
+		if reading.ContentBody != "<p>from the blob store</p>" {
+			t.Errorf("ContentBody = %q", reading.ContentBody)
+		}
+
+		// Bodies that came from the database are kept.
+		legacy := models.Reading{ID: "legacy", StoragePath: bodyKey, ContentBody: "<p>from the database</p>"}
+		if err := LoadReadingBody(ctx, store, &legacy); err != nil || legacy.ContentBody != "<p>from the database</p>" {
+			t.Errorf("LoadReadingBody on a database body = %q, %v", legacy.ContentBody, err)
+		}
+
+		missing := models.Reading{ID: "missing", StoragePath: ReadingKey("user", "missing", models.ReadingFormatHTML)}
+		if err := LoadReadingBody(ctx, store, &missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LoadReadingBody on a missing object error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("images", func(t *testing.T) {
+		images := []models.ReadingImage{
+			{ReadingID: "reading", ContentHash: "missing", StorageKey: ReadingImageKey("user", "reading", "missing")},
+			{ReadingID: "reading", ContentHash: "hash", StorageKey: imageKey},
+			{ReadingID: "legacy", ContentHash: "legacy", Data: []byte("database bytes")},
+		}
+		if err := LoadReadingImages(ctx, store, images); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LoadReadingImages error = %v, want ErrNotFound for the missing image", err)
+		}
+		if images[0].Data != nil {
+			t.Errorf("missing image has data %q", images[0].Data)
+		}
+		if string(images[1].Data) != "image bytes" {
+			t.Errorf("image data = %q, want the stored object", images[1].Data)
+		}
+		if string(images[2].Data) != "database bytes" {
+			t.Errorf("database image data = %q, want it kept", images[2].Data)
+		}
+	})
+
+	t.Run("original", func(t *testing.T) {
+		original := models.ReadingOriginal{ReadingID: "reading", StorageKey: originalKey}
+		if err := LoadReadingOriginal(ctx, store, &original); err != nil {
+			t.Fatalf("LoadReadingOriginal: %v", err)
+		}
+		if string(original.Data) != "%PDF-1.7" {
+			t.Errorf("original data = %q", original.Data)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+// Package storage keeps files (reading content, images and generated
+// editions) in a blob store addressed by slash-separated object keys.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/coreybb/logos/models"
+)
+
+// ErrNotFound is returned when no object exists under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore is the adapter interface for object storage.
+// Implement this to add new storage backends (GCS, Azure Blob, etc.).
+type BlobStore interface {
+	// Put stores data under key, replacing any existing object.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get retrieves the object stored under key.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Stat retrieves the metadata of the object stored under key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete removes the object stored under key. Deleting a missing object
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string // Quoted, as in an HTTP ETag header
+}
+
+// Object is a stored object with its content.
+type Object struct {
+	ObjectInfo
+	Data []byte
+}
+
+// Backend selects the BlobStore implementation.
+type Backend string
+
+const (
+	BackendLocal Backend = "local" // Files under a local directory
+	BackendS3    Backend = "s3"    // An S3-compatible service, such as AWS S3 or MinIO
+)
+
+// ParseBackend returns the backend with the given name.
+func ParseBackend(name string) (Backend, bool) {
+	switch backend := Backend(strings.ToLower(strings.TrimSpace(name))); backend {
+	case BackendLocal, BackendS3:
+		return backend, true
+	}
+	return "", false
+}
+
+// Config selects and configures a BlobStore.
+type Config struct {
+	Backend  Backend
+	LocalDir string // Root directory for BackendLocal
+	S3       S3Config
+}
+
+// New creates the BlobStore described by cfg.
+func New(cfg Config) (BlobStore, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		return NewLocalStore(cfg.LocalDir)
+	case BackendS3:
+		return NewS3Store(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob store backend %q", cfg.Backend)
+	}
+}
+
+// ReadingKey returns the key of a reading's content.
+func ReadingKey(userID, readingID string, format models.ReadingFormat) string {
+	return fmt.Sprintf("readings/%s/%s.%s", userID, readingID, format)
+}
+
+// ReadingImageKey returns the key of an image stored with a reading.
+func ReadingImageKey(userID, readingID, imageHash string) string {
+	return fmt.Sprintf("readings/%s/%s/images/%s", userID, readingID, imageHash)
+}
+
+// ReadingOriginalKey returns the key of the file a reading was converted from.
+func ReadingOriginalKey(userID, readingID string, format models.ReadingFormat) string {
+	return fmt.Sprintf("readings/%s/%s/original.%s", userID, readingID, format)
+}
+
+// EditionFileKey returns the key of a generated edition file. Each generation
+// gets its own file ID, so earlier files are kept.
+func EditionFileKey(userID, editionID, fileID string, format models.EditionFormat) string {
+	return fmt.Sprintf("editions/%s/%s/%s.%s", userID, editionID, fileID, format)
+}
+
+// EditionCoverKey returns the key of an edition's cover image at the given
+// size in pixels.
+func EditionCoverKey(userID, editionID string, width, height int) string {
+	return fmt.Sprintf("editions/%s/%s/cover-%dx%d.jpg", userID, editionID, width, height)
+}
+
+// Checks that a key is a clean relative path, so that it can't escape the
+// store's root or bucket.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
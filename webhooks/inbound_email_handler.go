@@ -1,15 +1,23 @@
 package webhooks
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/coreybb/logos/conversion"
 	"github.com/coreybb/logos/datastore"
 	"github.com/coreybb/logos/ingestion"
+	"github.com/coreybb/logos/models"
+	"github.com/coreybb/logos/storage"
 	"github.com/coreybb/logos/webutil"
+	"github.com/google/uuid"
 	"github.com/jhillyerd/enmime"
 )
 
@@ -20,28 +28,45 @@ const (
 	formFieldTo      = "to"
 	formFieldFrom    = "from"
 	formFieldSubject = "subject"
+
+	// maxInboundBodyBytes caps the webhook body read for verification.
+	// SendGrid limits inbound messages, including attachments, to 30MB.
+	maxInboundBodyBytes = 40 << 20
 )
 
 type InboundEmailHandler struct {
 	Orchestrator      *ingestion.IngestionOrchestrator
 	AllowedSenderRepo *datastore.AllowedSenderRepository
+	UserRepo          *datastore.UserRepository
+	HeldEmailRepo     *datastore.HeldEmailRepository
+	JobRepo           *datastore.IngestionJobRepository
+	Workers           *ingestion.WorkerPool // Optional; notified of new jobs
+	Verifiers         []InboundVerifier
 }
 
-func NewInboundEmailHandler(readingRepo *datastore.ReadingRepository, sourceRepo *datastore.SourceRepository, allowedSenderRepo *datastore.AllowedSenderRepository) *InboundEmailHandler {
+// NewInboundEmailHandler creates the inbound webhook handler. Every verifier
+// must accept a request before its payload is parsed; with none configured,
+// every request is rejected.
+func NewInboundEmailHandler(
+	readingRepo *datastore.ReadingRepository,
+	sourceRepo *datastore.SourceRepository,
+	allowedSenderRepo *datastore.AllowedSenderRepository,
+	userRepo *datastore.UserRepository,
+	heldEmailRepo *datastore.HeldEmailRepository,
+	jobRepo *datastore.IngestionJobRepository,
+	rawEmailRepo *datastore.RawEmailRepository,
+	blobs storage.BlobStore,
+	converter *conversion.Converter,
+	verifiers ...InboundVerifier,
+) *InboundEmailHandler {
 	contentProc := ingestion.NewContentProcessor()
-	converterInst, errConv := conversion.NewConverter()
-	if errConv != nil {
-		// NewConverter currently logs a warning and returns (converter, nil) even if pandoc is not found.
-		// If it were to return a critical error, this handler should probably propagate it.
-		log.Printf("WARN: Error initializing converter (this is unexpected as NewConverter usually doesn't error out): %v", errConv)
-		// Depending on future policy, might panic or return an error from NewInboundEmailHandler
-	}
-
-	pipelineService := ingestion.NewContentPipelineService(converterInst, contentProc)
+	pipelineService := ingestion.NewContentPipelineService(converter, contentProc)
 	readingBuild := ingestion.NewReadingBuilder(sourceRepo)
 	orch := ingestion.NewIngestionOrchestrator(
 		readingRepo,
 		sourceRepo,
+		rawEmailRepo,
+		blobs,
 		pipelineService,
 		readingBuild,
 	)
@@ -49,12 +74,25 @@ func NewInboundEmailHandler(readingRepo *datastore.ReadingRepository, sourceRepo
 	return &InboundEmailHandler{
 		Orchestrator:      orch,
 		AllowedSenderRepo: allowedSenderRepo,
+		UserRepo:          userRepo,
+		HeldEmailRepo:     heldEmailRepo,
+		JobRepo:           jobRepo,
+		Verifiers:         verifiers,
 	}
 }
 
+// HandleInbound verifies the webhook, stores the email as an ingestion job
+// and acknowledges it. Parsing, the sender policy and ingestion itself run
+// later in ProcessJob, outside the request.
 func (h *InboundEmailHandler) HandleInbound(w http.ResponseWriter, r *http.Request) {
 	log.Printf("InboundEmailHandler: HandleInbound called. Method: %s, Path: %s, Content-Type: %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
 
+	if err := h.verifyRequest(w, r); err != nil {
+		log.Printf("WARN (InboundEmailHandler): Rejected unverified webhook from %s: %v", r.RemoteAddr, err)
+		webutil.RespondWithError(w, http.StatusUnauthorized, "Webhook verification failed")
+		return
+	}
+
 	webhookData, err := parseWebhookRequest(r)
 	if err != nil {
 		if !webutil.HasResponseWriterSentHeader(w) {
@@ -64,85 +102,208 @@ func (h *InboundEmailHandler) HandleInbound(w http.ResponseWriter, r *http.Reque
 	}
 
 	userID, err := extractUserIDFromRecipient(webhookData.Recipient)
+	if err == nil {
+		_, err = uuid.Parse(userID)
+	}
 	if err != nil {
 		handleProcessingError(w, fmt.Sprintf("Could not extract UserID from recipient '%s'", webhookData.Recipient), err, true)
 		return
 	}
+	if _, err := h.UserRepo.GetUserByID(r.Context(), userID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			handleProcessingError(w, fmt.Sprintf("Unknown UserID '%s' in recipient", userID), err, true)
+			return
+		}
+		// Let SendGrid retry rather than drop the email.
+		handleProcessingError(w, fmt.Sprintf("Failed to look up UserID %s", userID), err, false)
+		return
+	}
 
-	// Parse env first as it's needed for reliable sender extraction and Message-ID
-	env, err := parseMimeMessage(webhookData.RawMIME)
-	if err != nil {
-		handleProcessingError(w, "Failed to parse raw MIME with enmime", err, true)
+	now := time.Now().UTC()
+	job := models.IngestionJob{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Sender:    webhookData.Sender,
+		Subject:   webhookData.Subject,
+		RawMIME:   webhookData.RawMIME,
+		Status:    models.IngestionJobStatusPending,
+		RunAfter:  now,
+	}
+	if err := h.JobRepo.CreateIngestionJob(r.Context(), &job); err != nil {
+		handleProcessingError(w, fmt.Sprintf("Failed to enqueue email for UserID %s", userID), err, false)
 		return
 	}
+	log.Printf("INFO: Queued email for UserID: %s, Subject: '%s', IngestionJobID: %s", userID, webhookData.Subject, job.ID)
+	if h.Workers != nil {
+		h.Workers.Notify()
+	}
+
+	w.Header().Set(webutil.HeaderContentType, webutil.ContentTypeTextPlainUTF8)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK (Email queued)"))
+}
+
+// ProcessJob resolves a queued email's sender, applies the user's ingestion
+// policy and ingests or holds the email. Failures that retrying can't fix
+// wrap ingestion.ErrJobNotRetryable.
+func (h *InboundEmailHandler) ProcessJob(ctx context.Context, job *models.IngestionJob) error {
+	env, err := parseMimeMessage(job.RawMIME)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ingestion.ErrJobNotRetryable, err)
+	}
 	// Get Message-ID early for logging, even if sender extraction fails
 	messageIDFromMIME := env.GetHeader("Message-ID")
 
-	var actualSenderEmail string
+	actualSenderEmail := resolveSenderEmail(env, job.Sender)
+	if actualSenderEmail == "" {
+		return fmt.Errorf("%w: could not determine actual sender email from parsed headers or raw input. Raw Sender Field: '%s', Message-ID: '%s'",
+			ingestion.ErrJobNotRetryable, job.Sender, messageIDFromMIME)
+	}
 
+	holdStatus, holdReason, err := h.evaluateSenderPolicy(ctx, job.UserID, actualSenderEmail)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fmt.Errorf("%w: unknown UserID '%s': %v", ingestion.ErrJobNotRetryable, job.UserID, err)
+		}
+		// Fail closed: retry rather than ingest without a policy decision.
+		return fmt.Errorf("failed to evaluate ingestion policy for UserID %s: %w", job.UserID, err)
+	}
+	if holdStatus != "" {
+		return h.holdEmail(ctx, job, actualSenderEmail, messageIDFromMIME, holdStatus, holdReason)
+	}
+
+	log.Printf("INFO: Processing email for UserID: %s, Sender: %s, Subject: '%s', Message-ID: '%s'",
+		job.UserID, actualSenderEmail, job.Subject, messageIDFromMIME)
+
+	err = h.Orchestrator.ProcessInboundEmail(ctx, job.UserID, actualSenderEmail, job.Subject, env, messageIDFromMIME, job.RawMIME)
+	if err != nil {
+		return fmt.Errorf("ingestion failed for UserID %s (Message-ID: %s): %w", job.UserID, messageIDFromMIME, err)
+	}
+
+	logAttachments(job.UserID, messageIDFromMIME, env)
+	return nil
+}
+
+// resolveSenderEmail returns the sender's address from the Sender or From
+// header, falling back to the webhook's from field. Returns "" if none of
+// them holds an address.
+func resolveSenderEmail(env *enmime.Envelope, rawSender string) string {
 	// Try "Sender" header first using AddressList
 	senderList, errSender := env.AddressList("Sender")
 	if errSender == nil && len(senderList) > 0 && senderList[0].Address != "" {
-		actualSenderEmail = strings.ToLower(senderList[0].Address)
+		return strings.ToLower(senderList[0].Address)
 	}
 
 	// If not found in "Sender", try "From" header using AddressList
-	if actualSenderEmail == "" {
-		fromList, errFrom := env.AddressList("From")
-		if errFrom == nil && len(fromList) > 0 && fromList[0].Address != "" {
-			actualSenderEmail = strings.ToLower(fromList[0].Address)
-		}
+	fromList, errFrom := env.AddressList("From")
+	if errFrom == nil && len(fromList) > 0 && fromList[0].Address != "" {
+		return strings.ToLower(fromList[0].Address)
 	}
 
-	// Fallback to raw webhookData.Sender if enmime parsing didn't yield an address
-	if actualSenderEmail == "" {
-		rawSenderInput := strings.TrimSpace(webhookData.Sender)
-		if rawSenderInput != "" {
-			// Simple extraction from "Name <email@example.com>" format
-			if strings.Contains(rawSenderInput, "<") && strings.Contains(rawSenderInput, ">") {
-				start := strings.LastIndex(rawSenderInput, "<")
-				end := strings.LastIndex(rawSenderInput, ">")
-				if start != -1 && end != -1 && start < end {
-					extracted := strings.TrimSpace(rawSenderInput[start+1 : end])
-					if extracted != "" { // Ensure something was actually extracted
-						actualSenderEmail = strings.ToLower(extracted)
-					}
-				}
-			}
-			// If parsing "Name <email>" failed or wasn't the format, and still no email,
-			// use the rawSenderInput directly if it looks somewhat like an email (contains @).
-			// This is a very basic check.
-			if actualSenderEmail == "" && strings.Contains(rawSenderInput, "@") {
-				actualSenderEmail = strings.ToLower(rawSenderInput)
+	// Fallback to the raw webhook sender if enmime parsing didn't yield an address
+	rawSenderInput := strings.TrimSpace(rawSender)
+	if rawSenderInput == "" {
+		return ""
+	}
+	// Simple extraction from "Name <email@example.com>" format
+	if strings.Contains(rawSenderInput, "<") && strings.Contains(rawSenderInput, ">") {
+		start := strings.LastIndex(rawSenderInput, "<")
+		end := strings.LastIndex(rawSenderInput, ">")
+		if start != -1 && end != -1 && start < end {
+			extracted := strings.TrimSpace(rawSenderInput[start+1 : end])
+			if extracted != "" { // Ensure something was actually extracted
+				return strings.ToLower(extracted)
 			}
 		}
 	}
-
-	if actualSenderEmail == "" {
-		errMsg := fmt.Sprintf("Could not determine actual sender email from parsed headers or raw input. Raw Sender Field: '%s', Message-ID: '%s'",
-			webhookData.Sender, messageIDFromMIME)
-		handleProcessingError(w, errMsg, nil, true)
-		return
+	// If parsing "Name <email>" failed or wasn't the format, use the raw input
+	// directly if it looks somewhat like an email (contains @).
+	// This is a very basic check.
+	if strings.Contains(rawSenderInput, "@") {
+		return strings.ToLower(rawSenderInput)
 	}
+	return ""
+}
 
-	log.Printf("INFO: Processing email for UserID: %s, Sender: %s, Subject: '%s', Message-ID: '%s'",
-		userID, actualSenderEmail, webhookData.Subject, messageIDFromMIME)
+// verifyRequest runs every configured verifier against the raw request body,
+// then restores the body so the multipart form can be parsed as usual.
+func (h *InboundEmailHandler) verifyRequest(w http.ResponseWriter, r *http.Request) error {
+	if len(h.Verifiers) == 0 {
+		return errors.New("no inbound webhook verification is configured")
+	}
 
-	err = h.Orchestrator.ProcessInboundEmail(w, r.Context(), userID, actualSenderEmail, webhookData.Subject, env, messageIDFromMIME)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboundBodyBytes))
 	if err != nil {
-		log.Printf("ERROR (HandleInbound): Error from IngestionOrchestrator for UserID %s (Message-ID: %s): %v", userID, messageIDFromMIME, err)
-		if !webutil.HasResponseWriterSentHeader(w) {
-			webutil.RespondWithError(w, http.StatusInternalServerError, "Internal server error processing email")
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	for _, verifier := range h.Verifiers {
+		if err := verifier.Verify(r, body); err != nil {
+			return err
 		}
-		return
 	}
+	return nil
+}
 
-	logAttachments(userID, messageIDFromMIME, env)
+// evaluateSenderPolicy applies the user's ingestion policy to a resolved sender.
+// It returns an empty status if the email should be ingested, otherwise the
+// status the email should be held under and the reason for holding it.
+func (h *InboundEmailHandler) evaluateSenderPolicy(ctx context.Context, userID, senderEmail string) (models.HeldEmailStatus, string, error) {
+	policy, err := h.UserRepo.GetIngestionPolicy(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if policy == models.IngestionPolicyOpen {
+		return "", "", nil
+	}
 
-	if !webutil.HasResponseWriterSentHeader(w) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("OK (Email processed successfully)"))
+	allowed, err := h.AllowedSenderRepo.IsAllowedSender(ctx, userID, senderEmail)
+	if err != nil {
+		return "", "", err
+	}
+	if allowed {
+		return "", "", nil
 	}
+
+	reason := fmt.Sprintf("sender %s does not match any allowed sender pattern (policy: %s)", senderEmail, policy)
+	switch policy {
+	case models.IngestionPolicyAllowlist:
+		return models.HeldEmailStatusRejected, reason, nil
+	case models.IngestionPolicyQuarantine:
+		return models.HeldEmailStatusQuarantined, reason, nil
+	default:
+		return "", "", fmt.Errorf("unknown ingestion policy %q for user %s", policy, userID)
+	}
+}
+
+// holdEmail records an email that failed the sender policy.
+func (h *InboundEmailHandler) holdEmail(
+	ctx context.Context, job *models.IngestionJob,
+	senderEmail, messageIDFromMIME string,
+	status models.HeldEmailStatus, reason string,
+) error {
+	held := models.HeldEmail{
+		ID:          uuid.NewString(),
+		UserID:      job.UserID,
+		CreatedAt:   time.Now().UTC(),
+		SenderEmail: senderEmail,
+		Subject:     job.Subject,
+		MessageID:   messageIDFromMIME,
+		Reason:      reason,
+		Status:      status,
+		RawMIME:     job.RawMIME,
+	}
+	if err := h.HeldEmailRepo.CreateHeldEmail(ctx, &held); err != nil {
+		return fmt.Errorf("failed to record %s email from %s for UserID %s: %w", status, senderEmail, job.UserID, err)
+	}
+
+	log.Printf("INFO: Email %s for UserID: %s, Sender: %s, Message-ID: '%s', HeldEmailID: %s. Reason: %s",
+		status, job.UserID, senderEmail, messageIDFromMIME, held.ID, reason)
+	return nil
 }
 
 type webhookInputData struct {
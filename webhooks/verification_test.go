@@ -0,0 +1,236 @@
+package webhooks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPayload = "--xYzZY\r\nContent-Disposition: form-data; name=\"to\"\r\n\r\nabc@parse.lakonic.dev\r\n--xYzZY--\r\n"
+
+// newTestSignatureVerifier generates a key pair and returns a verifier for
+// its public half, configured the way main.go does from the base64 key.
+func newTestSignatureVerifier(t *testing.T) (*ecdsa.PrivateKey, *SignatureVerifier) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	verifier, err := NewSignatureVerifier(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		t.Fatalf("NewSignatureVerifier: %v", err)
+	}
+	return key, verifier
+}
+
+func signedRequest(t *testing.T, key *ecdsa.PrivateKey, sentAt time.Time, body string) *http.Request {
+	t.Helper()
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	digest := sha256.Sum256([]byte(timestamp + body))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email", strings.NewReader(body))
+	r.Header.Set(sendGridTimestampHeader, timestamp)
+	r.Header.Set(sendGridSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+	return r
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	key, verifier := newTestSignatureVerifier(t)
+	_, otherVerifier := newTestSignatureVerifier(t)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		verifier *SignatureVerifier
+		request  func() *http.Request
+		body     string
+		wantErr  bool
+	}{
+		{
+			name:     "valid signature",
+			verifier: verifier,
+			request:  func() *http.Request { return signedRequest(t, key, now, testPayload) },
+			body:     testPayload,
+		},
+		{
+			name:     "body altered after signing",
+			verifier: verifier,
+			request:  func() *http.Request { return signedRequest(t, key, now, testPayload) },
+			body:     strings.Replace(testPayload, "abc", "xyz", 1),
+			wantErr:  true,
+		},
+		{
+			name:     "signed by another key",
+			verifier: otherVerifier,
+			request:  func() *http.Request { return signedRequest(t, key, now, testPayload) },
+			body:     testPayload,
+			wantErr:  true,
+		},
+		{
+			name:     "stale timestamp",
+			verifier: verifier,
+			request: func() *http.Request {
+				return signedRequest(t, key, now.Add(-signatureMaxAge-time.Minute), testPayload)
+			},
+			body:    testPayload,
+			wantErr: true,
+		},
+		{
+			name:     "timestamp in the future",
+			verifier: verifier,
+			request: func() *http.Request {
+				return signedRequest(t, key, now.Add(signatureMaxAge+time.Minute), testPayload)
+			},
+			body:    testPayload,
+			wantErr: true,
+		},
+		{
+			name:     "malformed signature",
+			verifier: verifier,
+			request: func() *http.Request {
+				r := signedRequest(t, key, now, testPayload)
+				r.Header.Set(sendGridSignatureHeader, "not base64!")
+				return r
+			},
+			body:    testPayload,
+			wantErr: true,
+		},
+		{
+			name:     "missing headers",
+			verifier: verifier,
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email", strings.NewReader(testPayload))
+			},
+			body:    testPayload,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.Verify(tt.request(), []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSignatureVerifierRejectsInvalidKey(t *testing.T) {
+	if _, err := NewSignatureVerifier("bm90IGEga2V5"); err == nil {
+		t.Error("NewSignatureVerifier accepted a key that is not PKIX DER")
+	}
+}
+
+func TestSharedSecretVerifier(t *testing.T) {
+	verifier := NewSharedSecretVerifier("s3cret")
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		wantErr bool
+	}{
+		{
+			name: "basic-auth password",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email", nil)
+				r.SetBasicAuth("sendgrid", "s3cret")
+				return r
+			},
+		},
+		{
+			name: "token query parameter",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email?token=s3cret", nil)
+			},
+		},
+		{
+			name: "wrong password",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email", nil)
+				r.SetBasicAuth("sendgrid", "guess")
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong password overrides a correct token",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email?token=s3cret", nil)
+				r.SetBasicAuth("sendgrid", "guess")
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "no secret presented",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email", nil)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.request(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleInboundRejectsWithoutVerifiers(t *testing.T) {
+	h := &InboundEmailHandler{}
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound-email?token=anything", strings.NewReader(testPayload))
+	w := httptest.NewRecorder()
+
+	h.HandleInbound(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestVerifyRequestRestoresBody(t *testing.T) {
+	key, verifier := newTestSignatureVerifier(t)
+	h := &InboundEmailHandler{Verifiers: []InboundVerifier{verifier, NewSharedSecretVerifier("s3cret")}}
+
+	r := signedRequest(t, key, time.Now(), testPayload)
+	r.SetBasicAuth("sendgrid", "s3cret")
+	if err := h.verifyRequest(httptest.NewRecorder(), r); err != nil {
+		t.Fatalf("verifyRequest: %v", err)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading body after verification: %v", err)
+	}
+	if string(body) != testPayload {
+		t.Errorf("body after verification = %q, want %q", body, testPayload)
+	}
+
+	// Every verifier must pass, not just one.
+	r = signedRequest(t, key, time.Now(), testPayload)
+	if err := h.verifyRequest(httptest.NewRecorder(), r); err == nil {
+		t.Error("verifyRequest accepted a request without the shared secret")
+	}
+}
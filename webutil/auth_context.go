@@ -0,0 +1,20 @@
+package webutil
+
+import "context"
+
+type contextKey string
+
+const authenticatedUserIDKey contextKey = "authenticatedUserID"
+
+// WithAuthenticatedUserID returns a copy of ctx carrying the ID of the user
+// the request was authenticated as.
+func WithAuthenticatedUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, authenticatedUserIDKey, userID)
+}
+
+// AuthenticatedUserID returns the user ID stored by WithAuthenticatedUserID.
+// The boolean is false for requests that did not pass authentication.
+func AuthenticatedUserID(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(authenticatedUserIDKey).(string)
+	return userID, ok && userID != ""
+}
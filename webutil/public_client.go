@@ -0,0 +1,65 @@
+package webutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// NewPublicClient returns an HTTP client that refuses to connect to loopback,
+// private, link-local and other non-public addresses, so user-supplied URLs
+// can't reach internal services or the cloud metadata server. The check runs on every
+// connection, including those made to follow redirects.
+func NewPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddr(addr) {
+				return fmt.Errorf("refusing to connect to non-public address %s", addr)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Unicast ranges that aren't private in the RFC 1918 sense but still reach
+// internal networks: carrier-grade NAT (often used inside cloud networks),
+// IETF protocol assignments, benchmarking, NAT64, which translates to IPv4
+// addresses that may be private, and IPv6 unique local addresses.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// IsPublicAddr reports whether addr is a globally routable unicast address.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}